@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const deletedUserLogin = "deleted-user"
+
+func anonymizeUserRevisions(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	revisionsCollection := databaseClient.Database("sardene-db").Collection("idea_revisions")
+
+	_, errInUpdating := revisionsCollection.UpdateMany(databaseContext, bson.M{"editor_id": userID},
+		bson.M{"$set": bson.M{"editor": deletedUserLogin, "editor_id": 0}})
+	return errInUpdating
+}
+
+// deleteUserAccount : Every step in mongo can be repeated safely and runs first, the stores then delete the user
+// with their gazes and ideas at once, so a failure midway leaves an account which can be deleted again
+func deleteUserAccount(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores) {
+	user := getAuthenticatedUser(ginContext)
+
+	ideasAction := ginContext.DefaultQuery("ideas", "anonymize")
+	if ideasAction != "anonymize" && ideasAction != "delete" {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Ideas should be either anonymize or delete"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	// Without mongo the stores hold everything there is of the user
+	var cascadeSteps []func(context.Context, *mongo.Client, int64) error
+	if databaseClient != nil {
+		cascadeSteps = []func(context.Context, *mongo.Client, int64) error{deleteUserBookmarks, deleteUserFollows,
+			deleteUserSubscriptions, deleteUserNotifications, deleteUserPreferences, deleteUserQuotas, deleteUserIdentities,
+			removeUserOrgMemberships, anonymizeUserRevisions, anonymizeUserIdeaUpdates, anonymizeUserAuditLog}
+	}
+	for _, cascadeStep := range cascadeSteps {
+		errInStep := cascadeStep(databaseContext, databaseClient, user.UserID)
+		if errInStep != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error while deleting account, please try again", "errorDetails": errInStep.Error()})
+			return
+		}
+	}
+	errInDeletingSessions := stores.Sessions.DeleteByUser(databaseContext, user.UserID)
+	if errInDeletingSessions != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while deleting account, please try again", "errorDetails": errInDeletingSessions.Error()})
+		return
+	}
+	// Activity events name the user by login only
+	errInAnonymizingActivity := anonymizeUserActivity(databaseContext, databaseClient, user.Login)
+	if errInAnonymizingActivity != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while deleting account, please try again", "errorDetails": errInAnonymizingActivity.Error()})
+		return
+	}
+
+	deletedIdeaIDs, errInDeletingUser := stores.Users.DeleteAccount(databaseContext, user.UserID, ideasAction == "delete", time.Now().Unix())
+	if errInDeletingUser != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while deleting account, please try again", "errorDetails": errInDeletingUser.Error()})
+		return
+	}
+	describeAuditedMutation(ginContext, auditActionAccountDeleted, "", nil, nil)
+
+	for _, deletedIdeaID := range deletedIdeaIDs {
+		errInDeletingDependents := deleteDependentsOfIdea(databaseContext, databaseClient, stores, deletedIdeaID)
+		if errInDeletingDependents != nil {
+			log.Println(errInDeletingDependents, "Failed to delete bookmarks, revisions and subscriptions of deleted idea", deletedIdeaID.Hex())
+		}
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Account deleted successfully"})
+}
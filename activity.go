@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	activityKindIdeaPublished = "idea.published"
+	activityKindIdeaLaunched  = "idea.launched"
+	activityKindGazeMilestone = "idea.gaze_milestone"
+)
+
+// Gaze counts worth telling everyone about, each is recorded once per idea
+var gazeMilestones = []int64{10, 50, 100, 250, 500, 1000, 5000}
+
+// ActivityEventStructure : Public event of the activity feed, in the events collection
+type ActivityEventStructure struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Kind       string             `json:"kind" bson:"kind"`
+	IdeaID     primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+	IdeaName   string             `json:"idea_name" bson:"idea_name"`
+	IdeaSlug   string             `json:"idea_slug" bson:"idea_slug"`
+	ActorLogin string             `json:"actor_login,omitempty" bson:"actor_login,omitempty"`
+	// Gaze count reached, only for milestones
+	Milestone int64 `json:"milestone,omitempty" bson:"milestone,omitempty"`
+	CreatedAt int64 `json:"created_at" bson:"created_at"`
+}
+
+// recordActivity : Only public ideas make it to the feed, callers log a failure and carry on.
+// There is no feed without mongo
+func recordActivity(databaseContext context.Context, databaseClient *mongo.Client, kind string, idea IdeaStructure, actorLogin string) error {
+	if databaseClient == nil || isIdeaPublic(idea) == false {
+		return nil
+	}
+	eventsCollection := databaseClient.Database("sardene-db").Collection("events")
+
+	_, errInAdding := eventsCollection.InsertOne(databaseContext, ActivityEventStructure{Kind: kind, IdeaID: idea.ID,
+		IdeaName: idea.Name, IdeaSlug: idea.Slug, ActorLogin: actorLogin, CreatedAt: time.Now().Unix()})
+	return errInAdding
+}
+
+// anonymizeUserActivity : Events stay in the feed but do not name the deleted user anymore
+func anonymizeUserActivity(databaseContext context.Context, databaseClient *mongo.Client, login string) error {
+	if databaseClient == nil || login == "" {
+		return nil
+	}
+	eventsCollection := databaseClient.Database("sardene-db").Collection("events")
+
+	_, errInUpdating := eventsCollection.UpdateMany(databaseContext, bson.M{"actor_login": login},
+		bson.M{"$set": bson.M{"actor_login": deletedUserLogin}})
+	return errInUpdating
+}
+
+// recordGazeMilestone : Gaze counts can fall back and pass a milestone again, the upsert keeps it to one event
+func recordGazeMilestone(databaseContext context.Context, databaseClient *mongo.Client, idea IdeaStructure, gazers int64) error {
+	isMilestone := false
+	for _, gazeMilestone := range gazeMilestones {
+		isMilestone = isMilestone || gazers == gazeMilestone
+	}
+	if databaseClient == nil || isMilestone == false || isIdeaPublic(idea) == false {
+		return nil
+	}
+	eventsCollection := databaseClient.Database("sardene-db").Collection("events")
+
+	milestoneFilter := bson.M{"kind": activityKindGazeMilestone, "idea_id": idea.ID, "milestone": gazers}
+	_, errInAdding := eventsCollection.UpdateOne(databaseContext, milestoneFilter, bson.M{"$setOnInsert": bson.M{
+		"idea_name": idea.Name, "idea_slug": idea.Slug, "created_at": time.Now().Unix()}},
+		options.Update().SetUpsert(true))
+	if isDuplicateKeyError(errInAdding) {
+		return nil
+	}
+	return errInAdding
+}
+
+// getActivity : Recent events, newest first. Events of ideas which are not public anymore are left out,
+// so a page can be shorter than the limit
+func getActivity(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores) {
+	pagination, errInPagination := getPaginationFromQuery(ginContext, 20, 100)
+	if errInPagination != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong pagination", "errorDetails": errInPagination.Error()})
+		return
+	}
+
+	eventsCollection := databaseClient.Database("sardene-db").Collection("events")
+	databaseContext := ginContext.Request.Context()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(pagination.Skip()).SetLimit(pagination.Limit)
+	eventsCursor, errInFinding := eventsCollection.Find(databaseContext, bson.M{}, findOptions)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	defer eventsCursor.Close(databaseContext)
+
+	events := []ActivityEventStructure{}
+	isPublicIdea := make(map[primitive.ObjectID]bool)
+	for eventsCursor.Next(databaseContext) {
+		var event ActivityEventStructure
+		errInDecoding := eventsCursor.Decode(&event)
+		if errInDecoding != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in decoding database", "errorDetails": errInDecoding.Error()})
+			return
+		}
+
+		isPublic, isChecked := isPublicIdea[event.IdeaID]
+		if isChecked == false {
+			idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, event.IdeaID)
+			if errInFindingIdea != nil && errInFindingIdea != errNotFoundInStore {
+				ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+					"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+				return
+			}
+			isPublic = errInFindingIdea == nil && isIdeaPublic(idea)
+			isPublicIdea[event.IdeaID] = isPublic
+		}
+		if isPublic == true {
+			events = append(events, event)
+		}
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": events, "count": len(events),
+		"page": pagination.Page, "limit": pagination.Limit})
+}
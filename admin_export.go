@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Rows between flushes of an export, so the client sees progress without a write per row
+const exportFlushEvery = 500
+
+// ExportedLikeStructure : Gaze as it is exported, the hashed address of the gazer stays in the database
+type ExportedLikeStructure struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserID    int64              `json:"userID" bson:"userID"`
+	IdeaID    primitive.ObjectID `json:"ideaID" bson:"ideaID"`
+	CreatedAt int64              `json:"created_at" bson:"created_at"`
+}
+
+// exportableCollection : How documents of a collection are decoded and laid out as CSV rows
+type exportableCollection struct {
+	collection  string
+	newDocument func() interface{}
+	csvHeader   []string
+	csvRow      func(document interface{}) []string
+}
+
+var exportableCollections = map[string]exportableCollection{
+	"ideas": {
+		collection:  "ideas",
+		newDocument: func() interface{} { return &IdeaStructure{} },
+		csvHeader: []string{"id", "name", "slug", "description", "publisher", "publisher_id", "status", "visibility",
+			"held_for_review", "featured", "gazers", "makers", "repo_url", "created_at", "updated_at"},
+		csvRow: func(document interface{}) []string {
+			idea := document.(*IdeaStructure)
+			return []string{idea.ID.Hex(), idea.Name, idea.Slug, idea.Description, idea.Publisher,
+				strconv.FormatInt(idea.PublisherID, 10), idea.Status, idea.Visibility, strconv.FormatBool(idea.HeldForReview),
+				strconv.FormatBool(idea.Featured), strconv.FormatInt(idea.Gazers, 10), strconv.FormatInt(idea.Makers, 10), idea.RepoURL,
+				strconv.FormatInt(idea.CreatedAt, 10), strconv.FormatInt(idea.UpdatedAt, 10)}
+		},
+	},
+	"users": {
+		collection:  "users",
+		newDocument: func() interface{} { return &UserStructure{} },
+		csvHeader: []string{"userID", "login", "name", "role", "display_name", "bio", "website", "location",
+			"banned", "suspended_until", "created_at"},
+		csvRow: func(document interface{}) []string {
+			user := document.(*UserStructure)
+			return []string{strconv.FormatInt(user.UserID, 10), user.Login, user.Name, user.Role, user.DisplayName, user.Bio,
+				user.Website, user.Location, strconv.FormatBool(user.Banned), strconv.FormatInt(user.SuspendedUntil, 10),
+				strconv.FormatInt(user.CreatedAt, 10)}
+		},
+	},
+	"likes": {
+		collection:  "likes",
+		newDocument: func() interface{} { return &ExportedLikeStructure{} },
+		csvHeader:   []string{"id", "userID", "ideaID", "created_at"},
+		csvRow: func(document interface{}) []string {
+			like := document.(*ExportedLikeStructure)
+			return []string{like.ID.Hex(), strconv.FormatInt(like.UserID, 10), like.IdeaID.Hex(), strconv.FormatInt(like.CreatedAt, 10)}
+		},
+	},
+}
+
+// decodeExportedDocument : Gazes saved before they had a time get the one of their id
+func decodeExportedDocument(documentsCursor *mongo.Cursor, exportable exportableCollection) (interface{}, error) {
+	document := exportable.newDocument()
+	errInDecoding := documentsCursor.Decode(document)
+	if like, isLike := document.(*ExportedLikeStructure); isLike && like.CreatedAt == 0 {
+		like.CreatedAt = objectIDCreatedAt(like.ID)
+	}
+	return document, errInDecoding
+}
+
+// exportCollection : Streams a whole collection as NDJSON or CSV straight from the cursor, oldest document first
+func exportCollection(ginContext *gin.Context, databaseClient *mongo.Client, databaseDriver string, collectionName string) {
+	exportable, isExportable := exportableCollections[collectionName]
+	if isExportable == false {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, only ideas, users and likes can be exported"})
+		return
+	}
+	// Stores of the other drivers cannot be streamed yet
+	if databaseDriver != "mongo" {
+		ginContext.JSON(http.StatusNotImplemented, gin.H{"status": http.StatusNotImplemented,
+			"error": "Exports read mongo, ideas, users and gazes are kept in " + databaseDriver})
+		return
+	}
+
+	format := ginContext.DefaultQuery("format", "ndjson")
+	if format != "ndjson" && format != "csv" {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Format should be ndjson or csv"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+	documentsCursor, errInFinding := databaseClient.Database("sardene-db").Collection(exportable.collection).Find(databaseContext,
+		bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	defer documentsCursor.Close(databaseContext)
+
+	if format == "csv" {
+		ginContext.Header("Content-Type", "text/csv")
+	} else {
+		ginContext.Header("Content-Type", "application/x-ndjson")
+	}
+	ginContext.Header("Content-Disposition", "attachment; filename="+collectionName+"."+format)
+	ginContext.Status(http.StatusOK)
+
+	if format == "csv" {
+		streamExportAsCSV(ginContext, databaseContext, documentsCursor, exportable)
+		return
+	}
+	streamExportAsNDJSON(ginContext, databaseContext, documentsCursor, exportable)
+}
+
+// streamExportAsNDJSON : One JSON document per line, a missing last newline tells the client the export broke off
+func streamExportAsNDJSON(ginContext *gin.Context, databaseContext context.Context, documentsCursor *mongo.Cursor,
+	exportable exportableCollection) {
+	exportedRows := 0
+	for documentsCursor.Next(databaseContext) {
+		document, errInDecoding := decodeExportedDocument(documentsCursor, exportable)
+		if errInDecoding != nil {
+			return
+		}
+		documentInJSON, errInEncoding := json.Marshal(document)
+		if errInEncoding != nil {
+			return
+		}
+		_, errInWriting := ginContext.Writer.Write(append(documentInJSON, '\n'))
+		if errInWriting != nil {
+			return
+		}
+
+		exportedRows++
+		if exportedRows%exportFlushEvery == 0 {
+			ginContext.Writer.Flush()
+		}
+	}
+}
+
+func streamExportAsCSV(ginContext *gin.Context, databaseContext context.Context, documentsCursor *mongo.Cursor,
+	exportable exportableCollection) {
+	csvWriter := csv.NewWriter(ginContext.Writer)
+	defer csvWriter.Flush()
+	_ = csvWriter.Write(exportable.csvHeader)
+
+	exportedRows := 0
+	for documentsCursor.Next(databaseContext) {
+		document, errInDecoding := decodeExportedDocument(documentsCursor, exportable)
+		if errInDecoding != nil {
+			// Headers are already sent, the truncated file is all that can be returned
+			return
+		}
+		errInWriting := csvWriter.Write(exportable.csvRow(document))
+		if errInWriting != nil {
+			return
+		}
+
+		exportedRows++
+		if exportedRows%exportFlushEvery == 0 {
+			csvWriter.Flush()
+			ginContext.Writer.Flush()
+		}
+	}
+}
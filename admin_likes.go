@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AdminLikeStructure : Structure of a raw like record for moderators
+type AdminLikeStructure struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	UserID int64              `json:"userID" bson:"userID"`
+	IdeaID primitive.ObjectID `json:"ideaID" bson:"ideaID"`
+	// Likes do not store their time, it is taken from the generated id
+	LikedAt int64 `json:"liked_at" bson:"-"`
+}
+
+// objectIDCreatedAt : First four bytes of an object id are the unix seconds it was generated at
+func objectIDCreatedAt(objectID primitive.ObjectID) int64 {
+	return int64(binary.BigEndian.Uint32(objectID[0:4]))
+}
+
+func getLikesFilterFromQuery(ginContext *gin.Context) (bson.M, error) {
+	likesFilter := bson.M{}
+
+	userIDInQuery := ginContext.Query("userID")
+	if userIDInQuery != "" {
+		userID, errInUserID := strconv.ParseInt(userIDInQuery, 10, 64)
+		if errInUserID != nil {
+			return likesFilter, errInUserID
+		}
+		likesFilter["userID"] = userID
+	}
+
+	ideaIDInQuery := ginContext.Query("ideaID")
+	if ideaIDInQuery != "" {
+		hexIdeaID, errInIdeaID := primitive.ObjectIDFromHex(ideaIDInQuery)
+		if errInIdeaID != nil {
+			return likesFilter, errInIdeaID
+		}
+		likesFilter["ideaID"] = hexIdeaID
+	}
+
+	return likesFilter, nil
+}
+
+func getLikesForAdmin(ginContext *gin.Context, databaseClient *mongo.Client) {
+
+	likesFilter, errInFilter := getLikesFilterFromQuery(ginContext)
+	if errInFilter != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, userID or ideaID is not valid"})
+		return
+	}
+
+	likesCollection := databaseClient.Database("sardene-db").Collection("likes")
+	databaseContext := ginContext.Request.Context()
+
+	// Newest likes first, object ids grow with insertion time
+	if ginContext.Query("format") == "csv" {
+		likesCursor, errInFinding := likesCollection.Find(databaseContext, likesFilter, options.Find().SetSort(bson.M{"_id": -1}))
+		if errInFinding != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+			return
+		}
+		defer likesCursor.Close(databaseContext)
+
+		streamLikesAsCSV(ginContext, databaseContext, likesCursor)
+		return
+	}
+
+	pagination, errInPagination := getPaginationFromQuery(ginContext, 50, 500)
+	if errInPagination != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": errInPagination.Error()})
+		return
+	}
+
+	likes := []*AdminLikeStructure{}
+	pageTotals, errInFinding := findPageWithTotals(databaseContext, likesCollection, likesFilter,
+		bson.D{{Key: "_id", Value: -1}}, pagination, &likes)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	for _, like := range likes {
+		like.LikedAt = objectIDCreatedAt(like.ID)
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": likes, "count": len(likes),
+		"page": pagination.Page, "limit": pagination.Limit,
+		"total_count": pageTotals.TotalCount, "total_pages": pageTotals.TotalPages})
+}
+
+// streamLikesAsCSV : Writes rows while iterating the cursor so large exports are not held in memory
+func streamLikesAsCSV(ginContext *gin.Context, databaseContext context.Context, likesCursor *mongo.Cursor) {
+	ginContext.Header("Content-Type", "text/csv")
+	ginContext.Header("Content-Disposition", "attachment; filename=likes.csv")
+	ginContext.Status(http.StatusOK)
+
+	csvWriter := csv.NewWriter(ginContext.Writer)
+	_ = csvWriter.Write([]string{"id", "userID", "ideaID", "liked_at"})
+
+	for likesCursor.Next(databaseContext) {
+		var like AdminLikeStructure
+		errInDecoding := likesCursor.Decode(&like)
+		if errInDecoding != nil {
+			// Headers are already sent, the truncated file is all that can be returned
+			break
+		}
+
+		_ = csvWriter.Write([]string{
+			like.ID.Hex(),
+			strconv.FormatInt(like.UserID, 10),
+			like.IdeaID.Hex(),
+			strconv.FormatInt(objectIDCreatedAt(like.ID), 10),
+		})
+	}
+
+	csvWriter.Flush()
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	analyticsBatchSize     = 100
+	analyticsFlushInterval = 5 * time.Second
+	analyticsMaxDaysShown  = 90
+	// Mongo answers creating a collection which already exists with this code
+	namespaceExistsErrorCode = 48
+)
+
+// RequestEventStructure : Served request in request_events, nothing in it points back to the user
+type RequestEventStructure struct {
+	Route           string `json:"route" bson:"route"`
+	Status          int    `json:"status" bson:"status"`
+	LatencyInMs     int64  `json:"latency_ms" bson:"latency_ms"`
+	UserAgentFamily string `json:"user_agent_family" bson:"user_agent_family"`
+	Day             string `json:"day" bson:"day"`
+	CreatedAt       int64  `json:"created_at" bson:"created_at"`
+}
+
+// RouteTrafficStructure : Traffic of one route on one UTC day
+type RouteTrafficStructure struct {
+	Day          string  `json:"day" bson:"day"`
+	Route        string  `json:"route" bson:"route"`
+	Requests     int64   `json:"requests" bson:"requests"`
+	ClientErrors int64   `json:"client_errors" bson:"client_errors"`
+	ServerErrors int64   `json:"server_errors" bson:"server_errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms" bson:"avg_latency_ms"`
+	MaxLatencyMs int64   `json:"max_latency_ms" bson:"max_latency_ms"`
+}
+
+// AnalyticsConfig : Size of the capped collection and of the queue in front of it
+type AnalyticsConfig struct {
+	MaxBytes   int64
+	BufferSize int64
+}
+
+// RequestAnalytics : Writes request events in batches away from the request, nil when analytics are switched off
+type RequestAnalytics struct {
+	databaseClient *mongo.Client
+	events         chan RequestEventStructure
+}
+
+var requestAnalytics *RequestAnalytics
+
+func loadAnalyticsConfig(configLoader *ConfigLoader) AnalyticsConfig {
+	var analyticsConfig AnalyticsConfig
+
+	analyticsConfig.MaxBytes = configLoader.Int("ANALYTICS_MAX_BYTES", 64*1024*1024)
+	if analyticsConfig.MaxBytes < 4096 {
+		configLoader.Invalid("ANALYTICS_MAX_BYTES", "should be at least 4096")
+	}
+	analyticsConfig.BufferSize = configLoader.Int("ANALYTICS_BUFFER_SIZE", 1000)
+	if analyticsConfig.BufferSize <= 0 {
+		configLoader.Invalid("ANALYTICS_BUFFER_SIZE", "should be more than 0")
+	}
+
+	return analyticsConfig
+}
+
+// newRequestAnalytics : Oldest events are dropped by mongo once the capped collection is full
+func newRequestAnalytics(databaseClient *mongo.Client, analyticsConfig AnalyticsConfig) *RequestAnalytics {
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelDBContext()
+
+	errInCreating := databaseClient.Database("sardene-db").RunCommand(databaseContext, bson.D{
+		{Key: "create", Value: "request_events"},
+		{Key: "capped", Value: true},
+		{Key: "size", Value: analyticsConfig.MaxBytes},
+	}).Err()
+	if commandError, isCommandError := errInCreating.(mongo.CommandError); isCommandError && commandError.Code == namespaceExistsErrorCode {
+		errInCreating = nil
+	}
+	if errInCreating != nil {
+		log.Fatal(errInCreating, "Failed to create request_events collection")
+	}
+
+	analytics := &RequestAnalytics{
+		databaseClient: databaseClient,
+		events:         make(chan RequestEventStructure, analyticsConfig.BufferSize),
+	}
+	go analytics.run()
+
+	return analytics
+}
+
+// Record : Never waits, events are dropped when the writer cannot keep up
+func (analytics *RequestAnalytics) Record(event RequestEventStructure) {
+	select {
+	case analytics.events <- event:
+	default:
+	}
+}
+
+func (analytics *RequestAnalytics) run() {
+	flushTicker := time.NewTicker(analyticsFlushInterval)
+	defer flushTicker.Stop()
+
+	batch := make([]interface{}, 0, analyticsBatchSize)
+	for {
+		select {
+		case event := <-analytics.events:
+			batch = append(batch, event)
+			if len(batch) < analyticsBatchSize {
+				continue
+			}
+		case <-flushTicker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		analytics.flush(batch)
+		batch = make([]interface{}, 0, analyticsBatchSize)
+	}
+}
+
+func (analytics *RequestAnalytics) flush(batch []interface{}) {
+	eventsCollection := analytics.databaseClient.Database("sardene-db").Collection("request_events")
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelDBContext()
+
+	_, errInAdding := eventsCollection.InsertMany(databaseContext, batch, options.InsertMany().SetOrdered(false))
+	if errInAdding != nil {
+		log.Println(errInAdding, "Failed to write", len(batch), "request events")
+	}
+}
+
+// userAgentFamily : Browser or client behind the user agent, the full string is never kept
+func userAgentFamily(userAgent string) string {
+	userAgent = strings.ToLower(userAgent)
+	switch {
+	case userAgent == "":
+		return "unknown"
+	case strings.Contains(userAgent, "bot") || strings.Contains(userAgent, "crawler") || strings.Contains(userAgent, "spider"):
+		return "bot"
+	case strings.HasPrefix(userAgent, "curl/"):
+		return "curl"
+	case strings.Contains(userAgent, "python"):
+		return "python"
+	case strings.HasPrefix(userAgent, "go-http-client"):
+		return "go"
+	case strings.Contains(userAgent, "postman"):
+		return "postman"
+	case strings.Contains(userAgent, "edg/"):
+		return "edge"
+	case strings.Contains(userAgent, "opr/"):
+		return "opera"
+	case strings.Contains(userAgent, "chrome/") || strings.Contains(userAgent, "crios/"):
+		return "chrome"
+	case strings.Contains(userAgent, "firefox/") || strings.Contains(userAgent, "fxios/"):
+		return "firefox"
+	case strings.Contains(userAgent, "safari/"):
+		return "safari"
+	}
+	return "other"
+}
+
+// recordRequestEvent : Middleware handing each request of the route to the analytics writer
+func recordRequestEvent(route string) gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		if requestAnalytics == nil {
+			ginContext.Next()
+			return
+		}
+
+		requestStart := time.Now()
+		ginContext.Next()
+
+		requestAnalytics.Record(RequestEventStructure{
+			Route:           route,
+			Status:          ginContext.Writer.Status(),
+			LatencyInMs:     time.Since(requestStart).Nanoseconds() / int64(time.Millisecond),
+			UserAgentFamily: userAgentFamily(ginContext.GetHeader("User-Agent")),
+			Day:             requestStart.UTC().Format("2006-01-02"),
+			CreatedAt:       requestStart.Unix(),
+		})
+	}
+}
+
+// getRouteTraffic : Traffic per route per day over the last ?days=, busiest routes of the newest day first
+func getRouteTraffic(ginContext *gin.Context, databaseClient *mongo.Client) {
+	daysShown, errInDays := strconv.Atoi(ginContext.DefaultQuery("days", "7"))
+	if errInDays != nil || daysShown <= 0 || daysShown > analyticsMaxDaysShown {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Days should be a number from 1 to " + strconv.Itoa(analyticsMaxDaysShown)})
+		return
+	}
+
+	eventsCollection := databaseClient.Database("sardene-db").Collection("request_events")
+	databaseContext := ginContext.Request.Context()
+
+	firstDay := time.Now().UTC().AddDate(0, 0, 1-daysShown).Format("2006-01-02")
+	trafficPipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"day": bson.M{"$gte": firstDay}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            bson.M{"day": "$day", "route": "$route"},
+			"requests":       bson.M{"$sum": 1},
+			"client_errors":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$and": bson.A{bson.M{"$gte": bson.A{"$status", 400}}, bson.M{"$lt": bson.A{"$status", 500}}}}, 1, 0}}},
+			"server_errors":  bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$status", 500}}, 1, 0}}},
+			"avg_latency_ms": bson.M{"$avg": "$latency_ms"},
+			"max_latency_ms": bson.M{"$max": "$latency_ms"},
+		}}},
+		{{Key: "$addFields", Value: bson.M{"day": "$_id.day", "route": "$_id.route"}}},
+		{{Key: "$sort", Value: bson.D{{Key: "day", Value: -1}, {Key: "requests", Value: -1}}}},
+	}
+
+	trafficCursor, errInAggregating := eventsCollection.Aggregate(databaseContext, trafficPipeline, options.Aggregate())
+	if errInAggregating != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInAggregating.Error()})
+		return
+	}
+	defer trafficCursor.Close(databaseContext)
+
+	routeTraffic := []RouteTrafficStructure{}
+	for trafficCursor.Next(databaseContext) {
+		var traffic RouteTrafficStructure
+		errInDecoding := trafficCursor.Decode(&traffic)
+		if errInDecoding != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in decoding database", "errorDetails": errInDecoding.Error()})
+			return
+		}
+		routeTraffic = append(routeTraffic, traffic)
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": routeTraffic, "count": len(routeTraffic)})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditActionIdeaArchived   = "idea.archived"
+	auditActionIdeaUnarchived = "idea.unarchived"
+)
+
+// abortIdeaArchived : For gazes, progress updates and other new activity on an archived idea, which stays readable
+func abortIdeaArchived(ginContext *gin.Context) {
+	ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+		"error": "Error, Idea is archived and takes no new activity"})
+}
+
+// setIdeaArchived : Archived ideas are left out of listings unless asked for with include=archived,
+// they are still found by their id
+func setIdeaArchived(ginContext *gin.Context, stores Stores, idea IdeaStructure, archived bool) {
+	databaseContext := ginContext.Request.Context()
+	hexIdeaID := idea.ID
+
+	if idea.Archived == archived {
+		ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Idea is already in that archived status"})
+		return
+	}
+
+	var archivedAt int64
+	if archived == true {
+		archivedAt = time.Now().Unix()
+	}
+	errInSetting := stores.Ideas.SetArchived(databaseContext, hexIdeaID, archived, archivedAt)
+	if errInSetting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInSetting.Error()})
+		return
+	}
+
+	auditAction := auditActionIdeaUnarchived
+	if archived == true {
+		auditAction = auditActionIdeaArchived
+	}
+	ideaAfterChange := idea
+	ideaAfterChange.Archived = archived
+	ideaAfterChange.ArchivedAt = archivedAt
+	describeAuditedMutation(ginContext, auditAction, hexIdeaID.Hex(), idea, ideaAfterChange)
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Archived status of idea changed successfully",
+		"data": gin.H{"archived": archived, "archived_at": archivedAt}})
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BlobStorage : Place where attachment bytes are kept, keyed by their content hash
+type BlobStorage interface {
+	Save(blobKey string, contentType string, blobReader io.Reader) error
+	Open(blobKey string) (io.ReadCloser, error)
+	Remove(blobKey string) error
+}
+
+// AttachmentStructure : Structure of attachment in database, one document per unique content
+type AttachmentStructure struct {
+	Hash        string `json:"hash" bson:"_id"`
+	ContentType string `json:"content_type" bson:"content_type"`
+	Size        int64  `json:"size" bson:"size"`
+	RefCount    int64  `json:"ref_count" bson:"ref_count"`
+	CreatedAt   int64  `json:"created_at" bson:"created_at"`
+}
+
+// AttachmentConfig : Limits on uploaded attachments and where they are kept
+type AttachmentConfig struct {
+	MaxBytes            int64
+	AllowedContentTypes map[string]bool
+	MaxImagesPerIdea    int64
+	// Storage is gridfs or s3, S3 is only loaded for the latter
+	Storage string
+	S3      S3Config
+}
+
+type gridFSBlobStorage struct {
+	bucket *gridfs.Bucket
+}
+
+func (blobStorage gridFSBlobStorage) Save(blobKey string, contentType string, blobReader io.Reader) error {
+	uploadOptions := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+	return blobStorage.bucket.UploadFromStreamWithID(blobKey, blobKey, blobReader, uploadOptions)
+}
+
+func (blobStorage gridFSBlobStorage) Open(blobKey string) (io.ReadCloser, error) {
+	return blobStorage.bucket.OpenDownloadStream(blobKey)
+}
+
+func (blobStorage gridFSBlobStorage) Remove(blobKey string) error {
+	return blobStorage.bucket.Delete(blobKey)
+}
+
+func newGridFSBlobStorage(databaseClient *mongo.Client) BlobStorage {
+	bucketOptions := options.GridFSBucket().SetName("attachment_blobs")
+	bucket, errInBucket := gridfs.NewBucket(databaseClient.Database("sardene-db"), bucketOptions)
+	if errInBucket != nil {
+		log.Fatal(errInBucket, "Failed to open attachment storage")
+	}
+	return gridFSBlobStorage{bucket: bucket}
+}
+
+// newBlobStorage : Storage picked with ATTACHMENT_STORAGE
+func newBlobStorage(databaseClient *mongo.Client, attachmentConfig AttachmentConfig) (BlobStorage, error) {
+	if attachmentConfig.Storage == blobStorageS3 {
+		return newS3BlobStorage(attachmentConfig.S3)
+	}
+	return newGridFSBlobStorage(databaseClient), nil
+}
+
+func loadAttachmentConfig(configLoader *ConfigLoader) AttachmentConfig {
+	var attachmentConfig AttachmentConfig
+
+	attachmentConfig.MaxBytes = configLoader.Int("ATTACHMENT_MAX_BYTES", 5*1024*1024)
+	attachmentConfig.AllowedContentTypes = make(map[string]bool)
+
+	for _, contentType := range configLoader.List("ATTACHMENT_CONTENT_TYPES", "image/png,image/jpeg,image/gif,image/webp") {
+		attachmentConfig.AllowedContentTypes[contentType] = true
+	}
+
+	attachmentConfig.MaxImagesPerIdea = configLoader.Int("IDEA_MAX_IMAGES", 10)
+	if attachmentConfig.MaxImagesPerIdea <= 0 {
+		configLoader.Invalid("IDEA_MAX_IMAGES", "should be more than 0")
+	}
+
+	attachmentConfig.Storage = configLoader.OneOf("ATTACHMENT_STORAGE", blobStorageGridFS, blobStorageGridFS, blobStorageS3)
+	if attachmentConfig.Storage == blobStorageS3 {
+		attachmentConfig.S3 = loadS3Config(configLoader)
+	}
+
+	return attachmentConfig
+}
+
+func attachmentURL(attachmentHash string) string {
+	return "/attachments/" + attachmentHash
+}
+
+// storeAttachment : Saves the blob only the first time its content is seen, later uploads just add a reference.
+// The reference belongs to the idea when ideaID is not primitive.NilObjectID.
+func storeAttachment(databaseContext context.Context, databaseClient *mongo.Client, blobStorage BlobStorage,
+	uploader GithubUserProfileStructure, ideaID primitive.ObjectID, attachmentBytes []byte, contentType string) (AttachmentStructure, bool, error) {
+	var attachment AttachmentStructure
+
+	attachmentsCollection := databaseClient.Database("sardene-db").Collection("attachments")
+	attachmentRefsCollection := databaseClient.Database("sardene-db").Collection("attachment_refs")
+
+	hashOfContent := sha256.Sum256(attachmentBytes)
+	attachmentHash := hex.EncodeToString(hashOfContent[:])
+
+	existingAttachments, errInCounting := attachmentsCollection.CountDocuments(databaseContext, bson.M{"_id": attachmentHash}, options.Count())
+	if errInCounting != nil {
+		return attachment, false, errInCounting
+	}
+
+	isDuplicate := existingAttachments != 0
+	if isDuplicate == false {
+		errInSaving := blobStorage.Save(attachmentHash, contentType, bytes.NewReader(attachmentBytes))
+		if errInSaving != nil && isDuplicateKeyError(errInSaving) == false {
+			return attachment, false, errInSaving
+		}
+	}
+
+	addReferenceUpdate := bson.M{
+		"$inc": bson.M{"ref_count": 1},
+		"$setOnInsert": bson.M{
+			"content_type": contentType,
+			"size":         len(attachmentBytes),
+			"created_at":   time.Now().Unix(),
+		},
+	}
+	upsertOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	errInUpdating := attachmentsCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": attachmentHash},
+		addReferenceUpdate, upsertOptions).Decode(&attachment)
+	if errInUpdating != nil {
+		return attachment, false, errInUpdating
+	}
+
+	referenceToAdd := bson.M{
+		"hash":       attachmentHash,
+		"user_id":    uploader.UserID,
+		"created_at": time.Now().Unix(),
+	}
+	if ideaID.IsZero() == false {
+		referenceToAdd["idea_id"] = ideaID
+	}
+	_, errInAddingReference := attachmentRefsCollection.InsertOne(databaseContext, referenceToAdd)
+	if errInAddingReference != nil {
+		return attachment, false, errInAddingReference
+	}
+
+	return attachment, isDuplicate, nil
+}
+
+// releaseAttachment : Drops one reference, the blob is removed once nothing refers to it
+func releaseAttachment(databaseContext context.Context, databaseClient *mongo.Client, blobStorage BlobStorage, referenceFilter bson.M) (bool, error) {
+	attachmentsCollection := databaseClient.Database("sardene-db").Collection("attachments")
+	attachmentRefsCollection := databaseClient.Database("sardene-db").Collection("attachment_refs")
+
+	var removedReference struct {
+		Hash string `bson:"hash"`
+	}
+	errInRemoving := attachmentRefsCollection.FindOneAndDelete(databaseContext, referenceFilter).Decode(&removedReference)
+	if errInRemoving != nil {
+		if errInRemoving.Error() == "mongo: no documents in result" {
+			return false, nil
+		}
+		return false, errInRemoving
+	}
+
+	var attachment AttachmentStructure
+	errInUpdating := attachmentsCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": removedReference.Hash},
+		bson.M{"$inc": bson.M{"ref_count": -1}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&attachment)
+	if errInUpdating != nil {
+		return true, errInUpdating
+	}
+
+	if attachment.RefCount <= 0 {
+		_, errInDeleting := attachmentsCollection.DeleteOne(databaseContext, bson.M{"_id": attachment.Hash, "ref_count": bson.M{"$lte": 0}})
+		if errInDeleting != nil {
+			return true, errInDeleting
+		}
+		errInRemovingBlob := blobStorage.Remove(attachment.Hash)
+		if errInRemovingBlob != nil {
+			log.Println(errInRemovingBlob, "Failed to remove unreferenced attachment", attachment.Hash)
+		}
+	}
+
+	return true, nil
+}
+
+func readUploadedAttachment(ginContext *gin.Context, attachmentConfig AttachmentConfig) ([]byte, string, int, string) {
+	uploadedFile, errInFile := ginContext.FormFile("file")
+	if errInFile != nil {
+		return nil, "", http.StatusBadRequest, "File should be posted as multipart form field named file"
+	}
+	if uploadedFile.Size > attachmentConfig.MaxBytes {
+		return nil, "", http.StatusRequestEntityTooLarge, "File is larger than the allowed size"
+	}
+
+	fileReader, errInOpening := uploadedFile.Open()
+	if errInOpening != nil {
+		return nil, "", http.StatusBadRequest, "Uploaded file cannot be read"
+	}
+	defer fileReader.Close()
+
+	attachmentBytes, errInReading := ioutil.ReadAll(io.LimitReader(fileReader, attachmentConfig.MaxBytes+1))
+	if errInReading != nil {
+		return nil, "", http.StatusBadRequest, "Uploaded file cannot be read"
+	}
+	if int64(len(attachmentBytes)) > attachmentConfig.MaxBytes {
+		return nil, "", http.StatusRequestEntityTooLarge, "File is larger than the allowed size"
+	}
+
+	// Type is taken from the content, not from what the client claims
+	contentType := http.DetectContentType(attachmentBytes)
+	if attachmentConfig.AllowedContentTypes[contentType] == false {
+		return nil, "", http.StatusUnsupportedMediaType, "File type " + contentType + " is not allowed"
+	}
+
+	return attachmentBytes, contentType, http.StatusOK, ""
+}
+
+func uploadAttachment(ginContext *gin.Context, databaseClient *mongo.Client, blobStorage BlobStorage, attachmentConfig AttachmentConfig) {
+	user := getAuthenticatedUser(ginContext)
+
+	attachmentBytes, contentType, statusOfUpload, errorOfUpload := readUploadedAttachment(ginContext, attachmentConfig)
+	if statusOfUpload != http.StatusOK {
+		ginContext.JSON(statusOfUpload, gin.H{"status": statusOfUpload, "error": errorOfUpload})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	attachment, isDuplicate, errInStoring := storeAttachment(databaseContext, databaseClient, blobStorage, user,
+		primitive.NilObjectID, attachmentBytes, contentType)
+	if errInStoring != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving attachment", "errorDetails": errInStoring.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": gin.H{
+		"hash":         attachment.Hash,
+		"url":          attachmentURL(attachment.Hash),
+		"content_type": attachment.ContentType,
+		"size":         attachment.Size,
+		"deduplicated": isDuplicate,
+	}})
+}
+
+func getAttachment(ginContext *gin.Context, databaseClient *mongo.Client, blobStorage BlobStorage, attachmentHash string) {
+	attachmentsCollection := databaseClient.Database("sardene-db").Collection("attachments")
+	databaseContext := ginContext.Request.Context()
+
+	var attachment AttachmentStructure
+	errInDecoding := attachmentsCollection.FindOne(databaseContext, bson.M{"_id": attachmentHash}).Decode(&attachment)
+	if errInDecoding != nil {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Attachment not found"})
+		return
+	}
+
+	blobReader, errInOpening := blobStorage.Open(attachmentHash)
+	if errInOpening != nil {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Attachment not found"})
+		return
+	}
+	defer blobReader.Close()
+
+	// Content never changes for a hash
+	ginContext.Header("Cache-Control", "public, max-age=31536000, immutable")
+	ginContext.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, blobReader, nil)
+}
+
+func deleteAttachment(ginContext *gin.Context, databaseClient *mongo.Client, blobStorage BlobStorage, attachmentHash string) {
+	user := getAuthenticatedUser(ginContext)
+
+	databaseContext := ginContext.Request.Context()
+
+	// Only references uploaded by the user themselves, and not used by an idea, can be released here
+	userReferenceFilter := bson.M{"hash": attachmentHash, "user_id": user.UserID, "idea_id": bson.M{"$exists": false}}
+	isReleased, errInReleasing := releaseAttachment(databaseContext, databaseClient, blobStorage, userReferenceFilter)
+	if errInReleasing != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while deleting attachment", "errorDetails": errInReleasing.Error()})
+		return
+	}
+	if isReleased == false {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Attachment of user not found"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Attachment deleted successfully"})
+}
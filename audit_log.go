@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	auditActionIdeaCreated = "idea.created"
+	auditActionIdeaUpdated = "idea.updated"
+	auditActionIdeaDeleted = "idea.deleted"
+	auditActionIdeaGazed   = "idea.gazed"
+	// Recorded without the actor, whose account is gone
+	auditActionAccountDeleted = "account.deleted"
+
+	auditLogEntryKey = "auditLogEntry"
+)
+
+// AuditLogEntryStructure : Structure of a mutating operation kept in audit_log, entries are never changed
+// except to stop naming a user who deleted their account
+type AuditLogEntryStructure struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Action     string             `json:"action" bson:"action"`
+	ActorID    int64              `json:"actor_id" bson:"actor_id"`
+	ActorLogin string             `json:"actor_login" bson:"actor_login"`
+	TargetID   string             `json:"target_id" bson:"target_id"`
+	Details    bson.M             `json:"details" bson:"details"`
+	Before     interface{}        `json:"before,omitempty" bson:"before,omitempty"`
+	After      interface{}        `json:"after,omitempty" bson:"after,omitempty"`
+	CreatedAt  int64              `json:"created_at" bson:"created_at"`
+}
+
+// describeAuditedMutation : Handlers name what they changed and snapshot it, before is nil for creates and after for deletes
+func describeAuditedMutation(ginContext *gin.Context, action string, targetID string, before interface{}, after interface{}) {
+	ginContext.Set(auditLogEntryKey, AuditLogEntryStructure{Action: action, TargetID: targetID, Before: before, After: after})
+}
+
+// recordMutation : Middleware adding every successful request of a mutating route to the audit log,
+// routes whose handler did not describe the mutation are recorded by their method and path
+func recordMutation(databaseClient *mongo.Client, route string) gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		ginContext.Next()
+
+		if ginContext.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+
+		entry := AuditLogEntryStructure{Action: route}
+		if describedEntry, isDescribed := ginContext.Get(auditLogEntryKey); isDescribed == true {
+			entry = describedEntry.(AuditLogEntryStructure)
+		}
+
+		params := bson.M{}
+		for _, param := range ginContext.Params {
+			params[param.Key] = param.Value
+			if entry.TargetID == "" {
+				entry.TargetID = param.Value
+			}
+		}
+		if entry.Details == nil {
+			entry.Details = bson.M{}
+		}
+		entry.Details["route"] = route
+		entry.Details["params"] = params
+
+		actor := getAuthenticatedUser(ginContext)
+		if entry.Action == auditActionAccountDeleted {
+			actor = GithubUserProfileStructure{Login: deletedUserLogin}
+		}
+		entry.ActorID = actor.UserID
+		entry.ActorLogin = actor.Login
+		entry.CreatedAt = time.Now().Unix()
+
+		// Response is already written, the request context may be gone by now
+		databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelDBContext()
+
+		auditLogCollection := databaseClient.Database("sardene-db").Collection("audit_log")
+		_, errInAdding := auditLogCollection.InsertOne(databaseContext, entry)
+		if errInAdding != nil {
+			log.Println(errInAdding, "Failed to audit log", entry.Action, "of", entry.TargetID)
+		}
+	}
+}
+
+// anonymizeUserAuditLog : Entries keep what was done, the user is dropped as actor and from snapshots of ideas and gazes
+func anonymizeUserAuditLog(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	auditLogCollection := databaseClient.Database("sardene-db").Collection("audit_log")
+
+	type anonymizingUpdate struct {
+		filter bson.M
+		update bson.M
+	}
+	anonymizingUpdates := []anonymizingUpdate{
+		{bson.M{"actor_id": userID}, bson.M{"$set": bson.M{"actor_id": 0, "actor_login": deletedUserLogin}}},
+	}
+	for _, snapshot := range []string{"before", "after"} {
+		anonymizingUpdates = append(anonymizingUpdates, []anonymizingUpdate{
+			{bson.M{snapshot + ".publisher_id": userID, snapshot + ".org": bson.M{"$in": bson.A{"", nil}}},
+				bson.M{"$set": bson.M{snapshot + ".publisher": deletedUserLogin, snapshot + ".publisher_id": 0}}},
+			// Ideas left are published under an org, which stays their publisher
+			{bson.M{snapshot + ".publisher_id": userID},
+				bson.M{"$set": bson.M{snapshot + ".author": deletedUserLogin, snapshot + ".publisher_id": 0}}},
+			{bson.M{snapshot + ".collaborators.user_id": userID},
+				bson.M{"$pull": bson.M{snapshot + ".collaborators": bson.M{"user_id": userID}}}},
+			{bson.M{snapshot + ".userID": userID},
+				bson.M{"$set": bson.M{snapshot + ".userID": 0}, "$unset": bson.M{snapshot + ".ip_hash": ""}}},
+		}...)
+	}
+
+	for _, update := range anonymizingUpdates {
+		_, errInUpdating := auditLogCollection.UpdateMany(databaseContext, update.filter, update.update)
+		if errInUpdating != nil {
+			return errInUpdating
+		}
+	}
+	return nil
+}
+
+// getAuditLog : Newest entries first, narrowed with ?action= and ?target_id=
+func getAuditLog(ginContext *gin.Context, databaseClient *mongo.Client) {
+	pagination, errInPagination := getPaginationFromQuery(ginContext, 50, 200)
+	if errInPagination != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong pagination", "errorDetails": errInPagination.Error()})
+		return
+	}
+
+	auditLogFilter := bson.M{}
+	if action := ginContext.Query("action"); action != "" {
+		auditLogFilter["action"] = action
+	}
+	if targetID := ginContext.Query("target_id"); targetID != "" {
+		auditLogFilter["target_id"] = targetID
+	}
+
+	auditLogCollection := databaseClient.Database("sardene-db").Collection("audit_log")
+	databaseContext := ginContext.Request.Context()
+
+	entries := []*AuditLogEntryStructure{}
+	pageTotals, errInFinding := findPageWithTotals(databaseContext, auditLogCollection, auditLogFilter,
+		bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, pagination, &entries)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": entries, "count": len(entries),
+		"page": pagination.Page, "limit": pagination.Limit,
+		"total_count": pageTotals.TotalCount, "total_pages": pageTotals.TotalPages})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuthThrottleConfig : Limits on signing in within a window, 0 turns a limit off
+type AuthThrottleConfig struct {
+	Window           time.Duration
+	MaxAttemptsPerIP int64
+	// Failed code exchanges past these lock the address or the code out until the window ends
+	MaxFailuresPerIP   int64
+	MaxFailuresPerCode int64
+}
+
+// AuthAttemptsStructure : Counter of sign in attempts or failures of one address or code in one window
+type AuthAttemptsStructure struct {
+	ID        string    `bson:"_id"`
+	Count     int64     `bson:"count"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func loadAuthThrottleConfig(configLoader *ConfigLoader) AuthThrottleConfig {
+	var authThrottleConfig AuthThrottleConfig
+
+	authThrottleConfig.Window = time.Duration(configLoader.Int("AUTH_THROTTLE_WINDOW_MINUTES", 15)) * time.Minute
+	authThrottleConfig.MaxAttemptsPerIP = configLoader.Int("AUTH_MAX_ATTEMPTS_PER_IP", 30)
+	authThrottleConfig.MaxFailuresPerIP = configLoader.Int("AUTH_MAX_FAILURES_PER_IP", 10)
+	authThrottleConfig.MaxFailuresPerCode = configLoader.Int("AUTH_MAX_FAILURES_PER_CODE", 3)
+	if authThrottleConfig.Window <= 0 {
+		configLoader.Invalid("AUTH_THROTTLE_WINDOW_MINUTES", "should be more than 0")
+	}
+
+	return authThrottleConfig
+}
+
+func hashAuthCode(githubAuthCode string) string {
+	hashedCode := sha256.Sum256([]byte(githubAuthCode))
+	return hex.EncodeToString(hashedCode[:])
+}
+
+// authWindowOf : Start and end of the window the current time falls in
+func authWindowOf(window time.Duration) (int64, time.Time) {
+	windowStart := time.Now().Truncate(window)
+	return windowStart.Unix(), windowStart.Add(window)
+}
+
+func authCounterID(kind string, key string, windowStart int64) string {
+	return kind + ":" + key + ":" + strconv.FormatInt(windowStart, 10)
+}
+
+func increaseAuthCounter(databaseContext context.Context, databaseClient *mongo.Client, counterID string, windowEnd time.Time) (int64, error) {
+	attemptsCollection := databaseClient.Database("sardene-db").Collection("auth_attempts")
+
+	counterUpdate := bson.M{"$inc": bson.M{"count": 1}, "$setOnInsert": bson.M{"expires_at": windowEnd}}
+	counterOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter AuthAttemptsStructure
+	errInCounting := attemptsCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": counterID}, counterUpdate, counterOptions).Decode(&counter)
+	if isDuplicateKeyError(errInCounting) {
+		errInCounting = attemptsCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": counterID}, counterUpdate, counterOptions).Decode(&counter)
+	}
+	return counter.Count, errInCounting
+}
+
+func readAuthCounter(databaseContext context.Context, databaseClient *mongo.Client, counterID string) (int64, error) {
+	attemptsCollection := databaseClient.Database("sardene-db").Collection("auth_attempts")
+
+	var counter AuthAttemptsStructure
+	errInFinding := attemptsCollection.FindOne(databaseContext, bson.M{"_id": counterID}).Decode(&counter)
+	if errInFinding == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return counter.Count, errInFinding
+}
+
+func abortAuthThrottled(ginContext *gin.Context, windowEnd time.Time, message string) {
+	ginContext.Header("Retry-After", strconv.FormatInt(int64(time.Until(windowEnd).Seconds())+1, 10))
+	ginContext.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": http.StatusTooManyRequests,
+		"error": message, "resets_at": windowEnd.Unix()})
+}
+
+// throttleAuth : Counts the attempt of the address and refuses it while the address or the code is over its limits.
+// False means the response is already written. Counters are kept in mongo, without it sign ins are not throttled
+func throttleAuth(ginContext *gin.Context, databaseClient *mongo.Client, authThrottleConfig AuthThrottleConfig, githubAuthCode string) bool {
+	if databaseClient == nil {
+		return true
+	}
+	databaseContext := ginContext.Request.Context()
+	windowStart, windowEnd := authWindowOf(authThrottleConfig.Window)
+	hashedIP := hashClientIP(ginContext.ClientIP())
+
+	if authThrottleConfig.MaxAttemptsPerIP > 0 {
+		attempts, errInCounting := increaseAuthCounter(databaseContext, databaseClient, authCounterID("ip_attempts", hashedIP, windowStart), windowEnd)
+		if errInCounting != nil {
+			ginContext.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in checking sign in attempts", "errorDetails": errInCounting.Error()})
+			return false
+		}
+		if attempts > authThrottleConfig.MaxAttemptsPerIP {
+			abortAuthThrottled(ginContext, windowEnd, "Too many sign in attempts, try again later")
+			return false
+		}
+	}
+
+	lockouts := []struct {
+		counterID   string
+		maxFailures int64
+		message     string
+	}{
+		{authCounterID("ip_failures", hashedIP, windowStart), authThrottleConfig.MaxFailuresPerIP, "Too many failed sign ins, try again later"},
+		{authCounterID("code_failures", hashAuthCode(githubAuthCode), windowStart), authThrottleConfig.MaxFailuresPerCode, "Code failed too many times, sign in again"},
+	}
+	for _, lockout := range lockouts {
+		if lockout.maxFailures <= 0 {
+			continue
+		}
+		failures, errInReading := readAuthCounter(databaseContext, databaseClient, lockout.counterID)
+		if errInReading != nil {
+			ginContext.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in checking sign in attempts", "errorDetails": errInReading.Error()})
+			return false
+		}
+		if failures >= lockout.maxFailures {
+			abortAuthThrottled(ginContext, windowEnd, lockout.message)
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordAuthFailure : Counts a refused code exchange against the address and the code
+func recordAuthFailure(ginContext *gin.Context, databaseClient *mongo.Client, authThrottleConfig AuthThrottleConfig, githubAuthCode string) {
+	if databaseClient == nil {
+		return
+	}
+	databaseContext := ginContext.Request.Context()
+	windowStart, windowEnd := authWindowOf(authThrottleConfig.Window)
+
+	if authThrottleConfig.MaxFailuresPerIP > 0 {
+		_, _ = increaseAuthCounter(databaseContext, databaseClient,
+			authCounterID("ip_failures", hashClientIP(ginContext.ClientIP()), windowStart), windowEnd)
+	}
+	if authThrottleConfig.MaxFailuresPerCode > 0 {
+		_, _ = increaseAuthCounter(databaseContext, databaseClient,
+			authCounterID("code_failures", hashAuthCode(githubAuthCode), windowStart), windowEnd)
+	}
+}
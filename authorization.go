@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const authenticatedUserKey = "authenticatedUser"
+
+// AuthorizationPolicy : Rule a request has to pass before it reaches the handler of a route
+type AuthorizationPolicy struct {
+	Name string
+	// Optional user is authenticated when the header is present, anonymous requests still pass
+	OptionalUser bool
+	RequireUser  bool
+	RequireAdmin bool
+	// Idea owner is the publisher of the idea in the ideaID param, admins pass as well
+	RequireIdeaOwner bool
+	// Collaborators of the idea pass the idea owner check too
+	AllowCollaborators bool
+}
+
+var (
+	policyPublic       = AuthorizationPolicy{Name: "public"}
+	policyOptionalUser = AuthorizationPolicy{Name: "optional user", OptionalUser: true}
+	policyUser         = AuthorizationPolicy{Name: "user", RequireUser: true}
+	policyAdmin        = AuthorizationPolicy{Name: "admin", RequireUser: true, RequireAdmin: true}
+	policyIdeaOwner    = AuthorizationPolicy{Name: "idea owner", RequireUser: true, RequireIdeaOwner: true}
+	policyIdeaEditor   = AuthorizationPolicy{Name: "idea editor", RequireUser: true, RequireIdeaOwner: true, AllowCollaborators: true}
+)
+
+// routePolicies : Every route has to be declared here, the server refuses to start with an undeclared route
+var routePolicies = map[string]AuthorizationPolicy{
+	"GET /":                                       policyPublic,
+	"GET /meta":                                   policyPublic,
+	"GET /oembed":                                 policyPublic,
+	"GET /sitemap.xml":                            policyPublic,
+	"GET /ideas":                                  policyOptionalUser,
+	"POST /auth":                                  policyPublic,
+	"POST /auth/email":                            policyPublic,
+	"GET /auth/email/verify":                      policyPublic,
+	"GET /auth/session":                           policyUser,
+	"DELETE /auth/session":                        policyUser,
+	"POST /idea/add":                              policyUser,
+	"PATCH /idea/gaze/:ideaID":                    policyUser,
+	"PATCH /idea/react/:ideaID":                   policyUser,
+	"POST /idea/link/:ideaID":                     policyIdeaEditor,
+	"DELETE /idea/link/:ideaID":                   policyIdeaEditor,
+	"GET /idea/:ideaID/full":                      policyOptionalUser,
+	"GET /idea/:ideaID/card.png":                  policyPublic,
+	"GET /idea/:ideaID/graph":                     policyPublic,
+	"GET /idea/:ideaID/similar":                   policyPublic,
+	"GET /idea/:ideaID/revisions":                 policyOptionalUser,
+	"GET /idea/:ideaID/updates":                   policyOptionalUser,
+	"POST /ideas/:ideaID/updates":                 policyUser,
+	"POST /ideas/:ideaID/export/github":           policyUser,
+	"GET /admin/moderation":                       policyAdmin,
+	"PATCH /admin/moderation/:reportID":           policyAdmin,
+	"GET /admin/metrics/outbound":                 policyAdmin,
+	"GET /admin/metrics/database":                 policyAdmin,
+	"GET /admin/likes":                            policyAdmin,
+	"GET /admin/backups":                          policyAdmin,
+	"POST /admin/backups":                         policyAdmin,
+	"GET /admin/export/:collection":               policyAdmin,
+	"PATCH /admin/users/:userID/suspension":       policyAdmin,
+	"GET /user/export":                            policyUser,
+	"GET /user/identities":                        policyUser,
+	"DELETE /user":                                policyUser,
+	"PATCH /user":                                 policyUser,
+	"GET /users/:login":                           policyPublic,
+	"POST /attachments":                           policyUser,
+	"GET /attachments/:hash":                      policyPublic,
+	"DELETE /attachments/:hash":                   policyUser,
+	"GET /status":                                 policyPublic,
+	"GET /health/dependencies":                    policyPublic,
+	"GET /stats":                                  policyPublic,
+	"GET /stats/timeseries":                       policyPublic,
+	"POST /admin/incidents":                       policyAdmin,
+	"PATCH /admin/incidents/:incidentID":          policyAdmin,
+	"GET /ideas/gazed":                            policyUser,
+	"POST /idea/gazed/check":                      policyUser,
+	"POST /idea/bookmark/:ideaID":                 policyUser,
+	"DELETE /idea/bookmark/:ideaID":               policyUser,
+	"GET /ideas/bookmarked":                       policyUser,
+	"POST /users/:login/follow":                   policyUser,
+	"DELETE /users/:login/follow":                 policyUser,
+	"GET /feed":                                   policyUser,
+	"GET /activity":                               policyPublic,
+	"POST /idea/subscribe/:ideaID":                policyUser,
+	"DELETE /idea/subscribe/:ideaID":              policyUser,
+	"GET /user/preferences":                       policyUser,
+	"PATCH /user/preferences":                     policyUser,
+	"GET /notifications":                          policyUser,
+	"POST /notifications/read":                    policyUser,
+	"GET /ideas/featured":                         policyPublic,
+	"GET /ideas/launched":                         policyPublic,
+	"GET /ideas/trending":                         policyPublic,
+	"GET /ideas/rising":                           policyPublic,
+	"GET /ideas/of-the-day":                       policyPublic,
+	"GET /ideas/suggest":                          policyPublic,
+	"PATCH /admin/ideas/:ideaID/featured":         policyAdmin,
+	"GET /admin/audit":                            policyAdmin,
+	"GET /admin/analytics":                        policyAdmin,
+	"PUT /idea/update/:ideaID":                    policyIdeaEditor,
+	"PATCH /idea/update/:ideaID":                  policyIdeaEditor,
+	"DELETE /idea/delete/:ideaID":                 policyIdeaOwner,
+	"PATCH /idea/archive/:ideaID":                 policyIdeaOwner,
+	"PATCH /idea/unarchive/:ideaID":               policyIdeaOwner,
+	"PUT /ideas/:ideaID/launch":                   policyIdeaOwner,
+	"POST /ideas/:ideaID/images":                  policyIdeaEditor,
+	"DELETE /ideas/:ideaID/images/:hash":          policyIdeaEditor,
+	"POST /ideas/:ideaID/collaborators":           policyIdeaOwner,
+	"DELETE /ideas/:ideaID/collaborators/:userID": policyIdeaOwner,
+	"POST /orgs":                                  policyUser,
+	"GET /orgs/:orgSlug":                          policyOptionalUser,
+	"POST /orgs/:orgSlug/invites":                 policyUser,
+	"POST /orgs/:orgSlug/invites/accept":          policyUser,
+	"PATCH /orgs/:orgSlug/members/:userID":        policyUser,
+	"DELETE /orgs/:orgSlug/members/:userID":       policyUser,
+}
+
+// PolicyRouter : Registers routes with the authorization middleware of their declared policy in front
+type PolicyRouter struct {
+	router         gin.IRoutes
+	stores         Stores
+	userSessions   *SessionStore
+	databaseClient *mongo.Client
+	requestTimeout time.Duration
+	suspension     SuspensionConfig
+}
+
+func newPolicyRouter(router gin.IRoutes, stores Stores, userSessions *SessionStore, databaseClient *mongo.Client,
+	requestTimeout time.Duration, suspension SuspensionConfig) PolicyRouter {
+	return PolicyRouter{router: router, stores: stores, userSessions: userSessions, databaseClient: databaseClient,
+		requestTimeout: requestTimeout, suspension: suspension}
+}
+
+// Handle : Adds the route behind its policy and the rate limits, with the timeout and Cache-Control of the route,
+// mutating routes are audit logged and drop cached responses
+func (policyRouter PolicyRouter) Handle(method string, path string, handlers ...gin.HandlerFunc) {
+	policy, isPolicyDeclared := routePolicies[method+" "+path]
+	if isPolicyDeclared == false {
+		// Mistake in the code rather than the environment, so it panics with the stack instead of exiting
+		panic("No authorization policy declared for " + method + " " + path)
+	}
+
+	requestTimeout, hasRouteTimeout := routeTimeouts[method+" "+path]
+	if hasRouteTimeout == false {
+		requestTimeout = policyRouter.requestTimeout
+	}
+
+	handlersWithPolicy := []gin.HandlerFunc{applyCacheControl(method, path, policy), recordRequestEvent(method + " " + path),
+		limitRequestTime(requestTimeout),
+		authorize(policy, policyRouter.stores, policyRouter.userSessions, policyRouter.databaseClient, policyRouter.suspension,
+			method+" "+path),
+		limitRequestRate()}
+	if cacheDuration, isCached := routeCacheDurations[method+" "+path]; isCached == true {
+		handlersWithPolicy = append(handlersWithPolicy, cacheResponses(method+" "+path, cacheDuration))
+	}
+	if method != http.MethodGet {
+		// Audit log is in mongo, there is none without it
+		if policyRouter.databaseClient != nil {
+			handlersWithPolicy = append(handlersWithPolicy, recordMutation(policyRouter.databaseClient, method+" "+path))
+		}
+		if routesKeepingCachedResponses[method+" "+path] == false {
+			handlersWithPolicy = append(handlersWithPolicy, invalidateResponsesOnWrite())
+		}
+	}
+	handlersWithPolicy = append(handlersWithPolicy, handlers...)
+	policyRouter.router.Handle(method, path, handlersWithPolicy...)
+}
+
+// GET : Adds a GET route behind its policy
+func (policyRouter PolicyRouter) GET(path string, handlers ...gin.HandlerFunc) {
+	policyRouter.Handle(http.MethodGet, path, handlers...)
+}
+
+// POST : Adds a POST route behind its policy
+func (policyRouter PolicyRouter) POST(path string, handlers ...gin.HandlerFunc) {
+	policyRouter.Handle(http.MethodPost, path, handlers...)
+}
+
+// PUT : Adds a PUT route behind its policy
+func (policyRouter PolicyRouter) PUT(path string, handlers ...gin.HandlerFunc) {
+	policyRouter.Handle(http.MethodPut, path, handlers...)
+}
+
+// PATCH : Adds a PATCH route behind its policy
+func (policyRouter PolicyRouter) PATCH(path string, handlers ...gin.HandlerFunc) {
+	policyRouter.Handle(http.MethodPatch, path, handlers...)
+}
+
+// DELETE : Adds a DELETE route behind its policy
+func (policyRouter PolicyRouter) DELETE(path string, handlers ...gin.HandlerFunc) {
+	policyRouter.Handle(http.MethodDelete, path, handlers...)
+}
+
+// getAuthenticatedUser : User set by the policy middleware, empty for anonymous requests
+func getAuthenticatedUser(ginContext *gin.Context) GithubUserProfileStructure {
+	authenticatedUser, isUserSet := ginContext.Get(authenticatedUserKey)
+	if isUserSet == false {
+		return GithubUserProfileStructure{}
+	}
+	return authenticatedUser.(GithubUserProfileStructure)
+}
+
+// isUserOwnerOfIdea : Owners of the org an idea is published under own it too, and its members are let in with collaborators
+func isUserOwnerOfIdea(databaseContext context.Context, githubUser GithubUserProfileStructure, stores Stores,
+	databaseClient *mongo.Client, ideaID string, allowCollaborators bool) (int, error) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		return http.StatusBadRequest, errInValidatingID
+	}
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea != nil {
+		if errInFindingIdea == errNotFoundInStore {
+			return http.StatusNotFound, errInFindingIdea
+		}
+		return http.StatusServiceUnavailable, errInFindingIdea
+	}
+
+	if idea.PublisherID == githubUser.UserID || (allowCollaborators == true && isCollaboratorOfIdea(idea, githubUser.UserID) == true) {
+		return http.StatusOK, nil
+	}
+	if idea.Org == "" {
+		return http.StatusForbidden, nil
+	}
+
+	orgRole, errInFindingOrg := orgRoleOfUser(databaseContext, databaseClient, idea.Org, githubUser.UserID)
+	if errInFindingOrg != nil {
+		return http.StatusServiceUnavailable, errInFindingOrg
+	}
+	if orgRole == orgRoleOwner || (allowCollaborators == true && orgRole == orgRoleMember) {
+		return http.StatusOK, nil
+	}
+	return http.StatusForbidden, nil
+}
+
+func authorize(policy AuthorizationPolicy, stores Stores, userSessions *SessionStore, databaseClient *mongo.Client,
+	suspension SuspensionConfig, route string) gin.HandlerFunc {
+	// Suspended accounts can read unless configured otherwise, and can always leave with their data
+	checkStanding := routesAllowedWhileSuspended[route] == false &&
+		(strings.HasPrefix(route, http.MethodGet+" ") == false || suspension.BlockReads == true)
+
+	return func(ginContext *gin.Context) {
+		if policy.RequireUser == false && policy.OptionalUser == false {
+			ginContext.Next()
+			return
+		}
+		if policy.OptionalUser == true && ginContext.GetHeader("Authorization") == "" {
+			ginContext.Next()
+			return
+		}
+
+		user, errInValidatingUser := validateAndGetUser(ginContext, stores, userSessions, checkStanding)
+		if isUpstreamUnavailable(errInValidatingUser) == true {
+			abortUpstreamUnavailable(ginContext, errInValidatingUser)
+			return
+		}
+		if errSuspended, isSuspended := errInValidatingUser.(*AccountSuspendedError); isSuspended {
+			abortAccountSuspended(ginContext, errSuspended)
+			return
+		}
+		if errInValidatingUser != nil {
+			ginContext.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized,
+				"error": "Autherization failed", "errorDetails": errInValidatingUser.Error()})
+			return
+		}
+		ginContext.Set(authenticatedUserKey, user)
+
+		if policy.RequireAdmin == false && policy.RequireIdeaOwner == false {
+			ginContext.Next()
+			return
+		}
+
+		isAdmin, errInCheckingAdmin := isUserAdmin(ginContext.Request.Context(), user, stores)
+		if errInCheckingAdmin != nil {
+			ginContext.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInCheckingAdmin.Error()})
+			return
+		}
+		if isAdmin == true {
+			ginContext.Next()
+			return
+		}
+		if policy.RequireAdmin == true {
+			ginContext.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+				"error": "Only admins can access this"})
+			return
+		}
+
+		ownershipStatus, errInCheckingOwner := isUserOwnerOfIdea(ginContext.Request.Context(), user, stores, databaseClient,
+			ginContext.Param("ideaID"), policy.AllowCollaborators)
+		switch ownershipStatus {
+		case http.StatusOK:
+			ginContext.Next()
+		case http.StatusBadRequest:
+			ginContext.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+				"error": "Error, Idea id is not valid"})
+		case http.StatusNotFound:
+			ginContext.AbortWithStatusJSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, Idea does not exists"})
+		case http.StatusForbidden:
+			forbiddenError := "Only the publisher or owners of its org can change this idea"
+			if policy.AllowCollaborators == true {
+				forbiddenError = "Only the publisher, collaborators or members of its org can change this idea"
+			}
+			ginContext.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "error": forbiddenError})
+		default:
+			ginContext.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInCheckingOwner.Error()})
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testCollaboratorID = 7
+	testStrangerID     = 9
+	testAdminID        = 1
+)
+
+// policyTestFixture : Ideas of the signed in user of newMemoryTestServer, with a collaborator, a stranger and an admin
+type policyTestFixture struct {
+	sessions   map[int64]string
+	ideaID     primitive.ObjectID
+	linkedID   primitive.ObjectID
+	unlinkedID primitive.ObjectID
+	pathFilter *strings.Replacer
+}
+
+func newPolicyTestFixture(t *testing.T, stores Stores, ownerSession string) policyTestFixture {
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Second)
+	defer cancelDBContext()
+	fixture := policyTestFixture{sessions: map[int64]string{42: ownerSession}}
+
+	testSessions := newSessionStore(stores.Sessions, SessionConfig{Lifetime: time.Hour})
+	for _, user := range []UserStructure{{UserID: testCollaboratorID, Login: "hubot"}, {UserID: testStrangerID, Login: "stranger"},
+		{UserID: testAdminID, Login: "admin", Role: userRoleAdmin}} {
+		user.CreatedAt = time.Now().Unix()
+		if errInAddingUser := stores.Users.Insert(databaseContext, user); errInAddingUser != nil {
+			t.Fatal(errInAddingUser)
+		}
+		sessionToken, _, errInCreatingSession := testSessions.create(databaseContext, user, identityProviderGithub, "")
+		if errInCreatingSession != nil {
+			t.Fatal(errInCreatingSession)
+		}
+		fixture.sessions[user.UserID] = sessionToken
+	}
+
+	var errInAddingIdea error
+	for _, ideaID := range []*primitive.ObjectID{&fixture.ideaID, &fixture.linkedID, &fixture.unlinkedID} {
+		*ideaID, errInAddingIdea = stores.Ideas.Insert(databaseContext, IdeaStructure{Name: "Sardene", Slug: "sardene",
+			Description: "Ideas worth building", Publisher: "octocat", PublisherID: 42, Visibility: ideaVisibilityPublic,
+			CreatedAt: time.Now().Unix(), Collaborators: []IdeaCollaboratorStructure{{UserID: testCollaboratorID, Login: "hubot"}}})
+		if errInAddingIdea != nil {
+			t.Fatal(errInAddingIdea)
+		}
+	}
+	errInLinking := stores.Ideas.AddLink(databaseContext, fixture.ideaID, IdeaLinkStructure{Type: ideaLinkBuildsOn, IdeaID: fixture.linkedID})
+	if errInLinking != nil {
+		t.Fatal(errInLinking)
+	}
+
+	fixture.pathFilter = strings.NewReplacer(":ideaID", fixture.ideaID.Hex(), ":userID", strconv.Itoa(testCollaboratorID))
+	return fixture
+}
+
+func TestRoutesAreAuthorizedByTheirPolicy(t *testing.T) {
+	today := time.Now().UTC().Format(launchDateLayout)
+	suspendedUntil := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	// Metrics of outbound calls are read from the client set up by the command
+	outboundHTTPClient = newOutboundHTTPClient(OutboundHTTPConfig{Timeout: time.Second, Recording: GithubRecordingConfig{Mode: "off"}})
+
+	// Stranger is a signed in user who neither publishes nor edits the idea and is no admin,
+	// the allowed caller is the admin for admin routes and otherwise the publisher of the idea
+	policyCases := []struct {
+		method string
+		route  string
+		body   func(fixture policyTestFixture) string
+		policy AuthorizationPolicy
+	}{
+		{http.MethodGet, "/auth/session", nil, policyUser},
+		{http.MethodGet, "/ideas/gazed", nil, policyUser},
+		{http.MethodGet, "/user/export", nil, policyUser},
+		{http.MethodPost, "/idea/add", func(policyTestFixture) string {
+			return `{"name":"Gazers","description":"Ideas gazed at by the makers"}`
+		}, policyUser},
+
+		{http.MethodGet, "/admin/metrics/outbound", nil, policyAdmin},
+		{http.MethodPatch, "/admin/ideas/:ideaID/featured", func(policyTestFixture) string {
+			return `{"featured":true}`
+		}, policyAdmin},
+		{http.MethodPatch, "/admin/users/:userID/suspension", func(policyTestFixture) string {
+			return `{"suspended_until":` + suspendedUntil + `,"reason":"Spam"}`
+		}, policyAdmin},
+
+		{http.MethodPost, "/idea/link/:ideaID", func(fixture policyTestFixture) string {
+			return `{"type":"` + ideaLinkBlockedBy + `","idea_id":"` + fixture.unlinkedID.Hex() + `"}`
+		}, policyIdeaEditor},
+		{http.MethodDelete, "/idea/link/:ideaID", func(fixture policyTestFixture) string {
+			return `{"idea_id":"` + fixture.linkedID.Hex() + `"}`
+		}, policyIdeaEditor},
+		{http.MethodPut, "/idea/update/:ideaID", func(policyTestFixture) string {
+			return `{"description":"Ideas worth building, shared with the makers"}`
+		}, policyIdeaEditor},
+		{http.MethodPatch, "/idea/update/:ideaID", func(policyTestFixture) string {
+			return `{"repo_url":null}`
+		}, policyIdeaEditor},
+
+		{http.MethodDelete, "/idea/delete/:ideaID", nil, policyIdeaOwner},
+		{http.MethodPost, "/ideas/:ideaID/collaborators", func(policyTestFixture) string {
+			return `{"login":"admin"}`
+		}, policyIdeaOwner},
+		{http.MethodDelete, "/ideas/:ideaID/collaborators/:userID", nil, policyIdeaOwner},
+		{http.MethodPatch, "/idea/archive/:ideaID", nil, policyIdeaOwner},
+		{http.MethodPatch, "/idea/unarchive/:ideaID", nil, policyIdeaOwner},
+		{http.MethodPut, "/ideas/:ideaID/launch", func(policyTestFixture) string {
+			return `{"product_url":"https://sardene.example.com","launch_date":"` + today + `","tagline":"Ideas worth building"}`
+		}, policyIdeaOwner},
+	}
+
+	for _, policyCase := range policyCases {
+		if declaredPolicy := routePolicies[policyCase.method+" "+policyCase.route]; declaredPolicy != policyCase.policy {
+			t.Errorf("%s %s is declared %s, not %s", policyCase.method, policyCase.route, declaredPolicy.Name, policyCase.policy.Name)
+			continue
+		}
+
+		// Allowed calls change the stores, so every route gets a server of its own
+		router, stores, ownerSession := newMemoryTestServer(t)
+		fixture := newPolicyTestFixture(t, stores, ownerSession)
+		path := fixture.pathFilter.Replace(policyCase.route)
+		body := ""
+		if policyCase.body != nil {
+			body = policyCase.body(fixture)
+		}
+
+		anonymousResponse := serveTestRequest(router, policyCase.method, path, body, "")
+		if anonymousResponse.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without a user answered %d: %s", policyCase.method, policyCase.route, anonymousResponse.Code,
+				anonymousResponse.Body.String())
+		}
+
+		allowedSession := fixture.sessions[testStrangerID]
+		if policyCase.policy != policyUser {
+			strangerResponse := serveTestRequest(router, policyCase.method, path, body, fixture.sessions[testStrangerID])
+			if strangerResponse.Code != http.StatusForbidden {
+				t.Errorf("%s %s by another user answered %d: %s", policyCase.method, policyCase.route, strangerResponse.Code,
+					strangerResponse.Body.String())
+			}
+			allowedSession = fixture.sessions[42]
+			if policyCase.policy == policyAdmin {
+				allowedSession = fixture.sessions[testAdminID]
+			}
+		}
+
+		allowedResponse := serveTestRequest(router, policyCase.method, path, body, allowedSession)
+		if allowedResponse.Code < 200 || allowedResponse.Code > 299 {
+			t.Errorf("%s %s by an allowed user answered %d: %s", policyCase.method, policyCase.route, allowedResponse.Code,
+				allowedResponse.Body.String())
+		}
+	}
+}
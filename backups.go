@@ -0,0 +1,315 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	backupStatusRunning   = "running"
+	backupStatusCompleted = "completed"
+	backupStatusFailed    = "failed"
+
+	backupTriggerScheduled = "scheduled"
+	backupTriggerManual    = "manual"
+
+	// Held while a backup runs, so a manual one never overlaps the scheduled one
+	backupLeaseName = "backup"
+	backupTimeout   = time.Hour
+)
+
+// errBackupRunning : Only one backup runs at a time across instances
+var errBackupRunning = errors.New("A backup is running already")
+
+// isBackingUp : Leases are renewed by the instance holding them, so this instance checks itself as well
+var isBackingUp int32
+
+// backedUpCollections : Collections holding data users or admins made, caches and counters are left out
+var backedUpCollections = []string{
+	"users", "ideas", "likes", "follows", "bookmarks", "idea_subscriptions", "idea_revisions", "user_preferences",
+	"user_identities", "notifications", "attachments", "attachment_refs", "moderation_queue", "status_incidents", "audit_log",
+	// Restored accounts not from GitHub keep their ids only with the sequence they were numbered by
+	"sequences",
+}
+
+// BackupConfig : Schedule of backups and how long they are kept, files go to the S3 bucket under their own prefix
+type BackupConfig struct {
+	Interval  time.Duration
+	Retention time.Duration
+	S3        S3Config
+}
+
+// BackupFileStructure : One collection of a backup, as gzipped canonical extended JSON, one document per line
+type BackupFileStructure struct {
+	Collection string `json:"collection" bson:"collection"`
+	Key        string `json:"key" bson:"key"`
+	Documents  int64  `json:"documents" bson:"documents"`
+	Bytes      int64  `json:"bytes" bson:"bytes"`
+}
+
+// BackupStructure : Record of a backup in the backups collection, the files themselves are in the bucket
+type BackupStructure struct {
+	ID          primitive.ObjectID    `json:"id" bson:"_id"`
+	Status      string                `json:"status" bson:"status"`
+	Trigger     string                `json:"trigger" bson:"trigger"`
+	TriggeredBy string                `json:"triggered_by,omitempty" bson:"triggered_by,omitempty"`
+	StartedAt   int64                 `json:"started_at" bson:"started_at"`
+	FinishedAt  int64                 `json:"finished_at" bson:"finished_at"`
+	Files       []BackupFileStructure `json:"files" bson:"files"`
+	Error       string                `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+func loadBackupConfig(configLoader *ConfigLoader) BackupConfig {
+	var backupConfig BackupConfig
+
+	backupConfig.Interval = time.Duration(configLoader.Int("BACKUP_INTERVAL_HOURS", 24)) * time.Hour
+	backupConfig.Retention = time.Duration(configLoader.Int("BACKUP_RETENTION_DAYS", 30)) * 24 * time.Hour
+	if backupConfig.Interval <= 0 {
+		configLoader.Invalid("BACKUP_INTERVAL_HOURS", "should be more than 0")
+	}
+	if backupConfig.Retention <= 0 {
+		configLoader.Invalid("BACKUP_RETENTION_DAYS", "should be more than 0")
+	}
+	backupConfig.S3 = loadS3Config(configLoader)
+	backupConfig.S3.KeyPrefix = configLoader.String("BACKUP_KEY_PREFIX", "backups/")
+
+	return backupConfig
+}
+
+func runBackupJob(databaseClient *mongo.Client, backupStorage s3BlobStorage, backupConfig BackupConfig) {
+	runScheduledJob(databaseClient, "backup", backupConfig.Interval, func() error {
+		_, errInBackingUp := startBackup(databaseClient, backupStorage, backupConfig, backupTriggerScheduled, "", false)
+		return errInBackingUp
+	})
+}
+
+// startBackup : Records the backup and dumps every collection, in the background when inBackground is set.
+// Returns errBackupRunning when another backup is running
+func startBackup(databaseClient *mongo.Client, backupStorage s3BlobStorage, backupConfig BackupConfig, trigger string,
+	triggeredBy string, inBackground bool) (BackupStructure, error) {
+	backup := BackupStructure{ID: primitive.NewObjectID(), Status: backupStatusRunning, Trigger: trigger,
+		TriggeredBy: triggeredBy, StartedAt: time.Now().Unix(), Files: []BackupFileStructure{}}
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelDBContext()
+
+	if atomic.CompareAndSwapInt32(&isBackingUp, 0, 1) == false {
+		return backup, errBackupRunning
+	}
+	isLeaseAcquired, errInAcquiring := acquireLease(databaseContext, databaseClient, backupLeaseName, backupTimeout)
+	if errInAcquiring != nil || isLeaseAcquired == false {
+		atomic.StoreInt32(&isBackingUp, 0)
+		if errInAcquiring != nil {
+			return backup, errInAcquiring
+		}
+		return backup, errBackupRunning
+	}
+
+	backupsCollection := databaseClient.Database("sardene-db").Collection("backups")
+	_, errInRecording := backupsCollection.InsertOne(databaseContext, backup)
+	if errInRecording != nil {
+		_ = releaseLease(databaseContext, databaseClient, backupLeaseName)
+		atomic.StoreInt32(&isBackingUp, 0)
+		return backup, errInRecording
+	}
+
+	if inBackground == true {
+		go func() {
+			errInBackingUp := runBackup(databaseClient, backupStorage, backupConfig, backup)
+			if errInBackingUp != nil {
+				log.Println(errInBackingUp, "Backup "+backup.ID.Hex()+" failed")
+			}
+		}()
+		return backup, nil
+	}
+	return backup, runBackup(databaseClient, backupStorage, backupConfig, backup)
+}
+
+// runBackup : Dumps the collections one after the other, then records how it went and drops expired backups
+func runBackup(databaseClient *mongo.Client, backupStorage s3BlobStorage, backupConfig BackupConfig, backup BackupStructure) error {
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancelDBContext()
+	defer atomic.StoreInt32(&isBackingUp, 0)
+	defer releaseLease(context.Background(), databaseClient, backupLeaseName)
+
+	var errInBackingUp error
+	for _, collectionName := range backedUpCollections {
+		backupFile := BackupFileStructure{Collection: collectionName, Key: backup.ID.Hex() + "/" + collectionName + ".ndjson.gz"}
+		backupFile.Documents, backupFile.Bytes, errInBackingUp = backUpCollection(databaseContext, databaseClient, backupStorage,
+			collectionName, backupFile.Key)
+		if errInBackingUp != nil {
+			break
+		}
+		backup.Files = append(backup.Files, backupFile)
+	}
+
+	backup.FinishedAt = time.Now().Unix()
+	backup.Status = backupStatusCompleted
+	if errInBackingUp != nil {
+		backup.Status = backupStatusFailed
+		backup.Error = errInBackingUp.Error()
+	}
+
+	backupsCollection := databaseClient.Database("sardene-db").Collection("backups")
+	_, errInRecording := backupsCollection.ReplaceOne(databaseContext, bson.M{"_id": backup.ID}, backup)
+	if errInRecording != nil {
+		log.Println(errInRecording, "Failed to record the end of backup "+backup.ID.Hex())
+	}
+
+	// Expired backups are only dropped once a newer one made it, so a broken bucket never leaves none
+	if errInBackingUp == nil {
+		errInExpiring := removeExpiredBackups(databaseContext, databaseClient, backupStorage, backupConfig, backup.ID)
+		if errInExpiring != nil {
+			log.Println(errInExpiring, "Failed to remove expired backups")
+		}
+	}
+
+	return errInBackingUp
+}
+
+// backUpCollection : Writes the collection gzipped to a temporary file while hashing it, as the upload has to be signed with the hash
+func backUpCollection(databaseContext context.Context, databaseClient *mongo.Client, backupStorage s3BlobStorage,
+	collectionName string, backupKey string) (int64, int64, error) {
+	backupFile, errInCreatingFile := ioutil.TempFile("", "sardene-backup-*.ndjson.gz")
+	if errInCreatingFile != nil {
+		return 0, 0, errInCreatingFile
+	}
+	defer os.Remove(backupFile.Name())
+	defer backupFile.Close()
+
+	backupHash := sha256.New()
+	gzipWriter := gzip.NewWriter(io.MultiWriter(backupFile, backupHash))
+
+	documentsCursor, errInFinding := databaseClient.Database("sardene-db").Collection(collectionName).Find(databaseContext,
+		bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if errInFinding != nil {
+		return 0, 0, errInFinding
+	}
+	defer documentsCursor.Close(databaseContext)
+
+	var documents int64
+	for documentsCursor.Next(databaseContext) {
+		// Canonical extended JSON keeps object ids, dates and number types, so mongoimport restores them as they were
+		documentInJSON, errInEncoding := bson.MarshalExtJSON(documentsCursor.Current, true, false)
+		if errInEncoding != nil {
+			return documents, 0, errInEncoding
+		}
+		_, errInWriting := gzipWriter.Write(append(documentInJSON, '\n'))
+		if errInWriting != nil {
+			return documents, 0, errInWriting
+		}
+		documents++
+	}
+	if errInCursor := documentsCursor.Err(); errInCursor != nil {
+		return documents, 0, errInCursor
+	}
+	if errInClosing := gzipWriter.Close(); errInClosing != nil {
+		return documents, 0, errInClosing
+	}
+
+	errInUploading := backupStorage.SaveFile(backupKey, "application/gzip", backupFile, hex.EncodeToString(backupHash.Sum(nil)))
+	if errInUploading != nil {
+		return documents, 0, errInUploading
+	}
+	fileInfo, errInStat := backupFile.Stat()
+	if errInStat != nil {
+		return documents, 0, errInStat
+	}
+	return documents, fileInfo.Size(), nil
+}
+
+// removeExpiredBackups : Deletes the files and records of backups older than the retention, keepID is the newest one
+func removeExpiredBackups(databaseContext context.Context, databaseClient *mongo.Client, backupStorage s3BlobStorage,
+	backupConfig BackupConfig, keepID primitive.ObjectID) error {
+	// Retention is longer than backupTimeout, so backups still running by then were cut off and are expired as well
+	backupsCollection := databaseClient.Database("sardene-db").Collection("backups")
+	expiredBefore := time.Now().Add(-backupConfig.Retention).Unix()
+
+	expiredCursor, errInFinding := backupsCollection.Find(databaseContext, bson.M{
+		"_id":        bson.M{"$ne": keepID},
+		"started_at": bson.M{"$lt": expiredBefore},
+	}, options.Find())
+	if errInFinding != nil {
+		return errInFinding
+	}
+	defer expiredCursor.Close(databaseContext)
+
+	for expiredCursor.Next(databaseContext) {
+		var expiredBackup BackupStructure
+		errInDecoding := expiredCursor.Decode(&expiredBackup)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+
+		for _, backupFile := range expiredBackup.Files {
+			errInRemoving := backupStorage.Remove(backupFile.Key)
+			if errInRemoving != nil {
+				return errInRemoving
+			}
+		}
+		_, errInDeleting := backupsCollection.DeleteOne(databaseContext, bson.M{"_id": expiredBackup.ID})
+		if errInDeleting != nil {
+			return errInDeleting
+		}
+	}
+
+	return expiredCursor.Err()
+}
+
+func triggerBackup(ginContext *gin.Context, databaseClient *mongo.Client, backupStorage s3BlobStorage, backupConfig BackupConfig) {
+	admin := getAuthenticatedUser(ginContext)
+
+	backup, errInStarting := startBackup(databaseClient, backupStorage, backupConfig, backupTriggerManual, admin.Login, true)
+	if errInStarting == errBackupRunning {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "A backup is running already, try again once it finishes"})
+		return
+	}
+	if errInStarting != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in starting backup", "errorDetails": errInStarting.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusAccepted, gin.H{"status": http.StatusAccepted, "data": backup})
+}
+
+func getBackups(ginContext *gin.Context, databaseClient *mongo.Client) {
+	backupsCollection := databaseClient.Database("sardene-db").Collection("backups")
+	databaseContext := ginContext.Request.Context()
+
+	pagination, errInPagination := getPaginationFromQuery(ginContext, 20, 100)
+	if errInPagination != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "error": errInPagination.Error()})
+		return
+	}
+
+	backups := []BackupStructure{}
+	pageTotals, errInFinding := findPageWithTotals(databaseContext, backupsCollection, bson.M{},
+		bson.D{{Key: "started_at", Value: -1}, {Key: "_id", Value: -1}}, pagination, &backups)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": backups, "count": len(backups),
+		"page": pagination.Page, "limit": pagination.Limit,
+		"total_count": pageTotals.TotalCount, "total_pages": pageTotals.TotalPages})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdeaBookmarkStructure : Structure of bookmark in bookmarks collection, private to the user and left out of rankings
+type IdeaBookmarkStructure struct {
+	UserID    int64              `json:"user_id" bson:"user_id"`
+	IdeaID    primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+	CreatedAt int64              `json:"created_at" bson:"created_at"`
+}
+
+func bookmarkIdea(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	user := getAuthenticatedUser(ginContext)
+	databaseContext := ginContext.Request.Context()
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea != nil || isIdeaVisibleTo(idea, user.UserID) == false {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+
+	bookmarksCollection := databaseClient.Database("sardene-db").Collection("bookmarks")
+
+	bookmarkToAdd := IdeaBookmarkStructure{UserID: user.UserID, IdeaID: hexIdeaID, CreatedAt: time.Now().Unix()}
+	_, errInAdding := bookmarksCollection.InsertOne(databaseContext, bookmarkToAdd)
+	if errInAdding != nil {
+		if isDuplicateKeyError(errInAdding) {
+			ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+				"error": "Error, User already bookmarked the idea"})
+			return
+		}
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "Idea bookmarked successfully"})
+}
+
+func removeBookmark(ginContext *gin.Context, databaseClient *mongo.Client, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	user := getAuthenticatedUser(ginContext)
+	bookmarksCollection := databaseClient.Database("sardene-db").Collection("bookmarks")
+	databaseContext := ginContext.Request.Context()
+
+	resultOfDeleting, errInDeleting := bookmarksCollection.DeleteOne(databaseContext, bson.M{"user_id": user.UserID, "idea_id": hexIdeaID})
+	if errInDeleting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+	if resultOfDeleting.DeletedCount == 0 {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Bookmark of idea not found"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Bookmark removed successfully"})
+}
+
+func getUserBookmarkedIdeas(ginContext *gin.Context, databaseClient *mongo.Client) {
+	user := getAuthenticatedUser(ginContext)
+	bookmarksCollection := databaseClient.Database("sardene-db").Collection("bookmarks")
+	databaseContext := ginContext.Request.Context()
+
+	bookmarksOptions := options.Find().SetSort(bson.M{"created_at": -1})
+	bookmarksCursor, errInFinding := bookmarksCollection.Find(databaseContext, bson.M{"user_id": user.UserID}, bookmarksOptions)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	defer bookmarksCursor.Close(databaseContext)
+
+	bookmarks := []*IdeaBookmarkStructure{}
+	for bookmarksCursor.Next(databaseContext) {
+		var bookmark IdeaBookmarkStructure
+		errInDecoding := bookmarksCursor.Decode(&bookmark)
+		if errInDecoding != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in decoding database", "errorDetails": errInDecoding.Error()})
+			return
+		}
+		bookmarks = append(bookmarks, &bookmark)
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": bookmarks, "count": len(bookmarks)})
+}
+
+// deleteUserBookmarks : Bookmarks are private, nothing of them is kept when the account goes
+func deleteUserBookmarks(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	bookmarksCollection := databaseClient.Database("sardene-db").Collection("bookmarks")
+
+	_, errInDeleting := bookmarksCollection.DeleteMany(databaseContext, bson.M{"user_id": userID})
+	return errInDeleting
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BrandingConfig : Deployment specific names and links, so self hosted instances can present themselves
+type BrandingConfig struct {
+	APIName        string `json:"api_name"`
+	DocsURL        string `json:"docs_url"`
+	WelcomeMessage string `json:"welcome_message"`
+	FrontendOrigin string `json:"frontend_origin"`
+}
+
+func loadBrandingConfig(configLoader *ConfigLoader, environment string) BrandingConfig {
+	var brandingConfig BrandingConfig
+
+	brandingConfig.APIName = configLoader.String("API_NAME", "Sardene API")
+	brandingConfig.DocsURL = configLoader.String("API_DOCS_URL", "https://github.com/M-ZubairAhmed/Sardene-API")
+
+	defaultFrontendOrigin := "https://sardene.netlify.app"
+	if environment == "dev" {
+		defaultFrontendOrigin = "http://localhost:3000"
+	}
+	brandingConfig.FrontendOrigin = configLoader.String("FRONTEND_ORIGIN", defaultFrontendOrigin)
+
+	defaultWelcomeMessage := "Welcome to " + brandingConfig.APIName + ", \nServer running successfully" +
+		"\nVisit " + brandingConfig.DocsURL + " for documentation."
+	brandingConfig.WelcomeMessage = configLoader.String("API_WELCOME_MESSAGE", defaultWelcomeMessage)
+
+	return brandingConfig
+}
+
+func getMeta(ginContext *gin.Context, brandingConfig BrandingConfig) {
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": brandingConfig})
+}
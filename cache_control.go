@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeSharedCacheDurations : How long browsers and CDNs in front of the API may reuse answers of public routes.
+// Only answers to anonymous callers are shareable, signed in callers get their own flags in the same routes
+var routeSharedCacheDurations = map[string]time.Duration{
+	"GET /meta":                  5 * time.Minute,
+	"GET /oembed":                5 * time.Minute,
+	"GET /sitemap.xml":           time.Hour,
+	"GET /ideas":                 30 * time.Second,
+	"GET /ideas/featured":        time.Minute,
+	"GET /ideas/launched":        time.Minute,
+	"GET /ideas/trending":        time.Minute,
+	"GET /ideas/rising":          time.Minute,
+	"GET /ideas/of-the-day":      5 * time.Minute,
+	"GET /activity":              time.Minute,
+	"GET /stats":                 5 * time.Minute,
+	"GET /stats/timeseries":      5 * time.Minute,
+	"GET /users/:login":          time.Minute,
+	"GET /idea/:ideaID/card.png": 5 * time.Minute,
+	"GET /idea/:ideaID/graph":    time.Minute,
+	"GET /idea/:ideaID/similar":  5 * time.Minute,
+}
+
+// cacheControlWriter : Errors are never reused, whatever the route allows. gin sets the status apart from the writer,
+// so it is checked when the headers are about to be sent
+type cacheControlWriter struct {
+	gin.ResponseWriter
+}
+
+func (responseWriter *cacheControlWriter) keepErrorsOutOfCaches() {
+	if responseWriter.Written() == false && responseWriter.Status() >= http.StatusBadRequest {
+		responseWriter.Header().Set("Cache-Control", "no-store")
+	}
+}
+
+func (responseWriter *cacheControlWriter) WriteHeaderNow() {
+	responseWriter.keepErrorsOutOfCaches()
+	responseWriter.ResponseWriter.WriteHeaderNow()
+}
+
+func (responseWriter *cacheControlWriter) Write(data []byte) (int, error) {
+	responseWriter.keepErrorsOutOfCaches()
+	return responseWriter.ResponseWriter.Write(data)
+}
+
+func (responseWriter *cacheControlWriter) WriteString(data string) (int, error) {
+	responseWriter.keepErrorsOutOfCaches()
+	return responseWriter.ResponseWriter.WriteString(data)
+}
+
+// applyCacheControl : Routes of signed in users are never stored, other reads are revalidated unless they
+// have a shared cache duration. Handlers may still set their own Cache-Control
+func applyCacheControl(method string, path string, policy AuthorizationPolicy) gin.HandlerFunc {
+	routeCacheControl := "no-store"
+	if policy.RequireUser == false && method == http.MethodGet {
+		routeCacheControl = "no-cache"
+		if sharedCacheDuration, isShared := routeSharedCacheDurations[method+" "+path]; isShared == true {
+			routeCacheControl = "public, max-age=" + strconv.Itoa(int(sharedCacheDuration.Seconds()))
+		}
+	}
+
+	return func(ginContext *gin.Context) {
+		responseCacheControl := routeCacheControl
+		if policy.OptionalUser == true {
+			ginContext.Writer.Header().Add("Vary", "Authorization")
+			if ginContext.GetHeader("Authorization") != "" || ginContext.GetHeader(apiKeyHeader) != "" {
+				responseCacheControl = "private, no-store"
+			}
+		}
+
+		ginContext.Header("Cache-Control", responseCacheControl)
+		ginContext.Writer = &cacheControlWriter{ginContext.Writer}
+		ginContext.Next()
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// listMadeAmong : Ideas among ideaIDs which the user is a maker of, makers are only kept in mongo
+func listMadeAmong(databaseContext context.Context, databaseClient *mongo.Client, userID int64,
+	ideaIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if databaseClient == nil {
+		return nil, nil
+	}
+	makersCollection := databaseClient.Database("sardene-db").Collection("makers")
+	var madeIdeaIDs []primitive.ObjectID
+
+	madeIdeas, errInFinding := makersCollection.Distinct(databaseContext, "ideaID",
+		bson.M{"userID": userID, "ideaID": bson.M{"$in": ideaIDs}}, options.Distinct())
+	if errInFinding != nil {
+		return madeIdeaIDs, errInFinding
+	}
+	for _, madeIdea := range madeIdeas {
+		if madeIdeaID, isObjectID := madeIdea.(primitive.ObjectID); isObjectID {
+			madeIdeaIDs = append(madeIdeaIDs, madeIdeaID)
+		}
+	}
+
+	return madeIdeaIDs, nil
+}
+
+// annotateCallerFlags : Sets gazed_by_me and made_by_me of every idea with two queries for the whole page
+func annotateCallerFlags(databaseContext context.Context, databaseClient *mongo.Client, stores Stores, userID int64,
+	ideas []IdeaStructure) error {
+	if len(ideas) == 0 {
+		return nil
+	}
+
+	ideaIDs := make([]primitive.ObjectID, len(ideas))
+	for index, idea := range ideas {
+		ideaIDs[index] = idea.ID
+	}
+
+	gazedIdeaIDs, errInFindingGazed := stores.Likes.ListGazedAmong(databaseContext, userID, ideaIDs)
+	if errInFindingGazed != nil {
+		return errInFindingGazed
+	}
+	madeIdeaIDs, errInFindingMade := listMadeAmong(databaseContext, databaseClient, userID, ideaIDs)
+	if errInFindingMade != nil {
+		return errInFindingMade
+	}
+
+	isGazed := make(map[primitive.ObjectID]bool)
+	for _, gazedIdeaID := range gazedIdeaIDs {
+		isGazed[gazedIdeaID] = true
+	}
+	isMade := make(map[primitive.ObjectID]bool)
+	for _, madeIdeaID := range madeIdeaIDs {
+		isMade[madeIdeaID] = true
+	}
+
+	for index := range ideas {
+		gazedByMe := isGazed[ideas[index].ID]
+		madeByMe := isMade[ideas[index].ID]
+		ideas[index].GazedByMe = &gazedByMe
+		ideas[index].MadeByMe = &madeByMe
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const upstreamUnavailableCode = "UPSTREAM_UNAVAILABLE"
+
+// UpstreamUnavailableError : Outside service could not be reached, failed with 5xx or its circuit is open
+type UpstreamUnavailableError struct {
+	Host  string
+	Cause string
+}
+
+func (errUpstream *UpstreamUnavailableError) Error() string {
+	return "Request to " + errUpstream.Host + " failed: " + errUpstream.Cause
+}
+
+func isUpstreamUnavailable(errInRequest error) bool {
+	_, isUpstreamError := errInRequest.(*UpstreamUnavailableError)
+	return isUpstreamError
+}
+
+// abortUpstreamUnavailable : Answers 503 with a code clients can tell apart from failures of the API itself
+func abortUpstreamUnavailable(ginContext *gin.Context, errInRequest error) {
+	ginContext.Header("Retry-After", strconv.Itoa(int(outboundHTTPClient.config.BreakerCooldown.Seconds())))
+	ginContext.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+		"code": upstreamUnavailableCode, "error": "GitHub is not reachable right now, try again shortly",
+		"errorDetails": errInRequest.Error()})
+}
+
+// hostCircuit : Failures of one host in a row, requests to it are refused while the circuit is open
+type hostCircuit struct {
+	consecutiveFailures int
+	openUntil           time.Time
+	// After the cooldown one trial request goes through, its result closes or reopens the circuit
+	isTrialSent bool
+}
+
+// CircuitBreaker : Stops calling a host which keeps failing, so requests fail right away instead of
+// waiting on timeouts, and lets a trial request through once the cooldown is over
+type CircuitBreaker struct {
+	mutex          sync.Mutex
+	failuresToOpen int
+	cooldown       time.Duration
+	circuitsOfHost map[string]*hostCircuit
+}
+
+func newCircuitBreaker(failuresToOpen int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{failuresToOpen: failuresToOpen, cooldown: cooldown, circuitsOfHost: make(map[string]*hostCircuit)}
+}
+
+func (breaker *CircuitBreaker) circuitOf(host string) *hostCircuit {
+	circuit, isKnownHost := breaker.circuitsOfHost[host]
+	if isKnownHost == false {
+		circuit = &hostCircuit{}
+		breaker.circuitsOfHost[host] = circuit
+	}
+	return circuit
+}
+
+// Allow : False while the circuit of the host is open, a 0 failure limit turns the breaker off
+func (breaker *CircuitBreaker) Allow(host string) bool {
+	if breaker.failuresToOpen <= 0 {
+		return true
+	}
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	circuit := breaker.circuitOf(host)
+	if circuit.consecutiveFailures < breaker.failuresToOpen {
+		return true
+	}
+	if time.Now().Before(circuit.openUntil) || circuit.isTrialSent == true {
+		return false
+	}
+	circuit.isTrialSent = true
+	return true
+}
+
+// Record : Success closes the circuit, failures past the limit open it for the cooldown
+func (breaker *CircuitBreaker) Record(host string, isFailure bool) {
+	if breaker.failuresToOpen <= 0 {
+		return
+	}
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	circuit := breaker.circuitOf(host)
+	circuit.isTrialSent = false
+	if isFailure == false {
+		circuit.consecutiveFailures = 0
+		return
+	}
+
+	circuit.consecutiveFailures++
+	if circuit.consecutiveFailures >= breaker.failuresToOpen {
+		circuit.openUntil = time.Now().Add(breaker.cooldown)
+	}
+}
+
+// Abandon : Request was given up by the caller, a trial request can be sent again
+func (breaker *CircuitBreaker) Abandon(host string) {
+	if breaker.failuresToOpen <= 0 {
+		return
+	}
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.circuitOf(host).isTrialSent = false
+}
+
+// IsOpen : True while requests to the host are refused
+func (breaker *CircuitBreaker) IsOpen(host string) bool {
+	if breaker.failuresToOpen <= 0 {
+		return false
+	}
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	circuit := breaker.circuitOf(host)
+	return circuit.consecutiveFailures >= breaker.failuresToOpen && time.Now().Before(circuit.openUntil)
+}
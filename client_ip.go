@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProxyConfig : Proxies in front of the API whose forwarding headers are believed
+type ProxyConfig struct {
+	TrustedProxies []*net.IPNet
+	ClientIPHeader string
+}
+
+func loadProxyConfig(configLoader *ConfigLoader) ProxyConfig {
+	var proxyConfig ProxyConfig
+
+	// Heroku's router connects from 10.0.0.0/8, nginx on the same host from 127.0.0.1
+	for _, trustedProxy := range configLoader.List("TRUSTED_PROXIES", "") {
+		proxyNetwork, isValid := parseIPNetwork(trustedProxy)
+		if isValid == false {
+			configLoader.Invalid("TRUSTED_PROXIES", "should be IPs or CIDR ranges, got "+trustedProxy)
+			continue
+		}
+		proxyConfig.TrustedProxies = append(proxyConfig.TrustedProxies, proxyNetwork)
+	}
+
+	// Cloudflare sends the address it saw in CF-Connecting-IP
+	proxyConfig.ClientIPHeader = configLoader.String("CLIENT_IP_HEADER", "")
+	if proxyConfig.ClientIPHeader != "" && len(proxyConfig.TrustedProxies) == 0 {
+		configLoader.Invalid("CLIENT_IP_HEADER", "is only read from TRUSTED_PROXIES, set them too")
+	}
+
+	return proxyConfig
+}
+
+func parseIPNetwork(value string) (*net.IPNet, bool) {
+	if strings.Contains(value, "/") == false {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, false
+		}
+		if ip.To4() != nil {
+			value = value + "/32"
+		} else {
+			value = value + "/128"
+		}
+	}
+
+	_, ipNetwork, errInParsing := net.ParseCIDR(value)
+	return ipNetwork, errInParsing == nil
+}
+
+func (proxyConfig ProxyConfig) isTrusted(ip net.IP) bool {
+	for _, trustedProxy := range proxyConfig.TrustedProxies {
+		if trustedProxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// realClientIP : Walks X-Forwarded-For from the right and stops at the first address that is not a trusted proxy,
+// entries left of it were written by the client and can be anything
+func realClientIP(request *http.Request, proxyConfig ProxyConfig) string {
+	remoteIP, _, errInSplitting := net.SplitHostPort(strings.TrimSpace(request.RemoteAddr))
+	if errInSplitting != nil {
+		remoteIP = strings.TrimSpace(request.RemoteAddr)
+	}
+
+	parsedRemoteIP := net.ParseIP(remoteIP)
+	if parsedRemoteIP == nil || proxyConfig.isTrusted(parsedRemoteIP) == false {
+		return remoteIP
+	}
+
+	if proxyConfig.ClientIPHeader != "" {
+		headerIP := net.ParseIP(strings.TrimSpace(request.Header.Get(proxyConfig.ClientIPHeader)))
+		if headerIP != nil {
+			return headerIP.String()
+		}
+	}
+
+	clientIP := remoteIP
+	forwardedFor := strings.Split(request.Header.Get("X-Forwarded-For"), ",")
+	for index := len(forwardedFor) - 1; index >= 0; index-- {
+		forwardedIP := net.ParseIP(strings.TrimSpace(forwardedFor[index]))
+		if forwardedIP == nil {
+			break
+		}
+		clientIP = forwardedIP.String()
+		if proxyConfig.isTrusted(forwardedIP) == false {
+			break
+		}
+	}
+
+	return clientIP
+}
+
+// resolveClientIP : Replaces the remote address with the real client IP, so ClientIP, the request log
+// and the vote analysis all see the same address. The router must not read forwarding headers itself.
+func resolveClientIP(proxyConfig ProxyConfig) gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		clientIP := realClientIP(ginContext.Request, proxyConfig)
+		ginContext.Request.RemoteAddr = net.JoinHostPort(clientIP, "0")
+		ginContext.Next()
+	}
+}
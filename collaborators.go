@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Upper bound of collaborators on one idea
+const maxCollaboratorsOfIdea = 20
+
+// IdeaCollaboratorStructure : Structure of a user who can edit the idea along with its publisher
+type IdeaCollaboratorStructure struct {
+	UserID  int64  `json:"user_id" bson:"user_id"`
+	Login   string `json:"login" bson:"login"`
+	AddedAt int64  `json:"added_at" bson:"added_at"`
+}
+
+// IdeaCollaboratorInput : Structure for incoming collaborator, users are found by their GitHub login
+type IdeaCollaboratorInput struct {
+	Login string `json:"login"`
+}
+
+func isCollaboratorOfIdea(idea IdeaStructure, userID int64) bool {
+	for _, collaborator := range idea.Collaborators {
+		if collaborator.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func addIdeaCollaborator(ginContext *gin.Context, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	var jsonInput IdeaCollaboratorInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil || len(strings.TrimSpace(jsonInput.Login)) == 0 {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Login of the collaborator is not provided in the post"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	// Only users who have signed in once can be added, so the login belongs to a known user id
+	collaboratorUser, errInFindingUser := stores.Users.FindByLogin(databaseContext, strings.TrimSpace(jsonInput.Login))
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, No user with this login has signed in"})
+			return
+		}
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUser.Error()})
+		return
+	}
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea != nil {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Idea not found"})
+		return
+	}
+	if idea.PublisherID == collaboratorUser.UserID {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Publisher of the idea cannot be its collaborator"})
+		return
+	}
+
+	collaborator := IdeaCollaboratorStructure{
+		UserID:  collaboratorUser.UserID,
+		Login:   collaboratorUser.Login,
+		AddedAt: time.Now().Unix(),
+	}
+	errInAdding := stores.Ideas.AddCollaborator(databaseContext, hexIdeaID, collaborator, maxCollaboratorsOfIdea)
+	if errInAdding != nil {
+		if errInAdding == errDuplicateInStore {
+			ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+				"error": "Error, User is already a collaborator or the idea has " + strconv.Itoa(maxCollaboratorsOfIdea) + " collaborators"})
+			return
+		}
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInAdding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": collaborator})
+}
+
+func removeIdeaCollaborator(ginContext *gin.Context, stores Stores, ideaID string, collaboratorID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	collaboratorUserID, errInUserID := strconv.ParseInt(collaboratorID, 10, 64)
+	if errInUserID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, User id is not valid"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	errInRemoving := stores.Ideas.RemoveCollaborator(databaseContext, hexIdeaID, collaboratorUserID)
+	if errInRemoving != nil {
+		if errInRemoving == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, Collaborator of idea not found"})
+			return
+		}
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInRemoving.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Collaborator removed successfully"})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const commandsUsage = `Usage: sardene-api [-config file] [command]
+
+Commands:
+  serve                 Run the API, the default when no command is given
+  migrate up            Apply pending database migrations and exit
+  seed                  Fill the database with fake users, ideas and gazes, only with ENVIRONMENT=dev
+  create-admin <login>  Make the GitHub user an admin, adding them if they never signed in
+
+Flags:
+`
+
+func main() {
+	configFilePath := flag.String("config", "", "Path of a .env or yaml config file, .env is read if it exists")
+	// Flags of the commands from before there were commands, deploy scripts still pass them
+	migrateOnly := flag.Bool("migrate", false, "Same as the migrate up command")
+	seedOnly := flag.Bool("seed", false, "Same as the seed command")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), commandsUsage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command, arguments := "serve", flag.Args()
+	if len(arguments) > 0 {
+		command, arguments = arguments[0], arguments[1:]
+	}
+	if *migrateOnly == true {
+		command, arguments = "migrate", []string{"up"}
+	}
+	if *seedOnly == true {
+		command, arguments = "seed", nil
+	}
+
+	runCommand := map[string]func(Config, []string) error{
+		"serve": func(config Config, arguments []string) error {
+			serve(config)
+			return nil
+		},
+		"migrate":      runMigrateCommand,
+		"seed":         runSeedCommand,
+		"create-admin": runCreateAdminCommand,
+	}[command]
+	if runCommand == nil {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config, errInConfig := loadConfig(*configFilePath)
+	if errInConfig != nil {
+		log.Fatal(errInConfig)
+	}
+	outboundHTTPClient = newOutboundHTTPClient(config.OutboundHTTP)
+
+	errInCommand := runCommand(config, arguments)
+	if errInCommand != nil {
+		log.Fatal(errInCommand)
+	}
+}
+
+// openDatabase : Mongo client of the config, nil for the memory driver which runs without mongo
+func openDatabase(config Config) *mongo.Client {
+	if config.DatabaseDriver == "memory" {
+		return nil
+	}
+	return connectToDatabase(config.DatabaseURL, config.Mongo, config.DatabaseConnectTimeout, config.DatabaseConnectRetries)
+}
+
+// openStores : Ideas, users, gazes and sessions can live in postgres or in memory, the rest of the features still need mongo
+func openStores(config Config, databaseClient *mongo.Client) Stores {
+	switch config.DatabaseDriver {
+	case "postgres":
+		log.Println("Storing ideas, users and gazes in postgres")
+		return newPostgresStores(connectToPostgres(config.PostgresURL))
+	case "memory":
+		log.Println("Storing ideas, users and gazes in memory, they are lost when the API stops")
+		return newMemoryStores()
+	}
+	return newMongoStores(databaseClient)
+}
+
+// Storages : Where attachments and backups are kept, nil for a feature which is off or runs without mongo
+type Storages struct {
+	Attachments BlobStorage
+	Backups     *s3BlobStorage
+}
+
+// openStorages : Built once for serve, the jobs and the routes share them
+func openStorages(config Config, databaseClient *mongo.Client) Storages {
+	var storages Storages
+	if databaseClient == nil {
+		return storages
+	}
+
+	if config.Features.Attachments == true {
+		attachmentStorage, errInAttachmentStorage := newBlobStorage(databaseClient, config.Attachment)
+		if errInAttachmentStorage != nil {
+			log.Fatal(errInAttachmentStorage, "Failed to open attachment storage")
+		}
+		storages.Attachments = attachmentStorage
+	}
+	if config.Features.Backups == true {
+		backupStorage, errInBackupStorage := newS3BlobStorage(config.Backup.S3)
+		if errInBackupStorage != nil {
+			log.Fatal(errInBackupStorage, "Failed to open backup storage")
+		}
+		storages.Backups = &backupStorage
+	}
+	return storages
+}
+
+// requirePersistentDatabase : Commands changing data are pointless when it is gone as soon as they exit
+func requirePersistentDatabase(config Config, command string) error {
+	if config.DatabaseDriver == "memory" {
+		return fmt.Errorf("%s with DB_DRIVER memory would be lost as soon as it finishes", command)
+	}
+	return nil
+}
+
+func runMigrateCommand(config Config, arguments []string) error {
+	// Migrations only go forward, up is asked for so a down can be added without changing what migrate means
+	if len(arguments) != 1 || arguments[0] != "up" {
+		return fmt.Errorf("Usage: sardene-api migrate up")
+	}
+	if errInDriver := requirePersistentDatabase(config, "Migrating"); errInDriver != nil {
+		return errInDriver
+	}
+
+	return runMigrations(openDatabase(config), config.Migration)
+}
+
+func runSeedCommand(config Config, arguments []string) error {
+	if len(arguments) != 0 {
+		return fmt.Errorf("Usage: sardene-api seed")
+	}
+	if errInDriver := requirePersistentDatabase(config, "Seeding"); errInDriver != nil {
+		return errInDriver
+	}
+
+	// Seeded documents are written in their latest shape, older ones have to be migrated first
+	databaseClient := openDatabase(config)
+	errInMigrating := runMigrations(databaseClient, config.Migration)
+	if errInMigrating != nil {
+		return errInMigrating
+	}
+
+	return seedDatabase(openStores(config, databaseClient), config.Environment)
+}
+
+func runCreateAdminCommand(config Config, arguments []string) error {
+	if len(arguments) != 1 || strings.TrimSpace(arguments[0]) == "" {
+		return fmt.Errorf("Usage: sardene-api create-admin <login>")
+	}
+	if errInDriver := requirePersistentDatabase(config, "Creating an admin"); errInDriver != nil {
+		return errInDriver
+	}
+	login := strings.TrimSpace(arguments[0])
+
+	stores := openStores(config, openDatabase(config))
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelDBContext()
+
+	userInDB, errInFindingUser := stores.Users.FindByLogin(databaseContext, login)
+	if errInFindingUser == errNotFoundInStore {
+		// Users are keyed by their GitHub id, so one who never signed in is looked up on GitHub first
+		githubUser, errInLookingUp := getPublicGithubProfile(databaseContext, login)
+		if errInLookingUp != nil {
+			return errInLookingUp
+		}
+		userInDB = UserStructure{UserID: githubUser.UserID, Login: githubUser.Login, Name: githubUser.Name,
+			CreatedAt: time.Now().Unix()}
+
+		errInAddingUser := stores.Users.Insert(databaseContext, userInDB)
+		if errInAddingUser != nil && errInAddingUser != errDuplicateInStore {
+			return errInAddingUser
+		}
+		errInFindingUser = nil
+	}
+	if errInFindingUser != nil {
+		return errInFindingUser
+	}
+
+	if userInDB.Role == userRoleAdmin {
+		log.Println(userInDB.Login, "is an admin already")
+		return nil
+	}
+	errInUpdatingRole := stores.Users.UpdateRole(databaseContext, userInDB.UserID, userRoleAdmin)
+	if errInUpdatingRole != nil {
+		return errInUpdatingRole
+	}
+
+	log.Println(userInDB.Login, "is an admin now, user id", userInDB.UserID)
+	return nil
+}
+
+// getPublicGithubProfile : Profile anyone can see of a GitHub login, no token needed
+func getPublicGithubProfile(requestContext context.Context, login string) (GithubUserProfileStructure, error) {
+	var githubProfile GithubUserProfileStructure
+
+	requestUser, errInRequestingUser := http.NewRequestWithContext(requestContext, "GET",
+		"https://api.github.com/users/"+url.PathEscape(login), nil)
+	if errInRequestingUser != nil {
+		return githubProfile, errInRequestingUser
+	}
+	requestUser.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	responseWithUser, errInResponseFromGithub := outboundHTTPClient.DoRetrying(requestUser)
+	if errInResponseFromGithub != nil {
+		return githubProfile, errInResponseFromGithub
+	}
+	defer responseWithUser.Body.Close()
+	if responseWithUser.StatusCode == http.StatusNotFound {
+		return githubProfile, fmt.Errorf("No GitHub user with login %q", login)
+	}
+	if responseWithUser.StatusCode != http.StatusOK {
+		return githubProfile, fmt.Errorf("GitHub answered %s looking up %q", responseWithUser.Status, login)
+	}
+
+	responseBytesWithUser, errInResponseBody := ioutil.ReadAll(responseWithUser.Body)
+	if errInResponseBody != nil {
+		return githubProfile, errInResponseBody
+	}
+	errInDecodingJSON := json.Unmarshal(responseBytesWithUser, &githubProfile)
+	return githubProfile, errInDecodingJSON
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipResponseWriter : Compression starts with the first byte of the body, responses without one are left as they are
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	gzipWriter   *gzip.Writer
+	isHeadMethod bool
+	// Set once the first write decided, nil gzipWriter afterwards means the body is sent uncompressed
+	isDecided bool
+}
+
+// isBodyAllowed : Statuses whose responses carry no body, so a gzip header and footer would be all there is
+func isBodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+func (responseWriter *gzipResponseWriter) startCompressing() {
+	responseWriter.isDecided = true
+	// Headers written already cannot announce the encoding anymore
+	if responseWriter.isHeadMethod || responseWriter.ResponseWriter.Written() ||
+		isBodyAllowed(responseWriter.ResponseWriter.Status()) == false {
+		return
+	}
+
+	responseWriter.Header().Set("Content-Encoding", "gzip")
+	// Length of compressed body is not known upfront
+	responseWriter.Header().Del("Content-Length")
+	responseWriter.gzipWriter = gzip.NewWriter(responseWriter.ResponseWriter)
+}
+
+func (responseWriter *gzipResponseWriter) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return responseWriter.ResponseWriter.Write(data)
+	}
+	if responseWriter.isDecided == false {
+		responseWriter.startCompressing()
+	}
+	if responseWriter.gzipWriter == nil {
+		return responseWriter.ResponseWriter.Write(data)
+	}
+	return responseWriter.gzipWriter.Write(data)
+}
+
+func (responseWriter *gzipResponseWriter) WriteString(data string) (int, error) {
+	return responseWriter.Write([]byte(data))
+}
+
+// Flush : Streaming responses need the compressed bytes pushed out along with the underlying writer
+func (responseWriter *gzipResponseWriter) Flush() {
+	if responseWriter.gzipWriter != nil {
+		_ = responseWriter.gzipWriter.Flush()
+	}
+	responseWriter.ResponseWriter.Flush()
+}
+
+// gzipResponses : Compresses responses for clients which accept gzip, meant for routes with large payloads
+func gzipResponses() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		if strings.Contains(ginContext.GetHeader("Accept-Encoding"), "gzip") == false {
+			ginContext.Next()
+			return
+		}
+
+		ginContext.Writer.Header().Add("Vary", "Accept-Encoding")
+		compressingWriter := &gzipResponseWriter{ResponseWriter: ginContext.Writer,
+			isHeadMethod: ginContext.Request.Method == http.MethodHead}
+		ginContext.Writer = compressingWriter
+
+		ginContext.Next()
+
+		if compressingWriter.gzipWriter != nil {
+			compressingWriter.gzipWriter.Close()
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultConfigFile = ".env"
+
+// FeatureToggles : Optional features a deployment can switch off
+type FeatureToggles struct {
+	Attachments  bool
+	StatusPage   bool
+	VoteAnalysis bool
+	LinkPreviews bool
+	Analytics    bool
+	// Responses of routes in routeCacheDurations are cached in memory
+	ResponseCache bool
+	// Collections are dumped to the S3 bucket on a schedule, see backups.go
+	Backups bool
+	// Requests are limited per address, user or API key, see rate_limits.go
+	RateLimits bool
+}
+
+// Config : Every setting of the API, loaded and validated once at startup
+type Config struct {
+	Environment            string
+	Port                   string
+	DatabaseDriver         string
+	DatabaseURL            string
+	PostgresURL            string
+	DatabaseConnectTimeout time.Duration
+	DatabaseConnectRetries int
+	DatabaseHealthInterval time.Duration
+	// Interval of the background probes of mongo and GitHub
+	DependencyProbeInterval time.Duration
+	RequestTimeout          time.Duration
+	GithubSecrets           GithubSecretsEnvs
+	CORSOrigins             []string
+	StatusCacheDuration     time.Duration
+	StatsCacheDuration      time.Duration
+	SuggestCacheDuration    time.Duration
+	// How long a GitHub profile is trusted for its access token, 0 asks GitHub on every request
+	GithubProfileCacheDuration time.Duration
+	// How much longer an expired profile signs in reads while GitHub is unavailable
+	GithubProfileStaleDuration time.Duration
+	// Interval of recounting gazers and makers of every idea, 0 turns the job off
+	CounterReconcileInterval time.Duration
+	// Interval of deleting gazes, bookmarks and the like left behind by deleted ideas, 0 turns the job off
+	OrphanSweepInterval time.Duration
+	// Interval of recomputing related ideas, 0 turns the job off
+	SimilarIdeasInterval time.Duration
+	// Interval of recomputing gazes of the last 7 days and trending scores, 0 turns the job off
+	TrendingRecomputeInterval time.Duration
+	// Interval of regenerating the sitemap, 0 generates it once on the first request
+	SitemapInterval time.Duration
+	// Interval of writing the counted views of ideas, 0 stops counting them
+	ViewFlushInterval  time.Duration
+	Features           FeatureToggles
+	TLS                TLSConfig
+	Proxy              ProxyConfig
+	Branding           BrandingConfig
+	OutboundHTTP       OutboundHTTPConfig
+	Mongo              MongoConfig
+	Migration          MigrationConfig
+	Quarantine         QuarantineConfig
+	Quota              QuotaConfig
+	AuthThrottle       AuthThrottleConfig
+	Suspension         SuspensionConfig
+	ContentFilter      ContentFilterConfig
+	DuplicateDetection DuplicateDetectionConfig
+	Attachment         AttachmentConfig
+	Backup             BackupConfig
+	VoteAnalysis       VoteAnalysisConfig
+	RepoSync           RepoSyncConfig
+	LinkPreview        LinkPreviewConfig
+	Analytics          AnalyticsConfig
+	ResponseCache      ResponseCacheConfig
+	RateLimit          RateLimitConfig
+	IdeaOfTheDay       IdeaOfTheDayConfig
+	ErrorReporting     ErrorReportingConfig
+	EmailAuth          EmailAuthConfig
+	Session            SessionConfig
+	Concurrency        ConcurrencyConfig
+}
+
+// ConfigLoader : Reads settings from the environment and collects every problem, so all of them are reported at once
+type ConfigLoader struct {
+	problems []string
+}
+
+// Invalid : Records a problem with the setting of key
+func (configLoader *ConfigLoader) Invalid(key string, problem string) {
+	configLoader.problems = append(configLoader.problems, key+": "+problem)
+}
+
+// Required : Setting without which the API cannot run
+func (configLoader *ConfigLoader) Required(key string, hint string) string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		configLoader.Invalid(key, "is required, "+hint)
+	}
+	return value
+}
+
+// String : Setting with a default when it is not set
+func (configLoader *ConfigLoader) String(key string, defaultValue string) string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// Int : Whole number setting
+func (configLoader *ConfigLoader) Int(key string, defaultValue int64) int64 {
+	value := configLoader.String(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	parsedValue, errInParsing := strconv.ParseInt(value, 10, 64)
+	if errInParsing != nil {
+		configLoader.Invalid(key, "should be a whole number, got "+strconv.Quote(value))
+		return defaultValue
+	}
+	return parsedValue
+}
+
+// Float : Decimal number setting
+func (configLoader *ConfigLoader) Float(key string, defaultValue float64) float64 {
+	value := configLoader.String(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	parsedValue, errInParsing := strconv.ParseFloat(value, 64)
+	if errInParsing != nil {
+		configLoader.Invalid(key, "should be a number, got "+strconv.Quote(value))
+		return defaultValue
+	}
+	return parsedValue
+}
+
+// Bool : Setting that is either true or false
+func (configLoader *ConfigLoader) Bool(key string, defaultValue bool) bool {
+	value := strings.ToLower(configLoader.String(key, ""))
+	switch value {
+	case "":
+		return defaultValue
+	case "true", "1", "yes", "on":
+		return true
+	case "false", "0", "no", "off":
+		return false
+	}
+
+	configLoader.Invalid(key, "should be true or false, got "+strconv.Quote(value))
+	return defaultValue
+}
+
+// OneOf : Setting limited to a few values, compared in lower case
+func (configLoader *ConfigLoader) OneOf(key string, defaultValue string, allowedValues ...string) string {
+	value := strings.ToLower(configLoader.String(key, defaultValue))
+	for _, allowedValue := range allowedValues {
+		if value == allowedValue {
+			return value
+		}
+	}
+
+	configLoader.Invalid(key, "should be one of "+strings.Join(allowedValues, ", ")+", got "+strconv.Quote(value))
+	return defaultValue
+}
+
+// List : Comma separated setting, empty items are dropped
+func (configLoader *ConfigLoader) List(key string, defaultValue string) []string {
+	var items []string
+	for _, item := range strings.Split(configLoader.String(key, defaultValue), ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Err : All problems found while loading, nil when the config is valid
+func (configLoader *ConfigLoader) Err() error {
+	if len(configLoader.problems) == 0 {
+		return nil
+	}
+	return errors.New("Invalid configuration:\n  " + strings.Join(configLoader.problems, "\n  "))
+}
+
+// readConfigFile : Settings from a .env or yaml file, keys are the same as the environment variables
+func readConfigFile(configFilePath string) (map[string]string, error) {
+	fileSettings := make(map[string]string)
+
+	fileContent, errInReading := ioutil.ReadFile(configFilePath)
+	if errInReading != nil {
+		return fileSettings, errInReading
+	}
+
+	fileExtension := strings.ToLower(filepath.Ext(configFilePath))
+	if fileExtension == ".yaml" || fileExtension == ".yml" {
+		var yamlSettings map[string]interface{}
+		errInParsing := yaml.Unmarshal(fileContent, &yamlSettings)
+		if errInParsing != nil {
+			return fileSettings, errInParsing
+		}
+		for key, value := range yamlSettings {
+			fileSettings[strings.ToUpper(key)] = fmt.Sprint(value)
+		}
+		return fileSettings, nil
+	}
+
+	lineScanner := bufio.NewScanner(strings.NewReader(string(fileContent)))
+	for lineNumber := 1; lineScanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(lineScanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		separatorIndex := strings.Index(line, "=")
+		if separatorIndex <= 0 {
+			return fileSettings, fmt.Errorf("Line %d of %s should look like KEY=value", lineNumber, configFilePath)
+		}
+		key := strings.TrimSpace(line[:separatorIndex])
+		value := strings.Trim(strings.TrimSpace(line[separatorIndex+1:]), `"'`)
+		fileSettings[key] = value
+	}
+
+	return fileSettings, lineScanner.Err()
+}
+
+// loadConfig : Environment variables take precedence over the config file, which is optional
+func loadConfig(configFilePath string) (Config, error) {
+	var config Config
+	configLoader := &ConfigLoader{}
+
+	isDefaultFile := configFilePath == ""
+	if isDefaultFile {
+		configFilePath = defaultConfigFile
+	}
+	fileSettings, errInReadingFile := readConfigFile(configFilePath)
+	if errInReadingFile != nil && (isDefaultFile == false || os.IsNotExist(errInReadingFile) == false) {
+		return config, fmt.Errorf("Failed to read config file %s: %v", configFilePath, errInReadingFile)
+	}
+	for key, value := range fileSettings {
+		if _, isSetInEnv := os.LookupEnv(key); isSetInEnv == false {
+			os.Setenv(key, value)
+		}
+	}
+
+	config.Environment = configLoader.Required("ENVIRONMENT", "use dev for local development")
+	config.Port = configLoader.Required("PORT", "the API listens on it")
+	if _, errInPort := strconv.Atoi(config.Port); config.Port != "" && errInPort != nil {
+		configLoader.Invalid("PORT", "should be a port number, got "+strconv.Quote(config.Port))
+	}
+	config.DatabaseDriver = configLoader.OneOf("DB_DRIVER", "mongo", "mongo", "postgres", "memory")
+	// Postgres keeps ideas, users, gazes and sessions, bookmarks, follows, notifications and the other features stay in
+	// mongo. Memory driver runs without mongo, those features are left out
+	if config.DatabaseDriver != "memory" {
+		config.DatabaseURL = configLoader.Required("DB_URL", "the mongodb:// url of the database")
+	}
+	if config.DatabaseDriver == "postgres" {
+		config.PostgresURL = configLoader.Required("POSTGRES_URL", "the postgres:// url when DB_DRIVER is postgres")
+	}
+	config.DatabaseConnectTimeout = time.Duration(configLoader.Int("DB_CONNECT_TIMEOUT_SECONDS", 10)) * time.Second
+	config.DatabaseConnectRetries = int(configLoader.Int("DB_CONNECT_RETRIES", 6))
+	config.DatabaseHealthInterval = time.Duration(configLoader.Int("DB_HEALTH_INTERVAL_SECONDS", 10)) * time.Second
+	if config.DatabaseHealthInterval <= 0 {
+		configLoader.Invalid("DB_HEALTH_INTERVAL_SECONDS", "should be more than 0")
+	}
+	config.DependencyProbeInterval = time.Duration(configLoader.Int("DEPENDENCY_PROBE_INTERVAL_SECONDS", 30)) * time.Second
+	if config.DependencyProbeInterval <= 0 {
+		configLoader.Invalid("DEPENDENCY_PROBE_INTERVAL_SECONDS", "should be more than 0")
+	}
+	config.RequestTimeout = time.Duration(configLoader.Int("REQUEST_TIMEOUT_SECONDS", 30)) * time.Second
+	if config.RequestTimeout <= 0 {
+		configLoader.Invalid("REQUEST_TIMEOUT_SECONDS", "should be more than 0")
+	}
+	config.GithubSecrets.Client = configLoader.Required("GITHUB_CLIENT", "client id of the GitHub OAuth app")
+	config.GithubSecrets.Secret = configLoader.Required("GITHUB_SECRET", "client secret of the GitHub OAuth app")
+
+	config.TLS = loadTLSConfig(configLoader)
+	config.Proxy = loadProxyConfig(configLoader)
+	config.Branding = loadBrandingConfig(configLoader, config.Environment)
+	config.CORSOrigins = configLoader.List("CORS_ORIGINS", config.Branding.FrontendOrigin)
+	for _, origin := range config.CORSOrigins {
+		if isValidCORSOrigin(origin) == false {
+			configLoader.Invalid("CORS_ORIGINS", "should be origins like https://ideas.example.com or https://*.example.com, got "+strconv.Quote(origin))
+		}
+	}
+	config.StatusCacheDuration = time.Duration(configLoader.Int("STATUS_CACHE_SECONDS", 10)) * time.Second
+	config.StatsCacheDuration = time.Duration(configLoader.Int("STATS_CACHE_SECONDS", 300)) * time.Second
+	config.SuggestCacheDuration = time.Duration(configLoader.Int("SUGGEST_CACHE_SECONDS", 60)) * time.Second
+	config.GithubProfileCacheDuration = time.Duration(configLoader.Int("GITHUB_PROFILE_CACHE_SECONDS", 300)) * time.Second
+	if config.GithubProfileCacheDuration < 0 {
+		configLoader.Invalid("GITHUB_PROFILE_CACHE_SECONDS", "should be 0 or more")
+	}
+	config.GithubProfileStaleDuration = time.Duration(configLoader.Int("GITHUB_PROFILE_STALE_SECONDS", 3600)) * time.Second
+	if config.GithubProfileStaleDuration < 0 {
+		configLoader.Invalid("GITHUB_PROFILE_STALE_SECONDS", "should be 0 or more")
+	}
+
+	config.CounterReconcileInterval = time.Duration(configLoader.Int("COUNTER_RECONCILE_INTERVAL_MINUTES", 360)) * time.Minute
+	config.OrphanSweepInterval = time.Duration(configLoader.Int("ORPHAN_SWEEP_INTERVAL_MINUTES", 60)) * time.Minute
+	config.SimilarIdeasInterval = time.Duration(configLoader.Int("SIMILAR_IDEAS_INTERVAL_MINUTES", 360)) * time.Minute
+	config.TrendingRecomputeInterval = time.Duration(configLoader.Int("TRENDING_RECOMPUTE_INTERVAL_MINUTES", 15)) * time.Minute
+	config.SitemapInterval = time.Duration(configLoader.Int("SITEMAP_INTERVAL_MINUTES", 60)) * time.Minute
+	config.ViewFlushInterval = time.Duration(configLoader.Int("VIEW_FLUSH_SECONDS", 30)) * time.Second
+	if config.ViewFlushInterval < 0 {
+		configLoader.Invalid("VIEW_FLUSH_SECONDS", "should be 0 or more")
+	}
+
+	config.Features.Attachments = configLoader.Bool("FEATURE_ATTACHMENTS", true)
+	config.Features.StatusPage = configLoader.Bool("FEATURE_STATUS_PAGE", true)
+	config.Features.VoteAnalysis = configLoader.Bool("FEATURE_VOTE_ANALYSIS", true)
+	config.Features.LinkPreviews = configLoader.Bool("FEATURE_LINK_PREVIEWS", true)
+	config.Features.Analytics = configLoader.Bool("FEATURE_ANALYTICS", true)
+	config.Features.ResponseCache = configLoader.Bool("FEATURE_RESPONSE_CACHE", true)
+	config.Features.Backups = configLoader.Bool("FEATURE_BACKUPS", false)
+	config.Features.RateLimits = configLoader.Bool("FEATURE_RATE_LIMITS", true)
+
+	config.OutboundHTTP = loadOutboundHTTPConfig(configLoader)
+	config.Mongo = loadMongoConfig(configLoader)
+	config.Migration = loadMigrationConfig(configLoader)
+	config.Quarantine = loadQuarantineConfig(configLoader)
+	config.Quota = loadQuotaConfig(configLoader)
+	config.AuthThrottle = loadAuthThrottleConfig(configLoader)
+	config.Suspension = loadSuspensionConfig(configLoader)
+	config.ContentFilter = loadContentFilterConfig(configLoader)
+	config.DuplicateDetection = loadDuplicateDetectionConfig(configLoader)
+	config.IdeaOfTheDay = loadIdeaOfTheDayConfig(configLoader)
+	config.Attachment = loadAttachmentConfig(configLoader)
+	config.VoteAnalysis = loadVoteAnalysisConfig(configLoader, config.Quarantine)
+	config.RepoSync = loadRepoSyncConfig(configLoader)
+	config.LinkPreview = loadLinkPreviewConfig(configLoader)
+	config.Analytics = loadAnalyticsConfig(configLoader)
+	config.ResponseCache = loadResponseCacheConfig(configLoader)
+	config.RateLimit = loadRateLimitConfig(configLoader)
+	config.Concurrency = loadConcurrencyConfig(configLoader)
+	config.ErrorReporting = loadErrorReportingConfig(configLoader, config.Environment)
+	config.Session = loadSessionConfig(configLoader)
+	config.EmailAuth = loadEmailAuthConfig(configLoader, config.Branding.FrontendOrigin)
+	// S3 settings are only required once backups are switched on
+	if config.Features.Backups == true {
+		config.Backup = loadBackupConfig(configLoader)
+	}
+
+	return config, configLoader.Err()
+}
+
+// isValidCORSOrigin : An origin with a scheme, where a wildcard may only stand for the leading subdomain
+func isValidCORSOrigin(origin string) bool {
+	schemeEnd := strings.Index(origin, "://")
+	if schemeEnd < 0 {
+		return false
+	}
+	scheme, host := origin[:schemeEnd], origin[schemeEnd+len("://"):]
+	if (scheme != "http" && scheme != "https") || host == "" || strings.Contains(host, "/") {
+		return false
+	}
+
+	if strings.Contains(host, "*") == false {
+		return true
+	}
+	return strings.HasPrefix(host, "*.") && strings.Count(host, "*") == 1 && len(host) > len("*.")
+}
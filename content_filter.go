@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	contentFilterActionFlag   = "flag"
+	contentFilterActionReject = "reject"
+)
+
+// ContentFilterConfig : Word list and heuristics used to catch spam in submissions
+type ContentFilterConfig struct {
+	BlockedWords         map[string]bool
+	MaxLinks             int
+	RepeatedWithinSecond int64
+	Action               string
+}
+
+var wordsInTextRegex = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+func loadContentFilterConfig(configLoader *ConfigLoader) ContentFilterConfig {
+	var contentFilterConfig ContentFilterConfig
+
+	contentFilterConfig.BlockedWords = make(map[string]bool)
+	for _, blockedWord := range configLoader.List("CONTENT_BLOCKED_WORDS", "") {
+		contentFilterConfig.BlockedWords[strings.ToLower(blockedWord)] = true
+	}
+
+	contentFilterConfig.MaxLinks = int(configLoader.Int("CONTENT_MAX_LINKS", 3))
+	contentFilterConfig.RepeatedWithinSecond = configLoader.Int("CONTENT_REPEAT_WINDOW_HOURS", 24) * 60 * 60
+	contentFilterConfig.Action = configLoader.OneOf("CONTENT_FILTER_ACTION", contentFilterActionFlag,
+		contentFilterActionFlag, contentFilterActionReject)
+
+	return contentFilterConfig
+}
+
+// checkSubmissionContent : Returns the reasons for which the text looks like spam, empty if it looks fine
+func checkSubmissionContent(contentFilterConfig ContentFilterConfig, texts ...string) []string {
+	var reasons []string
+
+	containsBlockedWord := false
+	numberOfLinks := 0
+	for _, text := range texts {
+		for _, word := range wordsInTextRegex.FindAllString(strings.ToLower(text), -1) {
+			if contentFilterConfig.BlockedWords[word] == true {
+				containsBlockedWord = true
+			}
+		}
+		numberOfLinks = numberOfLinks + len(linksInTextRegex.FindAllString(text, -1))
+	}
+
+	if containsBlockedWord == true {
+		reasons = append(reasons, "blocked_words")
+	}
+	if contentFilterConfig.MaxLinks >= 0 && numberOfLinks > contentFilterConfig.MaxLinks {
+		reasons = append(reasons, "too_many_links")
+	}
+
+	return reasons
+}
+
+// isRepeatedSubmission : Checks if the same user published an idea with the same name recently
+func isRepeatedSubmission(databaseContext context.Context, githubUser GithubUserProfileStructure, ideaName string, stores Stores, contentFilterConfig ContentFilterConfig) (bool, error) {
+	if contentFilterConfig.RepeatedWithinSecond <= 0 {
+		return false, nil
+	}
+
+	repeatWindowStart := time.Now().Unix() - contentFilterConfig.RepeatedWithinSecond
+	recentIdeas, errInFinding := stores.Ideas.ListByPublisherSince(databaseContext, githubUser.UserID, repeatWindowStart, 100)
+	if errInFinding != nil {
+		return false, errInFinding
+	}
+
+	normalizedName := strings.Join(wordsInTextRegex.FindAllString(strings.ToLower(ideaName), -1), " ")
+
+	for _, recentIdea := range recentIdeas {
+		normalizedRecentName := strings.Join(wordsInTextRegex.FindAllString(strings.ToLower(recentIdea.Name), -1), " ")
+		if normalizedRecentName == normalizedName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
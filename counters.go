@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdeaCounter : Counter field of ideas and the collection whose documents it counts, one per idea and user
+type IdeaCounter struct {
+	// Fields of embedded documents are given with dots
+	Field      string
+	Collection string
+	// Only documents matching the filter are counted, nil counts all
+	Filter bson.M
+}
+
+// Counters are incremented apart from the documents they count, so a failed write in between lets them drift
+var reconciledIdeaCounters = []IdeaCounter{
+	{Field: "gazers", Collection: "likes"},
+	// Nothing writes makers documents yet, so this keeps every makers counter at 0
+	{Field: "makers", Collection: "makers"},
+	{Field: "reactions." + reactionFire, Collection: "likes", Filter: bson.M{"reaction": reactionFire}},
+	{Field: "reactions." + reactionIdea, Collection: "likes", Filter: bson.M{"reaction": reactionIdea}},
+	{Field: "reactions." + reactionEyes, Collection: "likes", Filter: bson.M{"reaction": reactionEyes}},
+	{Field: "reactions." + reactionHeart, Collection: "likes", Filter: bson.M{"reaction": reactionHeart}},
+}
+
+func runCounterReconciliationJob(databaseClient *mongo.Client, interval time.Duration) {
+	runScheduledJob(databaseClient, "counter_reconciliation", interval, func() error {
+		for _, ideaCounter := range reconciledIdeaCounters {
+			errInReconciling := reconcileIdeaCounter(databaseClient, ideaCounter)
+			if errInReconciling != nil {
+				return errInReconciling
+			}
+		}
+		return nil
+	})
+}
+
+// reconcileIdeaCounter : Recounts the documents per idea and repairs ideas whose counter differs
+func reconcileIdeaCounter(databaseClient *mongo.Client, ideaCounter IdeaCounter) error {
+	ideasCollection := databaseClient.Database("sardene-db").Collection("ideas")
+	countedCollection := databaseClient.Database("sardene-db").Collection(ideaCounter.Collection)
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelDBContext()
+
+	countPipeline := bson.A{
+		bson.M{"$group": bson.M{"_id": "$ideaID", "count": bson.M{"$sum": 1}}},
+	}
+	if ideaCounter.Filter != nil {
+		countPipeline = append(bson.A{bson.M{"$match": ideaCounter.Filter}}, countPipeline...)
+	}
+	countsCursor, errInCounting := countedCollection.Aggregate(databaseContext, countPipeline)
+	if errInCounting != nil {
+		return errInCounting
+	}
+	defer countsCursor.Close(databaseContext)
+
+	countOfIdea := make(map[primitive.ObjectID]int64)
+	for countsCursor.Next(databaseContext) {
+		var ideaCount struct {
+			IdeaID primitive.ObjectID `bson:"_id"`
+			Count  int64              `bson:"count"`
+		}
+		errInDecoding := countsCursor.Decode(&ideaCount)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+		countOfIdea[ideaCount.IdeaID] = ideaCount.Count
+	}
+	if errInCursor := countsCursor.Err(); errInCursor != nil {
+		return errInCursor
+	}
+
+	ideasCursor, errInFinding := ideasCollection.Find(databaseContext, bson.M{},
+		options.Find().SetProjection(bson.M{"_id": 1, ideaCounter.Field: 1}))
+	if errInFinding != nil {
+		return errInFinding
+	}
+	defer ideasCursor.Close(databaseContext)
+
+	var repairedIdeas int
+	for ideasCursor.Next(databaseContext) {
+		ideaID, _ := ideasCursor.Current.Lookup("_id").ObjectIDOK()
+		var storedCounter interface{}
+		if storedValue := ideasCursor.Current.Lookup(strings.Split(ideaCounter.Field, ".")...); storedValue.Type != 0 {
+			errInDecoding := storedValue.Unmarshal(&storedCounter)
+			if errInDecoding != nil {
+				return errInDecoding
+			}
+		}
+		storedCount := counterValue(storedCounter)
+		actualCount := countOfIdea[ideaID]
+		if storedCount == actualCount {
+			continue
+		}
+
+		// Counters that changed since they were read are left for the next run
+		_, errInRepairing := ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID, ideaCounter.Field: storedCounter},
+			bson.M{"$set": bson.M{ideaCounter.Field: actualCount}})
+		if errInRepairing != nil {
+			return errInRepairing
+		}
+		log.Printf("Repaired %s of idea %s, it was %d but %d %s exist", ideaCounter.Field, ideaID.Hex(),
+			storedCount, actualCount, ideaCounter.Collection)
+		repairedIdeas++
+	}
+	if errInCursor := ideasCursor.Err(); errInCursor != nil {
+		return errInCursor
+	}
+
+	if repairedIdeas > 0 {
+		invalidateCachedResponses()
+		log.Printf("Counter reconciliation repaired %s of %d ideas", ideaCounter.Field, repairedIdeas)
+	}
+	return nil
+}
+
+// counterValue : Counters were written as int32 by older clients and int64 by this API
+func counterValue(value interface{}) int64 {
+	switch number := value.(type) {
+	case int32:
+		return int64(number)
+	case int64:
+		return number
+	case float64:
+		return int64(number)
+	}
+	return 0
+}
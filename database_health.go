@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DatabaseHealth : Result of the latest background ping, read by every request
+type DatabaseHealth struct {
+	isDown       int32
+	retryAfterIn time.Duration
+}
+
+var databaseHealth = &DatabaseHealth{}
+
+// IsDown : True while the latest ping failed
+func (health *DatabaseHealth) IsDown() bool {
+	return atomic.LoadInt32(&health.isDown) == 1
+}
+
+func (health *DatabaseHealth) setDown(isDown bool) {
+	var isDownValue int32
+	if isDown {
+		isDownValue = 1
+	}
+
+	wasDown := atomic.SwapInt32(&health.isDown, isDownValue)
+	if wasDown != isDownValue && isDown {
+		log.Println("DB is not reachable, requests needing it get 503 until it is back")
+	} else if wasDown != isDownValue {
+		log.Println("DB is reachable again")
+	}
+}
+
+// startDatabaseHealthMonitor : Pings the database every interval in the background and keeps databaseHealth up to date
+func startDatabaseHealthMonitor(databaseClient *mongo.Client, interval time.Duration) {
+	databaseHealth.retryAfterIn = interval
+	go monitorDatabaseHealth(databaseClient, interval)
+}
+
+func monitorDatabaseHealth(databaseClient *mongo.Client, interval time.Duration) {
+	healthTicker := time.NewTicker(interval)
+	defer healthTicker.Stop()
+
+	for range healthTicker.C {
+		pingContext, cancelPingContext := context.WithTimeout(context.Background(), interval)
+		errInPing := databaseClient.Ping(pingContext, nil)
+		cancelPingContext()
+
+		databaseHealth.setDown(errInPing != nil)
+	}
+}
+
+// pathsWithoutDatabase : Routes that still answer while the database is down
+var pathsWithoutDatabase = map[string]bool{
+	"/":                    true,
+	"/meta":                true,
+	"/status":              true,
+	"/health/dependencies": true,
+}
+
+// requireHealthyDatabase : Answers 503 right away while the database is down instead of waiting on timeouts
+func requireHealthyDatabase() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		if databaseHealth.IsDown() == false || pathsWithoutDatabase[ginContext.Request.URL.Path] == true {
+			ginContext.Next()
+			return
+		}
+
+		ginContext.Header("Retry-After", strconv.Itoa(int(databaseHealth.retryAfterIn.Seconds())))
+		ginContext.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Database is not reachable, try again shortly"})
+	}
+}
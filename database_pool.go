@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// MongoConfig : Connection pool, timeouts and concerns of the mongo client, zero values keep the driver defaults
+type MongoConfig struct {
+	MaxPoolSize            uint16
+	MaxConnIdleTime        time.Duration
+	SocketTimeout          time.Duration
+	ServerSelectionTimeout time.Duration
+	ReadConcern            string
+	// majority or a number of members
+	WriteConcern        string
+	WriteConcernTimeout time.Duration
+	// Listings and stats may read from secondaries with this preference, writes and every other read stay on the primary
+	ListingReadPreference string
+	// How far behind the primary a secondary may be to serve listings, 0 leaves it to the driver
+	ListingMaxStaleness time.Duration
+}
+
+// Secondaries are only refused for being stale from this on, see the max staleness of the server selection spec
+const minListingMaxStaleness = 90 * time.Second
+
+// DatabasePoolMetrics : Connections the client holds and the commands running on them.
+// The driver has no pool events yet, commands in flight stand in for checked out connections
+type DatabasePoolMetrics struct {
+	MaxPoolSize            uint16 `json:"max_pool_size"`
+	OpenConnections        int64  `json:"open_connections"`
+	OpenedConnections      int64  `json:"opened_connections"`
+	ClosedConnections      int64  `json:"closed_connections"`
+	FailedDials            int64  `json:"failed_dials"`
+	TotalDialTimeInMs      int64  `json:"total_dial_time_ms"`
+	CommandsInFlight       int64  `json:"commands_in_flight"`
+	MaxCommandsInFlight    int64  `json:"max_commands_in_flight"`
+	Commands               int64  `json:"commands"`
+	FailedCommands         int64  `json:"failed_commands"`
+	TotalCommandTimeInMs   int64  `json:"total_command_time_ms"`
+	CommandsAtFullPool     int64  `json:"commands_at_full_pool"`
+	SlowestCommandTimeInMs int64  `json:"slowest_command_time_ms"`
+}
+
+// DatabasePoolMonitor : Counts connections through the dialer of the client and commands through its monitor
+type DatabasePoolMonitor struct {
+	mutex   sync.Mutex
+	metrics DatabasePoolMetrics
+	dialer  *net.Dialer
+}
+
+var databasePoolMonitor = &DatabasePoolMonitor{dialer: &net.Dialer{KeepAlive: 300 * time.Second}}
+
+func loadMongoConfig(configLoader *ConfigLoader) MongoConfig {
+	var mongoConfig MongoConfig
+
+	maxPoolSize := configLoader.Int("DB_MAX_POOL_SIZE", 100)
+	if maxPoolSize <= 0 || maxPoolSize > 65535 {
+		configLoader.Invalid("DB_MAX_POOL_SIZE", "should be between 1 and 65535, got "+strconv.FormatInt(maxPoolSize, 10))
+	}
+	mongoConfig.MaxPoolSize = uint16(maxPoolSize)
+	mongoConfig.MaxConnIdleTime = time.Duration(configLoader.Int("DB_MAX_CONN_IDLE_SECONDS", 0)) * time.Second
+	mongoConfig.SocketTimeout = time.Duration(configLoader.Int("DB_SOCKET_TIMEOUT_SECONDS", 0)) * time.Second
+	mongoConfig.ServerSelectionTimeout = time.Duration(configLoader.Int("DB_SERVER_SELECTION_TIMEOUT_SECONDS", 0)) * time.Second
+	mongoConfig.ReadConcern = configLoader.OneOf("DB_READ_CONCERN", "", "", "local", "available", "majority", "linearizable")
+	mongoConfig.WriteConcern = configLoader.String("DB_WRITE_CONCERN", "")
+	if _, errInNumber := strconv.Atoi(mongoConfig.WriteConcern); mongoConfig.WriteConcern != "" &&
+		mongoConfig.WriteConcern != "majority" && errInNumber != nil {
+		configLoader.Invalid("DB_WRITE_CONCERN", "should be majority or a number of members, got "+strconv.Quote(mongoConfig.WriteConcern))
+	}
+	mongoConfig.WriteConcernTimeout = time.Duration(configLoader.Int("DB_WRITE_CONCERN_TIMEOUT_MS", 0)) * time.Millisecond
+	mongoConfig.ListingReadPreference = configLoader.OneOf("DB_LISTING_READ_PREFERENCE", "primary",
+		"primary", "primarypreferred", "secondary", "secondarypreferred", "nearest")
+	mongoConfig.ListingMaxStaleness = time.Duration(configLoader.Int("DB_LISTING_MAX_STALENESS_SECONDS", 0)) * time.Second
+	if mongoConfig.ListingMaxStaleness != 0 && mongoConfig.ListingReadPreference == "primary" {
+		configLoader.Invalid("DB_LISTING_MAX_STALENESS_SECONDS", "cannot be set when DB_LISTING_READ_PREFERENCE is primary")
+	} else if mongoConfig.ListingMaxStaleness != 0 && mongoConfig.ListingMaxStaleness < minListingMaxStaleness {
+		configLoader.Invalid("DB_LISTING_MAX_STALENESS_SECONDS", "should be 0 or at least "+
+			strconv.Itoa(int(minListingMaxStaleness.Seconds())))
+	}
+
+	return mongoConfig
+}
+
+// applyMongoConfig : Settings of the config on top of the ones in the url, with the pool monitor hooked in
+func applyMongoConfig(connectOptions *options.ClientOptions, mongoConfig MongoConfig) {
+	connectOptions.SetMaxPoolSize(mongoConfig.MaxPoolSize)
+	if mongoConfig.MaxConnIdleTime > 0 {
+		connectOptions.SetMaxConnIdleTime(mongoConfig.MaxConnIdleTime)
+	}
+	if mongoConfig.SocketTimeout > 0 {
+		connectOptions.SetSocketTimeout(mongoConfig.SocketTimeout)
+	}
+	if mongoConfig.ServerSelectionTimeout > 0 {
+		connectOptions.SetServerSelectionTimeout(mongoConfig.ServerSelectionTimeout)
+	}
+	if mongoConfig.ReadConcern != "" {
+		connectOptions.SetReadConcern(readconcern.New(readconcern.Level(mongoConfig.ReadConcern)))
+	}
+	if mongoConfig.WriteConcern != "" {
+		writeConcernOptions := []writeconcern.Option{writeconcern.WMajority()}
+		if membersToAcknowledge, errInNumber := strconv.Atoi(mongoConfig.WriteConcern); errInNumber == nil {
+			writeConcernOptions = []writeconcern.Option{writeconcern.W(membersToAcknowledge)}
+		}
+		if mongoConfig.WriteConcernTimeout > 0 {
+			writeConcernOptions = append(writeConcernOptions, writeconcern.WTimeout(mongoConfig.WriteConcernTimeout))
+		}
+		connectOptions.SetWriteConcern(writeconcern.New(writeConcernOptions...))
+	}
+
+	databasePoolMonitor.mutex.Lock()
+	databasePoolMonitor.metrics.MaxPoolSize = mongoConfig.MaxPoolSize
+	databasePoolMonitor.mutex.Unlock()
+	connectOptions.SetDialer(databasePoolMonitor)
+	connectOptions.SetMonitor(databasePoolMonitor.commandMonitor())
+}
+
+// listingDatabase : Database read by listings and stats, through the same pool as everything else.
+// Anything which has to see the writes of the caller must use the client instead
+func listingDatabase(databaseClient *mongo.Client, mongoConfig MongoConfig) *mongo.Database {
+	if mongoConfig.ListingReadPreference == "" || mongoConfig.ListingReadPreference == "primary" {
+		return databaseClient.Database("sardene-db")
+	}
+
+	listingMode, _ := readpref.ModeFromString(mongoConfig.ListingReadPreference)
+	var readPreferenceOptions []readpref.Option
+	if mongoConfig.ListingMaxStaleness > 0 {
+		readPreferenceOptions = append(readPreferenceOptions, readpref.WithMaxStaleness(mongoConfig.ListingMaxStaleness))
+	}
+	listingReadPreference, _ := readpref.New(listingMode, readPreferenceOptions...)
+	return databaseClient.Database("sardene-db", options.Database().SetReadPreference(listingReadPreference))
+}
+
+// monitoredConnection : Connection which tells the monitor when the driver closes it
+type monitoredConnection struct {
+	net.Conn
+	closeOnce sync.Once
+	monitor   *DatabasePoolMonitor
+}
+
+func (connection *monitoredConnection) Close() error {
+	connection.closeOnce.Do(func() {
+		connection.monitor.mutex.Lock()
+		connection.monitor.metrics.OpenConnections--
+		connection.monitor.metrics.ClosedConnections++
+		connection.monitor.mutex.Unlock()
+	})
+	return connection.Conn.Close()
+}
+
+// DialContext : Dials like the driver does by default, counting the connections
+func (monitor *DatabasePoolMonitor) DialContext(dialContext context.Context, network string, address string) (net.Conn, error) {
+	dialStart := time.Now()
+	connection, errInDialing := monitor.dialer.DialContext(dialContext, network, address)
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	monitor.metrics.TotalDialTimeInMs += time.Since(dialStart).Nanoseconds() / int64(time.Millisecond)
+	if errInDialing != nil {
+		monitor.metrics.FailedDials++
+		return nil, errInDialing
+	}
+	monitor.metrics.OpenConnections++
+	monitor.metrics.OpenedConnections++
+	return &monitoredConnection{Conn: connection, monitor: monitor}, nil
+}
+
+func (monitor *DatabasePoolMonitor) commandStarted() {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	monitor.metrics.Commands++
+	monitor.metrics.CommandsInFlight++
+	if monitor.metrics.CommandsInFlight > monitor.metrics.MaxCommandsInFlight {
+		monitor.metrics.MaxCommandsInFlight = monitor.metrics.CommandsInFlight
+	}
+	if monitor.metrics.MaxPoolSize > 0 && monitor.metrics.CommandsInFlight >= int64(monitor.metrics.MaxPoolSize) {
+		monitor.metrics.CommandsAtFullPool++
+	}
+}
+
+func (monitor *DatabasePoolMonitor) commandFinished(durationInNanos int64, isFailure bool) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	durationInMs := durationInNanos / int64(time.Millisecond)
+	monitor.metrics.CommandsInFlight--
+	monitor.metrics.TotalCommandTimeInMs += durationInMs
+	if durationInMs > monitor.metrics.SlowestCommandTimeInMs {
+		monitor.metrics.SlowestCommandTimeInMs = durationInMs
+	}
+	if isFailure == true {
+		monitor.metrics.FailedCommands++
+	}
+}
+
+func (monitor *DatabasePoolMonitor) commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, _ *event.CommandStartedEvent) {
+			monitor.commandStarted()
+		},
+		Succeeded: func(_ context.Context, succeededEvent *event.CommandSucceededEvent) {
+			monitor.commandFinished(succeededEvent.DurationNanos, false)
+		},
+		Failed: func(_ context.Context, failedEvent *event.CommandFailedEvent) {
+			monitor.commandFinished(failedEvent.DurationNanos, true)
+		},
+	}
+}
+
+// Metrics : Copy of the counters, safe to read while commands are going on
+func (monitor *DatabasePoolMonitor) Metrics() DatabasePoolMetrics {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	return monitor.metrics
+}
+
+func getDatabasePoolMetrics(ginContext *gin.Context, databaseClient *mongo.Client) {
+	poolMetrics := gin.H{"status": http.StatusOK, "data": databasePoolMonitor.Metrics()}
+	// Requests shed in front of the pool, left out when the limit is off
+	if requestConcurrencyLimiter != nil {
+		poolMetrics["requests"] = requestConcurrencyLimiter.Metrics()
+	}
+
+	ginContext.JSON(http.StatusOK, poolMetrics)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	statusUnknown = "unknown"
+	// Rate limit endpoint does not count against the rate limit of the API
+	githubProbeURL            = "https://api.github.com/rate_limit"
+	dependencyProbeTimeout    = 5 * time.Second
+	slowDependencyProbeTimeMs = 2000
+)
+
+// DependencyProbes : Latest result of the background probe of every dependency, requests only read them
+type DependencyProbes struct {
+	mutex        sync.Mutex
+	dependencies map[string]DependencyHealth
+}
+
+var dependencyProbes = &DependencyProbes{dependencies: map[string]DependencyHealth{
+	"mongo":  {Status: statusUnknown},
+	"github": {Status: statusUnknown},
+}}
+
+func (probes *DependencyProbes) set(dependency string, health DependencyHealth) {
+	probes.mutex.Lock()
+	defer probes.mutex.Unlock()
+
+	health.CheckedAt = time.Now().Unix()
+	probes.dependencies[dependency] = health
+}
+
+// forget : Drops a dependency the API runs without, so it is not reported as unknown forever
+func (probes *DependencyProbes) forget(dependency string) {
+	probes.mutex.Lock()
+	defer probes.mutex.Unlock()
+
+	delete(probes.dependencies, dependency)
+}
+
+// Snapshot : Copy of the latest results, safe to read while probes are going on
+func (probes *DependencyProbes) Snapshot() map[string]DependencyHealth {
+	probes.mutex.Lock()
+	defer probes.mutex.Unlock()
+
+	snapshot := make(map[string]DependencyHealth, len(probes.dependencies))
+	for dependency, health := range probes.dependencies {
+		snapshot[dependency] = health
+	}
+	return snapshot
+}
+
+// probeGithub : One request without retries, failures of the calls the API made since are folded in
+func probeGithub() DependencyHealth {
+	probeContext, cancelProbeContext := context.WithTimeout(context.Background(), dependencyProbeTimeout)
+	defer cancelProbeContext()
+
+	probeRequest, errInRequest := http.NewRequestWithContext(probeContext, "GET", githubProbeURL, nil)
+	if errInRequest != nil {
+		return DependencyHealth{Status: statusMajorOutage, Error: errInRequest.Error()}
+	}
+	probeRequest.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	probeStart := time.Now()
+	probeResponse, errInProbe := outboundHTTPClient.httpClient.Do(probeRequest)
+	if errInProbe != nil {
+		return DependencyHealth{Status: statusMajorOutage, Error: errInProbe.Error()}
+	}
+	probeResponse.Body.Close()
+
+	githubHealth := DependencyHealth{Status: statusOperational, LatencyInMs: time.Since(probeStart).Nanoseconds() / int64(time.Millisecond)}
+	if probeResponse.StatusCode >= http.StatusInternalServerError {
+		githubHealth.Status = statusMajorOutage
+		githubHealth.Error = fmt.Sprintf("GitHub answered with %d", probeResponse.StatusCode)
+		return githubHealth
+	}
+	if probeResponse.StatusCode != http.StatusOK {
+		githubHealth.Status = statusDegraded
+		githubHealth.Error = fmt.Sprintf("GitHub answered with %d", probeResponse.StatusCode)
+	}
+	if githubHealth.LatencyInMs > slowDependencyProbeTimeMs || checkGithubHealth().Status != statusOperational {
+		githubHealth.Status = statusDegraded
+	}
+	return githubHealth
+}
+
+func probeMongo(databaseClient *mongo.Client) DependencyHealth {
+	mongoHealth := checkMongoHealth(databaseClient)
+	if mongoHealth.Status == statusOperational && mongoHealth.LatencyInMs > slowDependencyProbeTimeMs {
+		mongoHealth.Status = statusDegraded
+	}
+	return mongoHealth
+}
+
+// probeDependencies : Mongo is left out of the probes when the API runs without it
+func probeDependencies(databaseClient *mongo.Client) {
+	var probesDone sync.WaitGroup
+	probesDone.Add(1)
+	if databaseClient != nil {
+		probesDone.Add(1)
+		go func() {
+			defer probesDone.Done()
+			dependencyProbes.set("mongo", probeMongo(databaseClient))
+		}()
+	}
+	go func() {
+		defer probesDone.Done()
+		dependencyProbes.set("github", probeGithub())
+	}()
+	probesDone.Wait()
+}
+
+// startDependencyProbes : Probes every dependency right away and then every interval in the background
+func startDependencyProbes(databaseClient *mongo.Client, interval time.Duration) {
+	if databaseClient == nil {
+		dependencyProbes.forget("mongo")
+	}
+	go func() {
+		probeDependencies(databaseClient)
+
+		probeTicker := time.NewTicker(interval)
+		defer probeTicker.Stop()
+		for range probeTicker.C {
+			probeDependencies(databaseClient)
+		}
+	}()
+}
+
+func getDependencyHealth(ginContext *gin.Context) {
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": dependencyProbes.Snapshot()})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxDuplicateCandidates = 20
+	maxDuplicatesReturned  = 5
+)
+
+// DuplicateDetectionConfig : Settings for finding already published ideas similar to a new one
+type DuplicateDetectionConfig struct {
+	SimilarityThreshold float64
+	RequireForce        bool
+}
+
+// DuplicateIdeaStructure : Structure of a likely duplicate returned with a new idea
+type DuplicateIdeaStructure struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description" bson:"description"`
+	Publisher   string             `json:"publisher" bson:"publisher"`
+	Gazers      int64              `json:"gazers" bson:"gazers"`
+	Similarity  float64            `json:"similarity" bson:"-"`
+}
+
+func loadDuplicateDetectionConfig(configLoader *ConfigLoader) DuplicateDetectionConfig {
+	var duplicateDetectionConfig DuplicateDetectionConfig
+
+	duplicateDetectionConfig.SimilarityThreshold = configLoader.Float("DUPLICATE_SIMILARITY_THRESHOLD", 0.5)
+	if duplicateDetectionConfig.SimilarityThreshold < 0 || duplicateDetectionConfig.SimilarityThreshold > 1 {
+		configLoader.Invalid("DUPLICATE_SIMILARITY_THRESHOLD", "should be a number between 0 and 1")
+	}
+	duplicateDetectionConfig.RequireForce = configLoader.Bool("DUPLICATE_REQUIRE_FORCE", true)
+
+	return duplicateDetectionConfig
+}
+
+func trigramsOf(text string) map[string]bool {
+	trigrams := make(map[string]bool)
+	normalizedText := " " + strings.Join(wordsInTextRegex.FindAllString(strings.ToLower(text), -1), " ") + " "
+	runesOfText := []rune(normalizedText)
+
+	for index := 0; index+3 <= len(runesOfText); index++ {
+		trigrams[string(runesOfText[index:index+3])] = true
+	}
+	return trigrams
+}
+
+// trigramSimilarity : Jaccard similarity of trigrams of both texts, 1 means identical
+func trigramSimilarity(firstText string, secondText string) float64 {
+	firstTrigrams := trigramsOf(firstText)
+	secondTrigrams := trigramsOf(secondText)
+
+	if len(firstTrigrams) == 0 || len(secondTrigrams) == 0 {
+		return 0
+	}
+
+	commonTrigrams := 0
+	for trigram := range firstTrigrams {
+		if secondTrigrams[trigram] == true {
+			commonTrigrams++
+		}
+	}
+
+	return float64(commonTrigrams) / float64(len(firstTrigrams)+len(secondTrigrams)-commonTrigrams)
+}
+
+// findLikelyDuplicates : Search of the stores narrows down candidates, trigram similarity decides if they are duplicates
+func findLikelyDuplicates(databaseContext context.Context, stores Stores, ideaName string, ideaDescription string, duplicateDetectionConfig DuplicateDetectionConfig) ([]DuplicateIdeaStructure, error) {
+	duplicates := []DuplicateIdeaStructure{}
+
+	// Only plain words are searched, so quotes and dashes in the idea are not read as text operators
+	searchWords := wordsInTextRegex.FindAllString(ideaName+" "+ideaDescription, -1)
+	if len(searchWords) == 0 {
+		return duplicates, nil
+	}
+
+	// Drafts and private ideas of others are never shown as duplicates
+	candidates, errInFinding := stores.Ideas.SearchPublished(databaseContext, searchWords, maxDuplicateCandidates)
+	if errInFinding != nil {
+		return duplicates, errInFinding
+	}
+
+	for _, candidateIdea := range candidates {
+		candidate := DuplicateIdeaStructure{ID: candidateIdea.ID, Name: candidateIdea.Name, Description: candidateIdea.Description,
+			Publisher: candidateIdea.Publisher, Gazers: candidateIdea.Gazers}
+
+		nameSimilarity := trigramSimilarity(ideaName, candidate.Name)
+		fullSimilarity := trigramSimilarity(ideaName+" "+ideaDescription, candidate.Name+" "+candidate.Description)
+		candidate.Similarity = nameSimilarity
+		if fullSimilarity > nameSimilarity {
+			candidate.Similarity = fullSimilarity
+		}
+
+		if candidate.Similarity >= duplicateDetectionConfig.SimilarityThreshold {
+			duplicates = append(duplicates, candidate)
+		}
+	}
+
+	sort.Slice(duplicates, func(first, second int) bool {
+		return duplicates[first].Similarity > duplicates[second].Similarity
+	})
+	if len(duplicates) > maxDuplicatesReturned {
+		duplicates = duplicates[:maxDuplicatesReturned]
+	}
+
+	return duplicates, nil
+}
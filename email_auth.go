@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	identityProviderEmail = "email"
+	// Accounts which are not from GitHub count down from -1, so their ids never clash with GitHub ids
+	localUserIDSequence = "local_user_id"
+	maxEmailLength      = 254
+)
+
+var errLoginTokenNotValid = errors.New("Login link is not valid or has expired")
+var errMailServerWithoutTLS = errors.New("Mail server does not offer STARTTLS, set SMTP_INSECURE=true to send login links without TLS")
+
+// EmailAuthConfig : Sign in with a link sent by email, for users without a GitHub account.
+// An empty signing secret turns it off
+type EmailAuthConfig struct {
+	SigningSecret string
+	LinkLifetime  time.Duration
+	// Links sent to one address within the sign in throttle window, 0 turns the limit off
+	MaxLinksPerAddress int64
+	// Page of the frontend the link opens with the token, it exchanges the token with GET /auth/email/verify
+	LinkURL      string
+	Sender       string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// Links are bearer credentials, they are only sent without TLS when this is set for a local mail server
+	SMTPInsecure bool
+}
+
+// EmailLoginInput : Address the login link is sent to
+type EmailLoginInput struct {
+	Email string `json:"email"`
+}
+
+// LoginTokenClaims : What the signature of a login link covers
+type LoginTokenClaims struct {
+	Email     string
+	ExpiresAt time.Time
+	Nonce     string
+}
+
+func loadEmailAuthConfig(configLoader *ConfigLoader, frontendOrigin string) EmailAuthConfig {
+	var emailAuthConfig EmailAuthConfig
+
+	emailAuthConfig.SigningSecret = configLoader.String("EMAIL_AUTH_SECRET", "")
+	if emailAuthConfig.SigningSecret == "" {
+		return emailAuthConfig
+	}
+	if len(emailAuthConfig.SigningSecret) < 32 {
+		configLoader.Invalid("EMAIL_AUTH_SECRET", "should be at least 32 characters")
+	}
+
+	emailAuthConfig.LinkLifetime = time.Duration(configLoader.Int("EMAIL_LINK_MINUTES", 15)) * time.Minute
+	if emailAuthConfig.LinkLifetime <= 0 {
+		configLoader.Invalid("EMAIL_LINK_MINUTES", "should be more than 0")
+	}
+	emailAuthConfig.MaxLinksPerAddress = configLoader.Int("EMAIL_MAX_LINKS_PER_ADDRESS", 5)
+	emailAuthConfig.LinkURL = configLoader.String("EMAIL_LINK_URL", strings.TrimRight(frontendOrigin, "/")+"/auth/email")
+	if strings.HasPrefix(emailAuthConfig.LinkURL, "https://") == false && strings.HasPrefix(emailAuthConfig.LinkURL, "http://") == false {
+		configLoader.Invalid("EMAIL_LINK_URL", "should be an http or https url, got "+strconv.Quote(emailAuthConfig.LinkURL))
+	}
+
+	emailAuthConfig.Sender = configLoader.Required("EMAIL_SENDER", "address login links are sent from when EMAIL_AUTH_SECRET is set")
+	if _, errInSender := mail.ParseAddress(emailAuthConfig.Sender); emailAuthConfig.Sender != "" && errInSender != nil {
+		configLoader.Invalid("EMAIL_SENDER", "should be an email address, got "+strconv.Quote(emailAuthConfig.Sender))
+	}
+	emailAuthConfig.SMTPHost = configLoader.Required("SMTP_HOST", "mail server login links are sent through when EMAIL_AUTH_SECRET is set")
+	emailAuthConfig.SMTPPort = configLoader.String("SMTP_PORT", "587")
+	if _, errInPort := strconv.Atoi(emailAuthConfig.SMTPPort); errInPort != nil {
+		configLoader.Invalid("SMTP_PORT", "should be a port number, got "+strconv.Quote(emailAuthConfig.SMTPPort))
+	}
+	emailAuthConfig.SMTPUsername = configLoader.String("SMTP_USERNAME", "")
+	emailAuthConfig.SMTPPassword = configLoader.String("SMTP_PASSWORD", "")
+	emailAuthConfig.SMTPInsecure = configLoader.Bool("SMTP_INSECURE", false)
+
+	return emailAuthConfig
+}
+
+// normalizeEmail : Bare address in lower case, names like "Jane <jane@example.com>" are refused
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || len(email) > maxEmailLength {
+		return "", errors.New("Email should be an address of at most " + strconv.Itoa(maxEmailLength) + " characters")
+	}
+
+	parsedAddress, errInParsing := mail.ParseAddress(email)
+	if errInParsing != nil || parsedAddress.Address != email {
+		return "", errors.New("Email should be an address like jane@example.com")
+	}
+	return email, nil
+}
+
+func signatureOf(signingSecret string, payload string) string {
+	signature := hmac.New(sha256.New, []byte(signingSecret))
+	signature.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(signature.Sum(nil))
+}
+
+// signLoginToken : Address, expiry and a nonce with their signature, so a link can neither be forged nor changed
+func signLoginToken(signingSecret string, email string, expiresAt time.Time) (string, error) {
+	nonceBytes := make([]byte, 16)
+	_, errInGenerating := rand.Read(nonceBytes)
+	if errInGenerating != nil {
+		return "", errInGenerating
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(email + "\n" + strconv.FormatInt(expiresAt.Unix(), 10) + "\n" +
+		hex.EncodeToString(nonceBytes)))
+	return payload + "." + signatureOf(signingSecret, payload), nil
+}
+
+// readLoginToken : Claims of a token signed with the secret which has not expired yet
+func readLoginToken(signingSecret string, loginToken string) (LoginTokenClaims, error) {
+	tokenParts := strings.Split(loginToken, ".")
+	if len(tokenParts) != 2 || hmac.Equal([]byte(signatureOf(signingSecret, tokenParts[0])), []byte(tokenParts[1])) == false {
+		return LoginTokenClaims{}, errLoginTokenNotValid
+	}
+
+	payload, errInDecoding := base64.RawURLEncoding.DecodeString(tokenParts[0])
+	if errInDecoding != nil {
+		return LoginTokenClaims{}, errLoginTokenNotValid
+	}
+	claimParts := strings.Split(string(payload), "\n")
+	if len(claimParts) != 3 {
+		return LoginTokenClaims{}, errLoginTokenNotValid
+	}
+	expiresAt, errInParsing := strconv.ParseInt(claimParts[1], 10, 64)
+	if errInParsing != nil || time.Now().Unix() > expiresAt {
+		return LoginTokenClaims{}, errLoginTokenNotValid
+	}
+
+	return LoginTokenClaims{Email: claimParts[0], ExpiresAt: time.Unix(expiresAt, 0), Nonce: claimParts[2]}, nil
+}
+
+// loginLinkOf : Token is added to the query of the configured link
+func loginLinkOf(linkURL string, loginToken string) string {
+	separator := "?"
+	if strings.Contains(linkURL, "?") {
+		separator = "&"
+	}
+	return linkURL + separator + "token=" + url.QueryEscape(loginToken)
+}
+
+// sendLoginLink : Mail server is reached with the request context, so a slow one does not outlive the request.
+// Port 465 is spoken over TLS, other ports have to offer STARTTLS unless SMTP_INSECURE is set
+func sendLoginLink(requestContext context.Context, emailAuthConfig EmailAuthConfig, apiName string, email string, loginLink string) error {
+	serverConnection, errInDialing := (&net.Dialer{}).DialContext(requestContext, "tcp",
+		net.JoinHostPort(emailAuthConfig.SMTPHost, emailAuthConfig.SMTPPort))
+	if errInDialing != nil {
+		return errInDialing
+	}
+	if deadline, hasDeadline := requestContext.Deadline(); hasDeadline == true {
+		serverConnection.SetDeadline(deadline)
+	}
+	isImplicitTLS := emailAuthConfig.SMTPPort == "465"
+	if isImplicitTLS == true {
+		serverConnection = tls.Client(serverConnection, &tls.Config{ServerName: emailAuthConfig.SMTPHost})
+	}
+
+	smtpClient, errInGreeting := smtp.NewClient(serverConnection, emailAuthConfig.SMTPHost)
+	if errInGreeting != nil {
+		serverConnection.Close()
+		return errInGreeting
+	}
+	defer smtpClient.Close()
+
+	// A missing STARTTLS may have been stripped on the way, the link is not sent in the clear then
+	hasStartTLS, _ := smtpClient.Extension("STARTTLS")
+	if isImplicitTLS == false && hasStartTLS == true {
+		errInStartingTLS := smtpClient.StartTLS(&tls.Config{ServerName: emailAuthConfig.SMTPHost})
+		if errInStartingTLS != nil {
+			return errInStartingTLS
+		}
+	}
+	if isImplicitTLS == false && hasStartTLS == false && emailAuthConfig.SMTPInsecure == false {
+		return errMailServerWithoutTLS
+	}
+	if emailAuthConfig.SMTPUsername != "" {
+		errInAuth := smtpClient.Auth(smtp.PlainAuth("", emailAuthConfig.SMTPUsername, emailAuthConfig.SMTPPassword, emailAuthConfig.SMTPHost))
+		if errInAuth != nil {
+			return errInAuth
+		}
+	}
+
+	errInSending := smtpClient.Mail(emailAuthConfig.Sender)
+	if errInSending == nil {
+		errInSending = smtpClient.Rcpt(email)
+	}
+	if errInSending != nil {
+		return errInSending
+	}
+	messageWriter, errInSending := smtpClient.Data()
+	if errInSending != nil {
+		return errInSending
+	}
+
+	validFor := strconv.FormatInt(int64(emailAuthConfig.LinkLifetime.Minutes()), 10) + " minutes"
+	message := "From: " + emailAuthConfig.Sender + "\r\n" +
+		"To: " + email + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", "Sign in to "+apiName) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Open this link to sign in, it works once within " + validFor + ":\r\n\r\n" +
+		loginLink + "\r\n\r\n" +
+		"If you did not ask to sign in, you can ignore this email.\r\n"
+	_, errInSending = messageWriter.Write([]byte(message))
+	if errInSending == nil {
+		errInSending = messageWriter.Close()
+	}
+	if errInSending != nil {
+		return errInSending
+	}
+	return smtpClient.Quit()
+}
+
+// nextLocalUserID : Ids of accounts not from GitHub, -1 first
+func nextLocalUserID(databaseContext context.Context, databaseClient *mongo.Client) (int64, error) {
+	sequencesCollection := databaseClient.Database("sardene-db").Collection("sequences")
+
+	sequenceUpdate := bson.M{"$inc": bson.M{"value": -1}}
+	sequenceOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var sequence struct {
+		Value int64 `bson:"value"`
+	}
+	errInIncreasing := sequencesCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": localUserIDSequence}, sequenceUpdate,
+		sequenceOptions).Decode(&sequence)
+	if isDuplicateKeyError(errInIncreasing) {
+		errInIncreasing = sequencesCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": localUserIDSequence}, sequenceUpdate,
+			sequenceOptions).Decode(&sequence)
+	}
+	return sequence.Value, errInIncreasing
+}
+
+// loginFromEmail : Name part of the address with the id of the account. GitHub logins cannot have an underscore,
+// so these never clash with one
+func loginFromEmail(email string, userID int64) string {
+	var login strings.Builder
+	for _, character := range email[:strings.LastIndex(email, "@")] {
+		if (character >= 'a' && character <= 'z') || (character >= '0' && character <= '9') {
+			login.WriteRune(character)
+		} else if login.Len() > 0 && strings.HasSuffix(login.String(), "-") == false {
+			login.WriteRune('-')
+		}
+		if login.Len() == 30 {
+			break
+		}
+	}
+
+	loginPrefix := strings.Trim(login.String(), "-")
+	if loginPrefix == "" {
+		loginPrefix = "user"
+	}
+	return loginPrefix + "_" + strconv.FormatInt(-userID, 10)
+}
+
+// findOrCreateEmailUser : Email identity leads to the account, the first sign in of an address creates both
+func findOrCreateEmailUser(databaseContext context.Context, databaseClient *mongo.Client, stores Stores, email string) (UserStructure, error) {
+	identitiesCollection := databaseClient.Database("sardene-db").Collection("user_identities")
+	identityFilter := bson.M{"provider": identityProviderEmail, "provider_user_id": email}
+
+	var identity UserIdentityStructure
+	errInFinding := identitiesCollection.FindOne(databaseContext, identityFilter).Decode(&identity)
+	if errInFinding == mongo.ErrNoDocuments {
+		newUserID, errInNumbering := nextLocalUserID(databaseContext, databaseClient)
+		if errInNumbering != nil {
+			return UserStructure{}, errInNumbering
+		}
+
+		identity = UserIdentityStructure{UserID: newUserID, Provider: identityProviderEmail, ProviderUserID: email,
+			Login: loginFromEmail(email, newUserID), LinkedAt: time.Now().Unix()}
+		_, errInFinding = identitiesCollection.InsertOne(databaseContext, identity)
+		// Parallel first sign ins of one address are stopped by the unique index, the account of the first one is used
+		if isDuplicateKeyError(errInFinding) {
+			errInFinding = identitiesCollection.FindOne(databaseContext, identityFilter).Decode(&identity)
+		}
+	}
+	if errInFinding != nil {
+		return UserStructure{}, errInFinding
+	}
+
+	// User is added after the identity, a failure in between is repaired on the next sign in
+	user, errInFindingUser := stores.Users.FindByUserID(databaseContext, identity.UserID)
+	if errInFindingUser == errNotFoundInStore {
+		user = UserStructure{UserID: identity.UserID, Login: identity.Login, CreatedAt: time.Now().Unix()}
+		errInFindingUser = stores.Users.Insert(databaseContext, user)
+		if errInFindingUser == errDuplicateInStore {
+			user, errInFindingUser = stores.Users.FindByUserID(databaseContext, identity.UserID)
+		}
+	}
+	return user, errInFindingUser
+}
+
+// requestEmailLogin : Anyone can ask for a link, the account is only made once the link is opened
+func requestEmailLogin(ginContext *gin.Context, databaseClient *mongo.Client, emailAuthConfig EmailAuthConfig,
+	authThrottleConfig AuthThrottleConfig, brandingConfig BrandingConfig) {
+	var jsonInput EmailLoginInput
+	errInInput := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInput != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong structure of posted data"})
+		return
+	}
+	email, errInEmail := normalizeEmail(jsonInput.Email)
+	if errInEmail != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Email is not valid", "errorDetails": errInEmail.Error()})
+		return
+	}
+
+	if throttleAuth(ginContext, databaseClient, authThrottleConfig, email) == false {
+		return
+	}
+	// Otherwise anyone could flood an inbox with links
+	if emailAuthConfig.MaxLinksPerAddress > 0 {
+		windowStart, windowEnd := authWindowOf(authThrottleConfig.Window)
+		linksSent, errInCounting := increaseAuthCounter(ginContext.Request.Context(), databaseClient,
+			authCounterID("email_links", hashAuthCode(email), windowStart), windowEnd)
+		if errInCounting != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in checking sign in attempts", "errorDetails": errInCounting.Error()})
+			return
+		}
+		if linksSent > emailAuthConfig.MaxLinksPerAddress {
+			abortAuthThrottled(ginContext, windowEnd, "Too many login links sent to this address, try again later")
+			return
+		}
+	}
+
+	expiresAt := time.Now().Add(emailAuthConfig.LinkLifetime)
+	loginToken, errInSigning := signLoginToken(emailAuthConfig.SigningSecret, email, expiresAt)
+	if errInSigning != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error in creating login link", "errorDetails": errInSigning.Error()})
+		return
+	}
+
+	errInSending := sendLoginLink(ginContext.Request.Context(), emailAuthConfig, brandingConfig.APIName, email,
+		loginLinkOf(emailAuthConfig.LinkURL, loginToken))
+	if errInSending != nil {
+		ginContext.JSON(http.StatusBadGateway, gin.H{"status": http.StatusBadGateway,
+			"error": "Error in sending login link", "errorDetails": errInSending.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusAccepted, gin.H{"status": http.StatusAccepted, "message": "Login link was sent to " + email,
+		"data": gin.H{"expires_at": expiresAt.Unix()}})
+}
+
+// verifyEmailLogin : Link works once, its nonce is kept until it would have expired anyway
+func verifyEmailLogin(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, userSessions *SessionStore,
+	emailAuthConfig EmailAuthConfig, authThrottleConfig AuthThrottleConfig) {
+	ginContext.Header("Cache-Control", "no-store")
+
+	loginToken := strings.TrimSpace(ginContext.Query("token"))
+	if loginToken == "" {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Token of the login link is not provided"})
+		return
+	}
+
+	if throttleAuth(ginContext, databaseClient, authThrottleConfig, loginToken) == false {
+		return
+	}
+	defer func() {
+		if ginContext.Writer.Status() == http.StatusForbidden {
+			recordAuthFailure(ginContext, databaseClient, authThrottleConfig, loginToken)
+		}
+	}()
+
+	claims, errInReading := readLoginToken(emailAuthConfig.SigningSecret, loginToken)
+	if errInReading != nil {
+		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "error": errInReading.Error()})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+	usedLinksCollection := databaseClient.Database("sardene-db").Collection("email_login_links")
+	_, errInUsing := usedLinksCollection.InsertOne(databaseContext, bson.M{"_id": claims.Nonce, "expires_at": claims.ExpiresAt})
+	if isDuplicateKeyError(errInUsing) {
+		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+			"error": "Login link was already used, ask for a new one"})
+		return
+	}
+	if errInUsing != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInUsing.Error()})
+		return
+	}
+
+	user, errInAddingUser := findOrCreateEmailUser(databaseContext, databaseClient, stores, claims.Email)
+	if errInAddingUser != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Cannot add user in database", "errorDetails": errInAddingUser.Error()})
+		return
+	}
+
+	sessionToken, sessionExpiresAt, errInCreatingSession := userSessions.create(databaseContext, user, identityProviderEmail, "")
+	if errInCreatingSession != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Cannot create session", "errorDetails": errInCreatingSession.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK,
+		"data": SessionAuthUser{UserID: user.UserID, Login: user.Login, Name: user.Name, AccessToken: sessionToken,
+			TokenType: "bearer", ExpiresAt: sessionExpiresAt.Unix()}})
+}
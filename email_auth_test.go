@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSMTPWithoutTLS : Mail server on a local port which accepts any mail and never offers STARTTLS,
+// as when the offer is stripped on the way. Messages it was handed are sent on the channel
+func serveSMTPWithoutTLS(t *testing.T) (string, chan string) {
+	listener, errInListening := net.Listen("tcp", "127.0.0.1:0")
+	if errInListening != nil {
+		t.Fatal(errInListening)
+	}
+	messages := make(chan string, 1)
+
+	go func() {
+		defer listener.Close()
+		connection, errInAccepting := listener.Accept()
+		if errInAccepting != nil {
+			return
+		}
+		defer connection.Close()
+
+		reader := bufio.NewReader(connection)
+		connection.Write([]byte("220 localhost ESMTP\r\n"))
+		var message strings.Builder
+		isInData := false
+		for {
+			line, errInReading := reader.ReadString('\n')
+			if errInReading != nil {
+				return
+			}
+			if isInData == true {
+				if line == ".\r\n" {
+					isInData = false
+					messages <- message.String()
+					connection.Write([]byte("250 OK\r\n"))
+				} else {
+					message.WriteString(line)
+				}
+				continue
+			}
+
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"):
+				connection.Write([]byte("250-localhost\r\n250 8BITMIME\r\n"))
+			case command == "DATA":
+				isInData = true
+				connection.Write([]byte("354 Go ahead\r\n"))
+			case command == "QUIT":
+				connection.Write([]byte("221 Bye\r\n"))
+				return
+			default:
+				connection.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+func testEmailAuthConfig(serverAddress string) EmailAuthConfig {
+	host, port, _ := net.SplitHostPort(serverAddress)
+	return EmailAuthConfig{Sender: "sardene@example.com", SMTPHost: host, SMTPPort: port, LinkLifetime: 15 * time.Minute}
+}
+
+func TestLoginLinkIsNotSentWithoutTLS(t *testing.T) {
+	serverAddress, messages := serveSMTPWithoutTLS(t)
+
+	requestContext, cancelRequestContext := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelRequestContext()
+	errInSending := sendLoginLink(requestContext, testEmailAuthConfig(serverAddress), "Sardene", "jane@example.com",
+		"https://sardene.example.com/auth/email?token=secret")
+
+	if errInSending != errMailServerWithoutTLS {
+		t.Fatalf("Sending without STARTTLS failed with %v", errInSending)
+	}
+	select {
+	case message := <-messages:
+		t.Fatalf("Login link was sent in the clear: %s", message)
+	default:
+	}
+}
+
+func TestLoginLinkIsSentWithoutTLSWhenInsecureIsSet(t *testing.T) {
+	serverAddress, messages := serveSMTPWithoutTLS(t)
+	emailAuthConfig := testEmailAuthConfig(serverAddress)
+	emailAuthConfig.SMTPInsecure = true
+
+	requestContext, cancelRequestContext := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelRequestContext()
+	errInSending := sendLoginLink(requestContext, emailAuthConfig, "Sardene", "jane@example.com",
+		"https://sardene.example.com/auth/email?token=secret")
+
+	if errInSending != nil {
+		t.Fatal(errInSending)
+	}
+	if message := <-messages; strings.Contains(message, "token=secret") == false {
+		t.Fatalf("Message does not hold the login link: %s", message)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	errorReportsBufferSize = 100
+	// Longest part of a response body kept in a report
+	maxReportedBodyLength = 2000
+)
+
+// ErrorReportingConfig : Reports go to a Sentry compatible service, an empty DSN turns reporting off
+type ErrorReportingConfig struct {
+	DSN         string
+	Release     string
+	Environment string
+}
+
+// ErrorReportStructure : Event in the shape of the Sentry store endpoint
+type ErrorReportStructure struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Logger      string                 `json:"logger"`
+	Message     string                 `json:"message,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Exception   *ErrorReportException  `json:"exception,omitempty"`
+	Request     ErrorReportRequest     `json:"request"`
+	User        *ErrorReportUser       `json:"user,omitempty"`
+	Tags        map[string]string      `json:"tags"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+// ErrorReportException : Panic with the stack it was raised from
+type ErrorReportException struct {
+	Values []ErrorReportExceptionValue `json:"values"`
+}
+
+// ErrorReportExceptionValue : One exception, Sentry allows a chain of them
+type ErrorReportExceptionValue struct {
+	Type       string                `json:"type"`
+	Value      string                `json:"value"`
+	Stacktrace ErrorReportStacktrace `json:"stacktrace"`
+}
+
+// ErrorReportStacktrace : Frames are oldest first
+type ErrorReportStacktrace struct {
+	Frames []ErrorReportFrame `json:"frames"`
+}
+
+// ErrorReportFrame : Function and line of one frame
+type ErrorReportFrame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// ErrorReportRequest : Request which failed, headers are left out as they carry tokens
+type ErrorReportRequest struct {
+	URL         string `json:"url"`
+	Method      string `json:"method"`
+	QueryString string `json:"query_string,omitempty"`
+}
+
+// ErrorReportUser : Only what is needed to find the user, never the access token
+type ErrorReportUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+// ErrorReporter : Sends reports away from the request, nil when reporting is off
+type ErrorReporter struct {
+	storeURL    string
+	authHeader  string
+	release     string
+	environment string
+	serverName  string
+	reports     chan ErrorReportStructure
+}
+
+var errorReporter *ErrorReporter
+
+func loadErrorReportingConfig(configLoader *ConfigLoader, environment string) ErrorReportingConfig {
+	var errorReportingConfig ErrorReportingConfig
+
+	errorReportingConfig.DSN = configLoader.String("SENTRY_DSN", "")
+	// Heroku sets the commit of the slug once runtime dyno metadata is switched on
+	errorReportingConfig.Release = configLoader.String("SENTRY_RELEASE", configLoader.String("HEROKU_SLUG_COMMIT", ""))
+	errorReportingConfig.Environment = configLoader.String("SENTRY_ENVIRONMENT", environment)
+	if errorReportingConfig.DSN != "" {
+		if _, _, errInDSN := parseErrorReportingDSN(errorReportingConfig.DSN); errInDSN != nil {
+			configLoader.Invalid("SENTRY_DSN", errInDSN.Error())
+		}
+	}
+
+	return errorReportingConfig
+}
+
+// parseErrorReportingDSN : DSN is like https://<key>@<host>/<project>, the store endpoint and auth header come from it
+func parseErrorReportingDSN(dsn string) (string, string, error) {
+	parsedDSN, errInParsing := url.Parse(dsn)
+	if errInParsing != nil || (parsedDSN.Scheme != "https" && parsedDSN.Scheme != "http") || parsedDSN.Host == "" {
+		return "", "", errors.New("should be like https://<key>@<host>/<project>")
+	}
+	publicKey := parsedDSN.User.Username()
+	projectPath := strings.Trim(parsedDSN.Path, "/")
+	lastSlash := strings.LastIndex(projectPath, "/")
+	projectID := projectPath[lastSlash+1:]
+	if publicKey == "" || projectID == "" {
+		return "", "", errors.New("should have a key and a project, like https://<key>@<host>/<project>")
+	}
+
+	pathPrefix := ""
+	if lastSlash >= 0 {
+		pathPrefix = "/" + projectPath[:lastSlash]
+	}
+	storeURL := parsedDSN.Scheme + "://" + parsedDSN.Host + pathPrefix + "/api/" + projectID + "/store/"
+	authHeader := "Sentry sentry_version=7, sentry_client=sardene-api/1.0, sentry_key=" + publicKey
+	if secretKey, hasSecret := parsedDSN.User.Password(); hasSecret == true {
+		authHeader = authHeader + ", sentry_secret=" + secretKey
+	}
+	return storeURL, authHeader, nil
+}
+
+func newErrorReporter(errorReportingConfig ErrorReportingConfig) *ErrorReporter {
+	storeURL, authHeader, errInDSN := parseErrorReportingDSN(errorReportingConfig.DSN)
+	if errInDSN != nil {
+		log.Fatal(errInDSN, "Failed to read SENTRY_DSN")
+	}
+	serverName, _ := os.Hostname()
+
+	reporter := &ErrorReporter{
+		storeURL:    storeURL,
+		authHeader:  authHeader,
+		release:     errorReportingConfig.Release,
+		environment: errorReportingConfig.Environment,
+		serverName:  serverName,
+		reports:     make(chan ErrorReportStructure, errorReportsBufferSize),
+	}
+	go reporter.run()
+
+	return reporter
+}
+
+// Report : Never waits, reports are dropped when the service cannot keep up
+func (reporter *ErrorReporter) Report(report ErrorReportStructure) {
+	select {
+	case reporter.reports <- report:
+	default:
+		log.Println("Dropped error report of request", report.Tags["request_id"])
+	}
+}
+
+func (reporter *ErrorReporter) run() {
+	for report := range reporter.reports {
+		errInSending := reporter.send(report)
+		if errInSending != nil {
+			log.Println(errInSending, "Failed to send error report of request", report.Tags["request_id"])
+		}
+	}
+}
+
+func (reporter *ErrorReporter) send(report ErrorReportStructure) error {
+	reportInJSON, errInEncoding := json.Marshal(report)
+	if errInEncoding != nil {
+		return errInEncoding
+	}
+
+	request, errInRequest := http.NewRequest(http.MethodPost, reporter.storeURL, bytes.NewReader(reportInJSON))
+	if errInRequest != nil {
+		return errInRequest
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Sentry-Auth", reporter.authHeader)
+
+	response, errInResponse := outboundHTTPClient.Do(request)
+	if errInResponse != nil {
+		return errInResponse
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("error reporting service answered %d", response.StatusCode)
+	}
+	return nil
+}
+
+// newErrorReport : Fills in what every report of a request carries, the user is known once the route authorized one
+func (reporter *ErrorReporter) newErrorReport(ginContext *gin.Context, level string) ErrorReportStructure {
+	eventID := make([]byte, 16)
+	rand.Read(eventID)
+
+	report := ErrorReportStructure{
+		EventID:     hex.EncodeToString(eventID),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       level,
+		Platform:    "go",
+		Logger:      "sardene-api",
+		Release:     reporter.release,
+		Environment: reporter.environment,
+		ServerName:  reporter.serverName,
+		Request: ErrorReportRequest{
+			URL:         ginContext.Request.URL.Path,
+			Method:      ginContext.Request.Method,
+			QueryString: ginContext.Request.URL.RawQuery,
+		},
+		Tags: map[string]string{"request_id": ginContext.GetString("requestID")},
+	}
+	if user := getAuthenticatedUser(ginContext); user.UserID != 0 {
+		report.User = &ErrorReportUser{ID: strconv.FormatInt(user.UserID, 10), Username: user.Login}
+	}
+	return report
+}
+
+// reportPanic : Called from the recovery of the request, so the frames of the panic are still on the stack
+func reportPanic(ginContext *gin.Context, recovered interface{}) {
+	if errorReporter == nil {
+		return
+	}
+
+	report := errorReporter.newErrorReport(ginContext, "fatal")
+	report.Tags["status"] = strconv.Itoa(http.StatusInternalServerError)
+	report.Exception = &ErrorReportException{Values: []ErrorReportExceptionValue{{
+		Type:       fmt.Sprintf("%T", recovered),
+		Value:      fmt.Sprint(recovered),
+		Stacktrace: ErrorReportStacktrace{Frames: panicFrames()},
+	}}}
+	errorReporter.Report(report)
+}
+
+// panicFrames : Frames from the handler which panicked up to the server, without the runtime and recovery ones
+func panicFrames() []ErrorReportFrame {
+	programCounters := make([]uintptr, 64)
+	callersCount := runtime.Callers(3, programCounters)
+	callerFrames := runtime.CallersFrames(programCounters[:callersCount])
+
+	var frames []ErrorReportFrame
+	for {
+		callerFrame, hasMore := callerFrames.Next()
+		// Everything above the panic is the recovery itself
+		if callerFrame.Function == "runtime.gopanic" {
+			frames = nil
+			if hasMore == false {
+				break
+			}
+			continue
+		}
+		frames = append([]ErrorReportFrame{{
+			Function: callerFrame.Function,
+			Filename: callerFrame.File,
+			Lineno:   callerFrame.Line,
+			InApp:    strings.HasPrefix(callerFrame.Function, "main."),
+		}}, frames...)
+		if hasMore == false {
+			break
+		}
+	}
+	return frames
+}
+
+// errorCapturingWriter : Keeps the body only once the handler answered with a server error
+type errorCapturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (responseWriter *errorCapturingWriter) Write(data []byte) (int, error) {
+	if responseWriter.Status() >= http.StatusInternalServerError && responseWriter.body.Len() < maxReportedBodyLength {
+		responseWriter.body.Write(data)
+	}
+	return responseWriter.ResponseWriter.Write(data)
+}
+
+// reportServerErrors : Handlers answer their failures with an error and errorDetails instead of returning them,
+// so every 5xx answer is reported with what it told the client
+func reportServerErrors() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		if errorReporter == nil {
+			ginContext.Next()
+			return
+		}
+
+		capturingWriter := &errorCapturingWriter{ResponseWriter: ginContext.Writer}
+		ginContext.Writer = capturingWriter
+		ginContext.Next()
+
+		status := ginContext.Writer.Status()
+		if status < http.StatusInternalServerError {
+			return
+		}
+
+		report := errorReporter.newErrorReport(ginContext, "error")
+		report.Tags["status"] = strconv.Itoa(status)
+		report.Extra = map[string]interface{}{}
+
+		var errorResponse struct {
+			Error        string `json:"error"`
+			ErrorDetails string `json:"errorDetails"`
+		}
+		if json.Unmarshal(capturingWriter.body.Bytes(), &errorResponse) == nil && errorResponse.Error != "" {
+			report.Message = errorResponse.Error
+			if errorResponse.ErrorDetails != "" {
+				report.Message = errorResponse.Error + ": " + errorResponse.ErrorDetails
+			}
+		} else if capturingWriter.body.Len() > 0 {
+			report.Extra["body"] = capturingWriter.body.String()
+		}
+		if len(ginContext.Errors) > 0 {
+			report.Extra["errors"] = ginContext.Errors.Errors()
+		}
+		if report.Message == "" {
+			report.Message = strconv.Itoa(status) + " " + http.StatusText(status) + " in " + ginContext.Request.Method + " " +
+				ginContext.Request.URL.Path
+		}
+		errorReporter.Report(report)
+	}
+}
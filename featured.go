@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	auditActionIdeaFeatured   = "idea.featured"
+	auditActionIdeaUnfeatured = "idea.unfeatured"
+)
+
+// IdeaFeaturedInput : Structure for incoming featured flag, a pointer so a missing flag is told apart from false
+type IdeaFeaturedInput struct {
+	Featured *bool `json:"featured"`
+}
+
+func setIdeaFeatured(ginContext *gin.Context, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	var jsonInput IdeaFeaturedInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil || jsonInput.Featured == nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Featured should be either true or false"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea != nil {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Idea not found"})
+		return
+	}
+	// Only public ideas can be highlighted to the whole community
+	if *jsonInput.Featured == true && isIdeaPublic(idea) == false {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "Error, Only public ideas can be featured"})
+		return
+	}
+	if idea.Featured == *jsonInput.Featured {
+		ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Idea is already in that featured status"})
+		return
+	}
+
+	var featuredAt int64
+	if *jsonInput.Featured == true {
+		featuredAt = time.Now().Unix()
+	}
+	errInSetting := stores.Ideas.SetFeatured(databaseContext, hexIdeaID, *jsonInput.Featured, featuredAt)
+	if errInSetting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInSetting.Error()})
+		return
+	}
+
+	auditAction := auditActionIdeaUnfeatured
+	if *jsonInput.Featured == true {
+		auditAction = auditActionIdeaFeatured
+	}
+	ideaAfterChange := idea
+	ideaAfterChange.Featured = *jsonInput.Featured
+	ideaAfterChange.FeaturedAt = featuredAt
+	describeAuditedMutation(ginContext, auditAction, hexIdeaID.Hex(), idea, ideaAfterChange)
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Featured status of idea changed successfully"})
+}
+
+func getFeaturedIdeas(ginContext *gin.Context, stores Stores) {
+	databaseContext := ginContext.Request.Context()
+
+	featuredIdeas, errInFinding := stores.Ideas.ListFeatured(databaseContext)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	if featuredIdeas == nil {
+		featuredIdeas = []IdeaStructure{}
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": featuredIdeas, "count": len(featuredIdeas)})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FollowStructure : Structure of follow in follows collection, the follower gets ideas of the followee in their feed
+type FollowStructure struct {
+	FollowerID    int64  `json:"follower_id" bson:"follower_id"`
+	FolloweeID    int64  `json:"followee_id" bson:"followee_id"`
+	FolloweeLogin string `json:"followee_login" bson:"followee_login"`
+	CreatedAt     int64  `json:"created_at" bson:"created_at"`
+}
+
+// findFollowee : User behind the login, answers the request itself when there is none
+func findFollowee(ginContext *gin.Context, stores Stores, login string) (UserStructure, bool) {
+	followee, errInFindingUser := stores.Users.FindByLogin(ginContext.Request.Context(), login)
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, No user with this login has signed in"})
+			return followee, false
+		}
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUser.Error()})
+		return followee, false
+	}
+	return followee, true
+}
+
+func followUser(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, login string) {
+	follower := getAuthenticatedUser(ginContext)
+
+	followee, isFolloweeFound := findFollowee(ginContext, stores, login)
+	if isFolloweeFound == false {
+		return
+	}
+	if followee.UserID == follower.UserID {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Users cannot follow themselves"})
+		return
+	}
+
+	followsCollection := databaseClient.Database("sardene-db").Collection("follows")
+	databaseContext := ginContext.Request.Context()
+
+	followToAdd := FollowStructure{
+		FollowerID:    follower.UserID,
+		FolloweeID:    followee.UserID,
+		FolloweeLogin: followee.Login,
+		CreatedAt:     time.Now().Unix(),
+	}
+	_, errInAdding := followsCollection.InsertOne(databaseContext, followToAdd)
+	if errInAdding != nil {
+		if isDuplicateKeyError(errInAdding) {
+			ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+				"error": "Error, User is already followed"})
+			return
+		}
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "User followed successfully"})
+}
+
+func unfollowUser(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, login string) {
+	follower := getAuthenticatedUser(ginContext)
+
+	followee, isFolloweeFound := findFollowee(ginContext, stores, login)
+	if isFolloweeFound == false {
+		return
+	}
+
+	followsCollection := databaseClient.Database("sardene-db").Collection("follows")
+	databaseContext := ginContext.Request.Context()
+
+	resultOfDeleting, errInDeleting := followsCollection.DeleteOne(databaseContext,
+		bson.M{"follower_id": follower.UserID, "followee_id": followee.UserID})
+	if errInDeleting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+	if resultOfDeleting.DeletedCount == 0 {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, User is not followed"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "User unfollowed successfully"})
+}
+
+// getFeed : Public ideas of followed publishers, newest first
+func getFeed(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores) {
+	user := getAuthenticatedUser(ginContext)
+
+	pagination, errInPagination := getPaginationFromQuery(ginContext, 20, 100)
+	if errInPagination != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong pagination", "errorDetails": errInPagination.Error()})
+		return
+	}
+
+	followsCollection := databaseClient.Database("sardene-db").Collection("follows")
+	databaseContext := ginContext.Request.Context()
+
+	followsCursor, errInFinding := followsCollection.Find(databaseContext, bson.M{"follower_id": user.UserID},
+		options.Find().SetProjection(bson.M{"followee_id": 1}))
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	defer followsCursor.Close(databaseContext)
+
+	var followeeIDs []int64
+	for followsCursor.Next(databaseContext) {
+		var follow FollowStructure
+		errInDecoding := followsCursor.Decode(&follow)
+		if errInDecoding != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in decoding database", "errorDetails": errInDecoding.Error()})
+			return
+		}
+		followeeIDs = append(followeeIDs, follow.FolloweeID)
+	}
+
+	feedIdeas := []IdeaStructure{}
+	if len(followeeIDs) > 0 {
+		ideasOfFollowees, errInListing := stores.Ideas.ListPublishedByPublishers(databaseContext, followeeIDs,
+			pagination.Skip(), pagination.Limit)
+		if errInListing != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInListing.Error()})
+			return
+		}
+		feedIdeas = append(feedIdeas, ideasOfFollowees...)
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": feedIdeas, "count": len(feedIdeas),
+		"page": pagination.Page, "limit": pagination.Limit})
+}
+
+// deleteUserFollows : Follows in both directions go with the account
+func deleteUserFollows(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	followsCollection := databaseClient.Database("sardene-db").Collection("follows")
+
+	_, errInDeleting := followsCollection.DeleteMany(databaseContext,
+		bson.M{"$or": bson.A{bson.M{"follower_id": userID}, bson.M{"followee_id": userID}}})
+	return errInDeleting
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GithubIssueExportInput : Repo is owner/name or the url of the repo
+type GithubIssueExportInput struct {
+	Repo string `json:"repo"`
+}
+
+// githubIssueBody : Description of the idea with a link back to it, so the issue and the idea can be found from each other
+func githubIssueBody(idea IdeaStructure, frontendOrigin string) string {
+	ideaURL := ideaPageURL(strings.TrimRight(frontendOrigin, "/"), idea)
+	return idea.Description + "\n\n---\n_Exported from [" + idea.Name + "](" + ideaURL + ")_"
+}
+
+// exportIdeaToGithubIssue : Issue is created with the caller's own GitHub token, so it is opened by them
+// and only in repos they can open issues in
+func exportIdeaToGithubIssue(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores,
+	userSessions *SessionStore, ideaID string, brandingConfig BrandingConfig) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	var jsonInput GithubIssueExportInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	repo := strings.TrimSpace(jsonInput.Repo)
+	if strings.HasPrefix(repo, "https://") == false {
+		repo = "https://github.com/" + repo
+	}
+	repoMatch := githubRepoURLRegex.FindStringSubmatch(repo)
+	if errInInputJSON != nil || repoMatch == nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Repo should be owner/name or the url of a GitHub repo"})
+		return
+	}
+
+	// Sessions of a GitHub sign in keep its token, signing in with email gives none to open the issue with
+	userAccessToken, errInAccessToken := extractAuthHeader(ginContext)
+	if errInAccessToken == nil && isSessionToken(userAccessToken) == true {
+		userAccessToken, errInAccessToken = userSessions.githubTokenOfSession(ginContext.Request.Context(), userAccessToken)
+	}
+	if errInAccessToken != nil {
+		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+			"error": "Exporting to GitHub needs a GitHub sign in", "errorDetails": errInAccessToken.Error()})
+		return
+	}
+
+	user := getAuthenticatedUser(ginContext)
+	databaseContext := ginContext.Request.Context()
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea == nil && isIdeaVisibleTo(idea, user.UserID) == false {
+		errInFindingIdea = errNotFoundInStore
+	}
+	if errInFindingIdea == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+	if errInFindingIdea != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+		return
+	}
+
+	isMaker, errInCheckingMaker := isMakerOfIdea(databaseContext, databaseClient, user.UserID, hexIdeaID)
+	if errInCheckingMaker != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInCheckingMaker.Error()})
+		return
+	}
+	if isMaker == false {
+		editorStatus, errInCheckingEditor := isUserOwnerOfIdea(databaseContext, user, stores, databaseClient, ideaID, true)
+		if editorStatus == http.StatusForbidden {
+			ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+				"error": "Only makers of the idea can export it"})
+			return
+		}
+		if editorStatus != http.StatusOK {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInCheckingEditor.Error()})
+			return
+		}
+	}
+
+	issueInJSON, _ := json.Marshal(map[string]string{"title": idea.Name, "body": githubIssueBody(idea, brandingConfig.FrontendOrigin)})
+	requestIssue, errInRequestingIssue := http.NewRequestWithContext(databaseContext, "POST",
+		"https://api.github.com/repos/"+repoMatch[1]+"/"+repoMatch[2]+"/issues", bytes.NewReader(issueInJSON))
+	if errInRequestingIssue != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error in creating GitHub issue", "errorDetails": errInRequestingIssue.Error()})
+		return
+	}
+	requestIssue.Header.Set("Accept", "application/vnd.github.v3+json")
+	requestIssue.Header.Set("Content-Type", "application/json")
+	requestIssue.Header.Set("Authorization", "token "+userAccessToken)
+
+	// Creating an issue is not idempotent, it is never retried
+	responseWithIssue, errInResponseFromGithub := outboundHTTPClient.Do(requestIssue)
+	if errInResponseFromGithub == nil && responseWithIssue.StatusCode >= http.StatusInternalServerError {
+		responseWithIssue.Body.Close()
+		errInResponseFromGithub = &UpstreamUnavailableError{Host: requestIssue.URL.Host, Cause: "GitHub answered " + responseWithIssue.Status}
+	}
+	if isUpstreamUnavailable(errInResponseFromGithub) == true {
+		abortUpstreamUnavailable(ginContext, errInResponseFromGithub)
+		return
+	}
+	if errInResponseFromGithub != nil {
+		ginContext.JSON(http.StatusBadGateway, gin.H{"status": http.StatusBadGateway,
+			"error": "Error in creating GitHub issue", "errorDetails": errInResponseFromGithub.Error()})
+		return
+	}
+	defer responseWithIssue.Body.Close()
+
+	responseBytesWithIssue, errInResponseBody := ioutil.ReadAll(responseWithIssue.Body)
+	var githubIssue struct {
+		Number  int64  `json:"number"`
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+	}
+	if errInResponseBody == nil {
+		errInResponseBody = json.Unmarshal(responseBytesWithIssue, &githubIssue)
+	}
+
+	switch responseWithIssue.StatusCode {
+	case http.StatusCreated:
+	case http.StatusUnauthorized:
+		ginContext.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized,
+			"error": "GitHub did not accept the token, sign in again", "errorDetails": githubIssue.Message})
+		return
+	// GitHub answers 404 for private repos the token cannot see as well
+	case http.StatusNotFound, http.StatusForbidden, http.StatusGone:
+		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+			"error":        "Issues cannot be opened in " + repoMatch[1] + "/" + repoMatch[2] + " with your GitHub account",
+			"errorDetails": githubIssue.Message})
+		return
+	default:
+		ginContext.JSON(http.StatusBadGateway, gin.H{"status": http.StatusBadGateway,
+			"error": "Error in creating GitHub issue", "errorDetails": responseWithIssue.Status + " " + githubIssue.Message})
+		return
+	}
+	if errInResponseBody != nil || githubIssue.HTMLURL == "" {
+		ginContext.JSON(http.StatusBadGateway, gin.H{"status": http.StatusBadGateway,
+			"error": "Error in reading GitHub issue, it may have been created"})
+		return
+	}
+
+	errInSaving := stores.Ideas.SetGithubIssueURL(databaseContext, hexIdeaID, githubIssue.HTMLURL)
+	if errInSaving != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "GitHub issue was created but saving it to the idea failed", "errorDetails": errInSaving.Error(),
+			"data": gin.H{"issue_url": githubIssue.HTMLURL, "issue_number": githubIssue.Number}})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "Idea exported to GitHub issue successfully",
+		"data": gin.H{"issue_url": githubIssue.HTMLURL, "issue_number": githubIssue.Number}})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const maxCachedGithubProfiles = 10000
+
+type cachedGithubProfile struct {
+	profile   GithubUserProfileStructure
+	expiresAt time.Time
+	// Expired profiles still sign in reads until then while GitHub is unavailable
+	staleUntil time.Time
+}
+
+// GithubProfileCache : Profiles of access tokens GitHub answered for recently, so a burst of requests from one user
+// does not wait on GitHub every time. Tokens are kept only as their sha256 hash
+type GithubProfileCache struct {
+	mutex         sync.Mutex
+	cacheDuration time.Duration
+	staleDuration time.Duration
+	byTokenHash   map[string]cachedGithubProfile
+}
+
+// githubProfiles : Nil when GITHUB_PROFILE_CACHE_SECONDS is 0, every request is then checked with GitHub
+var githubProfiles *GithubProfileCache
+
+func newGithubProfileCache(cacheDuration time.Duration, staleDuration time.Duration) *GithubProfileCache {
+	return &GithubProfileCache{cacheDuration: cacheDuration, staleDuration: staleDuration,
+		byTokenHash: make(map[string]cachedGithubProfile)}
+}
+
+func hashOfAccessToken(accessToken string) string {
+	tokenHash := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(tokenHash[:])
+}
+
+// get : Profile of the token while it has not expired, or until it goes stale when allowStale is set
+func (cache *GithubProfileCache) get(accessToken string, allowStale bool) (GithubUserProfileStructure, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	tokenHash := hashOfAccessToken(accessToken)
+	cached, isCached := cache.byTokenHash[tokenHash]
+	if isCached == false {
+		return GithubUserProfileStructure{}, false
+	}
+	if time.Now().After(cached.staleUntil) {
+		delete(cache.byTokenHash, tokenHash)
+		return GithubUserProfileStructure{}, false
+	}
+	if allowStale == false && time.Now().After(cached.expiresAt) {
+		return GithubUserProfileStructure{}, false
+	}
+	return cached.profile, true
+}
+
+// set : Expired profiles are dropped once the cache is full, and everything when that is not enough
+func (cache *GithubProfileCache) set(accessToken string, profile GithubUserProfileStructure) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if len(cache.byTokenHash) >= maxCachedGithubProfiles {
+		for tokenHash, cached := range cache.byTokenHash {
+			if time.Now().After(cached.staleUntil) {
+				delete(cache.byTokenHash, tokenHash)
+			}
+		}
+		if len(cache.byTokenHash) >= maxCachedGithubProfiles {
+			cache.byTokenHash = make(map[string]cachedGithubProfile)
+		}
+	}
+	cache.byTokenHash[hashOfAccessToken(accessToken)] = cachedGithubProfile{profile: profile,
+		expiresAt: time.Now().Add(cache.cacheDuration), staleUntil: time.Now().Add(cache.cacheDuration + cache.staleDuration)}
+}
+
+// getCachedUserGithubProfile : Profile of the token from the cache, asking GitHub only on a miss.
+// Failed lookups are never cached, a revoked token is refused as soon as its cached profile expires.
+// While GitHub is unavailable, reads fall back to expired profiles which have not gone stale yet
+func getCachedUserGithubProfile(requestContext context.Context, accessToken string, isRead bool) (GithubUserProfileStructure, error) {
+	if githubProfiles == nil {
+		return getUserGithubProfile(requestContext, accessToken)
+	}
+
+	if cachedProfile, isCached := githubProfiles.get(accessToken, false); isCached == true {
+		return cachedProfile, nil
+	}
+
+	githubProfile, errInGettingProfile := getUserGithubProfile(requestContext, accessToken)
+	if errInGettingProfile != nil {
+		if isRead == true && isUpstreamUnavailable(errInGettingProfile) == true {
+			if staleProfile, isCached := githubProfiles.get(accessToken, true); isCached == true {
+				return staleProfile, nil
+			}
+		}
+		return githubProfile, errInGettingProfile
+	}
+	githubProfiles.set(accessToken, githubProfile)
+	return githubProfile, nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GithubRecordingConfig : Calls to GitHub can be written to cassettes and answered from them later,
+// so the auth and profile flows run in tests without real GitHub credentials
+type GithubRecordingConfig struct {
+	// off, record or replay
+	Mode        string
+	CassetteDir string
+}
+
+// GithubCassette : One recorded call to GitHub, secrets are replaced before it is written
+type GithubCassette struct {
+	Method            string              `json:"method"`
+	URL               string              `json:"url"`
+	BodyHash          string              `json:"body_sha256,omitempty"`
+	AuthorizationHash string              `json:"authorization_sha256,omitempty"`
+	Status            int                 `json:"status"`
+	Header            map[string][]string `json:"header"`
+	Body              string              `json:"body"`
+}
+
+// githubRecordingTransport : Records or replays requests to GitHub hosts, others go straight to the next transport
+type githubRecordingTransport struct {
+	next   http.RoundTripper
+	config GithubRecordingConfig
+	mutex  sync.Mutex
+	// Tokens GitHub handed out while recording, calls made with them are keyed with recordedGithubToken instead
+	redactedTokens map[string]bool
+}
+
+const recordedGithubToken = "recorded-github-token"
+
+var githubHosts = map[string]bool{"github.com": true, "api.github.com": true}
+
+// Query values which are secret or differ between runs, they are left out of the cassette and its name
+var redactedGithubQueryKeys = []string{"client_id", "client_secret", "code", "access_token"}
+
+// Response fields holding credentials, the recorded ones are swapped for recordedGithubToken
+var redactedGithubResponseKeys = []string{"access_token", "refresh_token"}
+
+func loadGithubRecordingConfig(configLoader *ConfigLoader) GithubRecordingConfig {
+	var githubRecordingConfig GithubRecordingConfig
+
+	githubRecordingConfig.Mode = configLoader.OneOf("GITHUB_RECORDING", "off", "off", "record", "replay")
+	githubRecordingConfig.CassetteDir = configLoader.String("GITHUB_CASSETTE_DIR", filepath.Join("testdata", "github"))
+	if githubRecordingConfig.Mode != "off" && githubRecordingConfig.CassetteDir == "" {
+		configLoader.Invalid("GITHUB_CASSETTE_DIR", "should be set while GITHUB_RECORDING is "+githubRecordingConfig.Mode)
+	}
+
+	return githubRecordingConfig
+}
+
+func newGithubRecordingTransport(next http.RoundTripper, githubRecordingConfig GithubRecordingConfig) http.RoundTripper {
+	if githubRecordingConfig.Mode == "off" || githubRecordingConfig.Mode == "" {
+		return next
+	}
+	return &githubRecordingTransport{next: next, config: githubRecordingConfig, redactedTokens: map[string]bool{}}
+}
+
+// redactedGithubURL : URL of the request without its secrets, the same for every run of a test
+func redactedGithubURL(requestURL *url.URL) string {
+	redactedURL := *requestURL
+	query := redactedURL.Query()
+	for _, redactedKey := range redactedGithubQueryKeys {
+		if query.Get(redactedKey) != "" {
+			query.Set(redactedKey, "REDACTED")
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.String()
+}
+
+// authorizationHash : Calls made with different credentials get different cassettes, the token itself is not written.
+// Tokens handed out while recording are hashed as recordedGithubToken, which is what replay hands out for them
+func (transport *githubRecordingTransport) authorizationHash(request *http.Request) string {
+	authorization := request.Header.Get("Authorization")
+	transport.mutex.Lock()
+	for redactedToken := range transport.redactedTokens {
+		authorization = strings.Replace(authorization, redactedToken, recordedGithubToken, -1)
+	}
+	transport.mutex.Unlock()
+
+	return hashOfBody([]byte(authorization))
+}
+
+// redactedGithubForm : Form bodies carry the same secrets as queries, they are hashed without them
+func redactedGithubForm(request *http.Request, body []byte) []byte {
+	if strings.HasPrefix(request.Header.Get("Content-Type"), "application/x-www-form-urlencoded") == false {
+		return body
+	}
+	form, errInParsing := url.ParseQuery(string(body))
+	if errInParsing != nil {
+		return body
+	}
+	for _, redactedKey := range redactedGithubQueryKeys {
+		if form.Get(redactedKey) != "" {
+			form.Set(redactedKey, "REDACTED")
+		}
+	}
+	return []byte(form.Encode())
+}
+
+func hashOfBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	bodyHash := sha256.Sum256(body)
+	return hex.EncodeToString(bodyHash[:])
+}
+
+// cassettePath : Calls with the same method, URL, body and Authorization share a cassette, the latest recording wins
+func (transport *githubRecordingTransport) cassettePath(method string, redactedURL string, bodyHash string,
+	authorizationHash string) string {
+	cassetteKey := sha256.Sum256([]byte(method + " " + redactedURL + " " + bodyHash + " " + authorizationHash))
+	return filepath.Join(transport.config.CassetteDir, hex.EncodeToString(cassetteKey[:8])+".json")
+}
+
+// redactedGithubBody : Tokens GitHub hands out are not written to disk, JSON which can't be read is kept as is.
+// The tokens which were replaced are returned with the body
+func redactedGithubBody(body []byte) ([]byte, []string) {
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return body, nil
+	}
+
+	isRedacted := false
+	var redactedTokens []string
+	for _, redactedKey := range redactedGithubResponseKeys {
+		if token, hasKey := fields[redactedKey]; hasKey == true {
+			if tokenString, isString := token.(string); isString == true && tokenString != "" {
+				redactedTokens = append(redactedTokens, tokenString)
+			}
+			fields[redactedKey] = recordedGithubToken
+			isRedacted = true
+		}
+	}
+	if isRedacted == false {
+		return body, nil
+	}
+
+	redactedBody, errInEncoding := json.Marshal(fields)
+	if errInEncoding != nil {
+		return body, nil
+	}
+	return redactedBody, redactedTokens
+}
+
+func readRequestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil {
+		return nil, nil
+	}
+	if request.GetBody != nil {
+		bodyCopy, errInBody := request.GetBody()
+		if errInBody != nil {
+			return nil, errInBody
+		}
+		defer bodyCopy.Close()
+		return ioutil.ReadAll(bodyCopy)
+	}
+
+	body, errInReading := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if errInReading != nil {
+		return nil, errInReading
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func (transport *githubRecordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if githubHosts[request.URL.Host] == false {
+		return transport.next.RoundTrip(request)
+	}
+
+	requestBody, errInBody := readRequestBody(request)
+	if errInBody != nil {
+		return nil, errInBody
+	}
+	redactedURL := redactedGithubURL(request.URL)
+	bodyHash := hashOfBody(redactedGithubForm(request, requestBody))
+	authorizationHash := transport.authorizationHash(request)
+	cassettePath := transport.cassettePath(request.Method, redactedURL, bodyHash, authorizationHash)
+
+	if transport.config.Mode == "replay" {
+		return transport.replay(request, cassettePath)
+	}
+
+	response, errInRequest := transport.next.RoundTrip(request)
+	if errInRequest != nil {
+		return nil, errInRequest
+	}
+	responseBody, errInReading := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if errInReading != nil {
+		return nil, errInReading
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	header := make(map[string][]string, len(response.Header))
+	for headerName, headerValues := range response.Header {
+		if headerName != "Set-Cookie" {
+			header[headerName] = headerValues
+		}
+	}
+	redactedBody, redactedTokens := redactedGithubBody(responseBody)
+	transport.mutex.Lock()
+	for _, redactedToken := range redactedTokens {
+		transport.redactedTokens[redactedToken] = true
+	}
+	transport.mutex.Unlock()
+
+	cassette := GithubCassette{Method: request.Method, URL: redactedURL, BodyHash: bodyHash,
+		AuthorizationHash: authorizationHash, Status: response.StatusCode, Header: header, Body: string(redactedBody)}
+	// Recording is for tests, a cassette which can't be written should not fail the call being recorded
+	if errInWriting := transport.write(cassettePath, cassette); errInWriting != nil {
+		log.Println(errInWriting, "// Cannot record GitHub call", request.Method, redactedURL)
+	}
+
+	return response, nil
+}
+
+func (transport *githubRecordingTransport) write(cassettePath string, cassette GithubCassette) error {
+	// URLs are written as they are, & is not escaped for HTML
+	var cassetteInJSON bytes.Buffer
+	encoder := json.NewEncoder(&cassetteInJSON)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if errInEncoding := encoder.Encode(cassette); errInEncoding != nil {
+		return errInEncoding
+	}
+
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	if errInDir := os.MkdirAll(transport.config.CassetteDir, 0755); errInDir != nil {
+		return errInDir
+	}
+	return ioutil.WriteFile(cassettePath, cassetteInJSON.Bytes(), 0644)
+}
+
+func (transport *githubRecordingTransport) replay(request *http.Request, cassettePath string) (*http.Response, error) {
+	cassetteInJSON, errInReading := ioutil.ReadFile(cassettePath)
+	if os.IsNotExist(errInReading) {
+		return nil, fmt.Errorf("No GitHub cassette recorded for %s %s, record it with GITHUB_RECORDING=record",
+			request.Method, redactedGithubURL(request.URL))
+	}
+	if errInReading != nil {
+		return nil, errInReading
+	}
+
+	cassette := GithubCassette{Header: map[string][]string{}}
+	if errInDecoding := json.Unmarshal(cassetteInJSON, &cassette); errInDecoding != nil {
+		return nil, fmt.Errorf("GitHub cassette %s cannot be read: %s", cassettePath, errInDecoding.Error())
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cassette.Status, http.StatusText(cassette.Status)),
+		StatusCode:    cassette.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header(cassette.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(cassette.Body))),
+		ContentLength: int64(len(cassette.Body)),
+		Request:       request,
+	}, nil
+}
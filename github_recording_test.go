@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newReplayTestServer : Server over memory stores whose GitHub calls are answered from testdata/github
+func newReplayTestServer(t *testing.T) *gin.Engine {
+	for key, value := range map[string]string{"GITHUB_CLIENT": "client", "GITHUB_SECRET": "secret",
+		"DB_DRIVER": "memory", "PORT": "8080", "ENVIRONMENT": "dev", "GITHUB_RECORDING": "replay"} {
+		os.Setenv(key, value)
+	}
+	defer os.Unsetenv("GITHUB_RECORDING")
+	config, errInConfig := loadConfig("")
+	if errInConfig != nil {
+		t.Fatal(errInConfig)
+	}
+
+	gin.SetMode(gin.TestMode)
+	outboundHTTPClient = newOutboundHTTPClient(config.OutboundHTTP)
+	return NewServer(config, newMemoryStores())
+}
+
+func serveTestRequest(router *gin.Engine, method string, path string, body string, accessToken string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestAuthAndProfileReplayedFromCassettes(t *testing.T) {
+	router := newReplayTestServer(t)
+
+	authResponse := serveTestRequest(router, http.MethodPost, "/auth", `{"code":"9d3d5e1a2b4c6f7e8a0b"}`, "")
+	if authResponse.Code != http.StatusOK {
+		t.Fatalf("POST /auth answered %d: %s", authResponse.Code, authResponse.Body.String())
+	}
+	var signedIn struct {
+		Data SessionAuthUser `json:"data"`
+	}
+	if errInDecoding := json.Unmarshal(authResponse.Body.Bytes(), &signedIn); errInDecoding != nil {
+		t.Fatal(errInDecoding)
+	}
+	if signedIn.Data.Login != "octocat" || isSessionToken(signedIn.Data.AccessToken) == false {
+		t.Fatalf("POST /auth signed in %+v", signedIn.Data)
+	}
+
+	sessionResponse := serveTestRequest(router, http.MethodGet, "/auth/session", "", signedIn.Data.AccessToken)
+	var session struct {
+		Data SessionInfoStructure `json:"data"`
+	}
+	if errInDecoding := json.Unmarshal(sessionResponse.Body.Bytes(), &session); errInDecoding != nil {
+		t.Fatal(errInDecoding)
+	}
+	if sessionResponse.Code != http.StatusOK || session.Data.UserID != 583231 || session.Data.Provider != identityProviderGithub {
+		t.Fatalf("GET /auth/session answered %d: %s", sessionResponse.Code, sessionResponse.Body.String())
+	}
+
+	profileResponse := serveTestRequest(router, http.MethodGet, "/users/octocat", "", "")
+	if profileResponse.Code != http.StatusOK || strings.Contains(profileResponse.Body.String(), "The Octocat") == false {
+		t.Fatalf("GET /users/octocat answered %d: %s", profileResponse.Code, profileResponse.Body.String())
+	}
+
+	// GitHub tokens are checked against the recorded profile, the one handed out by the recorded sign in is known
+	gazedResponse := serveTestRequest(router, http.MethodGet, "/ideas/gazed", "", recordedGithubToken)
+	if gazedResponse.Code != http.StatusOK {
+		t.Fatalf("GET /ideas/gazed with the recorded token answered %d: %s", gazedResponse.Code, gazedResponse.Body.String())
+	}
+}
+
+func TestCassettesAreKeyedByAuthorization(t *testing.T) {
+	router := newReplayTestServer(t)
+
+	// Same call as the profile of the recorded token, only the Authorization differs
+	revokedResponse := serveTestRequest(router, http.MethodGet, "/ideas/gazed", "", "gho_revoked")
+	if revokedResponse.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /ideas/gazed with a revoked token answered %d: %s", revokedResponse.Code, revokedResponse.Body.String())
+	}
+
+	unrecordedResponse := serveTestRequest(router, http.MethodGet, "/ideas/gazed", "", "gho_never_recorded")
+	if unrecordedResponse.Code == http.StatusOK {
+		t.Fatalf("GET /ideas/gazed with a token without a cassette answered %s", unrecordedResponse.Body.String())
+	}
+}
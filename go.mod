@@ -1,4 +1,4 @@
-// +heroku goVersion go1.12
+// +heroku goVersion go1.14
 
 module github.com/m-zubairahmed/sardene-api
 
@@ -11,12 +11,16 @@ require (
 	github.com/go-stack/stack v1.8.0 // indirect
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/google/go-cmp v0.2.0 // indirect
+	github.com/lib/pq v1.9.0
 	github.com/mongodb/mongo-go-driver v1.0.1
 	github.com/tidwall/pretty v0.0.0-20190325153808-1166b9ac2b65 // indirect
+	github.com/ugorji/go v1.1.4
 	github.com/xdg/scram v0.0.0-20180814205039-7eeb5667e42c // indirect
 	github.com/xdg/stringprep v1.0.0 // indirect
+	github.com/yuin/goldmark v1.4.12
 	go.mongodb.org/mongo-driver v1.0.1
-	golang.org/x/crypto v0.0.0-20190426145343-a29dc8fdc734 // indirect
+	golang.org/x/crypto v0.0.0-20190426145343-a29dc8fdc734
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58 // indirect
-	golang.org/x/text v0.3.2 // indirect
+	golang.org/x/text v0.3.2
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	tlsModeOff      = "off"
+	tlsModeFiles    = "files"
+	tlsModeAutocert = "autocert"
+)
+
+// TLSConfig : How the API serves HTTPS when it is not behind a TLS terminator such as Heroku's router
+type TLSConfig struct {
+	Mode             string
+	CertFile         string
+	KeyFile          string
+	AutocertDomains  []string
+	AutocertCacheDir string
+	RedirectPort     string
+}
+
+func loadTLSConfig(configLoader *ConfigLoader) TLSConfig {
+	var tlsConfig TLSConfig
+
+	tlsConfig.Mode = configLoader.OneOf("TLS_MODE", tlsModeOff, tlsModeOff, tlsModeFiles, tlsModeAutocert)
+	tlsConfig.RedirectPort = configLoader.String("TLS_REDIRECT_PORT", "80")
+
+	switch tlsConfig.Mode {
+	case tlsModeFiles:
+		tlsConfig.CertFile = configLoader.Required("TLS_CERT_FILE", "path of the certificate when TLS_MODE is files")
+		tlsConfig.KeyFile = configLoader.Required("TLS_KEY_FILE", "path of the private key when TLS_MODE is files")
+	case tlsModeAutocert:
+		tlsConfig.AutocertDomains = configLoader.List("TLS_AUTOCERT_DOMAINS", "")
+		if len(tlsConfig.AutocertDomains) == 0 {
+			configLoader.Invalid("TLS_AUTOCERT_DOMAINS", "is required when TLS_MODE is autocert, certificates are only requested for these domains")
+		}
+		tlsConfig.AutocertCacheDir = configLoader.String("TLS_AUTOCERT_CACHE_DIR", "autocert-cache")
+		// Let's Encrypt sends its http-01 challenges to port 80
+		if tlsConfig.RedirectPort == "" {
+			configLoader.Invalid("TLS_REDIRECT_PORT", "is required when TLS_MODE is autocert, challenges are answered on it")
+		}
+	}
+
+	return tlsConfig
+}
+
+// redirectToHTTPS : Sends plain HTTP requests to the same path on the HTTPS port
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		host, _, errInSplitting := net.SplitHostPort(request.Host)
+		if errInSplitting != nil {
+			host = request.Host
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		// 308 keeps the method and body, so a POST is not turned into a GET
+		http.Redirect(responseWriter, request, "https://"+host+request.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func listenForRedirects(redirectPort string, handler http.Handler) {
+	errInListening := http.ListenAndServe(":"+redirectPort, handler)
+	if errInListening != nil {
+		log.Fatal(errInListening, "// Cannot listen for HTTP to HTTPS redirects")
+	}
+}
+
+// serveAPI : Serves plain HTTP, or HTTPS from certificate files or from Let's Encrypt
+func serveAPI(router *gin.Engine, port string, tlsConfig TLSConfig) error {
+	switch tlsConfig.Mode {
+	case tlsModeFiles:
+		if tlsConfig.RedirectPort != "" {
+			go listenForRedirects(tlsConfig.RedirectPort, redirectToHTTPS(port))
+		}
+		return router.RunTLS(":"+port, tlsConfig.CertFile, tlsConfig.KeyFile)
+
+	case tlsModeAutocert:
+		certificateManager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(tlsConfig.AutocertDomains...),
+			Cache:      autocert.DirCache(tlsConfig.AutocertCacheDir),
+		}
+		go listenForRedirects(tlsConfig.RedirectPort, certificateManager.HTTPHandler(redirectToHTTPS(port)))
+
+		server := &http.Server{
+			Addr:      ":" + port,
+			Handler:   router,
+			TLSConfig: certificateManager.TLSConfig(),
+		}
+		log.Println("Serving HTTPS for", tlsConfig.AutocertDomains)
+		return server.ListenAndServeTLS("", "")
+	}
+
+	return router.Run(":" + port)
+}
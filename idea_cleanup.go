@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdeaDependent : Collection in mongo whose documents belong to one idea, by the field holding its id
+type IdeaDependent struct {
+	Collection string
+	IdeaField  string
+}
+
+// Gazes are deleted through the stores, they are in mongo only with the mongo driver
+var ideaDependents = []IdeaDependent{
+	{Collection: "makers", IdeaField: "ideaID"},
+	{Collection: "bookmarks", IdeaField: "idea_id"},
+	{Collection: "idea_revisions", IdeaField: "idea_id"},
+	{Collection: "similar_ideas", IdeaField: "_id"},
+	{Collection: "events", IdeaField: "idea_id"},
+	{Collection: "idea_updates", IdeaField: "idea_id"},
+}
+
+// deleteDependentsOfIdea : Runs after the idea is deleted, whatever a failure leaves behind is removed by the orphan sweep
+func deleteDependentsOfIdea(databaseContext context.Context, databaseClient *mongo.Client, stores Stores,
+	ideaID primitive.ObjectID) error {
+	errInDeletingGazes := stores.Likes.DeleteByIdea(databaseContext, ideaID)
+	if errInDeletingGazes != nil || databaseClient == nil {
+		return errInDeletingGazes
+	}
+
+	for _, ideaDependent := range ideaDependents {
+		dependentCollection := databaseClient.Database("sardene-db").Collection(ideaDependent.Collection)
+		_, errInDeleting := dependentCollection.DeleteMany(databaseContext, bson.M{ideaDependent.IdeaField: ideaID})
+		if errInDeleting != nil {
+			return errInDeleting
+		}
+	}
+
+	return deleteSubscriptionsOfIdea(databaseContext, databaseClient, []interface{}{ideaID})
+}
+
+func runOrphanSweepJob(databaseClient *mongo.Client, stores Stores, databaseDriver string, interval time.Duration) {
+	runScheduledJob(databaseClient, "orphan_sweep", interval, func() error {
+		// Sweep reads mongo, gazes are only kept there with the mongo driver
+		sweptDependents := ideaDependents
+		if databaseDriver == "mongo" {
+			sweptDependents = append([]IdeaDependent{{Collection: "likes", IdeaField: "ideaID"}}, ideaDependents...)
+		}
+		for _, ideaDependent := range sweptDependents {
+			errInSweeping := sweepOrphansOfDeletedIdeas(databaseClient, stores, ideaDependent)
+			if errInSweeping != nil {
+				return errInSweeping
+			}
+		}
+		return nil
+	})
+}
+
+// sweepOrphansOfDeletedIdeas : Deletes the documents of ideas which do not exist anymore, ideas are looked up
+// in the stores as they may not be in mongo
+func sweepOrphansOfDeletedIdeas(databaseClient *mongo.Client, stores Stores, ideaDependent IdeaDependent) error {
+	dependentCollection := databaseClient.Database("sardene-db").Collection(ideaDependent.Collection)
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelDBContext()
+
+	referencedIdeaIDs, errInFinding := dependentCollection.Distinct(databaseContext, ideaDependent.IdeaField, bson.M{},
+		options.Distinct())
+	if errInFinding != nil {
+		return errInFinding
+	}
+
+	var referencedObjectIDs []primitive.ObjectID
+	for _, referencedIdeaID := range referencedIdeaIDs {
+		if ideaID, isObjectID := referencedIdeaID.(primitive.ObjectID); isObjectID {
+			referencedObjectIDs = append(referencedObjectIDs, ideaID)
+		}
+	}
+	if len(referencedObjectIDs) == 0 {
+		return nil
+	}
+	existingIdeas, errInFindingIdeas := stores.Ideas.FindByIDs(databaseContext, referencedObjectIDs)
+	if errInFindingIdeas != nil {
+		return errInFindingIdeas
+	}
+	isExistingIdea := make(map[primitive.ObjectID]bool, len(existingIdeas))
+	for _, existingIdea := range existingIdeas {
+		isExistingIdea[existingIdea.ID] = true
+	}
+
+	var deletedIdeaIDs []primitive.ObjectID
+	for _, referencedIdeaID := range referencedObjectIDs {
+		if isExistingIdea[referencedIdeaID] == false {
+			deletedIdeaIDs = append(deletedIdeaIDs, referencedIdeaID)
+		}
+	}
+	if len(deletedIdeaIDs) == 0 {
+		return nil
+	}
+
+	result, errInDeleting := dependentCollection.DeleteMany(databaseContext,
+		bson.M{ideaDependent.IdeaField: bson.M{"$in": deletedIdeaIDs}})
+	if errInDeleting != nil {
+		return errInDeleting
+	}
+	log.Printf("Swept %d %s of %d deleted ideas", result.DeletedCount, ideaDependent.Collection, len(deletedIdeaIDs))
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PublicUserProfile : Structure of user details that are safe to show to everyone
+type PublicUserProfile struct {
+	UserID      int64  `json:"userID" bson:"userID"`
+	Login       string `json:"login" bson:"login"`
+	Name        string `json:"name" bson:"name"`
+	CreatedAt   int64  `json:"created_at" bson:"created_at"`
+	DisplayName string `json:"display_name" bson:"display_name"`
+	Bio         string `json:"bio" bson:"bio"`
+	Website     string `json:"website" bson:"website"`
+	Location    string `json:"location" bson:"location"`
+}
+
+// IdeaDetailStructure : Structure of everything the idea detail page shows
+type IdeaDetailStructure struct {
+	IdeaStructure    `bson:",inline"`
+	PublisherProfile *PublicUserProfile `json:"publisher_profile" bson:"publisher_profile"`
+	RevisionCount    int64              `json:"revision_count" bson:"revision_count"`
+	GazedByMe        bool               `json:"gazed_by_me" bson:"gazed_by_me"`
+	MadeByMe         bool               `json:"made_by_me" bson:"made_by_me"`
+}
+
+func publicProfileOfUser(user UserStructure) *PublicUserProfile {
+	return &PublicUserProfile{UserID: user.UserID, Login: user.Login, Name: user.Name, CreatedAt: user.CreatedAt,
+		DisplayName: user.DisplayName, Bio: user.Bio, Website: user.Website, Location: user.Location}
+}
+
+// getIdeaDetail : Idea, publisher and gaze come from the stores, revisions and makers are only kept in mongo
+func getIdeaDetail(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	// Caller is optional, anonymous visitors get the idea without their interaction flags
+	callerUserID := getAuthenticatedUser(ginContext).UserID
+	databaseContext := ginContext.Request.Context()
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea == errNotFoundInStore || (errInFindingIdea == nil && isIdeaVisibleTo(idea, callerUserID) == false) {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+	if errInFindingIdea != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+		return
+	}
+	ideaDetail := IdeaDetailStructure{IdeaStructure: idea}
+
+	// Ideas of deleted users are left with publisher id 0
+	if idea.PublisherID != 0 {
+		publisher, errInFindingPublisher := stores.Users.FindByUserID(databaseContext, idea.PublisherID)
+		if errInFindingPublisher != nil && errInFindingPublisher != errNotFoundInStore {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInFindingPublisher.Error()})
+			return
+		}
+		if errInFindingPublisher == nil {
+			ideaDetail.PublisherProfile = publicProfileOfUser(publisher)
+		}
+	}
+
+	if callerUserID != 0 {
+		_, errInFindingGaze := stores.Likes.Find(databaseContext, callerUserID, hexIdeaID)
+		if errInFindingGaze != nil && errInFindingGaze != errNotFoundInStore {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInFindingGaze.Error()})
+			return
+		}
+		ideaDetail.GazedByMe = errInFindingGaze == nil
+	}
+
+	if databaseClient != nil {
+		revisionCount, errInCounting := databaseClient.Database("sardene-db").Collection("idea_revisions").
+			CountDocuments(databaseContext, bson.M{"idea_id": hexIdeaID}, options.Count())
+		if errInCounting != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInCounting.Error()})
+			return
+		}
+		ideaDetail.RevisionCount = revisionCount
+
+		if callerUserID != 0 {
+			madeIdeaIDs, errInFindingMade := listMadeAmong(databaseContext, databaseClient, callerUserID, []primitive.ObjectID{hexIdeaID})
+			if errInFindingMade != nil {
+				ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+					"error": "Error in searching database", "errorDetails": errInFindingMade.Error()})
+				return
+			}
+			ideaDetail.MadeByMe = len(madeIdeaIDs) != 0
+		}
+	}
+
+	// Publishers looking at their own idea are not its reach
+	if ideaViews != nil && ideaDetail.PublisherID != callerUserID {
+		ideaDetail.Views += ideaViews.Record(ideaDetail.ID)
+	}
+
+	if isHTMLRenderRequested(ginContext) {
+		descriptionHTML, errInRendering := renderMarkdown(ideaDetail.Description)
+		if errInRendering != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in rendering description", "errorDetails": errInRendering.Error()})
+			return
+		}
+		ideaDetail.DescriptionHTML = descriptionHTML
+	}
+
+	if linkPreviewer != nil {
+		ideasWithPreviews := []IdeaStructure{ideaDetail.IdeaStructure}
+		errInPreviews := linkPreviewer.attachLinkPreviews(databaseContext, ideasWithPreviews)
+		if errInPreviews != nil {
+			log.Println(errInPreviews, "Failed to read link previews")
+		}
+		ideaDetail.LinkPreviews = ideasWithPreviews[0].LinkPreviews
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": ideaDetail})
+}
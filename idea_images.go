@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IdeaImageStructure : Structure of an image shown with an idea, the bytes are kept as an attachment
+type IdeaImageStructure struct {
+	Hash        string `json:"hash" bson:"hash"`
+	URL         string `json:"url" bson:"url"`
+	ContentType string `json:"content_type" bson:"content_type"`
+	Size        int64  `json:"size" bson:"size"`
+	UploadedAt  int64  `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+// uploadIdeaImage : The image is kept on the idea in the stores, its bytes are an attachment in mongo
+func uploadIdeaImage(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, blobStorage BlobStorage,
+	attachmentConfig AttachmentConfig, ideaID string) {
+	user := getAuthenticatedUser(ginContext)
+
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	attachmentBytes, contentType, statusOfUpload, errorOfUpload := readUploadedAttachment(ginContext, attachmentConfig)
+	if statusOfUpload != http.StatusOK {
+		ginContext.JSON(statusOfUpload, gin.H{"status": statusOfUpload, "error": errorOfUpload})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	attachment, _, errInStoring := storeAttachment(databaseContext, databaseClient, blobStorage, user,
+		hexIdeaID, attachmentBytes, contentType)
+	if errInStoring != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving image", "errorDetails": errInStoring.Error()})
+		return
+	}
+
+	imageToAdd := IdeaImageStructure{
+		Hash:        attachment.Hash,
+		URL:         attachmentURL(attachment.Hash),
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		UploadedAt:  time.Now().Unix(),
+	}
+
+	errInAdding := stores.Ideas.AddImage(databaseContext, hexIdeaID, imageToAdd, attachmentConfig.MaxImagesPerIdea, time.Now().Unix())
+	if errInAdding != nil {
+		releaseIdeaImage(databaseContext, databaseClient, blobStorage, hexIdeaID, attachment.Hash)
+	}
+	if errInAdding != nil && errInAdding != errDuplicateInStore {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while adding image to idea", "errorDetails": errInAdding.Error()})
+		return
+	}
+	if errInAdding == errDuplicateInStore {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "Error, Idea already has this image or " + strconv.FormatInt(attachmentConfig.MaxImagesPerIdea, 10) + " images"})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": imageToAdd})
+}
+
+func deleteIdeaImage(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, blobStorage BlobStorage,
+	ideaID string, imageHash string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	errInRemoving := stores.Ideas.RemoveImage(databaseContext, hexIdeaID, imageHash, time.Now().Unix())
+	if errInRemoving == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Image of idea not found"})
+		return
+	}
+	if errInRemoving != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while removing image from idea", "errorDetails": errInRemoving.Error()})
+		return
+	}
+
+	errInReleasing := releaseIdeaImage(databaseContext, databaseClient, blobStorage, hexIdeaID, imageHash)
+	if errInReleasing != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while deleting image", "errorDetails": errInReleasing.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Image deleted successfully"})
+}
+
+func releaseIdeaImage(databaseContext context.Context, databaseClient *mongo.Client, blobStorage BlobStorage,
+	ideaID primitive.ObjectID, imageHash string) error {
+	_, errInReleasing := releaseAttachment(databaseContext, databaseClient, blobStorage, bson.M{"hash": imageHash, "idea_id": ideaID})
+	return errInReleasing
+}
+
+// releaseImagesOfIdea : Drops the attachment references of a deleted idea, so its images do not outlive it
+func releaseImagesOfIdea(databaseContext context.Context, databaseClient *mongo.Client, blobStorage BlobStorage, ideaID primitive.ObjectID) error {
+	for {
+		isReleased, errInReleasing := releaseAttachment(databaseContext, databaseClient, blobStorage, bson.M{"idea_id": ideaID})
+		if errInReleasing != nil || isReleased == false {
+			return errInReleasing
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ideaLinkBlockedBy = "blocked_by"
+	ideaLinkBuildsOn  = "builds_on"
+	// Upper bound of ideas walked while checking cycles or building a graph
+	maxIdeasInGraph = 200
+)
+
+// IdeaLinkStructure : Structure of a typed relationship from one idea to another
+type IdeaLinkStructure struct {
+	Type   string             `json:"type" bson:"type"`
+	IdeaID primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+}
+
+// IdeaLinkInput : Structure for incoming link between ideas
+type IdeaLinkInput struct {
+	Type   string `json:"type"`
+	IdeaID string `json:"idea_id"`
+}
+
+// IdeaGraphNode : Structure of an idea in the graph response
+type IdeaGraphNode struct {
+	ID     primitive.ObjectID `json:"id"`
+	Name   string             `json:"name"`
+	Gazers int64              `json:"gazers"`
+}
+
+// IdeaGraphEdge : Structure of a link in the graph response
+type IdeaGraphEdge struct {
+	From primitive.ObjectID `json:"from"`
+	To   primitive.ObjectID `json:"to"`
+	Type string             `json:"type"`
+}
+
+func isValidIdeaLinkType(linkType string) bool {
+	return linkType == ideaLinkBlockedBy || linkType == ideaLinkBuildsOn
+}
+
+// doesLinkCreateCycle : Walks outgoing links starting from the target idea, if the source idea is reachable the new link would close a cycle.
+// A walk going past maxIdeasInGraph counts as a cycle, links are only added when they are known not to close one
+func doesLinkCreateCycle(findIdeas func([]primitive.ObjectID) ([]IdeaStructure, error), sourceIdeaID primitive.ObjectID,
+	targetIdeaID primitive.ObjectID) (bool, error) {
+	visitedIdeas := map[primitive.ObjectID]bool{targetIdeaID: true}
+	ideasToVisit := []primitive.ObjectID{targetIdeaID}
+
+	for len(ideasToVisit) != 0 {
+		if len(visitedIdeas) > maxIdeasInGraph {
+			return true, nil
+		}
+
+		ideasFound, errInFinding := findIdeas(ideasToVisit)
+		if errInFinding != nil {
+			return false, errInFinding
+		}
+
+		ideasToVisit = nil
+		for _, idea := range ideasFound {
+			for _, link := range idea.Links {
+				if link.IdeaID == sourceIdeaID {
+					return true, nil
+				}
+				if visitedIdeas[link.IdeaID] == false {
+					visitedIdeas[link.IdeaID] = true
+					ideasToVisit = append(ideasToVisit, link.IdeaID)
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// isLinkedTo : Whether the idea already has a link to linkedIdeaID, whatever its type
+func isLinkedTo(idea IdeaStructure, linkedIdeaID primitive.ObjectID) bool {
+	for _, link := range idea.Links {
+		if link.IdeaID == linkedIdeaID {
+			return true
+		}
+	}
+	return false
+}
+
+// addLinkWithoutCycle : Checks of AddLink shared by the stores, which call it with link writes serialized.
+// saveLink only runs once both ideas exist, are not linked and the link closes no cycle
+func addLinkWithoutCycle(findIdeas func([]primitive.ObjectID) ([]IdeaStructure, error), ideaID primitive.ObjectID,
+	link IdeaLinkStructure, saveLink func() error) error {
+	ideasFound, errInFinding := findIdeas([]primitive.ObjectID{ideaID, link.IdeaID})
+	if errInFinding != nil {
+		return errInFinding
+	}
+	if len(ideasFound) != 2 {
+		return errNotFoundInStore
+	}
+	for _, idea := range ideasFound {
+		if idea.ID == ideaID && isLinkedTo(idea, link.IdeaID) {
+			return errDuplicateInStore
+		}
+	}
+
+	createsCycle, errInCheckingCycle := doesLinkCreateCycle(findIdeas, ideaID, link.IdeaID)
+	if errInCheckingCycle != nil {
+		return errInCheckingCycle
+	}
+	if createsCycle == true {
+		return errConflictInStore
+	}
+
+	return saveLink()
+}
+
+func linkIdeas(ginContext *gin.Context, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	var jsonInput IdeaLinkInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong structure of posted data"})
+		return
+	}
+
+	if isValidIdeaLinkType(jsonInput.Type) == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Link type should be either " + ideaLinkBlockedBy + " or " + ideaLinkBuildsOn})
+		return
+	}
+
+	hexLinkedIdeaID, errInValidatingLinkedID := primitive.ObjectIDFromHex(jsonInput.IdeaID)
+	if errInValidatingLinkedID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Linked idea id is not valid"})
+		return
+	}
+
+	if hexLinkedIdeaID == hexIdeaID {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea cannot be linked to itself"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	// Drafts and private ideas of others answer like missing ones, so links cannot probe for them
+	linkedIdea, errInFindingLinkedIdea := stores.Ideas.FindByID(databaseContext, hexLinkedIdeaID)
+	if errInFindingLinkedIdea == nil && isIdeaVisibleTo(linkedIdea, getAuthenticatedUser(ginContext).UserID) == false {
+		errInFindingLinkedIdea = errNotFoundInStore
+	}
+	if errInFindingLinkedIdea == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+	if errInFindingLinkedIdea != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingLinkedIdea.Error()})
+		return
+	}
+
+	linkToAdd := IdeaLinkStructure{Type: jsonInput.Type, IdeaID: hexLinkedIdeaID}
+
+	errInAdding := stores.Ideas.AddLink(databaseContext, hexIdeaID, linkToAdd)
+	switch errInAdding {
+	case nil:
+	case errNotFoundInStore:
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	case errDuplicateInStore:
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "Error, Ideas are already linked"})
+		return
+	case errConflictInStore:
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "Error, Link would create a cycle between ideas or join a graph too large to check"})
+		return
+	default:
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInAdding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": linkToAdd})
+}
+
+func unlinkIdeas(ginContext *gin.Context, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	var jsonInput IdeaLinkInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong structure of posted data"})
+		return
+	}
+
+	hexLinkedIdeaID, errInValidatingLinkedID := primitive.ObjectIDFromHex(jsonInput.IdeaID)
+	if errInValidatingLinkedID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Linked idea id is not valid"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	_, errInFinding := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFinding == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea not found"})
+		return
+	}
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	errInRemoving := stores.Ideas.RemoveLink(databaseContext, hexIdeaID, hexLinkedIdeaID)
+	if errInRemoving == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Ideas are not linked"})
+		return
+	}
+	if errInRemoving != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Removed link between ideas"})
+}
+
+func getIdeaGraph(ginContext *gin.Context, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	nodesInGraph := make(map[primitive.ObjectID]IdeaGraphNode)
+	edgesInGraph := make(map[IdeaGraphEdge]bool)
+	ideasToVisit := []primitive.ObjectID{hexIdeaID}
+	visitedIdeas := map[primitive.ObjectID]bool{hexIdeaID: true}
+
+	// Walking links in both directions, so ideas building on this one are part of the graph too
+	for len(ideasToVisit) != 0 && len(nodesInGraph) < maxIdeasInGraph {
+		ideasVisited, errInFinding := stores.Ideas.FindByIDs(databaseContext, ideasToVisit)
+		var ideasLinking []IdeaStructure
+		if errInFinding == nil {
+			ideasLinking, errInFinding = stores.Ideas.ListLinkingTo(databaseContext, ideasToVisit)
+		}
+		if errInFinding != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+			return
+		}
+
+		var ideasFound []IdeaStructure
+		for _, idea := range append(ideasVisited, ideasLinking...) {
+			if isIdeaPublic(idea) {
+				ideasFound = append(ideasFound, idea)
+			}
+		}
+
+		ideasToVisit = nil
+		for _, idea := range ideasFound {
+			nodesInGraph[idea.ID] = IdeaGraphNode{ID: idea.ID, Name: idea.Name, Gazers: idea.Gazers}
+			if visitedIdeas[idea.ID] == false {
+				visitedIdeas[idea.ID] = true
+				ideasToVisit = append(ideasToVisit, idea.ID)
+			}
+
+			for _, link := range idea.Links {
+				edgesInGraph[IdeaGraphEdge{From: idea.ID, To: link.IdeaID, Type: link.Type}] = true
+				if visitedIdeas[link.IdeaID] == false {
+					visitedIdeas[link.IdeaID] = true
+					ideasToVisit = append(ideasToVisit, link.IdeaID)
+				}
+			}
+		}
+	}
+
+	if _, isIdeaFound := nodesInGraph[hexIdeaID]; isIdeaFound == false {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+
+	nodes := make([]IdeaGraphNode, 0, len(nodesInGraph))
+	for _, node := range nodesInGraph {
+		nodes = append(nodes, node)
+	}
+
+	// Edges pointing to deleted or hidden ideas are dropped
+	edges := make([]IdeaGraphEdge, 0, len(edgesInGraph))
+	for edge := range edgesInGraph {
+		_, isFromFound := nodesInGraph[edge.From]
+		_, isToFound := nodesInGraph[edge.To]
+		if isFromFound && isToFound {
+			edges = append(edges, edge)
+		}
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK,
+		"data": gin.H{"root": hexIdeaID, "nodes": nodes, "edges": edges}})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLinkToIdeaCallerCannotSeeAnswersNotFound(t *testing.T) {
+	router, stores, sessionToken := newMemoryTestServer(t)
+	ideaID := addTestIdea(t, stores, 0)
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Second)
+	defer cancelDBContext()
+	privateIdeaID, _ := stores.Ideas.Insert(databaseContext, IdeaStructure{Name: "Hidden", Slug: "hidden", Publisher: "stranger",
+		PublisherID: 9, Visibility: ideaVisibilityPrivate, CreatedAt: time.Now().Unix()})
+	draftIdeaID, _ := stores.Ideas.Insert(databaseContext, IdeaStructure{Name: "Draft", Slug: "draft", Publisher: "octocat",
+		PublisherID: 42, Visibility: ideaVisibilityDraft, CreatedAt: time.Now().Unix()})
+
+	// Private idea of another user answers like one which does not exist
+	for _, linkedIdeaID := range []primitive.ObjectID{privateIdeaID, primitive.NewObjectID()} {
+		linkResponse := serveTestRequest(router, http.MethodPost, "/idea/link/"+ideaID.Hex(),
+			`{"type":"`+ideaLinkBuildsOn+`","idea_id":"`+linkedIdeaID.Hex()+`"}`, sessionToken)
+		if linkResponse.Code != http.StatusNotFound {
+			t.Fatalf("POST /idea/link to %s answered %d: %s", linkedIdeaID.Hex(), linkResponse.Code, linkResponse.Body.String())
+		}
+	}
+	ideaInDB, _ := stores.Ideas.FindByID(databaseContext, ideaID)
+	if len(ideaInDB.Links) != 0 {
+		t.Fatalf("Idea was linked to %+v", ideaInDB.Links)
+	}
+
+	draftResponse := serveTestRequest(router, http.MethodPost, "/idea/link/"+ideaID.Hex(),
+		`{"type":"`+ideaLinkBuildsOn+`","idea_id":"`+draftIdeaID.Hex()+`"}`, sessionToken)
+	if draftResponse.Code != http.StatusCreated {
+		t.Fatalf("POST /idea/link to a draft of the caller answered %d: %s", draftResponse.Code, draftResponse.Body.String())
+	}
+}
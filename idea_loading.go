@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const loadedIdeaKey = "loadedIdea"
+
+// loadIdea : Idea of the :ideaID of the route, read once for the handler. A malformed id is answered with 400 and
+// an idea which does not exist with 404. Visibility is left to the handler, admins and org owners change ideas they cannot see
+func loadIdea(stores Stores) gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ginContext.Param("ideaID"))
+		if errInValidatingID != nil {
+			ginContext.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+				"error": "Error, Idea id is not valid"})
+			return
+		}
+
+		idea, errInFindingIdea := stores.Ideas.FindByID(ginContext.Request.Context(), hexIdeaID)
+		if errInFindingIdea == errNotFoundInStore {
+			abortIdeaNotFound(ginContext)
+			return
+		}
+		if errInFindingIdea != nil {
+			ginContext.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+			return
+		}
+
+		ginContext.Set(loadedIdeaKey, idea)
+		ginContext.Next()
+	}
+}
+
+// abortIdeaNotFound : Also for ideas the caller may not see, so their existence is not given away
+func abortIdeaNotFound(ginContext *gin.Context) {
+	ginContext.AbortWithStatusJSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+		"error": "Error, Idea does not exists"})
+}
+
+// getLoadedIdea : Idea set by loadIdea, routes without it must not call this
+func getLoadedIdea(ginContext *gin.Context) IdeaStructure {
+	return ginContext.MustGet(loadedIdeaKey).(IdeaStructure)
+}
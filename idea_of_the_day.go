@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Days of the idea of the day are UTC dates
+const ideaOfTheDayLayout = "2006-01-02"
+
+// IdeaOfTheDayConfig : Days start whenever the job next checks after midnight UTC, so the interval is how late a pick can be
+type IdeaOfTheDayConfig struct {
+	// 0 turns the job off
+	CheckInterval time.Duration
+	MinGazes      int64
+}
+
+// IdeaOfTheDayStructure : Structure of idea_of_the_day collection, one document per day which is also the history of picks
+type IdeaOfTheDayStructure struct {
+	Day      string             `json:"day" bson:"_id"`
+	IdeaID   primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+	IdeaName string             `json:"idea_name" bson:"idea_name"`
+	IdeaSlug string             `json:"idea_slug" bson:"idea_slug"`
+	PickedAt int64              `json:"picked_at" bson:"picked_at"`
+}
+
+func loadIdeaOfTheDayConfig(configLoader *ConfigLoader) IdeaOfTheDayConfig {
+	var ideaOfTheDayConfig IdeaOfTheDayConfig
+
+	ideaOfTheDayConfig.CheckInterval = time.Duration(configLoader.Int("IDEA_OF_THE_DAY_CHECK_INTERVAL_MINUTES", 60)) * time.Minute
+	ideaOfTheDayConfig.MinGazes = configLoader.Int("IDEA_OF_THE_DAY_MIN_GAZES", 5)
+	if ideaOfTheDayConfig.MinGazes < 0 {
+		configLoader.Invalid("IDEA_OF_THE_DAY_MIN_GAZES", "should be 0 or more")
+	}
+
+	return ideaOfTheDayConfig
+}
+
+func runIdeaOfTheDayJob(databaseClient *mongo.Client, stores Stores, ideaOfTheDayConfig IdeaOfTheDayConfig) {
+	runScheduledJob(databaseClient, "idea_of_the_day", ideaOfTheDayConfig.CheckInterval, func() error {
+		return pickIdeaOfTheDay(databaseClient, stores, ideaOfTheDayConfig, time.Now().UTC())
+	})
+}
+
+// pickIdeaOfTheDay : Picks at random among public ideas with enough gazes which were never picked or featured,
+// checks after the first of the day find the day picked and do nothing
+func pickIdeaOfTheDay(databaseClient *mongo.Client, stores Stores, ideaOfTheDayConfig IdeaOfTheDayConfig, now time.Time) error {
+	ideaOfTheDayCollection := databaseClient.Database("sardene-db").Collection("idea_of_the_day")
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelDBContext()
+
+	day := now.Format(ideaOfTheDayLayout)
+	pickedToday, errInCounting := ideaOfTheDayCollection.CountDocuments(databaseContext, bson.M{"_id": day})
+	if errInCounting != nil || pickedToday > 0 {
+		return errInCounting
+	}
+
+	pickedIdeaIDs, errInFindingPicked := ideaOfTheDayCollection.Distinct(databaseContext, "idea_id", bson.M{}, options.Distinct())
+	if errInFindingPicked != nil {
+		return errInFindingPicked
+	}
+	isPicked := make(map[primitive.ObjectID]bool, len(pickedIdeaIDs))
+	for _, pickedIdeaID := range pickedIdeaIDs {
+		if ideaID, isObjectID := pickedIdeaID.(primitive.ObjectID); isObjectID == true {
+			isPicked[ideaID] = true
+		}
+	}
+
+	// Public ideas which are not archived
+	publicIdeas, errInFindingIdeas := stores.Ideas.ListPublished(databaseContext, IdeaListFilter{},
+		[]string{"id", "name", "slug", "gazers", "featured"})
+	if errInFindingIdeas != nil {
+		return errInFindingIdeas
+	}
+	var qualifyingIdeas []IdeaStructure
+	for _, idea := range publicIdeas {
+		if idea.Featured == false && idea.Gazers >= ideaOfTheDayConfig.MinGazes && isPicked[idea.ID] == false {
+			qualifyingIdeas = append(qualifyingIdeas, idea)
+		}
+	}
+
+	if len(qualifyingIdeas) == 0 {
+		log.Println("No idea qualifies to be the idea of the day " + day)
+		return nil
+	}
+	// Seeded with the time, instances picking on the same day do not all start from the same sequence
+	random := rand.New(rand.NewSource(now.UnixNano()))
+	pickedIdea := qualifyingIdeas[random.Intn(len(qualifyingIdeas))]
+
+	_, errInAdding := ideaOfTheDayCollection.InsertOne(databaseContext, IdeaOfTheDayStructure{Day: day, IdeaID: pickedIdea.ID,
+		IdeaName: pickedIdea.Name, IdeaSlug: pickedIdea.Slug, PickedAt: now.Unix()})
+	if isDuplicateKeyError(errInAdding) {
+		return nil
+	}
+	if errInAdding != nil {
+		return errInAdding
+	}
+	log.Println("Picked idea " + pickedIdea.ID.Hex() + " as the idea of the day " + day)
+	return nil
+}
+
+// getIdeaOfTheDay : The latest pick, which is the one of yesterday until today is picked
+func getIdeaOfTheDay(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores) {
+	ideaOfTheDayCollection := databaseClient.Database("sardene-db").Collection("idea_of_the_day")
+	databaseContext := ginContext.Request.Context()
+
+	var ideaOfTheDay IdeaOfTheDayStructure
+	errInFinding := ideaOfTheDayCollection.FindOne(databaseContext, bson.M{},
+		options.FindOne().SetSort(bson.M{"_id": -1})).Decode(&ideaOfTheDay)
+	if errInFinding != nil && errInFinding != mongo.ErrNoDocuments {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	var idea IdeaStructure
+	errInFindingIdea := errNotFoundInStore
+	if errInFinding == nil {
+		idea, errInFindingIdea = stores.Ideas.FindByID(databaseContext, ideaOfTheDay.IdeaID)
+	}
+	// Picked ideas can be deleted, hidden or archived later in the day
+	if errInFindingIdea == nil && (isIdeaPublic(idea) == false || idea.Archived == true) {
+		errInFindingIdea = errNotFoundInStore
+	}
+	if errInFindingIdea != nil {
+		if errInFindingIdea == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, No idea of the day"})
+			return
+		}
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": idea, "day": ideaOfTheDay.Day,
+		"picked_at": ideaOfTheDay.PickedAt})
+}
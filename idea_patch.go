@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Content type of JSON Merge Patch, plain JSON is taken as one as well
+const mergePatchContentType = "application/merge-patch+json"
+
+// ideaMergePatchFields : Fields of an idea a merge patch can change, by their json name.
+// A field left out of the patch stays as it is, null clears it where an idea can do without it
+var ideaMergePatchFields = map[string]func(contentUpdate *IdeaContentUpdate, patchedValue json.RawMessage) error{
+	"name": func(contentUpdate *IdeaContentUpdate, patchedValue json.RawMessage) error {
+		name, errInDecoding := decodePatchedString(patchedValue)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+		name = normalizeIdeaName(name)
+		if lengthInRunes(name) == 0 || lengthInRunes(name) > maxIdeaNameLength {
+			return errors.New("Name should be 1 to " + strconv.Itoa(maxIdeaNameLength) + " characters long")
+		}
+		contentUpdate.Name = &name
+		return nil
+	},
+	"description": func(contentUpdate *IdeaContentUpdate, patchedValue json.RawMessage) error {
+		description, errInDecoding := decodePatchedString(patchedValue)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+		description = normalizeIdeaDescription(description)
+		if lengthInRunes(description) > maxIdeaDescriptionLength {
+			return errors.New("Description should be at most " + strconv.Itoa(maxIdeaDescriptionLength) + " characters long")
+		}
+		description = sanitizeMarkdown(description)
+		if description == "" {
+			return errors.New("Description should not be empty")
+		}
+		contentUpdate.Description = &description
+		return nil
+	},
+	"repo_url": func(contentUpdate *IdeaContentUpdate, patchedValue json.RawMessage) error {
+		// Null or an empty url unlinks the repo
+		repoURL := ""
+		if isPatchedNull(patchedValue) == false {
+			var errInDecoding error
+			repoURL, errInDecoding = decodePatchedString(patchedValue)
+			if errInDecoding != nil {
+				return errInDecoding
+			}
+		}
+		if strings.TrimSpace(repoURL) != "" {
+			repoURL = normalizeRepoURL(repoURL)
+			if repoURL == "" {
+				return errors.New("Repo url should look like https://github.com/owner/name")
+			}
+		}
+		contentUpdate.RepoURL = &repoURL
+		return nil
+	},
+	"visibility": func(contentUpdate *IdeaContentUpdate, patchedValue json.RawMessage) error {
+		visibility, errInDecoding := decodePatchedString(patchedValue)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+		visibility = strings.ToLower(strings.TrimSpace(visibility))
+		if isValidIdeaVisibility(visibility) == false {
+			return errors.New("Visibility should be public, draft or private")
+		}
+		contentUpdate.Visibility = &visibility
+		return nil
+	},
+	// Not changed by the patch, it is the version the patch was made against like in a PUT
+	"version": func(contentUpdate *IdeaContentUpdate, patchedValue json.RawMessage) error {
+		var expectedVersion int64
+		if isPatchedNull(patchedValue) || json.Unmarshal(patchedValue, &expectedVersion) != nil {
+			return errors.New("Version should be a number")
+		}
+		contentUpdate.ExpectedVersion = &expectedVersion
+		return nil
+	},
+}
+
+func isPatchedNull(patchedValue json.RawMessage) bool {
+	return strings.TrimSpace(string(patchedValue)) == "null"
+}
+
+// decodePatchedString : Fields decoded with this cannot be cleared, null is refused like any value which is not a string
+func decodePatchedString(patchedValue json.RawMessage) (string, error) {
+	var patchedString string
+	if isPatchedNull(patchedValue) || json.Unmarshal(patchedValue, &patchedString) != nil {
+		return "", errors.New("Value should be a string")
+	}
+	return patchedString, nil
+}
+
+// patchIdea : Applies a JSON Merge Patch (RFC 7396) to the content of an idea
+func patchIdea(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaBeforeEdit IdeaStructure) {
+	contentType := ginContext.ContentType()
+	if contentType != mergePatchContentType && contentType != gin.MIMEJSON {
+		ginContext.JSON(http.StatusUnsupportedMediaType, gin.H{"status": http.StatusUnsupportedMediaType,
+			"error": "Patch should be sent as " + mergePatchContentType})
+		return
+	}
+
+	var patchedFields map[string]json.RawMessage
+	errInInputJSON := json.NewDecoder(ginContext.Request.Body).Decode(&patchedFields)
+	if errInInputJSON != nil || patchedFields == nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Patch should be a JSON object"})
+		return
+	}
+
+	var contentUpdate IdeaContentUpdate
+	for field, patchedValue := range patchedFields {
+		applyPatchedField, isPatchable := ideaMergePatchFields[field]
+		if isPatchable == false {
+			ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+				"error": "Field " + field + " cannot be patched"})
+			return
+		}
+		errInField := applyPatchedField(&contentUpdate, patchedValue)
+		if errInField != nil {
+			ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+				"error": "Error in field " + field, "errorDetails": errInField.Error()})
+			return
+		}
+	}
+	if contentUpdate.Name == nil && contentUpdate.Description == nil && contentUpdate.RepoURL == nil && contentUpdate.Visibility == nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Patch changes nothing"})
+		return
+	}
+
+	saveIdeaContentUpdate(ginContext, databaseClient, stores, ideaBeforeEdit, contentUpdate)
+}
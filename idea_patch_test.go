@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func servePatchRequest(router *gin.Engine, path string, contentType string, patch string, accessToken string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(patch))
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestMergePatchOfNullRepoURLClearsIt(t *testing.T) {
+	router, stores, sessionToken := newMemoryTestServer(t)
+	ideaID := addTestIdea(t, stores, 0)
+
+	patchResponse := servePatchRequest(router, "/idea/update/"+ideaID.Hex(), mergePatchContentType, `{"repo_url":null}`, sessionToken)
+	if patchResponse.Code != http.StatusOK {
+		t.Fatalf("PATCH of a null repo url answered %d: %s", patchResponse.Code, patchResponse.Body.String())
+	}
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Second)
+	defer cancelDBContext()
+	ideaInDB, _ := stores.Ideas.FindByID(databaseContext, ideaID)
+	if ideaInDB.RepoURL != "" || ideaInDB.Name != "Sardene" {
+		t.Fatalf("Idea after patching a null repo url is %+v", ideaInDB)
+	}
+}
+
+func TestMergePatchesWhichAreRefused(t *testing.T) {
+	router, stores, sessionToken := newMemoryTestServer(t)
+	ideaID := addTestIdea(t, stores, 0)
+
+	refusedPatches := []struct {
+		contentType string
+		patch       string
+		status      int
+	}{
+		// Name cannot be cleared, an idea cannot do without it
+		{mergePatchContentType, `{"name":null}`, http.StatusBadRequest},
+		{mergePatchContentType, `{"publisher_id":7}`, http.StatusBadRequest},
+		// Version only says which version the patch was made against
+		{mergePatchContentType, `{"version":0}`, http.StatusBadRequest},
+		{"text/plain", `{"description":"Ideas worth building, shared with the makers"}`, http.StatusUnsupportedMediaType},
+	}
+	for _, refusedPatch := range refusedPatches {
+		patchResponse := servePatchRequest(router, "/idea/update/"+ideaID.Hex(), refusedPatch.contentType, refusedPatch.patch,
+			sessionToken)
+		if patchResponse.Code != refusedPatch.status {
+			t.Errorf("PATCH of %s as %s answered %d: %s", refusedPatch.patch, refusedPatch.contentType, patchResponse.Code,
+				patchResponse.Body.String())
+		}
+	}
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Second)
+	defer cancelDBContext()
+	ideaInDB, _ := stores.Ideas.FindByID(databaseContext, ideaID)
+	if ideaInDB.Version != 0 || ideaInDB.Name != "Sardene" {
+		t.Fatalf("Idea after refused patches is %+v", ideaInDB)
+	}
+}
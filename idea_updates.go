@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxIdeaUpdateLength          = 500
+	maxIdeaUpdateLinkLength      = 2000
+	notificationKindIdeaProgress = "idea.progress"
+)
+
+// IdeaProgressUpdateStructure : Structure of progress update in idea_updates collection, posted by makers of the idea
+type IdeaProgressUpdateStructure struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	IdeaID    primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+	UserID    int64              `json:"user_id" bson:"user_id"`
+	Login     string             `json:"login" bson:"login"`
+	Text      string             `json:"text" bson:"text"`
+	Link      string             `json:"link,omitempty" bson:"link,omitempty"`
+	CreatedAt int64              `json:"created_at" bson:"created_at"`
+}
+
+// IdeaProgressUpdateInput : Structure for incoming progress update
+type IdeaProgressUpdateInput struct {
+	Text string `json:"text"`
+	Link string `json:"link"`
+}
+
+// isMakerOfIdea : Makers are only kept in mongo
+func isMakerOfIdea(databaseContext context.Context, databaseClient *mongo.Client, userID int64, ideaID primitive.ObjectID) (bool, error) {
+	makersCollection := databaseClient.Database("sardene-db").Collection("makers")
+
+	makerCount, errInCounting := makersCollection.CountDocuments(databaseContext, bson.M{"userID": userID, "ideaID": ideaID},
+		options.Count().SetLimit(1))
+	return makerCount > 0, errInCounting
+}
+
+// addIdeaUpdate : Makers post progress, and so can whoever edits the idea since they are the ones building it
+func addIdeaUpdate(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	var jsonInput IdeaProgressUpdateInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	text := strings.TrimSpace(normalizeText(jsonInput.Text, true))
+	link := strings.TrimSpace(jsonInput.Link)
+	if errInInputJSON != nil || lengthInRunes(text) == 0 || lengthInRunes(text) > maxIdeaUpdateLength {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Text of the update should be 1 to " + strconv.Itoa(maxIdeaUpdateLength) + " characters long"})
+		return
+	}
+	if link != "" && (len(link) > maxIdeaUpdateLinkLength || isValidWebsite(link) == false) {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Link should be an http or https url"})
+		return
+	}
+
+	user := getAuthenticatedUser(ginContext)
+	databaseContext := ginContext.Request.Context()
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea == nil && isIdeaVisibleTo(idea, user.UserID) == false {
+		errInFindingIdea = errNotFoundInStore
+	}
+	if errInFindingIdea == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+	if errInFindingIdea != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+		return
+	}
+	if idea.Archived == true {
+		abortIdeaArchived(ginContext)
+		return
+	}
+
+	isMaker, errInCheckingMaker := isMakerOfIdea(databaseContext, databaseClient, user.UserID, hexIdeaID)
+	if errInCheckingMaker != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInCheckingMaker.Error()})
+		return
+	}
+	if isMaker == false {
+		editorStatus, errInCheckingEditor := isUserOwnerOfIdea(databaseContext, user, stores, databaseClient, ideaID, true)
+		if editorStatus == http.StatusForbidden {
+			ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+				"error": "Only makers of the idea can post updates to it"})
+			return
+		}
+		if editorStatus != http.StatusOK {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInCheckingEditor.Error()})
+			return
+		}
+	}
+
+	ideaUpdate := IdeaProgressUpdateStructure{IdeaID: hexIdeaID, UserID: user.UserID, Login: user.Login, Text: text, Link: link,
+		CreatedAt: time.Now().Unix()}
+	updatesCollection := databaseClient.Database("sardene-db").Collection("idea_updates")
+	addedUpdate, errInAdding := updatesCollection.InsertOne(databaseContext, ideaUpdate)
+	if errInAdding != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInAdding.Error()})
+		return
+	}
+	ideaUpdate.ID, _ = addedUpdate.InsertedID.(primitive.ObjectID)
+
+	errInNotifying := notifyIdeaSubscribers(databaseContext, databaseClient, idea, notificationKindIdeaProgress, user)
+	if errInNotifying != nil {
+		log.Println(errInNotifying, "Failed to notify subscribers of progress on idea", hexIdeaID.Hex())
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": ideaUpdate})
+}
+
+// getIdeaUpdates : Newest first, updates of drafts and private ideas are as hidden as the ideas themselves
+func getIdeaUpdates(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+	pagination, errInPagination := getPaginationFromQuery(ginContext, 20, 100)
+	if errInPagination != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong pagination", "errorDetails": errInPagination.Error()})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea == nil && isIdeaVisibleTo(idea, getAuthenticatedUser(ginContext).UserID) == false {
+		errInFindingIdea = errNotFoundInStore
+	}
+	if errInFindingIdea == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+	if errInFindingIdea != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+		return
+	}
+
+	updatesCollection := databaseClient.Database("sardene-db").Collection("idea_updates")
+	ideaUpdates := []IdeaProgressUpdateStructure{}
+	pageTotals, errInFinding := findPageWithTotals(databaseContext, updatesCollection, bson.M{"idea_id": hexIdeaID},
+		bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, pagination, &ideaUpdates)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": ideaUpdates, "count": len(ideaUpdates),
+		"page": pagination.Page, "limit": pagination.Limit,
+		"total_count": pageTotals.TotalCount, "total_pages": pageTotals.TotalPages})
+}
+
+// anonymizeUserIdeaUpdates : Progress stays with the idea but does not point to the deleted user anymore
+func anonymizeUserIdeaUpdates(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	updatesCollection := databaseClient.Database("sardene-db").Collection("idea_updates")
+
+	_, errInUpdating := updatesCollection.UpdateMany(databaseContext, bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"login": deletedUserLogin, "user_id": 0}})
+	return errInUpdating
+}
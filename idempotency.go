@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// Keys are forgotten by the TTL index of idempotency_keys after this long
+	idempotencyKeyLifetime = 24 * time.Hour
+
+	idempotencyInProgress = "in_progress"
+	idempotencyDone       = "done"
+)
+
+// IdempotencyKeyStructure : Response stored for a key, so a retried write gets it back instead of running again
+type IdempotencyKeyStructure struct {
+	ID           string    `bson:"_id"`
+	UserID       int64     `bson:"user_id"`
+	Route        string    `bson:"route"`
+	RequestHash  string    `bson:"request_hash"`
+	Status       string    `bson:"status"`
+	StatusCode   int       `bson:"status_code"`
+	ContentType  string    `bson:"content_type"`
+	ResponseBody []byte    `bson:"response_body"`
+	CreatedAt    time.Time `bson:"created_at"`
+	// Requests still in progress after this are taken to have died, a retry takes the key over
+	ClaimedUntil time.Time `bson:"claimed_until"`
+}
+
+// recordingResponseWriter : Keeps a copy of the body written to the client
+type recordingResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (responseWriter *recordingResponseWriter) Write(data []byte) (int, error) {
+	responseWriter.body.Write(data)
+	return responseWriter.ResponseWriter.Write(data)
+}
+
+func (responseWriter *recordingResponseWriter) WriteString(data string) (int, error) {
+	responseWriter.body.WriteString(data)
+	return responseWriter.ResponseWriter.WriteString(data)
+}
+
+// idempotentWrite : Runs the handler once per Idempotency-Key of a user and replays its response on retries,
+// requests without the header are handled as before. requestTimeout is how long the handler may run
+func idempotentWrite(databaseClient *mongo.Client, requestTimeout time.Duration) gin.HandlerFunc {
+	// Keys are kept in mongo, without it a retried write is done again
+	if databaseClient == nil {
+		return func(ginContext *gin.Context) {
+			ginContext.Next()
+		}
+	}
+	return func(ginContext *gin.Context) {
+		idempotencyKey := ginContext.GetHeader(idempotencyKeyHeader)
+		if idempotencyKey == "" {
+			ginContext.Next()
+			return
+		}
+		if len(idempotencyKey) > 255 {
+			ginContext.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+				"error": idempotencyKeyHeader + " should be at most 255 characters"})
+			return
+		}
+
+		user := getAuthenticatedUser(ginContext)
+		databaseContext := ginContext.Request.Context()
+		idempotencyCollection := databaseClient.Database("sardene-db").Collection("idempotency_keys")
+
+		requestBody, errInReadingBody := ioutil.ReadAll(ginContext.Request.Body)
+		if errInReadingBody != nil {
+			ginContext.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+				"error": "Could not read the request", "errorDetails": errInReadingBody.Error()})
+			return
+		}
+		ginContext.Request.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+
+		route := ginContext.Request.Method + " " + ginContext.Request.URL.Path
+		requestHash := sha256.Sum256(append([]byte(route+"\n"), requestBody...))
+
+		storedKey := IdempotencyKeyStructure{
+			ID:          hashedIdempotencyKey(user.UserID, idempotencyKey),
+			UserID:      user.UserID,
+			Route:       route,
+			RequestHash: hex.EncodeToString(requestHash[:]),
+			Status:      idempotencyInProgress,
+			CreatedAt:   time.Now(),
+			// Handlers are cut off at the request timeout, a claim held twice as long belongs to a request which died.
+			// Mongo keeps milliseconds, the claim is matched on it when the outcome is saved
+			ClaimedUntil: time.Now().Add(2 * requestTimeout).Truncate(time.Millisecond),
+		}
+
+		isClaimed, errInClaimingKey := claimIdempotencyKey(databaseContext, idempotencyCollection, storedKey)
+		if errInClaimingKey != nil {
+			ginContext.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Could not check " + idempotencyKeyHeader, "errorDetails": errInClaimingKey.Error()})
+			return
+		}
+		if isClaimed == false {
+			replayIdempotentResponse(ginContext, idempotencyCollection, storedKey)
+			return
+		}
+
+		responseWriter := &recordingResponseWriter{ResponseWriter: ginContext.Writer}
+		ginContext.Writer = responseWriter
+		// Deferred so a panicking handler releases the key too, the panic goes on to the recovery after
+		defer func() {
+			panicValue := recover()
+			saveIdempotentOutcome(idempotencyCollection, storedKey, responseWriter, panicValue != nil)
+			if panicValue != nil {
+				panic(panicValue)
+			}
+		}()
+		ginContext.Next()
+	}
+}
+
+// claimIdempotencyKey : Key is claimed when it is new, or when its request has been in progress past its claim,
+// the same request is then handled again. Keys claimed before claims had a deadline can be taken over too
+func claimIdempotencyKey(databaseContext context.Context, idempotencyCollection *mongo.Collection,
+	requestedKey IdempotencyKeyStructure) (bool, error) {
+	_, errInInserting := idempotencyCollection.InsertOne(databaseContext, requestedKey)
+	if errInInserting == nil || isDuplicateKeyError(errInInserting) == false {
+		return errInInserting == nil, errInInserting
+	}
+
+	result, errInReclaiming := idempotencyCollection.UpdateOne(databaseContext, bson.M{
+		"_id":           requestedKey.ID,
+		"request_hash":  requestedKey.RequestHash,
+		"status":        idempotencyInProgress,
+		"claimed_until": bson.M{"$not": bson.M{"$gt": time.Now()}},
+	}, bson.M{"$set": bson.M{"claimed_until": requestedKey.ClaimedUntil}})
+	if errInReclaiming != nil {
+		return false, errInReclaiming
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// saveIdempotentOutcome : Stores the response for replays. Failures on our side are not remembered,
+// so the client can retry them with the same key. A request whose key was taken over leaves it to the one which took it
+func saveIdempotentOutcome(idempotencyCollection *mongo.Collection, claimedKey IdempotencyKeyStructure,
+	responseWriter *recordingResponseWriter, isPanicking bool) {
+	// The request context may be done by now, the outcome still has to be saved
+	saveContext, cancelSaveContext := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelSaveContext()
+
+	claimFilter := bson.M{"_id": claimedKey.ID, "claimed_until": claimedKey.ClaimedUntil}
+	if isPanicking == true || responseWriter.Status() >= http.StatusInternalServerError {
+		idempotencyCollection.DeleteOne(saveContext, claimFilter)
+		return
+	}
+
+	idempotencyCollection.UpdateOne(saveContext, claimFilter, bson.M{"$set": bson.M{
+		"status":        idempotencyDone,
+		"status_code":   responseWriter.Status(),
+		"content_type":  responseWriter.Header().Get("Content-Type"),
+		"response_body": responseWriter.body.Bytes(),
+	}})
+}
+
+func replayIdempotentResponse(ginContext *gin.Context, idempotencyCollection *mongo.Collection, requestedKey IdempotencyKeyStructure) {
+	var storedKey IdempotencyKeyStructure
+	errInFindingKey := idempotencyCollection.FindOne(ginContext.Request.Context(), bson.M{"_id": requestedKey.ID}).Decode(&storedKey)
+	if errInFindingKey != nil {
+		ginContext.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Could not check " + idempotencyKeyHeader, "errorDetails": errInFindingKey.Error()})
+		return
+	}
+
+	if storedKey.RequestHash != requestedKey.RequestHash {
+		ginContext.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"status": http.StatusUnprocessableEntity,
+			"error": idempotencyKeyHeader + " was already used for a different request"})
+		return
+	}
+	if storedKey.Status == idempotencyInProgress {
+		ginContext.Header("Retry-After", "1")
+		ginContext.AbortWithStatusJSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "The first request with this " + idempotencyKeyHeader + " is still being handled"})
+		return
+	}
+
+	ginContext.Header("Idempotent-Replayed", "true")
+	ginContext.Data(storedKey.StatusCode, storedKey.ContentType, storedKey.ResponseBody)
+	ginContext.Abort()
+}
+
+// hashedIdempotencyKey : Keys are scoped to the user, two users picking the same key do not collide
+func hashedIdempotencyKey(userID int64, idempotencyKey string) string {
+	hashedKey := sha256.Sum256([]byte(strconv.FormatInt(userID, 10) + ":" + idempotencyKey))
+	return hex.EncodeToString(hashedKey[:])
+}
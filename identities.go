@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const identityProviderGithub = "github"
+
+// UserIdentityStructure : Account of a sign in provider which belongs to a Sardene account.
+// GitHub is the only provider so far, so every account has just the identity it signed up with
+type UserIdentityStructure struct {
+	UserID         int64  `json:"-" bson:"user_id"`
+	Provider       string `json:"provider" bson:"provider"`
+	ProviderUserID string `json:"provider_user_id" bson:"provider_user_id"`
+	Login          string `json:"login" bson:"login"`
+	LinkedAt       int64  `json:"linked_at" bson:"linked_at"`
+}
+
+// recordGithubIdentity : Keeps the GitHub identity of the account, the login is refreshed on every sign in
+func recordGithubIdentity(databaseContext context.Context, databaseClient *mongo.Client, githubUser GithubUserProfileStructure) error {
+	if databaseClient == nil {
+		return nil
+	}
+	identitiesCollection := databaseClient.Database("sardene-db").Collection("user_identities")
+
+	providerUserID := strconv.FormatInt(githubUser.UserID, 10)
+	_, errInRecording := identitiesCollection.UpdateOne(databaseContext,
+		bson.M{"provider": identityProviderGithub, "provider_user_id": providerUserID},
+		bson.M{
+			"$set":         bson.M{"login": githubUser.Login},
+			"$setOnInsert": bson.M{"user_id": githubUser.UserID, "linked_at": time.Now().Unix()},
+		},
+		options.Update().SetUpsert(true))
+	if isDuplicateKeyError(errInRecording) {
+		return nil
+	}
+	return errInRecording
+}
+
+func getUserIdentities(ginContext *gin.Context, databaseClient *mongo.Client) {
+	user := getAuthenticatedUser(ginContext)
+
+	identitiesCollection := databaseClient.Database("sardene-db").Collection("user_identities")
+	databaseContext := ginContext.Request.Context()
+
+	identitiesCursor, errInFinding := identitiesCollection.Find(databaseContext, bson.M{"user_id": user.UserID},
+		options.Find().SetSort(bson.M{"linked_at": 1}))
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	defer identitiesCursor.Close(databaseContext)
+
+	identities := []UserIdentityStructure{}
+	for identitiesCursor.Next(databaseContext) {
+		var identity UserIdentityStructure
+		errInDecoding := identitiesCursor.Decode(&identity)
+		if errInDecoding != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in decoding identities", "errorDetails": errInDecoding.Error()})
+			return
+		}
+		identities = append(identities, identity)
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": identities, "count": len(identities)})
+}
+
+func deleteUserIdentities(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	identitiesCollection := databaseClient.Database("sardene-db").Collection("user_identities")
+
+	_, errInDeleting := identitiesCollection.DeleteMany(databaseContext, bson.M{"user_id": userID})
+	return errInDeleting
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const duplicateKeyErrorCode = 11000
+
+// Mongo answers a transaction on a standalone server with this code
+const illegalOperationErrorCode = 20
+
+// RequiredIndex : Index that has to exist for queries of a collection to avoid collection scans
+type RequiredIndex struct {
+	Collection string
+	Model      mongo.IndexModel
+	// Runs when creating the index fails, for cleaning up data which stops it from being built
+	PrepareForRetry func(context.Context, *mongo.Collection) error
+}
+
+var requiredIndexes = []RequiredIndex{
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("ideas_created_at"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "publisher_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("ideas_publisher_id"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "publisher", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("ideas_publisher"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "repo_url", Value: 1}, {Key: "repo.synced_at", Value: 1}},
+		Options: options.Index().SetName("ideas_repo_sync"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+		Options: options.Index().SetName("ideas_text").SetWeights(bson.M{"name": 3, "description": 1}),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "links.idea_id", Value: 1}},
+		Options: options.Index().SetName("ideas_links_idea_id"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetName("ideas_slug"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "featured", Value: 1}, {Key: "featured_at", Value: -1}},
+		Options: options.Index().SetName("ideas_featured"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "status", Value: 1}, {Key: "launch.launched_at", Value: -1}},
+		Options: options.Index().SetName("ideas_status_launched_at"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "trending_score", Value: -1}},
+		Options: options.Index().SetName("ideas_trending_score"),
+	}},
+	{Collection: "ideas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "gazes_last_7d", Value: -1}},
+		Options: options.Index().SetName("ideas_gazes_last_7d"),
+	}},
+	{Collection: "idea_updates", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "idea_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("idea_updates_idea_id_created_at"),
+	}},
+	{Collection: "idea_updates", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("idea_updates_user_id"),
+	}},
+	{Collection: "orgs", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "members.user_id", Value: 1}},
+		Options: options.Index().SetName("orgs_members_user_id"),
+	}},
+	{Collection: "orgs", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "invites.user_id", Value: 1}},
+		Options: options.Index().SetName("orgs_invites_user_id"),
+	}},
+	{Collection: "events", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("events_created_at"),
+	}},
+	{Collection: "events", Model: mongo.IndexModel{
+		Keys: bson.D{{Key: "idea_id", Value: 1}, {Key: "milestone", Value: 1}},
+		Options: options.Index().SetName("events_gaze_milestone_unique").SetUnique(true).
+			SetPartialFilterExpression(bson.M{"kind": activityKindGazeMilestone}),
+	}},
+	{Collection: "audit_log", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("audit_log_created_at"),
+	}},
+	{Collection: "likes", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "userID", Value: 1}, {Key: "ideaID", Value: 1}},
+		Options: options.Index().SetName("likes_user_idea_unique").SetUnique(true),
+	}, PrepareForRetry: removeDuplicateLikes},
+	{Collection: "likes", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "ideaID", Value: 1}},
+		Options: options.Index().SetName("likes_idea_id"),
+	}},
+	{Collection: "likes", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("likes_created_at"),
+	}},
+	{Collection: "users", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "userID", Value: 1}},
+		Options: options.Index().SetName("users_user_id_unique").SetUnique(true),
+	}},
+	{Collection: "bookmarks", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "idea_id", Value: 1}},
+		Options: options.Index().SetName("bookmarks_user_idea_unique").SetUnique(true),
+	}},
+	{Collection: "bookmarks", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("bookmarks_user_created_at"),
+	}},
+	{Collection: "idea_subscriptions", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "idea_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("idea_subscriptions_idea_user_unique").SetUnique(true),
+	}},
+	{Collection: "idea_subscriptions", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("idea_subscriptions_user_id"),
+	}},
+	{Collection: "notifications", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("notifications_user_created_at"),
+	}},
+	{Collection: "follows", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "follower_id", Value: 1}, {Key: "followee_id", Value: 1}},
+		Options: options.Index().SetName("follows_follower_followee_unique").SetUnique(true),
+	}},
+	{Collection: "follows", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "followee_id", Value: 1}},
+		Options: options.Index().SetName("follows_followee_id"),
+	}},
+	{Collection: "users", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "login", Value: 1}},
+		Options: options.Index().SetName("users_login"),
+	}},
+	{Collection: "user_identities", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "provider_user_id", Value: 1}},
+		Options: options.Index().SetName("user_identities_provider_user_unique").SetUnique(true),
+	}},
+	{Collection: "user_identities", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("user_identities_user_id"),
+	}},
+	{Collection: "idea_revisions", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "idea_id", Value: 1}, {Key: "edited_at", Value: -1}},
+		Options: options.Index().SetName("idea_revisions_idea_id"),
+	}},
+	{Collection: "moderation_queue", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+		Options: options.Index().SetName("moderation_queue_status"),
+	}},
+	{Collection: "idempotency_keys", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetName("idempotency_keys_ttl").SetExpireAfterSeconds(int32(idempotencyKeyLifetime.Seconds())),
+	}},
+	{Collection: "auth_attempts", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("auth_attempts_ttl").SetExpireAfterSeconds(0),
+	}},
+	{Collection: "sessions", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("sessions_ttl").SetExpireAfterSeconds(0),
+	}},
+	{Collection: "sessions", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("sessions_user_id"),
+	}},
+	{Collection: "email_login_links", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("email_login_links_ttl").SetExpireAfterSeconds(0),
+	}},
+	{Collection: "user_quotas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("user_quotas_ttl").SetExpireAfterSeconds(0),
+	}},
+	{Collection: "user_quotas", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("user_quotas_user_id"),
+	}},
+	{Collection: "attachment_refs", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("attachment_refs_hash_user_id"),
+	}},
+	{Collection: "attachment_refs", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "idea_id", Value: 1}, {Key: "hash", Value: 1}},
+		Options: options.Index().SetName("attachment_refs_idea_id_hash").SetSparse(true),
+	}},
+}
+
+// isTransactionNotSupportedError : Standalone servers refuse transactions, they need a replica set or a sharded cluster
+func isTransactionNotSupportedError(errInCommand error) bool {
+	commandError, isCommandError := errInCommand.(mongo.CommandError)
+	return isCommandError && commandError.Code == illegalOperationErrorCode
+}
+
+func isDuplicateKeyError(errInWrite error) bool {
+	if writeException, isWriteException := errInWrite.(mongo.WriteException); isWriteException {
+		for _, writeError := range writeException.WriteErrors {
+			if writeError.Code == duplicateKeyErrorCode {
+				return true
+			}
+		}
+	}
+	// Upserts racing on the same _id fail as a command
+	if commandError, isCommandError := errInWrite.(mongo.CommandError); isCommandError && commandError.Code == duplicateKeyErrorCode {
+		return true
+	}
+	if bulkWriteException, isBulkWriteException := errInWrite.(mongo.BulkWriteException); isBulkWriteException {
+		for _, writeError := range bulkWriteException.WriteErrors {
+			if writeError.Code == duplicateKeyErrorCode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeDuplicateLikes : Likes doubled by earlier races would stop the unique index from being built
+func removeDuplicateLikes(databaseContext context.Context, likesCollection *mongo.Collection) error {
+	duplicateLikesPipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":     bson.M{"userID": "$userID", "ideaID": "$ideaID"},
+			"likeIDs": bson.M{"$push": "$_id"},
+			"total":   bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"total": bson.M{"$gt": 1}}}},
+	}
+
+	duplicatesCursor, errInAggregating := likesCollection.Aggregate(databaseContext, duplicateLikesPipeline, options.Aggregate().SetAllowDiskUse(true))
+	if errInAggregating != nil {
+		return errInAggregating
+	}
+	defer duplicatesCursor.Close(databaseContext)
+
+	for duplicatesCursor.Next(databaseContext) {
+		var duplicateLikes struct {
+			LikeIDs []primitive.ObjectID `bson:"likeIDs"`
+		}
+		errInDecoding := duplicatesCursor.Decode(&duplicateLikes)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+
+		// First like is kept, the rest are extra
+		_, errInDeleting := likesCollection.DeleteMany(databaseContext, bson.M{"_id": bson.M{"$in": duplicateLikes.LikeIDs[1:]}})
+		if errInDeleting != nil {
+			return errInDeleting
+		}
+		log.Println("Removed", len(duplicateLikes.LikeIDs)-1, "duplicate likes")
+	}
+
+	return duplicatesCursor.Err()
+}
+
+func listIndexNames(databaseContext context.Context, collection *mongo.Collection) (map[string]bool, error) {
+	indexNames := make(map[string]bool)
+
+	indexesCursor, errInListing := collection.Indexes().List(databaseContext)
+	if errInListing != nil {
+		return indexNames, errInListing
+	}
+	defer indexesCursor.Close(databaseContext)
+
+	for indexesCursor.Next(databaseContext) {
+		var index struct {
+			Name string `bson:"name"`
+		}
+		errInDecoding := indexesCursor.Decode(&index)
+		if errInDecoding != nil {
+			return indexNames, errInDecoding
+		}
+		indexNames[index.Name] = true
+	}
+
+	return indexNames, indexesCursor.Err()
+}
+
+// ensureIndexes : Creates the required indexes which are missing, existing ones are left as they are
+func ensureIndexes(databaseClient *mongo.Client) {
+	sardeneDatabase := databaseClient.Database("sardene-db")
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelDBContext()
+
+	existingIndexesOfCollection := make(map[string]map[string]bool)
+
+	for _, requiredIndex := range requiredIndexes {
+		collection := sardeneDatabase.Collection(requiredIndex.Collection)
+		indexName := *requiredIndex.Model.Options.Name
+
+		existingIndexes, isListed := existingIndexesOfCollection[requiredIndex.Collection]
+		if isListed == false {
+			var errInListing error
+			existingIndexes, errInListing = listIndexNames(databaseContext, collection)
+			if errInListing != nil {
+				log.Fatal(errInListing, "Failed to list indexes of "+requiredIndex.Collection)
+			}
+			existingIndexesOfCollection[requiredIndex.Collection] = existingIndexes
+		}
+		if existingIndexes[indexName] == true {
+			continue
+		}
+
+		_, errInCreatingIndex := collection.Indexes().CreateOne(databaseContext, requiredIndex.Model)
+		if errInCreatingIndex != nil && requiredIndex.PrepareForRetry != nil {
+			errInPreparing := requiredIndex.PrepareForRetry(databaseContext, collection)
+			if errInPreparing != nil {
+				log.Fatal(errInPreparing, "Failed to prepare "+requiredIndex.Collection+" for index "+indexName)
+			}
+			_, errInCreatingIndex = collection.Indexes().CreateOne(databaseContext, requiredIndex.Model)
+		}
+		if errInCreatingIndex != nil {
+			log.Fatal(errInCreatingIndex, "Failed to create index "+indexName+" on "+requiredIndex.Collection)
+		}
+
+		log.Println("Created index", indexName, "on", requiredIndex.Collection)
+	}
+}
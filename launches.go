@@ -0,0 +1,136 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	auditActionIdeaLaunched = "idea.launched"
+
+	maxLaunchTaglineLength    = 120
+	maxLaunchProductURLLength = 2048
+	launchDateLayout          = "2006-01-02"
+)
+
+// IdeaLaunchStructure : Product shipped from an idea, shown in /ideas/launched
+type IdeaLaunchStructure struct {
+	ProductURL string `json:"product_url" bson:"product_url"`
+	// Midnight UTC of the day the product launched
+	LaunchedAt int64  `json:"launched_at" bson:"launched_at"`
+	Tagline    string `json:"tagline" bson:"tagline"`
+}
+
+// IdeaLaunchInput : Structure for incoming launch, the date is a day like 2019-07-30
+type IdeaLaunchInput struct {
+	ProductURL string `json:"product_url"`
+	LaunchDate string `json:"launch_date"`
+	Tagline    string `json:"tagline"`
+}
+
+// launchOfInput : Normalized launch, or the problem with one of its fields
+func launchOfInput(jsonInput IdeaLaunchInput, now time.Time) (IdeaLaunchStructure, string) {
+	var launch IdeaLaunchStructure
+
+	launch.ProductURL = strings.TrimSpace(jsonInput.ProductURL)
+	if launch.ProductURL == "" || lengthInRunes(launch.ProductURL) > maxLaunchProductURLLength ||
+		isValidWebsite(launch.ProductURL) == false {
+		return launch, "Product url should be an http or https url of at most " + strconv.Itoa(maxLaunchProductURLLength) + " characters"
+	}
+
+	launchDate, errInParsingDate := time.Parse(launchDateLayout, strings.TrimSpace(jsonInput.LaunchDate))
+	if errInParsingDate != nil {
+		return launch, "Launch date should be a day like " + launchDateLayout
+	}
+	// A day ahead is allowed, it may already be that day where the publisher is
+	if launchDate.After(now.UTC().AddDate(0, 0, 1)) {
+		return launch, "Launch date should not be in the future"
+	}
+	launch.LaunchedAt = launchDate.Unix()
+
+	launch.Tagline = normalizeIdeaName(jsonInput.Tagline)
+	if lengthInRunes(launch.Tagline) == 0 || lengthInRunes(launch.Tagline) > maxLaunchTaglineLength {
+		return launch, "Tagline should be 1 to " + strconv.Itoa(maxLaunchTaglineLength) + " characters long"
+	}
+
+	return launch, ""
+}
+
+// launchIdea : Moves the idea to launched with its launch, launching it again replaces the launch
+func launchIdea(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, idea IdeaStructure) {
+	var jsonInput IdeaLaunchInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Launch should be given as JSON", "errorDetails": errInInputJSON.Error()})
+		return
+	}
+	launch, problemWithLaunch := launchOfInput(jsonInput, time.Now())
+	if problemWithLaunch != "" {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "error": problemWithLaunch})
+		return
+	}
+	if idea.Archived == true {
+		abortIdeaArchived(ginContext)
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	errInSetting := stores.Ideas.SetLaunched(databaseContext, idea.ID, launch)
+	if errInSetting == errNotFoundInStore {
+		abortIdeaNotFound(ginContext)
+		return
+	}
+	if errInSetting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInSetting.Error()})
+		return
+	}
+
+	ideaAfterChange := idea
+	ideaAfterChange.Status = ideaStatusLaunched
+	ideaAfterChange.Launch = &launch
+	describeAuditedMutation(ginContext, auditActionIdeaLaunched, idea.ID.Hex(), idea, ideaAfterChange)
+	user := getAuthenticatedUser(ginContext)
+	errInRecordingActivity := recordActivity(databaseContext, databaseClient, activityKindIdeaLaunched, ideaAfterChange, user.Login)
+	if errInRecordingActivity != nil {
+		log.Println(errInRecordingActivity, "Failed to add launch of idea to activity", idea.ID.Hex())
+	}
+	errInNotifying := notifyIdeaSubscribers(databaseContext, databaseClient, ideaAfterChange, notificationKindIdeaLaunched, user)
+	if errInNotifying != nil {
+		log.Println(errInNotifying, "Failed to notify subscribers of idea", idea.ID.Hex())
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Idea launched successfully",
+		"data": gin.H{"status": ideaStatusLaunched, "launch": launch}})
+}
+
+// getLaunchedIdeas : Public launched ideas which are not archived, the latest launch first
+func getLaunchedIdeas(ginContext *gin.Context, stores Stores) {
+	pagination, errInPagination := getPaginationFromQuery(ginContext, 20, 100)
+	if errInPagination != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong pagination", "errorDetails": errInPagination.Error()})
+		return
+	}
+
+	launchedIdeas, errInFinding := stores.Ideas.ListLaunched(ginContext.Request.Context(), pagination.Skip(), pagination.Limit)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	if launchedIdeas == nil {
+		launchedIdeas = []IdeaStructure{}
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": launchedIdeas, "count": len(launchedIdeas),
+		"page": pagination.Page, "limit": pagination.Limit})
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LinkPreviewStructure : OpenGraph card of a url mentioned in an idea, cached in link_previews
+type LinkPreviewStructure struct {
+	URL         string `json:"url" bson:"_id"`
+	Title       string `json:"title" bson:"title"`
+	Description string `json:"description" bson:"description"`
+	Image       string `json:"image" bson:"image"`
+	FetchedAt   int64  `json:"fetched_at" bson:"fetched_at"`
+	// Failed previews are cached too, so a dead link is not fetched on every edit
+	Failed bool `json:"-" bson:"failed"`
+}
+
+// LinkPreviewConfig : Limits of fetching pages for previews
+type LinkPreviewConfig struct {
+	CacheDuration   time.Duration
+	MaxBytes        int64
+	MaxLinksPerIdea int
+}
+
+// LinkPreviewer : Fetches previews in the background, nil when link previews are switched off
+type LinkPreviewer struct {
+	databaseClient *mongo.Client
+	httpClient     *http.Client
+	config         LinkPreviewConfig
+	// URLs being fetched right now, so two edits of an idea do not fetch a page twice
+	fetchingMutex sync.Mutex
+	fetching      map[string]bool
+}
+
+var linkPreviewer *LinkPreviewer
+
+var errPrivateAddress = errors.New("Link points to a private address")
+
+// Punctuation right after a url usually ends the sentence, not the url
+const trailingURLPunctuation = ".,;:!?"
+
+var (
+	urlsInTextRegex    = regexp.MustCompile(`(?i)https?://[^\s<>()"'\[\]]+`)
+	htmlHeadEndRegex   = regexp.MustCompile(`(?i)</head\s*>`)
+	htmlMetaTagRegex   = regexp.MustCompile(`(?i)<meta\s[^>]*>`)
+	htmlTitleRegex     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title\s*>`)
+	htmlAttributeRegex = regexp.MustCompile(`(?i)([a-z:_-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+)
+
+// privateNetworks : Ranges which are not reachable from the internet, carrier grade NAT included
+var privateNetworks = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, errInCIDR := net.ParseCIDR(cidr)
+		if errInCIDR != nil {
+			panic(errInCIDR)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func loadLinkPreviewConfig(configLoader *ConfigLoader) LinkPreviewConfig {
+	var linkPreviewConfig LinkPreviewConfig
+
+	linkPreviewConfig.CacheDuration = time.Duration(configLoader.Int("LINK_PREVIEW_CACHE_HOURS", 7*24)) * time.Hour
+	linkPreviewConfig.MaxBytes = configLoader.Int("LINK_PREVIEW_MAX_BYTES", 512*1024)
+	if linkPreviewConfig.MaxBytes <= 0 {
+		configLoader.Invalid("LINK_PREVIEW_MAX_BYTES", "should be more than 0")
+	}
+	linkPreviewConfig.MaxLinksPerIdea = int(configLoader.Int("LINK_PREVIEW_MAX_LINKS", 5))
+
+	return linkPreviewConfig
+}
+
+// isPublicIP : Pages on the network of the API itself are never fetched for a preview
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, privateNetwork := range privateNetworks {
+		if privateNetwork.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func newLinkPreviewer(databaseClient *mongo.Client, linkPreviewConfig LinkPreviewConfig, outboundHTTPConfig OutboundHTTPConfig) *LinkPreviewer {
+	// Address is checked after it is resolved, so a hostname pointing inside cannot slip through, redirects included
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, errInAddress := net.SplitHostPort(address)
+			if errInAddress != nil {
+				return errInAddress
+			}
+			if ip := net.ParseIP(host); ip == nil || isPublicIP(ip) == false {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: outboundHTTPConfig.Timeout,
+		MaxIdleConnsPerHost:   outboundHTTPConfig.MaxIdleConnsPerHost,
+	}
+
+	return &LinkPreviewer{
+		databaseClient: databaseClient,
+		httpClient:     &http.Client{Transport: transport, Timeout: outboundHTTPConfig.Timeout},
+		config:         linkPreviewConfig,
+		fetching:       make(map[string]bool),
+	}
+}
+
+// urlsInText : Distinct http urls of the text in the order they appear, at most maxURLs of them
+func urlsInText(text string, maxURLs int) []string {
+	var urls []string
+	isSeen := make(map[string]bool)
+
+	for _, foundURL := range urlsInTextRegex.FindAllString(text, -1) {
+		foundURL = strings.TrimRight(foundURL, trailingURLPunctuation)
+		if isSeen[foundURL] || isAllowedPreviewURL(foundURL) == false {
+			continue
+		}
+		if len(urls) >= maxURLs {
+			break
+		}
+		isSeen[foundURL] = true
+		urls = append(urls, foundURL)
+	}
+	return urls
+}
+
+// RefreshInBackground : Fetches previews of the urls in the text which are not cached or have gone stale
+func (previewer *LinkPreviewer) RefreshInBackground(text string) {
+	urls := urlsInText(text, previewer.config.MaxLinksPerIdea)
+	if len(urls) == 0 {
+		return
+	}
+
+	go func() {
+		previewContext, cancelPreviewContext := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancelPreviewContext()
+
+		errInRefreshing := previewer.refresh(previewContext, urls)
+		if errInRefreshing != nil {
+			log.Println(errInRefreshing, "Failed to refresh link previews")
+		}
+	}()
+}
+
+func (previewer *LinkPreviewer) refresh(previewContext context.Context, urls []string) error {
+	linkPreviewsCollection := previewer.databaseClient.Database("sardene-db").Collection("link_previews")
+
+	freshAfter := time.Now().Add(-previewer.config.CacheDuration).Unix()
+	freshPreviews, errInFinding := findLinkPreviews(previewContext, previewer.databaseClient,
+		bson.M{"_id": bson.M{"$in": urls}, "fetched_at": bson.M{"$gte": freshAfter}})
+	if errInFinding != nil {
+		return errInFinding
+	}
+
+	for _, previewURL := range urls {
+		if _, isFresh := freshPreviews[previewURL]; isFresh || previewer.startFetching(previewURL) == false {
+			continue
+		}
+
+		linkPreview, errInFetching := previewer.fetch(previewContext, previewURL)
+		previewer.stopFetching(previewURL)
+		if errInFetching != nil {
+			log.Println(errInFetching, "Failed to fetch preview of", previewURL)
+			linkPreview = LinkPreviewStructure{URL: previewURL, FetchedAt: time.Now().Unix(), Failed: true}
+		}
+
+		_, errInSaving := linkPreviewsCollection.ReplaceOne(previewContext, bson.M{"_id": previewURL}, linkPreview,
+			options.Replace().SetUpsert(true))
+		if errInSaving != nil {
+			return errInSaving
+		}
+	}
+
+	return nil
+}
+
+func (previewer *LinkPreviewer) startFetching(previewURL string) bool {
+	previewer.fetchingMutex.Lock()
+	defer previewer.fetchingMutex.Unlock()
+
+	if previewer.fetching[previewURL] {
+		return false
+	}
+	previewer.fetching[previewURL] = true
+	return true
+}
+
+func (previewer *LinkPreviewer) stopFetching(previewURL string) {
+	previewer.fetchingMutex.Lock()
+	defer previewer.fetchingMutex.Unlock()
+
+	delete(previewer.fetching, previewURL)
+}
+
+func (previewer *LinkPreviewer) fetch(previewContext context.Context, previewURL string) (LinkPreviewStructure, error) {
+	linkPreview := LinkPreviewStructure{URL: previewURL, FetchedAt: time.Now().Unix()}
+
+	requestPage, errInRequestingPage := http.NewRequestWithContext(previewContext, "GET", previewURL, nil)
+	if errInRequestingPage != nil {
+		return linkPreview, errInRequestingPage
+	}
+	requestPage.Header.Set("Accept", "text/html")
+	requestPage.Header.Set("User-Agent", "Sardene-LinkPreview/1.0")
+
+	responseWithPage, errInResponse := previewer.httpClient.Do(requestPage)
+	if errInResponse != nil {
+		return linkPreview, errInResponse
+	}
+	defer responseWithPage.Body.Close()
+
+	if responseWithPage.StatusCode != http.StatusOK {
+		return linkPreview, errors.New("Page answered " + responseWithPage.Status)
+	}
+	if strings.Contains(strings.ToLower(responseWithPage.Header.Get("Content-Type")), "html") == false {
+		return linkPreview, errors.New("Page is not html")
+	}
+
+	pageBytes, errInReading := ioutil.ReadAll(io.LimitReader(responseWithPage.Body, previewer.config.MaxBytes))
+	if errInReading != nil {
+		return linkPreview, errInReading
+	}
+
+	linkPreview.Title, linkPreview.Description, linkPreview.Image = parseOpenGraph(string(pageBytes))
+	// Relative images are resolved against the page the redirects ended on
+	if linkPreview.Image != "" {
+		imageURL, errInImageURL := responseWithPage.Request.URL.Parse(linkPreview.Image)
+		if errInImageURL != nil || (imageURL.Scheme != "http" && imageURL.Scheme != "https") {
+			linkPreview.Image = ""
+		} else {
+			linkPreview.Image = imageURL.String()
+		}
+	}
+
+	return linkPreview, nil
+}
+
+// parseOpenGraph : Title, description and image of the page, from og: tags with the plain html ones as fallback
+func parseOpenGraph(page string) (string, string, string) {
+	if headEnd := htmlHeadEndRegex.FindStringIndex(page); headEnd != nil {
+		page = page[:headEnd[0]]
+	}
+
+	metaContents := make(map[string]string)
+	for _, metaTag := range htmlMetaTagRegex.FindAllString(page, -1) {
+		attributes := make(map[string]string)
+		for _, attribute := range htmlAttributeRegex.FindAllStringSubmatch(metaTag, -1) {
+			attributes[strings.ToLower(attribute[1])] = strings.Trim(attribute[2], `"'`)
+		}
+
+		metaName := attributes["property"]
+		if metaName == "" {
+			metaName = attributes["name"]
+		}
+		metaName = strings.ToLower(metaName)
+		if _, isSet := metaContents[metaName]; metaName != "" && isSet == false {
+			metaContents[metaName] = strings.TrimSpace(html.UnescapeString(attributes["content"]))
+		}
+	}
+
+	title := metaContents["og:title"]
+	if title == "" {
+		if titleMatch := htmlTitleRegex.FindStringSubmatch(page); titleMatch != nil {
+			title = strings.TrimSpace(html.UnescapeString(titleMatch[1]))
+		}
+	}
+	description := metaContents["og:description"]
+	if description == "" {
+		description = metaContents["description"]
+	}
+
+	return limitText(title, 300), limitText(description, 1000), metaContents["og:image"]
+}
+
+func limitText(text string, maxRunes int) string {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	return string(runes[:maxRunes])
+}
+
+func findLinkPreviews(databaseContext context.Context, databaseClient *mongo.Client, previewsFilter bson.M) (map[string]LinkPreviewStructure, error) {
+	linkPreviewsCollection := databaseClient.Database("sardene-db").Collection("link_previews")
+	linkPreviews := make(map[string]LinkPreviewStructure)
+
+	previewsCursor, errInFinding := linkPreviewsCollection.Find(databaseContext, previewsFilter, options.Find())
+	if errInFinding != nil {
+		return linkPreviews, errInFinding
+	}
+	defer previewsCursor.Close(databaseContext)
+
+	for previewsCursor.Next(databaseContext) {
+		var linkPreview LinkPreviewStructure
+		errInDecoding := previewsCursor.Decode(&linkPreview)
+		if errInDecoding != nil {
+			return linkPreviews, errInDecoding
+		}
+		linkPreviews[linkPreview.URL] = linkPreview
+	}
+
+	return linkPreviews, previewsCursor.Err()
+}
+
+// attachLinkPreviews : Fills in the cached previews of every idea with one query, urls without a preview yet are left out
+func (previewer *LinkPreviewer) attachLinkPreviews(databaseContext context.Context, ideas []IdeaStructure) error {
+	urlsOfIdeas := make([][]string, len(ideas))
+	var allURLs []string
+	for ideaIndex, idea := range ideas {
+		urlsOfIdeas[ideaIndex] = urlsInText(idea.Description, previewer.config.MaxLinksPerIdea)
+		allURLs = append(allURLs, urlsOfIdeas[ideaIndex]...)
+	}
+	if len(allURLs) == 0 {
+		return nil
+	}
+
+	linkPreviews, errInFinding := findLinkPreviews(databaseContext, previewer.databaseClient,
+		bson.M{"_id": bson.M{"$in": allURLs}, "failed": false})
+	if errInFinding != nil {
+		return errInFinding
+	}
+
+	for ideaIndex := range ideas {
+		for _, previewURL := range urlsOfIdeas[ideaIndex] {
+			if linkPreview, isCached := linkPreviews[previewURL]; isCached {
+				ideas[ideaIndex].LinkPreviews = append(ideas[ideaIndex].LinkPreviews, linkPreview)
+			}
+		}
+	}
+	return nil
+}
+
+// isAllowedPreviewURL : Only absolute http urls are fetched
+func isAllowedPreviewURL(previewURL string) bool {
+	parsedURL, errInParsing := url.Parse(previewURL)
+	return errInParsing == nil && (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != ""
+}
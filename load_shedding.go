@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConcurrencyConfig : Requests one instance works on at once, and how many more wait for one of them to finish
+type ConcurrencyConfig struct {
+	MaxInFlight  int64
+	MaxQueued    int64
+	QueueTimeout time.Duration
+	// Sent as Retry-After with shed requests
+	RetryAfter time.Duration
+}
+
+// ConcurrencyLimiter : Slots of requests in flight, nil when MAX_IN_FLIGHT_REQUESTS is 0.
+// Requests beyond the slots and the queue are shed, so a spike cannot exhaust the pool of mongo connections
+type ConcurrencyLimiter struct {
+	config ConcurrencyConfig
+	slots  chan struct{}
+	queued int64
+	shed   int64
+}
+
+// ConcurrencyMetrics : Requests in flight and waiting right now, and those shed since the instance started
+type ConcurrencyMetrics struct {
+	MaxInFlight int64 `json:"max_in_flight"`
+	InFlight    int64 `json:"in_flight"`
+	Queued      int64 `json:"queued"`
+	Shed        int64 `json:"shed"`
+}
+
+var requestConcurrencyLimiter *ConcurrencyLimiter
+
+func loadConcurrencyConfig(configLoader *ConfigLoader) ConcurrencyConfig {
+	var concurrencyConfig ConcurrencyConfig
+
+	concurrencyConfig.MaxInFlight = configLoader.Int("MAX_IN_FLIGHT_REQUESTS", 200)
+	if concurrencyConfig.MaxInFlight < 0 {
+		configLoader.Invalid("MAX_IN_FLIGHT_REQUESTS", "should be 0 or more")
+	}
+	concurrencyConfig.MaxQueued = configLoader.Int("MAX_QUEUED_REQUESTS", 100)
+	if concurrencyConfig.MaxQueued < 0 {
+		configLoader.Invalid("MAX_QUEUED_REQUESTS", "should be 0 or more")
+	}
+	concurrencyConfig.QueueTimeout = time.Duration(configLoader.Int("REQUEST_QUEUE_TIMEOUT_MS", 500)) * time.Millisecond
+	if concurrencyConfig.QueueTimeout < 0 {
+		configLoader.Invalid("REQUEST_QUEUE_TIMEOUT_MS", "should be 0 or more")
+	}
+	concurrencyConfig.RetryAfter = time.Duration(configLoader.Int("SHED_RETRY_AFTER_SECONDS", 2)) * time.Second
+	if concurrencyConfig.RetryAfter <= 0 {
+		configLoader.Invalid("SHED_RETRY_AFTER_SECONDS", "should be more than 0")
+	}
+
+	return concurrencyConfig
+}
+
+func newConcurrencyLimiter(concurrencyConfig ConcurrencyConfig) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{config: concurrencyConfig, slots: make(chan struct{}, concurrencyConfig.MaxInFlight)}
+}
+
+// acquire : Takes a slot right away or waits in the queue for one, false when the request has to be shed
+func (concurrencyLimiter *ConcurrencyLimiter) acquire(ginContext *gin.Context) bool {
+	select {
+	case concurrencyLimiter.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if atomic.AddInt64(&concurrencyLimiter.queued, 1) > concurrencyLimiter.config.MaxQueued {
+		atomic.AddInt64(&concurrencyLimiter.queued, -1)
+		return false
+	}
+	defer atomic.AddInt64(&concurrencyLimiter.queued, -1)
+
+	queueTimer := time.NewTimer(concurrencyLimiter.config.QueueTimeout)
+	defer queueTimer.Stop()
+
+	select {
+	case concurrencyLimiter.slots <- struct{}{}:
+		return true
+	case <-queueTimer.C:
+		return false
+	case <-ginContext.Request.Context().Done():
+		return false
+	}
+}
+
+func (concurrencyLimiter *ConcurrencyLimiter) release() {
+	<-concurrencyLimiter.slots
+}
+
+// Metrics : Snapshot for the admin metrics of the database
+func (concurrencyLimiter *ConcurrencyLimiter) Metrics() ConcurrencyMetrics {
+	return ConcurrencyMetrics{
+		MaxInFlight: concurrencyLimiter.config.MaxInFlight,
+		InFlight:    int64(len(concurrencyLimiter.slots)),
+		Queued:      atomic.LoadInt64(&concurrencyLimiter.queued),
+		Shed:        atomic.LoadInt64(&concurrencyLimiter.shed),
+	}
+}
+
+// limitConcurrentRequests : Routes answering without the database never wait for a slot, so probes see a busy instance as up
+func limitConcurrentRequests() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		if requestConcurrencyLimiter == nil || pathsWithoutDatabase[ginContext.Request.URL.Path] == true {
+			ginContext.Next()
+			return
+		}
+
+		if requestConcurrencyLimiter.acquire(ginContext) == false {
+			atomic.AddInt64(&requestConcurrencyLimiter.shed, 1)
+			ginContext.Header("Retry-After", strconv.Itoa(int(requestConcurrencyLimiter.config.RetryAfter.Seconds())))
+			ginContext.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Server is busy, try again shortly"})
+			return
+		}
+		defer requestConcurrencyLimiter.release()
+
+		ginContext.Next()
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LeaseStructure : Lock in locks collection, held by one instance until it is released or expires
+type LeaseStructure struct {
+	Name      string `json:"name" bson:"_id"`
+	Owner     string `json:"owner" bson:"owner"`
+	ExpiresAt int64  `json:"expires_at" bson:"expires_at"`
+}
+
+// instanceID : Identifies this process as owner of leases, unique across instances and restarts
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	hostname, errInHostname := os.Hostname()
+	if errInHostname != nil {
+		hostname = "unknown"
+	}
+
+	randomBytes := make([]byte, 4)
+	rand.Read(randomBytes)
+
+	return hostname + "-" + strconv.Itoa(os.Getpid()) + "-" + hex.EncodeToString(randomBytes)
+}
+
+// acquireLease : Takes the lease if it is free or expired, renewing it when this instance already holds it
+func acquireLease(databaseContext context.Context, databaseClient *mongo.Client, leaseName string, leaseDuration time.Duration) (bool, error) {
+	locksCollection := databaseClient.Database("sardene-db").Collection("locks")
+	timeNow := time.Now().Unix()
+
+	freeOrOwnLease := bson.M{
+		"_id": leaseName,
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$lt": timeNow}},
+			bson.M{"owner": instanceID},
+		},
+	}
+	takeLease := bson.M{"$set": bson.M{
+		"owner":      instanceID,
+		"expires_at": timeNow + int64(leaseDuration.Seconds()),
+	}}
+
+	// Lease held by someone else doesn't match the filter, so the upsert fails on the unique _id
+	_, errInAcquiring := locksCollection.UpdateOne(databaseContext, freeOrOwnLease, takeLease, options.Update().SetUpsert(true))
+	if errInAcquiring != nil {
+		if isDuplicateKeyError(errInAcquiring) {
+			return false, nil
+		}
+		return false, errInAcquiring
+	}
+
+	return true, nil
+}
+
+// releaseLease : Frees the lease only if this instance still holds it
+func releaseLease(databaseContext context.Context, databaseClient *mongo.Client, leaseName string) error {
+	locksCollection := databaseClient.Database("sardene-db").Collection("locks")
+
+	_, errInReleasing := locksCollection.DeleteOne(databaseContext, bson.M{"_id": leaseName, "owner": instanceID})
+	return errInReleasing
+}
+
+// waitForLease : Tries to take the lease until it is free or the context is done, for short work instances take turns on
+func waitForLease(databaseContext context.Context, databaseClient *mongo.Client, leaseName string, leaseDuration time.Duration) error {
+	for {
+		isLeaseAcquired, errInAcquiring := acquireLease(databaseContext, databaseClient, leaseName, leaseDuration)
+		if errInAcquiring != nil || isLeaseAcquired == true {
+			return errInAcquiring
+		}
+
+		select {
+		case <-databaseContext.Done():
+			return databaseContext.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// keepLeaseRenewed : Renews the lease until stop is closed, for work that can outlast a single lease
+func keepLeaseRenewed(databaseClient *mongo.Client, leaseName string, leaseDuration time.Duration, stop <-chan struct{}) {
+	renewTicker := time.NewTicker(leaseDuration / 3)
+	defer renewTicker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-renewTicker.C:
+			databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 30*time.Second)
+			acquireLease(databaseContext, databaseClient, leaseName, leaseDuration)
+			cancelDBContext()
+		}
+	}
+}
+
+// runScheduledJob : Runs job every interval on whichever instance holds its lease, so it runs once per interval across instances
+func runScheduledJob(databaseClient *mongo.Client, jobName string, interval time.Duration, job func() error) {
+	if interval <= 0 {
+		return
+	}
+	leaseName := "job:" + jobName
+	// Lease outlives the run so instances ticking later in the same interval skip it, and expires before the next one
+	leaseDuration := interval * 9 / 10
+
+	jobTicker := time.NewTicker(interval)
+	defer jobTicker.Stop()
+
+	for range jobTicker.C {
+		databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 30*time.Second)
+		isLeaseAcquired, errInAcquiring := acquireLease(databaseContext, databaseClient, leaseName, leaseDuration)
+		cancelDBContext()
+		if errInAcquiring != nil {
+			log.Println(errInAcquiring, "Failed to acquire lease of job "+jobName)
+			continue
+		}
+		if isLeaseAcquired == false {
+			continue
+		}
+
+		stopRenewingLease := make(chan struct{})
+		go keepLeaseRenewed(databaseClient, leaseName, leaseDuration, stopRenewingLease)
+
+		errInJob := job()
+		close(stopRenewingLease)
+		if errInJob != nil {
+			log.Println(errInJob, "Scheduled job "+jobName+" failed")
+		}
+	}
+}
@@ -1,20 +1,21 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -27,9 +28,66 @@ type IdeaStructure struct {
 	Description string             `json:"description" bson:"description"`
 	Publisher   string             `json:"publisher" bson:"publisher"`
 	PublisherID int64              `json:"publisher_id" bson:"publisher_id"`
-	Makers      int64              `json:"makers" bson:"makers"`
-	Gazers      int64              `json:"gazers" bson:"gazers"`
-	CreatedAt   int64              `json:"created_at" bson:"created_at"`
+	// Ideas published under an org have its slug as publisher and the login of the member who wrote it as author,
+	// publisher id stays the id of the author
+	Org    string `json:"org,omitempty" bson:"org,omitempty"`
+	Author string `json:"author,omitempty" bson:"author,omitempty"`
+	Makers int64  `json:"makers" bson:"makers"`
+	Gazers int64  `json:"gazers" bson:"gazers"`
+	// Fetches of the detail page, written in batches so it can lag behind by the view flush interval
+	Views int64 `json:"views" bson:"views"`
+	// Count of each reaction, they add up to gazers
+	Reactions map[string]int64 `json:"reactions" bson:"reactions,omitempty"`
+	CreatedAt int64            `json:"created_at" bson:"created_at"`
+	UpdatedAt int64            `json:"updated_at" bson:"updated_at"`
+	Slug      string           `json:"slug" bson:"slug"`
+	Status    string           `json:"status" bson:"status"`
+	// Held ideas are hidden from listings until a moderator reviews them
+	HeldForReview bool   `json:"held_for_review" bson:"held_for_review"`
+	Visibility    string `json:"visibility" bson:"visibility"`
+	// Featured ideas are picked by admins and listed in /ideas/featured, newest pick first
+	Featured   bool  `json:"featured" bson:"featured"`
+	FeaturedAt int64 `json:"featured_at" bson:"featured_at"`
+	// Archived ideas are left out of listings and cannot be gazed, they stay readable at their url
+	Archived   bool  `json:"archived" bson:"archived"`
+	ArchivedAt int64 `json:"archived_at" bson:"archived_at"`
+	// Kept up to date as ideas are gazed and recomputed by the trending job, so trending and rising sorts are indexed
+	GazesLast7d   int64   `json:"gazes_last_7d" bson:"gazes_last_7d"`
+	TrendingScore float64 `json:"trending_score" bson:"trending_score"`
+	// Incremented by every edit of the content, editors send the version they read so concurrent edits are not lost
+	Version int64 `json:"version" bson:"version"`
+	// Collaborators can edit the idea like its publisher, only the publisher manages them
+	Collaborators []IdeaCollaboratorStructure `json:"collaborators" bson:"collaborators"`
+	Links         []IdeaLinkStructure         `json:"links" bson:"links"`
+	// Repo is filled in by the repo sync job, clients only send the url
+	RepoURL string                 `json:"repo_url" bson:"repo_url"`
+	Repo    *RepoMetadataStructure `json:"repo,omitempty" bson:"repo,omitempty"`
+	// Set when the idea moves to launched through /ideas/:ideaID/launch
+	Launch *IdeaLaunchStructure `json:"launch,omitempty" bson:"launch,omitempty"`
+	// Issue the idea was last exported to with /ideas/:ideaID/export/github
+	GithubIssueURL string `json:"github_issue_url,omitempty" bson:"github_issue_url,omitempty"`
+	// Images are uploaded through /ideas/:ideaID/images, their bytes are attachments kept in mongo
+	Images []IdeaImageStructure `json:"images,omitempty" bson:"images,omitempty"`
+	// Rendered only when asked for with ?render=html, never stored
+	DescriptionHTML string `json:"description_html,omitempty" bson:"-"`
+	// Cards of the urls in the description, read from the link_previews cache
+	LinkPreviews []LinkPreviewStructure `json:"link_previews,omitempty" bson:"-"`
+	// Relationship of the signed in caller to the idea, left out for anonymous visitors
+	GazedByMe *bool `json:"gazed_by_me,omitempty" bson:"-"`
+	MadeByMe  *bool `json:"made_by_me,omitempty" bson:"-"`
+}
+
+const ideaStatusOpen = "open"
+
+var nonSlugCharactersRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugOf : Lowercase name with dashes in place of everything but letters and digits, for readable urls
+func slugOf(ideaName string) string {
+	slug := strings.Trim(nonSlugCharactersRegex.ReplaceAllString(strings.ToLower(ideaName), "-"), "-")
+	if len(slug) > 80 {
+		slug = strings.TrimRight(slug[:80], "-")
+	}
+	return slug
 }
 
 // GithubAccessTokenResponse : Structure of response from github after code is posted to them
@@ -37,6 +95,9 @@ type GithubAccessTokenResponse struct {
 	AccessToken string `json:"access_token"`
 	TokenType   string `json:"token_type"`
 	Scope       string `json:"scope"`
+	// GitHub answers bad or expired codes with 200 and these instead of a token
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
 }
 
 // GithubUserProfileStructure : Strucutre of github profile json
@@ -46,16 +107,6 @@ type GithubUserProfileStructure struct {
 	Name   string `json:"name"`
 }
 
-// GithubAuthUser : Strucutre of github user and its access tokens
-type GithubAuthUser struct {
-	UserID      int64  `json:"userID"`
-	Login       string `json:"login"`
-	Name        string `json:"name"`
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	Scope       string `json:"scope"`
-}
-
 // GithubAuthCode : Structure for incoming code of github
 type GithubAuthCode struct {
 	Code string `json:"code"`
@@ -67,29 +118,40 @@ type GithubSecretsEnvs struct {
 	Secret string
 }
 
-// IdeaLikesStructure : Strucutre for like in like collections
-type IdeaLikesStructure struct {
-	UserID int64              `json:"userID" bson:"userID"`
-	IdeaID primitive.ObjectID `json:"ideaID" bson:"ideaID"`
+// UserStructure : Structure of user in database
+type UserStructure struct {
+	UserID    int64  `json:"userID" bson:"userID"`
+	Login     string `json:"login" bson:"login"`
+	Name      string `json:"name" bson:"name"`
+	CreatedAt int64  `json:"created_at" bson:"created_at"`
+	Role      string `json:"role" bson:"role"`
+	// Set by the user, the rest is copied from GitHub
+	DisplayName string `json:"display_name" bson:"display_name"`
+	Bio         string `json:"bio" bson:"bio"`
+	Website     string `json:"website" bson:"website"`
+	Location    string `json:"location" bson:"location"`
+	// Set by admins, see suspensions.go
+	Banned           bool   `json:"banned" bson:"banned"`
+	SuspendedUntil   int64  `json:"suspended_until" bson:"suspended_until"`
+	SuspensionReason string `json:"suspension_reason" bson:"suspension_reason"`
 }
 
-func getEnvValues(envKeyStrings [5]string) map[string]string {
-	envValues := make(map[string]string)
-
-	for _, keyString := range envKeyStrings {
-		if os.Getenv(keyString) == "" {
-			log.Fatal("No env value provided for " + keyString)
-		}
-		envValues[keyString] = os.Getenv(keyString)
-	}
-	return envValues
+// IdeaLikesStructure : Strucutre for like in like collections
+type IdeaLikesStructure struct {
+	UserID    int64              `json:"userID" bson:"userID"`
+	IdeaID    primitive.ObjectID `json:"ideaID" bson:"ideaID"`
+	IPHash    string             `json:"-" bson:"ip_hash"`
+	Reaction  string             `json:"reaction" bson:"reaction"`
+	CreatedAt int64              `json:"created_at" bson:"created_at"`
 }
 
-func connectToDatabase(databaseURL string) *mongo.Client {
+// connectToDatabase : Retries with backoff so a restart during a short database outage doesn't bring the API down
+func connectToDatabase(databaseURL string, mongoConfig MongoConfig, connectTimeout time.Duration, connectRetries int) *mongo.Client {
 	connectOptions := options.Client()
 	connectOptions.ApplyURI(databaseURL)
+	applyMongoConfig(connectOptions, mongoConfig)
 
-	connectContext, errorInContext := context.WithTimeout(context.Background(), 10*time.Second)
+	connectContext, errorInContext := context.WithTimeout(context.Background(), connectTimeout)
 
 	defer errorInContext()
 
@@ -99,10 +161,21 @@ func connectToDatabase(databaseURL string) *mongo.Client {
 		log.Fatal(errInConnection, "Failed to connect to DB")
 	}
 
-	errInPing := databaseClient.Ping(connectContext, nil)
+	for attempt := 0; ; attempt++ {
+		pingContext, cancelPingContext := context.WithTimeout(context.Background(), connectTimeout)
+		errInPing := databaseClient.Ping(pingContext, nil)
+		cancelPingContext()
 
-	if errInPing != nil {
-		log.Fatal(errInPing, "DB not found")
+		if errInPing == nil {
+			break
+		}
+		if attempt >= connectRetries {
+			log.Fatal(errInPing, "DB not found")
+		}
+
+		retryAfter := backoffWithJitter(time.Second, attempt)
+		log.Println(errInPing, "DB not reachable, retrying in", retryAfter)
+		time.Sleep(retryAfter)
 	}
 
 	return databaseClient
@@ -130,12 +203,12 @@ func extractAuthHeader(ginContext *gin.Context) (string, error) {
 	return trimmedAuthFromHeader, nil
 }
 
-func getUserGithubProfile(accessToken string) (GithubUserProfileStructure, error) {
+func getUserGithubProfile(requestContext context.Context, accessToken string) (GithubUserProfileStructure, error) {
 	var emptyGithubProfile GithubUserProfileStructure
 	var githubProfile GithubUserProfileStructure
 	getGithubUserURL := "https://api.github.com/user"
 
-	requestUser, errInRequestingUser := http.NewRequest("GET", getGithubUserURL, nil)
+	requestUser, errInRequestingUser := http.NewRequestWithContext(requestContext, "GET", getGithubUserURL, nil)
 
 	if errInRequestingUser != nil {
 		return githubProfile, errInRequestingUser
@@ -144,14 +217,14 @@ func getUserGithubProfile(accessToken string) (GithubUserProfileStructure, error
 	authHeader := "token " + accessToken
 	requestUser.Header.Set("Accept", "application/vnd.github.v3+json")
 	requestUser.Header.Set("Authorization", authHeader)
-	httpClientForGithubProfile := http.Client{}
-	httpClientForGithubProfile.Timeout = time.Minute * 10
-
-	responseReaderWithUser, errInResponseFromGithub := httpClientForGithubProfile.Do(requestUser)
+	responseReaderWithUser, errInResponseFromGithub := outboundHTTPClient.Do(requestUser)
 	if errInResponseFromGithub != nil {
 		return emptyGithubProfile, errInResponseFromGithub
 	}
 	defer responseReaderWithUser.Body.Close()
+	if responseReaderWithUser.StatusCode >= http.StatusInternalServerError {
+		return emptyGithubProfile, &UpstreamUnavailableError{Host: requestUser.URL.Host, Cause: "GitHub answered " + responseReaderWithUser.Status}
+	}
 
 	responseBytesWithUser, errInResponseBody := ioutil.ReadAll(responseReaderWithUser.Body)
 	if errInResponseBody != nil {
@@ -170,7 +243,10 @@ func getUserGithubProfile(accessToken string) (GithubUserProfileStructure, error
 	return githubProfile, nil
 }
 
-func validateAndGetUser(ginContext *gin.Context) (GithubUserProfileStructure, error) {
+// validateAndGetUser : GitHub profile of the caller, or the user of their session when they signed in without GitHub.
+// Suspended accounts are refused when checkStanding is set
+func validateAndGetUser(ginContext *gin.Context, stores Stores, userSessions *SessionStore,
+	checkStanding bool) (GithubUserProfileStructure, error) {
 	var emptyGithubUser GithubUserProfileStructure
 
 	userAccessToken, errInAccessTokenFormat := extractAuthHeader(ginContext)
@@ -178,137 +254,225 @@ func validateAndGetUser(ginContext *gin.Context) (GithubUserProfileStructure, er
 		return emptyGithubUser, errInAccessTokenFormat
 	}
 
-	githubUser, errInGithubAccess := getUserGithubProfile(userAccessToken)
+	var githubUser GithubUserProfileStructure
+	var errInGithubAccess error
+	if isSessionToken(userAccessToken) == true {
+		githubUser, errInGithubAccess = userSessions.userOfSession(ginContext.Request.Context(), userAccessToken)
+	} else {
+		githubUser, errInGithubAccess = getCachedUserGithubProfile(ginContext.Request.Context(), userAccessToken,
+			ginContext.Request.Method == http.MethodGet)
+	}
 	if errInGithubAccess != nil {
 		return emptyGithubUser, errInGithubAccess
 	}
 
+	if checkStanding == true {
+		errInStanding := checkAccountStanding(ginContext.Request.Context(), githubUser, stores)
+		if errInStanding != nil {
+			return emptyGithubUser, errInStanding
+		}
+	}
+
 	return githubUser, nil
 }
 
-func addUserToDatabase(githubUser GithubUserProfileStructure, databaseClient *mongo.Client) error {
-	usersCollections := databaseClient.Database("sardene-db").Collection("users")
-	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancelDBContext()
+func addUserToDatabase(databaseContext context.Context, githubUser GithubUserProfileStructure, stores Stores) error {
 
-	userFilter := bson.M{"userID": githubUser.UserID}
-	userFoundResult := usersCollections.FindOne(databaseContext, userFilter, options.FindOne())
-
-	var foundUserInDB GithubUserProfileStructure
-
-	doesUserExistsInDB := true
-
-	errInDecoding := userFoundResult.Decode(&foundUserInDB)
-	if errInDecoding != nil {
-		if errInDecoding.Error() == "mongo: no documents in result" {
-			doesUserExistsInDB = false
-		} else {
-			return errInDecoding
-		}
-	}
-
-	if doesUserExistsInDB == true {
+	_, errInFindingUser := stores.Users.FindByUserID(databaseContext, githubUser.UserID)
+	if errInFindingUser == nil {
 		return nil
 	}
+	if errInFindingUser != errNotFoundInStore {
+		return errInFindingUser
+	}
+
 	// Else user not found in db, new user
-	userToAdd := bson.M{
-		"userID": githubUser.UserID,
-		"login":  githubUser.Login,
-		"name":   githubUser.Name,
+	userToAdd := UserStructure{
+		UserID:    githubUser.UserID,
+		Login:     githubUser.Login,
+		Name:      githubUser.Name,
+		CreatedAt: time.Now().Unix(),
 	}
-	_, errInAddingUser := usersCollections.InsertOne(databaseContext, userToAdd, options.InsertOne())
-	if errInAddingUser != nil {
+	errInAddingUser := stores.Users.Insert(databaseContext, userToAdd)
+	// Parallel first logins of the same user are stopped by the unique index, one of them is enough
+	if errInAddingUser != nil && errInAddingUser != errDuplicateInStore {
 		return errInAddingUser
 	}
 
 	return nil
 }
 
-func welcome(ginContext *gin.Context) {
-	message := "Welcome to Sardene API, \nServer running successfully" +
-		"\nVisit https://github.com/M-ZubairAhmed/Sardene-API for documentation."
-	ginContext.String(http.StatusOK, message)
+func welcome(ginContext *gin.Context, brandingConfig BrandingConfig) {
+	ginContext.String(http.StatusOK, brandingConfig.WelcomeMessage)
+}
+
+// parseTimeQuery : Unix seconds, an RFC 3339 time or a 2006-01-02 date
+func parseTimeQuery(ginContext *gin.Context, key string) (int64, error) {
+	value := strings.TrimSpace(ginContext.Query(key))
+	if value == "" {
+		return 0, nil
+	}
+
+	if unixTime, errInParsing := strconv.ParseInt(value, 10, 64); errInParsing == nil {
+		return unixTime, nil
+	}
+	for _, timeLayout := range []string{time.RFC3339, "2006-01-02"} {
+		if parsedTime, errInParsing := time.Parse(timeLayout, value); errInParsing == nil {
+			return parsedTime.Unix(), nil
+		}
+	}
+	return 0, errors.New(key + " should be unix seconds, an RFC 3339 time or a date like 2006-01-02")
+}
+
+func parseIdeaListFilter(ginContext *gin.Context) (IdeaListFilter, error) {
+	var filter IdeaListFilter
+	var errInParsing error
+
+	filter.Publisher = strings.TrimSpace(ginContext.Query("publisher"))
+	filter.CreatedAfter, errInParsing = parseTimeQuery(ginContext, "created_after")
+	if errInParsing != nil {
+		return filter, errInParsing
+	}
+	filter.CreatedBefore, errInParsing = parseTimeQuery(ginContext, "created_before")
+	if errInParsing != nil {
+		return filter, errInParsing
+	}
+
+	for _, included := range strings.Split(ginContext.Query("include"), ",") {
+		switch strings.TrimSpace(included) {
+		case "":
+		case "drafts":
+			filter.DraftsOf = getAuthenticatedUser(ginContext).UserID
+			if filter.DraftsOf == 0 {
+				return filter, errors.New("Sign in to include your drafts")
+			}
+		case "archived":
+			filter.IncludeArchived = true
+		default:
+			return filter, errors.New("Only drafts and archived can be included, got " + included)
+		}
+	}
+	return filter, nil
 }
 
-func getIdeas(ginContext *gin.Context, databaseClient *mongo.Client) {
-	var ideas []*IdeaStructure
+// getIdeas : Listed from listingStores, which may lag behind. Flags of the caller are read from stores,
+// so an idea they just gazed shows as gazed
+func getIdeas(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, listingStores Stores) {
+	databaseContext := ginContext.Request.Context()
 
-	ideasCollection := databaseClient.Database("sardene-db").Collection("ideas")
-	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancelDBContext()
+	fields, errInFields := parseIdeaFields(ginContext.Query("fields"))
+	if errInFields != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong fields asked for", "errorDetails": errInFields.Error()})
+		return
+	}
 
-	findOptions := options.Find()
-	ideasCursor, errorInFinding := ideasCollection.Find(databaseContext, bson.D{{}}, findOptions)
+	filter, errInFilter := parseIdeaListFilter(ginContext)
+	if errInFilter != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong filter of ideas", "errorDetails": errInFilter.Error()})
+		return
+	}
 
+	ideas, errorInFinding := listingStores.Ideas.ListPublished(databaseContext, filter, fields)
 	if errorInFinding != nil {
-		_ = ideasCursor.Close(databaseContext)
-		databaseContext.Done()
 		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
-			"error": "Error in searching database"})
+			"error": "Error in searching database", "errorDetails": errorInFinding.Error()})
 		return
 	}
 
-	for ideasCursor.Next(databaseContext) {
-		var idea IdeaStructure
+	lengthOfIdeas := len(ideas)
 
-		errInDecoding := ideasCursor.Decode(&idea)
-		if errInDecoding != nil {
-			_ = ideasCursor.Close(databaseContext)
-			databaseContext.Done()
-			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
-				"error": "Error in decoding database", "errorDetails": errInDecoding.Error()})
+	// Flags need the ids of the ideas, which asked fields may leave out
+	callerUserID := getAuthenticatedUser(ginContext).UserID
+	if callerUserID != 0 && (len(fields) == 0 || isFieldAskedFor(fields, "gazed_by_me") || isFieldAskedFor(fields, "made_by_me")) {
+		errInAnnotating := annotateCallerFlags(databaseContext, databaseClient, stores, callerUserID, ideas)
+		if errInAnnotating != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInAnnotating.Error()})
 			return
 		}
-
-		ideas = append(ideas, &idea)
 	}
 
-	errInCursor := ideasCursor.Err()
-	if errInCursor != nil {
-		databaseContext.Done()
-		_ = ideasCursor.Close(databaseContext)
-		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
-			"error": "Error while iterating database"})
+	if isHTMLRenderRequested(ginContext) {
+		errInRendering := renderIdeaDescriptions(ideas)
+		if errInRendering != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in rendering descriptions", "errorDetails": errInRendering.Error()})
+			return
+		}
 	}
 
-	_ = ideasCursor.Close(databaseContext)
+	// Asked fields may leave out the description the previews are found in
+	if linkPreviewer != nil && len(fields) == 0 {
+		errInPreviews := linkPreviewer.attachLinkPreviews(databaseContext, ideas)
+		if errInPreviews != nil {
+			log.Println(errInPreviews, "Failed to read link previews")
+		}
+	}
 
-	lengthOfIdeas := len(ideas)
+	if len(fields) > 0 {
+		selectedIdeas, errInSelecting := selectIdeaFields(ideas, fields)
+		if errInSelecting != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in selecting fields", "errorDetails": errInSelecting.Error()})
+			return
+		}
+		ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": selectedIdeas, "count": lengthOfIdeas})
+		return
+	}
 
 	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": ideas, "count": lengthOfIdeas})
-	databaseContext.Done()
 	return
 }
 
-func authenticateUser(ginContext *gin.Context, databaseClient *mongo.Client, githubSecrets GithubSecretsEnvs) {
+func authenticateUser(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, userSessions *SessionStore,
+	githubSecrets GithubSecretsEnvs, authThrottleConfig AuthThrottleConfig) {
 	var githubCodeInput GithubAuthCode
 
 	errInInput := ginContext.ShouldBindJSON(&githubCodeInput)
-	if errInInput != nil {
+	if errInInput != nil || len(strings.TrimSpace(githubCodeInput.Code)) == 0 {
 		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
 			"error": "Wrong structure of posted data"})
 		return
 	}
 
-	githubAuthCode := githubCodeInput.Code
-	githubAccessTokenURL := fmt.Sprint("https://github.com/login/oauth/access_token", "?client_id=", githubSecrets.Client, "&client_secret=", githubSecrets.Secret, "&code=", githubAuthCode)
+	githubAuthCode := strings.TrimSpace(githubCodeInput.Code)
+	if throttleAuth(ginContext, databaseClient, authThrottleConfig, githubAuthCode) == false {
+		return
+	}
+	// Every refused exchange counts towards the lockout of the address and the code
+	defer func() {
+		if ginContext.Writer.Status() == http.StatusForbidden {
+			recordAuthFailure(ginContext, databaseClient, authThrottleConfig, githubAuthCode)
+		}
+	}()
 
-	var jsonEmptyInput = []byte(`{}`)
-	postReqToGithub, errInPostToGithub := http.NewRequest("POST", githubAccessTokenURL, bytes.NewBuffer(jsonEmptyInput))
+	// Secrets go in the body, URLs end up in error messages and logs
+	githubAccessTokenForm := url.Values{"client_id": {githubSecrets.Client}, "client_secret": {githubSecrets.Secret},
+		"code": {githubAuthCode}}
+	postReqToGithub, errInPostToGithub := http.NewRequestWithContext(ginContext.Request.Context(), "POST",
+		"https://github.com/login/oauth/access_token", strings.NewReader(githubAccessTokenForm.Encode()))
 	if errInPostToGithub != nil {
 		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
-			"error": "Cannot be authenciated", "errorDetails": errInInput.Error()})
+			"error": "Cannot be authenciated", "errorDetails": errInPostToGithub.Error()})
 		return
 	}
 
 	postReqToGithub.Header.Set("Accept", "application/json")
-	httpClientForGithub := http.Client{}
-	httpClientForGithub.Timeout = time.Minute * 10
-
-	postResFromGithub, errInRespFromGithub := httpClientForGithub.Do(postReqToGithub)
+	postReqToGithub.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	postResFromGithub, errInRespFromGithub := outboundHTTPClient.DoRetrying(postReqToGithub)
+	if errInRespFromGithub == nil && postResFromGithub.StatusCode >= http.StatusInternalServerError {
+		postResFromGithub.Body.Close()
+		errInRespFromGithub = &UpstreamUnavailableError{Host: postReqToGithub.URL.Host, Cause: "GitHub answered " + postResFromGithub.Status}
+	}
+	if isUpstreamUnavailable(errInRespFromGithub) == true {
+		abortUpstreamUnavailable(ginContext, errInRespFromGithub)
+		return
+	}
 	if errInRespFromGithub != nil {
 		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
-			"error": "Cannot be authenciated", "errorDetails": errInInput.Error()})
+			"error": "Cannot be authenciated", "errorDetails": errInRespFromGithub.Error()})
 		return
 	}
 	defer postResFromGithub.Body.Close()
@@ -316,7 +480,7 @@ func authenticateUser(ginContext *gin.Context, databaseClient *mongo.Client, git
 	githubRespInBytes, errInReader := ioutil.ReadAll(postResFromGithub.Body)
 	if errInReader != nil {
 		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
-			"error": "Cannot be authenciated", "errorDetails": errInInput.Error()})
+			"error": "Cannot be authenciated", "errorDetails": errInReader.Error()})
 		return
 	}
 
@@ -324,51 +488,63 @@ func authenticateUser(ginContext *gin.Context, databaseClient *mongo.Client, git
 	errInReadingToken := json.Unmarshal(githubRespInBytes, &jsonRespFromGithub)
 	if errInReadingToken != nil {
 		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
-			"error": "Cannot be authenciated", "errorDetails": errInInput.Error()})
+			"error": "Cannot be authenciated", "errorDetails": errInReadingToken.Error()})
+		return
+	}
+	if jsonRespFromGithub.AccessToken == "" {
+		errorFromGithub := jsonRespFromGithub.ErrorDescription
+		if errorFromGithub == "" {
+			errorFromGithub = jsonRespFromGithub.Error
+		}
+		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+			"error": "Code is not valid or has expired", "errorDetails": errorFromGithub})
 		return
 	}
 
-	userGithubProfile, errInGettingProfile := getUserGithubProfile(jsonRespFromGithub.AccessToken)
+	userGithubProfile, errInGettingProfile := getUserGithubProfile(ginContext.Request.Context(), jsonRespFromGithub.AccessToken)
+	if isUpstreamUnavailable(errInGettingProfile) == true {
+		abortUpstreamUnavailable(ginContext, errInGettingProfile)
+		return
+	}
 	if errInGettingProfile != nil {
 		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
 			"error": "Cannot get user", "errorDetails": errInGettingProfile.Error()})
 		return
 	}
 
-	var githubAuthUser GithubAuthUser
-	githubAuthUser.UserID = userGithubProfile.UserID
-	githubAuthUser.Login = userGithubProfile.Login
-	githubAuthUser.Name = userGithubProfile.Name
-	githubAuthUser.AccessToken = jsonRespFromGithub.AccessToken
-	githubAuthUser.TokenType = jsonRespFromGithub.TokenType
-	githubAuthUser.Scope = jsonRespFromGithub.Scope
-
-	errInAddingUserInDB := addUserToDatabase(userGithubProfile, databaseClient)
+	errInAddingUserInDB := addUserToDatabase(ginContext.Request.Context(), userGithubProfile, stores)
 	if errInAddingUserInDB != nil {
-		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
 			"error": "Cannot add user in database", "errorDetails": errInAddingUserInDB.Error()})
 		return
 	}
 
-	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK,
-		"data": githubAuthUser})
-
-	return
-}
-
-func addIdea(ginContext *gin.Context, databaseClient *mongo.Client) {
+	errInRecordingIdentity := recordGithubIdentity(ginContext.Request.Context(), databaseClient, userGithubProfile)
+	if errInRecordingIdentity != nil {
+		log.Println(errInRecordingIdentity, "Failed to record GitHub identity of user", userGithubProfile.UserID)
+	}
 
-	user, errInValidatingUser := validateAndGetUser(ginContext)
-	if errInValidatingUser != nil {
-		ginContext.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized,
-			"error": "Autherization failed", "errorDetails": errInValidatingUser.Error()})
+	// GitHub token stays with the session, the browser only gets the session token
+	sessionUser := UserStructure{UserID: userGithubProfile.UserID, Login: userGithubProfile.Login, Name: userGithubProfile.Name}
+	sessionToken, sessionExpiresAt, errInCreatingSession := userSessions.create(ginContext.Request.Context(), sessionUser,
+		identityProviderGithub, jsonRespFromGithub.AccessToken)
+	if errInCreatingSession != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Cannot create session", "errorDetails": errInCreatingSession.Error()})
 		return
 	}
 
-	ideasCollection := databaseClient.Database("sardene-db").Collection("ideas")
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK,
+		"data": SessionAuthUser{UserID: userGithubProfile.UserID, Login: userGithubProfile.Login, Name: userGithubProfile.Name,
+			AccessToken: sessionToken, TokenType: "bearer", ExpiresAt: sessionExpiresAt.Unix()}})
+}
 
-	databaseContext, cancelContext := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancelContext()
+func addIdea(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, quarantineConfig QuarantineConfig,
+	contentFilterConfig ContentFilterConfig, duplicateDetectionConfig DuplicateDetectionConfig, quotaConfig QuotaConfig) {
+
+	user := getAuthenticatedUser(ginContext)
+
+	databaseContext := ginContext.Request.Context()
 
 	var jsonInput IdeaStructure
 	createdTime := time.Now().Unix()
@@ -377,380 +553,1108 @@ func addIdea(ginContext *gin.Context, databaseClient *mongo.Client) {
 	if errInInputJSON != nil {
 		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
 			"error": "Wrong structure of posted data"})
-		databaseContext.Done()
 		return
 	}
 
-	lengthOfName := len(strings.TrimSpace(jsonInput.Name))
-	lengthOfDescription := len(strings.TrimSpace(jsonInput.Description))
+	// Invisible characters do not count, so a name of only zero width spaces is empty
+	jsonInput.Name = normalizeIdeaName(jsonInput.Name)
+	jsonInput.Description = normalizeIdeaDescription(jsonInput.Description)
+	lengthOfName := lengthInRunes(jsonInput.Name)
+	lengthOfDescription := lengthInRunes(jsonInput.Description)
 
 	if lengthOfName == 0 || lengthOfDescription == 0 {
 		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
 			"error": "Name or description is not provided in the post"})
-		databaseContext.Done()
 		return
 
 	}
+	if lengthOfName > maxIdeaNameLength || lengthOfDescription > maxIdeaDescriptionLength {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Name should be at most " + strconv.Itoa(maxIdeaNameLength) + " and description at most " +
+				strconv.Itoa(maxIdeaDescriptionLength) + " characters long"})
+		return
+	}
 
 	// Cleaning data
-	jsonInput.Name = strings.TrimSpace(jsonInput.Name)
-	jsonInput.Description = strings.TrimSpace(jsonInput.Description)
+	jsonInput.Description = sanitizeMarkdown(jsonInput.Description)
+	if len(jsonInput.Description) == 0 {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Description has nothing left after removing HTML"})
+		return
+	}
+	jsonInput.DescriptionHTML = ""
+	jsonInput.Visibility = strings.ToLower(strings.TrimSpace(jsonInput.Visibility))
+	if jsonInput.Visibility == "" {
+		jsonInput.Visibility = ideaVisibilityPublic
+	}
+	if isValidIdeaVisibility(jsonInput.Visibility) == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Visibility should be public, draft or private"})
+		return
+	}
+	if strings.TrimSpace(jsonInput.RepoURL) != "" {
+		jsonInput.RepoURL = normalizeRepoURL(jsonInput.RepoURL)
+		if jsonInput.RepoURL == "" {
+			ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+				"error": "Repo url should look like https://github.com/owner/name"})
+			return
+		}
+	}
 	// Defaulting data
 	jsonInput.Makers = 0
 	jsonInput.Gazers = 0
+	jsonInput.Reactions = map[string]int64{}
 	jsonInput.CreatedAt = createdTime
-	// User data
-	jsonInput.Publisher = user.Login
-	jsonInput.PublisherID = user.UserID
-
-	ideaToAdd := bson.M{
-		"name":         jsonInput.Name,
-		"description":  jsonInput.Description,
-		"publisher":    jsonInput.Publisher,
-		"publisher_id": jsonInput.PublisherID,
-		"makers":       jsonInput.Makers,
-		"gazers":       jsonInput.Gazers,
-		"created_at":   createdTime,
+	jsonInput.UpdatedAt = createdTime
+	jsonInput.Status = ideaStatusOpen
+	jsonInput.HeldForReview = false
+	jsonInput.Featured = false
+	jsonInput.FeaturedAt = 0
+	jsonInput.Archived = false
+	jsonInput.ArchivedAt = 0
+	jsonInput.Links = []IdeaLinkStructure{}
+	jsonInput.Collaborators = []IdeaCollaboratorStructure{}
+	jsonInput.Repo = nil
+	jsonInput.Author = ""
+
+	// Any member can publish under the org
+	jsonInput.Org = strings.ToLower(strings.TrimSpace(jsonInput.Org))
+	if jsonInput.Org != "" && databaseClient == nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Orgs are not available on this server"})
+		return
+	}
+	if jsonInput.Org != "" {
+		orgRole, errInFindingOrg := orgRoleOfUser(databaseContext, databaseClient, jsonInput.Org, user.UserID)
+		if errInFindingOrg != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInFindingOrg.Error()})
+			return
+		}
+		if orgRole == "" {
+			ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+				"error": "Only members of the org can publish ideas under it"})
+			return
+		}
 	}
 
-	addedIdea, errInAdding := ideasCollection.InsertOne(databaseContext, ideaToAdd)
-	if errInAdding != nil {
+	// Reasons for which the idea is held for moderation
+	var moderationReasons []string
+
+	// Stricter limits for new accounts
+	isQuarantined, errInCheckingQuarantine := isUserQuarantined(databaseContext, user, stores, quarantineConfig)
+	if errInCheckingQuarantine != nil {
 		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
-			"error": "Error while saving to database"})
+			"error": "Error in checking user account", "errorDetails": errInCheckingQuarantine.Error()})
 		return
 	}
 
-	// Get the generated ID from DB
-	jsonInput.ID = addedIdea.InsertedID.(primitive.ObjectID)
+	if isQuarantined == true {
+		ideasPublishedToday, errInCounting := countIdeasPublishedToday(databaseContext, user, stores)
+		if errInCounting != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in checking user account", "errorDetails": errInCounting.Error()})
+			return
+		}
+		if ideasPublishedToday >= quarantineConfig.DailyIdeaQuota {
+			ginContext.JSON(http.StatusTooManyRequests, gin.H{"status": http.StatusTooManyRequests,
+				"error": "New accounts can only publish a limited number of ideas per day"})
+			return
+		}
 
-	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": jsonInput})
-	databaseContext.Done()
-	return
-}
+		if containsLinks(jsonInput.Name) || containsLinks(jsonInput.Description) {
+			if quarantineConfig.LinkPolicy == quarantineLinkPolicyStrip {
+				jsonInput.Name = stripLinks(jsonInput.Name)
+				jsonInput.Description = stripLinks(jsonInput.Description)
+				if len(jsonInput.Name) == 0 || len(jsonInput.Description) == 0 {
+					ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+						"error": "Name or description cannot be only links for new accounts"})
+					return
+				}
+			} else {
+				moderationReasons = append(moderationReasons, "links_from_new_account")
+			}
+		}
+	}
 
-func likeAnIdea(ginContext *gin.Context, databaseClient *mongo.Client, ideaID string) {
+	// Spam and profanity filtering
+	moderationReasons = append(moderationReasons, checkSubmissionContent(contentFilterConfig, jsonInput.Name, jsonInput.Description)...)
 
-	// Check if Idea id is valid
-	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
-	if errInValidatingID != nil {
-		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
-			"error": "Error, Idea id is not valid"})
+	isRepeated, errInCheckingRepeated := isRepeatedSubmission(databaseContext, user, jsonInput.Name, stores, contentFilterConfig)
+	if errInCheckingRepeated != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error in checking user account", "errorDetails": errInCheckingRepeated.Error()})
 		return
 	}
+	if isRepeated == true {
+		moderationReasons = append(moderationReasons, "repeated_submission")
+	}
 
-	// Getting user details from the header
-	user, errInValidatingUser := validateAndGetUser(ginContext)
-	if errInValidatingUser != nil {
-		ginContext.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized,
-			"error": "Autherization failed", "errorDetails": errInValidatingUser.Error()})
+	if len(moderationReasons) != 0 {
+		// Moderation queue is in mongo, without it a held idea could never be released
+		if contentFilterConfig.Action == contentFilterActionReject || databaseClient == nil {
+			ginContext.JSON(http.StatusUnprocessableEntity, gin.H{"status": http.StatusUnprocessableEntity,
+				"error": "Idea looks like spam and was not published", "errorDetails": moderationReasons})
+			return
+		}
+		jsonInput.HeldForReview = true
+	}
+
+	// Same idea should not be published again and again
+	likelyDuplicates, errInFindingDuplicates := findLikelyDuplicates(databaseContext, stores, jsonInput.Name, jsonInput.Description, duplicateDetectionConfig)
+	if errInFindingDuplicates != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingDuplicates.Error()})
 		return
 	}
 
-	databaseContext, cancelContext := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancelContext()
+	isForced := ginContext.Query("force") == "true"
+	if len(likelyDuplicates) != 0 && duplicateDetectionConfig.RequireForce == true && isForced == false {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error":      "Similar ideas already exist, post again with force=true to publish anyway",
+			"duplicates": likelyDuplicates})
+		return
+	}
 
-	// Checking if idea exists
-	var ideaFound IdeaStructure
-	ideasCollection := databaseClient.Database("sardene-db").Collection("ideas")
-	findIdeaFilter := bson.M{"_id": hexIdeaID}
+	// User data
+	jsonInput.Publisher = user.Login
+	jsonInput.PublisherID = user.UserID
+	if jsonInput.Org != "" {
+		jsonInput.Publisher = jsonInput.Org
+		jsonInput.Author = user.Login
+	}
 
-	ideaFoundInDB := ideasCollection.FindOne(databaseContext, findIdeaFilter, options.FindOne())
+	jsonInput.Slug = slugOf(jsonInput.Name)
 
-	errInDecodingIdea := ideaFoundInDB.Decode(&ideaFound)
-	if errInDecodingIdea != nil {
-		databaseContext.Done()
-		if errInDecodingIdea.Error() == "mongo: no documents in result" {
-			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
-				"error": "Error, Idea does not exists", "errorDetails": errInDecodingIdea.Error()})
-			return
-		}
-		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
-			"error": "Error, Couldnt decode idea from idea id", "errorDetails": errInDecodingIdea.Error()})
+	if consumeQuota(ginContext, databaseClient, user.UserID, quotaActionIdeas, quotaConfig.DailyIdeas) == false {
 		return
 	}
 
-	// Checking if user already liked
-	likesCollection := databaseClient.Database("sardene-db").Collection("likes")
+	addedIdeaID, errInAdding := stores.Ideas.Insert(databaseContext, jsonInput)
+	if errInAdding != nil {
+		refundQuota(databaseContext, databaseClient, user.UserID, quotaActionIdeas)
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
 
-	userlikedFilter := bson.M{"userID": user.UserID, "ideaID": hexIdeaID}
-	userFoundResult := likesCollection.FindOne(databaseContext, userlikedFilter, options.FindOne())
+	// Get the generated ID from DB
+	jsonInput.ID = addedIdeaID
+	describeAuditedMutation(ginContext, auditActionIdeaCreated, addedIdeaID.Hex(), nil, jsonInput)
 
-	didUserLikedIdeaBefore := true
+	// Held ideas wait for review, links in possible spam are not fetched
+	if linkPreviewer != nil && jsonInput.HeldForReview == false {
+		linkPreviewer.RefreshInBackground(jsonInput.Description)
+	}
 
-	var userLikedIdea IdeaLikesStructure
-	errInDecoding := userFoundResult.Decode(&userLikedIdea)
-	if errInDecoding != nil {
-		if errInDecoding.Error() == "mongo: no documents in result" {
-			didUserLikedIdeaBefore = false
+	if jsonInput.HeldForReview == true {
+		errInReporting := fileModerationReport(databaseContext, databaseClient, jsonInput.ID, moderationReasons, moderationReporterSystem, nil)
+		if errInReporting != nil {
+			log.Println(errInReporting, "Failed to add idea to moderation queue")
 		}
-	}
 
-	if didUserLikedIdeaBefore == true {
-		databaseContext.Done()
-		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
-			"error": "Error, User already liked the idea"})
+		ginContext.JSON(http.StatusAccepted, gin.H{"status": http.StatusAccepted, "data": jsonInput,
+			"duplicates": likelyDuplicates, "message": "Idea is held for moderation before it is published"})
 		return
 	}
 
-	// Find idea and Increasing count in idea DB
-	updateGazeOfIdea := bson.M{"$inc": bson.M{"gazers": 1}}
+	errInRecordingActivity := recordActivity(databaseContext, databaseClient, activityKindIdeaPublished, jsonInput, user.Login)
+	if errInRecordingActivity != nil {
+		log.Println(errInRecordingActivity, "Failed to add publishing of idea to activity", addedIdeaID.Hex())
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": jsonInput, "duplicates": likelyDuplicates})
+	return
+}
+
+// likeAnIdea : Gazes through /idea/gaze are eyes reactions, other reactions come through reactToIdea
+func likeAnIdea(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaToGaze IdeaStructure, reaction string,
+	quotaConfig QuotaConfig) {
+	hexIdeaID := ideaToGaze.ID
+
+	// Getting user details from the header
+	user := getAuthenticatedUser(ginContext)
+
+	databaseContext := ginContext.Request.Context()
 
-	_, errInFindingIdea := ideasCollection.UpdateOne(databaseContext, findIdeaFilter, updateGazeOfIdea)
-	if errInFindingIdea != nil {
-		databaseContext.Done()
-		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Idea not found"})
+	// Drafts and private ideas cannot be gazed
+	if isIdeaPublic(ideaToGaze) == false {
+		abortIdeaNotFound(ginContext)
+		return
+	}
+	if ideaToGaze.Archived == true {
+		abortIdeaArchived(ginContext)
 		return
 	}
 
-	// Adding user to likes DB
-	ideaLikedByUserToAdd := bson.M{
-		"userID": user.UserID,
-		"ideaID": hexIdeaID,
+	// Adding user to likes DB, the unique index on userID and ideaID rejects a second like
+	// even when two requests race, so the counter is only increased for the one that wins
+	ideaLikedByUserToAdd := IdeaLikesStructure{
+		UserID:    user.UserID,
+		IdeaID:    hexIdeaID,
+		IPHash:    hashClientIP(ginContext.ClientIP()),
+		Reaction:  reaction,
+		CreatedAt: time.Now().Unix(),
 	}
 
-	_, errInAdding := likesCollection.InsertOne(databaseContext, ideaLikedByUserToAdd)
+	if consumeQuota(ginContext, databaseClient, user.UserID, quotaActionGazes, quotaConfig.DailyGazes) == false {
+		return
+	}
+
+	errInAdding := stores.Likes.Insert(databaseContext, ideaLikedByUserToAdd)
 	if errInAdding != nil {
-		databaseContext.Done()
+		refundQuota(databaseContext, databaseClient, user.UserID, quotaActionGazes)
+		if errInAdding == errDuplicateInStore {
+			ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+				"error": "Error, User already liked the idea"})
+			return
+		}
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	// Increasing count in idea DB
+	gazers, errInIncreasingGazers := stores.Ideas.IncrementGazers(databaseContext, hexIdeaID, 1)
+	if errInIncreasingGazers == nil {
+		errInIncreasingGazers = stores.Ideas.IncrementReaction(databaseContext, hexIdeaID, reaction, 1)
+	}
+	if errInIncreasingGazers != nil {
+		// Taking back the like so that it can be retried and the counter stays in step
+		_ = stores.Likes.Delete(databaseContext, user.UserID, hexIdeaID)
+		refundQuota(databaseContext, databaseClient, user.UserID, quotaActionGazes)
 		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
 			"error": "Error while saving to database"})
 		return
 	}
 
-	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": "",
+	describeAuditedMutation(ginContext, auditActionIdeaGazed, hexIdeaID.Hex(), nil, ideaLikedByUserToAdd)
+
+	errInRecordingActivity := recordGazeMilestone(databaseContext, databaseClient, ideaToGaze, gazers)
+	if errInRecordingActivity != nil {
+		log.Println(errInRecordingActivity, "Failed to add gaze milestone of idea to activity", hexIdeaID.Hex())
+	}
+
+	// Ideas of deleted users have nobody left to tell
+	if ideaToGaze.PublisherID != 0 {
+		errInNotifying := addNotifications(databaseContext, databaseClient, []int64{ideaToGaze.PublisherID},
+			notificationKindIdeaGazed, ideaToGaze, user)
+		if errInNotifying != nil {
+			log.Println(errInNotifying, "Failed to notify publisher of gaze on idea", hexIdeaID.Hex())
+		}
+	}
+
+	// Count as saved, gazes of others since the idea was read are in it too
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK,
+		"data":    gin.H{"gazers": gazers, "gazed": true, "reaction": reaction},
 		"message": "Increased gaze count of idea"})
-	databaseContext.Done()
 	return
 }
 
-func getUserLikedIdeas(ginContext *gin.Context, databaseClient *mongo.Client) {
-	// Getting user details from the header
-	user, errInValidatingUser := validateAndGetUser(ginContext)
-	if errInValidatingUser != nil {
-		ginContext.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized,
-			"error": "Autherization failed", "errorDetails": errInValidatingUser.Error()})
-		return
-	}
+// GazedCheckInput : Structure for incoming ideas whose gaze status is asked for
+type GazedCheckInput struct {
+	IdeaIDs []string `json:"idea_ids"`
+}
 
-	ideasCollection := databaseClient.Database("sardene-db").Collection("likes")
-	databaseContext, cancelContext := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancelContext()
+const maxIdeasInGazedCheck = 100
 
-	findingAllUserLikedIdeas := bson.M{"userID": user.UserID}
-	foundIdeasUserLikedCursor, errInFindingUsersLikedIdeas := ideasCollection.Find(databaseContext, findingAllUserLikedIdeas, options.Find())
+// checkGazedIdeas : Gaze status of a page of ideas in one request, every asked id is in the answer
+func checkGazedIdeas(ginContext *gin.Context, stores Stores) {
+	user := getAuthenticatedUser(ginContext)
 
-	// Cursor errors
-	if errInFindingUsersLikedIdeas != nil {
-		_ = foundIdeasUserLikedCursor.Close(databaseContext)
-		databaseContext.Done()
-		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
-			"error": "Error in searching database", "errorDetails": errInFindingUsersLikedIdeas.Error()})
+	var jsonInput GazedCheckInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil || len(jsonInput.IdeaIDs) == 0 || len(jsonInput.IdeaIDs) > maxIdeasInGazedCheck {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Idea ids should be a list of 1 to " + strconv.Itoa(maxIdeasInGazedCheck) + " ids"})
 		return
 	}
-	errInFoundIdeasCursor := foundIdeasUserLikedCursor.Err()
-	if errInFoundIdeasCursor != nil {
-		_ = foundIdeasUserLikedCursor.Close(databaseContext)
-		databaseContext.Done()
+
+	isGazed := make(map[string]bool)
+	var hexIdeaIDs []primitive.ObjectID
+	for _, ideaID := range jsonInput.IdeaIDs {
+		hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+		if errInValidatingID != nil {
+			ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+				"error": "Error, Idea id " + strconv.Quote(ideaID) + " is not valid"})
+			return
+		}
+		isGazed[hexIdeaID.Hex()] = false
+		hexIdeaIDs = append(hexIdeaIDs, hexIdeaID)
+	}
+
+	gazedIdeaIDs, errInFinding := stores.Likes.ListGazedAmong(ginContext.Request.Context(), user.UserID, hexIdeaIDs)
+	if errInFinding != nil {
 		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
-			"error": "Error in searching database", "errorDetails": errInFoundIdeasCursor.Error()})
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
 		return
 	}
+	for _, gazedIdeaID := range gazedIdeaIDs {
+		isGazed[gazedIdeaID.Hex()] = true
+	}
 
-	// Will contains all the user liked ideas
-	var userLikedIdeas []*IdeaLikesStructure
-
-	// Looping throught all user ideas
-	for foundIdeasUserLikedCursor.Next(databaseContext) {
-		var userLikedIdea IdeaLikesStructure
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": isGazed})
+}
 
-		errInDecodedUserLikedIdea := foundIdeasUserLikedCursor.Decode(&userLikedIdea)
+func getUserLikedIdeas(ginContext *gin.Context, stores Stores) {
+	// Getting user details from the header
+	user := getAuthenticatedUser(ginContext)
 
-		if errInDecodedUserLikedIdea != nil {
-			_ = foundIdeasUserLikedCursor.Close(databaseContext)
-			databaseContext.Done()
-			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
-				"error": "Error in searching database", "errorDetails": errInDecodedUserLikedIdea.Error()})
-			return
-		}
+	databaseContext := ginContext.Request.Context()
 
-		// Appending to user liked ideas array if no error found above
-		userLikedIdeas = append(userLikedIdeas, &userLikedIdea)
+	// Will contains all the user liked ideas
+	userLikedIdeas, errInFindingUsersLikedIdeas := stores.Likes.ListByUser(databaseContext, user.UserID)
+	if errInFindingUsersLikedIdeas != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUsersLikedIdeas.Error()})
+		return
 	}
 
-	// Close the cursor after looping
-	_ = foundIdeasUserLikedCursor.Close(databaseContext)
-
 	totalNumberOfIdeas := len(userLikedIdeas)
 
 	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": userLikedIdeas, "count": totalNumberOfIdeas})
-	databaseContext.Done()
 }
 
-func updateIdea(ginContext *gin.Context, databaseClient *mongo.Client, ideaID string) {
-	ideasCollection := databaseClient.Database("sardene-db").Collection("ideas")
+// IdeaUpdateInput : Changed content of an idea along with the version it was read at
+type IdeaUpdateInput struct {
+	IdeaStructure
+	// Left out by clients from before versions, their edits overwrite whatever is stored
+	Version *int64 `json:"version"`
+}
 
-	databaseContext, cancelContext := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancelContext()
+func updateIdea(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaBeforeEdit IdeaStructure) {
+	var jsonInput IdeaUpdateInput
 
-	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
-	if errInValidatingID != nil {
-		databaseContext.Done()
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil {
 		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
-			"error": "Error, Idea id is not valid"})
+			"error": "Wrong structure of posted data", "errorDetails": errInInputJSON})
 		return
 	}
 
-	var jsonInput IdeaStructure
+	jsonInput.Name = normalizeIdeaName(jsonInput.Name)
+	jsonInput.Description = normalizeIdeaDescription(jsonInput.Description)
+	lengthOfName := lengthInRunes(jsonInput.Name)
+	lengthOfDescription := lengthInRunes(jsonInput.Description)
+	lengthOfRepoURL := len(strings.TrimSpace(jsonInput.RepoURL))
+	visibility := strings.ToLower(strings.TrimSpace(jsonInput.Visibility))
 
-	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
-	if errInInputJSON != nil {
+	if lengthOfName == 0 && lengthOfDescription == 0 && lengthOfRepoURL == 0 && visibility == "" {
 		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
-			"error": "Wrong structure of posted data", "errorDetails": errInInputJSON})
-		databaseContext.Done()
+			"error": "Name, description, repo url and visibility are all empty"})
+		return
+	}
+	if lengthOfName > maxIdeaNameLength || lengthOfDescription > maxIdeaDescriptionLength {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Name should be at most " + strconv.Itoa(maxIdeaNameLength) + " and description at most " +
+				strconv.Itoa(maxIdeaDescriptionLength) + " characters long"})
+		return
+	}
+	if visibility != "" && isValidIdeaVisibility(visibility) == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Visibility should be public, draft or private"})
 		return
 	}
 
-	lengthOfName := len(strings.TrimSpace(jsonInput.Name))
-	lengthOfDescription := len(strings.TrimSpace(jsonInput.Description))
-
-	if lengthOfName == 0 && lengthOfDescription == 0 {
+	normalizedRepoURL := normalizeRepoURL(jsonInput.RepoURL)
+	if lengthOfRepoURL != 0 && normalizedRepoURL == "" {
 		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
-			"error": "Both name and description are empty"})
-		databaseContext.Done()
+			"error": "Repo url should look like https://github.com/owner/name"})
 		return
 	}
 
-	filterOfUpdatingIdea := bson.M{"_id": hexIdeaID}
-	var updateIdea bson.M
+	// Empty fields of a PUT are left as they are, PATCH is for clearing them
+	var contentUpdate IdeaContentUpdate
+	if lengthOfName != 0 {
+		contentUpdate.Name = &jsonInput.Name
+	}
+	if lengthOfDescription != 0 {
+		// Description left empty by sanitizing stays as it was
+		sanitizedDescription := sanitizeMarkdown(jsonInput.Description)
+		if sanitizedDescription != "" {
+			contentUpdate.Description = &sanitizedDescription
+		}
+	}
+	if normalizedRepoURL != "" {
+		contentUpdate.RepoURL = &normalizedRepoURL
+	}
+	if visibility != "" {
+		contentUpdate.Visibility = &visibility
+	}
+	contentUpdate.ExpectedVersion = jsonInput.Version
 
-	if lengthOfName == 0 && lengthOfDescription != 0 {
-		// Updating only description
-		updateIdea = bson.M{"$set": bson.M{
-			"description": jsonInput.Description,
-		}}
-	} else if lengthOfName != 0 && lengthOfDescription == 0 {
-		// Updating only name
-		updateIdea = bson.M{"$set": bson.M{
-			"name": jsonInput.Name,
-		}}
-	} else {
-		// updating both
-		updateIdea = bson.M{"$set": bson.M{
-			"name":        jsonInput.Name,
-			"description": jsonInput.Description,
-		}}
+	saveIdeaContentUpdate(ginContext, databaseClient, stores, ideaBeforeEdit, contentUpdate)
+}
+
+// saveIdeaContentUpdate : Saves a revision of the idea as it was, then the update, for both PUT and PATCH of an idea
+func saveIdeaContentUpdate(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaBeforeEdit IdeaStructure,
+	contentUpdate IdeaContentUpdate) {
+	databaseContext := ginContext.Request.Context()
+	hexIdeaID := ideaBeforeEdit.ID
+
+	// Editor is recorded in the revision history
+	user := getAuthenticatedUser(ginContext)
+
+	// Checked before the revision is saved, the store checks again in case of an edit in between
+	if contentUpdate.ExpectedVersion != nil && *contentUpdate.ExpectedVersion != ideaBeforeEdit.Version {
+		abortIdeaVersionConflict(ginContext, ideaBeforeEdit.Version)
+		return
 	}
 
-	_, errInFindingIdea := ideasCollection.UpdateOne(databaseContext, filterOfUpdatingIdea, updateIdea)
-	if errInFindingIdea != nil {
-		databaseContext.Done()
-		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Idea not found"})
+	errInAddingRevision := addIdeaRevision(databaseContext, databaseClient, ideaBeforeEdit, user)
+	if errInAddingRevision != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving revision to database"})
 		return
 	}
 
-	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Updated idea successfully"})
-	databaseContext.Done()
-	return
-}
+	if contentUpdate.Name != nil {
+		slug := slugOf(*contentUpdate.Name)
+		contentUpdate.Slug = &slug
+	}
+	contentUpdate.UpdatedAt = time.Now().Unix()
 
-func deleteIdea(ginContext *gin.Context, databaseClient *mongo.Client, ideaID string) {
-	ideasCollection := databaseClient.Database("sardene-db").Collection("ideas")
+	ideaAfterEdit, errInUpdatingIdea := stores.Ideas.UpdateContent(databaseContext, hexIdeaID, contentUpdate)
+	if errInUpdatingIdea == errConflictInStore {
+		ideaInDB, _ := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+		abortIdeaVersionConflict(ginContext, ideaInDB.Version)
+		return
+	}
+	// Deleted since it was loaded
+	if errInUpdatingIdea == errNotFoundInStore {
+		abortIdeaNotFound(ginContext)
+		return
+	}
+	if errInUpdatingIdea != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInUpdatingIdea.Error()})
+		return
+	}
 
-	databaseContext, cancelContext := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancelContext()
+	if linkPreviewer != nil && contentUpdate.Description != nil {
+		linkPreviewer.RefreshInBackground(*contentUpdate.Description)
+	}
 
-	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
-	if errInValidatingID != nil {
-		databaseContext.Done()
-		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
-			"error": "Error, Idea id is not valid"})
-		return
+	describeAuditedMutation(ginContext, auditActionIdeaUpdated, hexIdeaID.Hex(), ideaBeforeEdit, ideaAfterEdit)
+	// Drafts are published by making them public
+	if isIdeaPublic(ideaBeforeEdit) == false && isIdeaPublic(ideaAfterEdit) == true {
+		errInRecordingActivity := recordActivity(databaseContext, databaseClient, activityKindIdeaPublished, ideaAfterEdit,
+			ideaAfterEdit.Publisher)
+		if errInRecordingActivity != nil {
+			log.Println(errInRecordingActivity, "Failed to add publishing of idea to activity", hexIdeaID.Hex())
+		}
 	}
+	errInNotifying := notifyIdeaSubscribers(databaseContext, databaseClient, ideaAfterEdit, notificationKindIdeaUpdated, user)
+	if errInNotifying != nil {
+		log.Println(errInNotifying, "Failed to notify subscribers of idea", hexIdeaID.Hex())
+	}
+
+	// Idea as saved, so clients need not read it again
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Updated idea successfully",
+		"version": ideaAfterEdit.Version, "data": ideaAfterEdit})
+}
 
-	findIdeaFilter := bson.M{"_id": hexIdeaID}
+// abortIdeaVersionConflict : Editor has to read the idea again and redo their edit on top of the stored version
+func abortIdeaVersionConflict(ginContext *gin.Context, storedVersion int64) {
+	ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+		"error": "Error, Idea was edited by someone else since it was read", "version": storedVersion})
+}
+
+// deleteIdea : blobStorage is nil when attachments are switched off
+func deleteIdea(ginContext *gin.Context, stores Stores, databaseClient *mongo.Client, blobStorage BlobStorage, ideaBeforeDelete IdeaStructure) {
+	databaseContext := ginContext.Request.Context()
+	hexIdeaID := ideaBeforeDelete.ID
 
-	_, errInDeletingIdea := ideasCollection.DeleteOne(databaseContext, findIdeaFilter)
+	errInDeletingIdea := stores.Ideas.Delete(databaseContext, hexIdeaID)
+	// Deleted by another request since it was loaded
+	if errInDeletingIdea == errNotFoundInStore {
+		abortIdeaNotFound(ginContext)
+		return
+	}
 	if errInDeletingIdea != nil {
-		databaseContext.Done()
-		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Idea not found"})
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while deleting from database", "errorDetails": errInDeletingIdea.Error()})
 		return
 	}
+	describeAuditedMutation(ginContext, auditActionIdeaDeleted, hexIdeaID.Hex(), ideaBeforeDelete, nil)
+
+	errInDeletingDependents := deleteDependentsOfIdea(databaseContext, databaseClient, stores, hexIdeaID)
+	if errInDeletingDependents != nil {
+		log.Println(errInDeletingDependents, "Failed to delete gazes, bookmarks and subscriptions of deleted idea", hexIdeaID.Hex())
+	}
+
+	if blobStorage != nil {
+		errInReleasingImages := releaseImagesOfIdea(databaseContext, databaseClient, blobStorage, hexIdeaID)
+		if errInReleasingImages != nil {
+			log.Println(errInReleasingImages, "Failed to release images of deleted idea", hexIdeaID.Hex())
+		}
+	}
 
 	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Idea deleted successfully"})
-	databaseContext.Done()
 	return
 
 }
 
-func main() {
-	envKeys := [5]string{"ENVIRONMENT", "DB_URL", "PORT", "GITHUB_CLIENT", "GITHUB_SECRET"}
-	env := getEnvValues(envKeys)
+// serve : Runs the API until it fails, the default command
+func serve(config Config) {
+	databaseClient := openDatabase(config)
+	stores := openStores(config, databaseClient)
+	storages := openStorages(config, databaseClient)
+	startServices(config, databaseClient, stores, storages)
+	router := newServer(config, databaseClient, stores, storages)
 
-	port := env["PORT"]
+	errInStartingServer := serveAPI(router, config.Port, config.TLS)
+	if errInStartingServer != nil {
+		log.Fatal(errInStartingServer, "// Cannot start server")
+	}
+}
 
-	router := gin.Default()
+// NewServer : Engine with every route and middleware over the given stores, ready for httptest. It runs without mongo
+// and starts nothing, so caches, rate limits, analytics, attachments, backups and the jobs are off. GitHub is called
+// through the outbound client of the process, built with GITHUB_RECORDING=replay the auth and profile flows run without GitHub
+func NewServer(config Config, stores Stores) *gin.Engine {
+	return newServer(config, nil, stores, Storages{})
+}
+
+// startServices : Sets up the caches, limiters and reporters the handlers share and starts the background jobs,
+// only serve runs it. Features left out here are nil and stay off in the server
+func startServices(config Config, databaseClient *mongo.Client, stores Stores, storages Storages) {
+	if config.GithubProfileCacheDuration > 0 {
+		githubProfiles = newGithubProfileCache(config.GithubProfileCacheDuration, config.GithubProfileStaleDuration)
+	}
 
-	allowedOrigin := "https://sardene.netlify.app"
-	if env["ENVIRONMENT"] == "dev" {
-		allowedOrigin = "http://localhost:3000"
+	if config.ErrorReporting.DSN != "" {
+		errorReporter = newErrorReporter(config.ErrorReporting)
+		log.Println("Reporting panics and server errors of release " + config.ErrorReporting.Release)
+	}
+
+	if config.Concurrency.MaxInFlight > 0 {
+		requestConcurrencyLimiter = newConcurrencyLimiter(config.Concurrency)
+	}
+	if config.Features.ResponseCache == true {
+		responseCache = newResponseCache(config.ResponseCache)
+	}
+	if config.Features.RateLimits == true {
+		requestRateLimiter = newRateLimiter(config.RateLimit)
 	}
+	if config.ViewFlushInterval > 0 {
+		ideaViews = newViewCounter(stores, config.ViewFlushInterval)
+	}
+	startSitemapGeneration(stores, config.Branding.FrontendOrigin, config.SitemapInterval)
+	startDependencyProbes(databaseClient, config.DependencyProbeInterval)
+
+	// Memory driver runs without mongo, features which keep their data in mongo are left out
+	if databaseClient == nil {
+		return
+	}
+
+	startDatabaseHealthMonitor(databaseClient, config.DatabaseHealthInterval)
+	// Serving starts right away, documents not yet backfilled are read with their zero values
+	go func() {
+		errInMigrating := runMigrations(databaseClient, config.Migration)
+		if errInMigrating != nil {
+			log.Println(errInMigrating)
+		}
+	}()
+	ensureIndexes(databaseClient)
+
+	// Capped collection has to exist before anything writes to it
+	if config.Features.Analytics == true {
+		requestAnalytics = newRequestAnalytics(databaseClient, config.Analytics)
+	}
+
+	// Previews are cached in mongo whichever driver keeps the ideas
+	if config.Features.LinkPreviews == true {
+		linkPreviewer = newLinkPreviewer(databaseClient, config.LinkPreview, config.OutboundHTTP)
+	}
+
+	// Analysis reads the gazes in mongo
+	if config.Features.VoteAnalysis == true && config.DatabaseDriver == "mongo" {
+		go runVoteAnalysisJob(databaseClient, config.VoteAnalysis)
+	}
+
+	// Counters are recounted from the gazes and makers in mongo, with postgres they are kept by the stores
+	if config.DatabaseDriver == "mongo" {
+		go runCounterReconciliationJob(databaseClient, config.CounterReconcileInterval)
+	}
+
+	// Jobs below go through the stores, they only keep their leases and results in mongo
+	go runOrphanSweepJob(databaseClient, stores, config.DatabaseDriver, config.OrphanSweepInterval)
+	go runRepoSyncJob(databaseClient, stores, config.RepoSync)
+	go runSimilarIdeasJob(databaseClient, stores, config.SimilarIdeasInterval)
+	go runIdeaOfTheDayJob(databaseClient, stores, config.IdeaOfTheDay)
+	go runTrendingJob(databaseClient, stores, config.TrendingRecomputeInterval)
+
+	// Backups dump mongo, with the memory driver there is nothing worth keeping
+	if storages.Backups != nil {
+		go runBackupJob(databaseClient, *storages.Backups, config.Backup)
+	}
+}
+
+// newServer : Routes and middleware over the stores, it reads what startServices set up and starts nothing itself
+func newServer(config Config, databaseClient *mongo.Client, stores Stores, storages Storages) *gin.Engine {
+	router := gin.New()
+	router.ForwardedByClientIP = false
+	router.Use(resolveClientIP(config.Proxy))
+	// MessagePack wraps the recovery, so a panic is answered in the format the client asked for
+	router.Use(gin.Logger(), assignRequestID(), negotiateMessagePack(), recoverWithJSON(), reportServerErrors())
+
+	brandingConfig := config.Branding
 
 	corsConfig := cors.Config{
-		AllowOrigins:     []string{allowedOrigin},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Cache-Control", "Accept", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowOrigins:  config.CORSOrigins,
+		AllowWildcard: true,
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowHeaders: []string{"Origin", "Authorization", "Cache-Control", "Accept", "Content-Type", "Idempotency-Key",
+			apiKeyHeader},
+		ExposeHeaders: []string{"Allow", "Content-Length", "Idempotent-Replayed", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining",
+			"X-RateLimit-Reset"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
 
 	router.Use(cors.New(corsConfig))
+	router.Use(recordRequestMetrics())
+	// Shed requests are still answered with CORS headers and counted in the metrics
+	router.Use(limitConcurrentRequests())
+	handleUnknownRoutes(router)
 
-	databaseClient := connectToDatabase(env["DB_URL"])
+	// Memory driver runs without mongo, features which keep their data in mongo are left out
+	isWithoutMongo := databaseClient == nil
 
-	router.GET("/", welcome)
+	if isWithoutMongo == false {
+		router.Use(requireHealthyDatabase())
+	}
+
+	// Heavy listings may read from secondaries, only the mongo driver has them
+	var sardeneListingDatabase *mongo.Database
+	if isWithoutMongo == false {
+		sardeneListingDatabase = listingDatabase(databaseClient, config.Mongo)
+	}
+	listingStores := stores
+	if config.DatabaseDriver == "mongo" && isWithoutMongo == false {
+		listingStores = newMongoStoresOf(sardeneListingDatabase)
+	}
+
+	userSessions := newSessionStore(stores.Sessions, config.Session)
+	routes := newPolicyRouter(router, stores, userSessions, databaseClient, config.RequestTimeout, config.Suspension)
+
+	routes.GET("/", func(ginContext *gin.Context) {
+		welcome(ginContext, brandingConfig)
+	})
+
+	routes.GET("/meta", func(ginContext *gin.Context) {
+		getMeta(ginContext, brandingConfig)
+	})
+
+	routes.GET("/sitemap.xml", func(ginContext *gin.Context) {
+		getSitemap(ginContext)
+	})
+
+	routes.GET("/oembed", func(ginContext *gin.Context) {
+		getOEmbed(ginContext, stores, brandingConfig)
+	})
 
 	// TODO convert to pagination endpoint
-	router.GET("/ideas", func(ginContext *gin.Context) {
-		getIdeas(ginContext, databaseClient)
+	routes.GET("/ideas", func(ginContext *gin.Context) {
+		getIdeas(ginContext, databaseClient, stores, listingStores)
+	})
+
+	routes.POST("/auth", func(ginContext *gin.Context) {
+		authenticateUser(ginContext, databaseClient, stores, userSessions, config.GithubSecrets, config.AuthThrottle)
+	})
+
+	routes.GET("/auth/session", func(ginContext *gin.Context) {
+		getAuthSession(ginContext, userSessions)
+	})
+
+	routes.DELETE("/auth/session", func(ginContext *gin.Context) {
+		deleteAuthSession(ginContext, userSessions)
 	})
 
-	router.POST("/auth", func(ginContext *gin.Context) {
-		var githubSecrets GithubSecretsEnvs
-		githubSecrets.Client = env["GITHUB_CLIENT"]
-		githubSecrets.Secret = env["GITHUB_SECRET"]
+	if config.EmailAuth.SigningSecret != "" && isWithoutMongo == false {
+		routes.POST("/auth/email", func(ginContext *gin.Context) {
+			requestEmailLogin(ginContext, databaseClient, config.EmailAuth, config.AuthThrottle, brandingConfig)
+		})
 
-		authenticateUser(ginContext, databaseClient, githubSecrets)
+		routes.GET("/auth/email/verify", func(ginContext *gin.Context) {
+			verifyEmailLogin(ginContext, databaseClient, stores, userSessions, config.EmailAuth, config.AuthThrottle)
+		})
+	}
+
+	if isWithoutMongo == false {
+		routes.GET("/user/identities", func(ginContext *gin.Context) {
+			getUserIdentities(ginContext, databaseClient)
+		})
+	}
+
+	routes.POST("/idea/add", idempotentWrite(databaseClient, config.RequestTimeout), func(ginContext *gin.Context) {
+		addIdea(ginContext, databaseClient, stores, config.Quarantine, config.ContentFilter, config.DuplicateDetection, config.Quota)
 	})
 
-	router.POST("/idea/add", func(ginContext *gin.Context) {
-		addIdea(ginContext, databaseClient)
+	routes.PATCH("/idea/gaze/:ideaID", loadIdea(stores), func(ginContext *gin.Context) {
+		likeAnIdea(ginContext, databaseClient, stores, getLoadedIdea(ginContext), reactionEyes, config.Quota)
 	})
 
-	router.PATCH("/idea/gaze/:ideaID", func(ginContext *gin.Context) {
+	routes.PATCH("/idea/react/:ideaID", loadIdea(stores), func(ginContext *gin.Context) {
+		reactToIdea(ginContext, databaseClient, stores, getLoadedIdea(ginContext), config.Quota)
+	})
+
+	routes.POST("/idea/link/:ideaID", func(ginContext *gin.Context) {
 		ideaID := ginContext.Param("ideaID")
-		likeAnIdea(ginContext, databaseClient, ideaID)
+		linkIdeas(ginContext, stores, ideaID)
 	})
 
-	router.GET("/ideas/gazed", func(ginContext *gin.Context) {
-		getUserLikedIdeas(ginContext, databaseClient)
+	routes.DELETE("/idea/link/:ideaID", func(ginContext *gin.Context) {
+		ideaID := ginContext.Param("ideaID")
+		unlinkIdeas(ginContext, stores, ideaID)
+	})
+
+	routes.GET("/idea/:ideaID/full", func(ginContext *gin.Context) {
+		ideaID := ginContext.Param("ideaID")
+		getIdeaDetail(ginContext, databaseClient, stores, ideaID)
+	})
+
+	routes.GET("/idea/:ideaID/card.png", func(ginContext *gin.Context) {
+		ideaID := ginContext.Param("ideaID")
+		getIdeaShareCard(ginContext, stores, ideaID, brandingConfig)
+	})
+
+	routes.GET("/idea/:ideaID/graph", func(ginContext *gin.Context) {
+		ideaID := ginContext.Param("ideaID")
+		getIdeaGraph(ginContext, stores, ideaID)
+	})
+
+	// Moderation queue is kept in mongo
+	if isWithoutMongo == false {
+		routes.GET("/admin/moderation", func(ginContext *gin.Context) {
+			getModerationQueue(ginContext, databaseClient)
+		})
+
+		routes.PATCH("/admin/moderation/:reportID", func(ginContext *gin.Context) {
+			reportID := ginContext.Param("reportID")
+			resolveModerationReport(ginContext, databaseClient, stores, reportID)
+		})
+	}
+
+	routes.PATCH("/admin/users/:userID/suspension", func(ginContext *gin.Context) {
+		userID := ginContext.Param("userID")
+		setUserSuspension(ginContext, stores, userID)
+	})
+
+	routes.GET("/admin/metrics/outbound", func(ginContext *gin.Context) {
+		getOutboundMetrics(ginContext)
+	})
+
+	// Revisions, updates and the maker records are kept in mongo
+	if isWithoutMongo == false {
+		routes.GET("/admin/metrics/database", func(ginContext *gin.Context) {
+			getDatabasePoolMetrics(ginContext, databaseClient)
+		})
+
+		routes.GET("/admin/export/:collection", gzipResponses(), func(ginContext *gin.Context) {
+			collectionName := ginContext.Param("collection")
+			exportCollection(ginContext, databaseClient, config.DatabaseDriver, collectionName)
+		})
+
+		routes.GET("/idea/:ideaID/revisions", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			getIdeaRevisions(ginContext, databaseClient, stores, ideaID)
+		})
+
+		routes.GET("/idea/:ideaID/updates", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			getIdeaUpdates(ginContext, databaseClient, stores, ideaID)
+		})
+
+		// Under /ideas like the images, gin cannot route POST /idea/:ideaID next to POST /idea/add
+		routes.POST("/ideas/:ideaID/updates", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			addIdeaUpdate(ginContext, databaseClient, stores, ideaID)
+		})
+
+		routes.POST("/ideas/:ideaID/export/github", idempotentWrite(databaseClient, config.RequestTimeout), func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			exportIdeaToGithubIssue(ginContext, databaseClient, stores, userSessions, ideaID, brandingConfig)
+		})
+	}
+
+	routes.GET("/user/export", func(ginContext *gin.Context) {
+		exportUserData(ginContext, databaseClient, stores)
+	})
+
+	routes.DELETE("/user", func(ginContext *gin.Context) {
+		deleteUserAccount(ginContext, databaseClient, stores)
+	})
+
+	routes.PATCH("/user", func(ginContext *gin.Context) {
+		updateUserSettings(ginContext, stores)
+	})
+
+	routes.GET("/users/:login", func(ginContext *gin.Context) {
+		login := ginContext.Param("login")
+		getPublicUserProfile(ginContext, stores, login)
+	})
+
+	blobStorage := storages.Attachments
+	if blobStorage != nil {
+
+		routes.POST("/attachments", func(ginContext *gin.Context) {
+			uploadAttachment(ginContext, databaseClient, blobStorage, config.Attachment)
+		})
+
+		routes.GET("/attachments/:hash", func(ginContext *gin.Context) {
+			attachmentHash := ginContext.Param("hash")
+			getAttachment(ginContext, databaseClient, blobStorage, attachmentHash)
+		})
+
+		routes.DELETE("/attachments/:hash", func(ginContext *gin.Context) {
+			attachmentHash := ginContext.Param("hash")
+			deleteAttachment(ginContext, databaseClient, blobStorage, attachmentHash)
+		})
+
+		routes.POST("/ideas/:ideaID/images", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			uploadIdeaImage(ginContext, databaseClient, stores, blobStorage, config.Attachment, ideaID)
+		})
+
+		routes.DELETE("/ideas/:ideaID/images/:hash", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			imageHash := ginContext.Param("hash")
+			deleteIdeaImage(ginContext, databaseClient, stores, blobStorage, ideaID, imageHash)
+		})
+	}
+
+	// Backups dump mongo, with the memory driver there is nothing worth keeping
+	if storages.Backups != nil {
+		backupStorage := *storages.Backups
+
+		routes.GET("/admin/backups", func(ginContext *gin.Context) {
+			getBackups(ginContext, databaseClient)
+		})
+
+		routes.POST("/admin/backups", func(ginContext *gin.Context) {
+			triggerBackup(ginContext, databaseClient, backupStorage, config.Backup)
+		})
+	}
+
+	routes.POST("/ideas/:ideaID/collaborators", func(ginContext *gin.Context) {
+		ideaID := ginContext.Param("ideaID")
+		addIdeaCollaborator(ginContext, stores, ideaID)
+	})
+
+	routes.DELETE("/ideas/:ideaID/collaborators/:userID", func(ginContext *gin.Context) {
+		ideaID := ginContext.Param("ideaID")
+		collaboratorID := ginContext.Param("userID")
+		removeIdeaCollaborator(ginContext, stores, ideaID, collaboratorID)
+	})
+
+	routes.GET("/health/dependencies", func(ginContext *gin.Context) {
+		getDependencyHealth(ginContext)
+	})
+
+	if config.Features.StatusPage == true && isWithoutMongo == false {
+		routes.GET("/status", func(ginContext *gin.Context) {
+			getStatusPage(ginContext, databaseClient, config.StatusCacheDuration)
+		})
+
+		routes.POST("/admin/incidents", func(ginContext *gin.Context) {
+			addIncident(ginContext, databaseClient)
+		})
+
+		routes.PATCH("/admin/incidents/:incidentID", func(ginContext *gin.Context) {
+			incidentID := ginContext.Param("incidentID")
+			resolveIncident(ginContext, databaseClient, incidentID)
+		})
+	}
+
+	// Stats and the gaze listing aggregate ideas, users and gazes in mongo, the other drivers have none there
+	if config.DatabaseDriver == "mongo" {
+		routes.GET("/stats", func(ginContext *gin.Context) {
+			getCommunityStats(ginContext, sardeneListingDatabase, config.StatsCacheDuration)
+		})
+
+		routes.GET("/stats/timeseries", func(ginContext *gin.Context) {
+			getStatsTimeseries(ginContext, sardeneListingDatabase)
+		})
+
+		routes.GET("/admin/likes", gzipResponses(), func(ginContext *gin.Context) {
+			getLikesForAdmin(ginContext, databaseClient)
+		})
+	}
+
+	// Bookmarks, follows, activity, subscriptions and notifications are kept in mongo
+	if isWithoutMongo == false {
+		routes.POST("/idea/bookmark/:ideaID", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			bookmarkIdea(ginContext, databaseClient, stores, ideaID)
+		})
+
+		routes.DELETE("/idea/bookmark/:ideaID", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			removeBookmark(ginContext, databaseClient, ideaID)
+		})
+
+		routes.GET("/ideas/bookmarked", func(ginContext *gin.Context) {
+			getUserBookmarkedIdeas(ginContext, databaseClient)
+		})
+
+		routes.POST("/users/:login/follow", func(ginContext *gin.Context) {
+			login := ginContext.Param("login")
+			followUser(ginContext, databaseClient, stores, login)
+		})
+
+		routes.DELETE("/users/:login/follow", func(ginContext *gin.Context) {
+			login := ginContext.Param("login")
+			unfollowUser(ginContext, databaseClient, stores, login)
+		})
+
+		routes.GET("/feed", func(ginContext *gin.Context) {
+			getFeed(ginContext, databaseClient, stores)
+		})
+
+		routes.GET("/activity", func(ginContext *gin.Context) {
+			getActivity(ginContext, databaseClient, stores)
+		})
+
+		routes.POST("/idea/subscribe/:ideaID", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			subscribeToIdea(ginContext, databaseClient, stores, ideaID)
+		})
+
+		routes.DELETE("/idea/subscribe/:ideaID", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			unsubscribeFromIdea(ginContext, databaseClient, ideaID)
+		})
+
+		routes.GET("/user/preferences", func(ginContext *gin.Context) {
+			getUserPreferences(ginContext, databaseClient)
+		})
+
+		routes.PATCH("/user/preferences", func(ginContext *gin.Context) {
+			updateUserPreferences(ginContext, databaseClient)
+		})
+
+		routes.GET("/notifications", func(ginContext *gin.Context) {
+			getNotifications(ginContext, databaseClient)
+		})
+
+		routes.POST("/notifications/read", func(ginContext *gin.Context) {
+			markNotificationsRead(ginContext, databaseClient)
+		})
+
+		routes.GET("/idea/:ideaID/similar", func(ginContext *gin.Context) {
+			ideaID := ginContext.Param("ideaID")
+			getSimilarIdeas(ginContext, databaseClient, stores, ideaID)
+		})
+	}
+
+	routes.POST("/idea/gazed/check", func(ginContext *gin.Context) {
+		checkGazedIdeas(ginContext, stores)
+	})
+
+	routes.GET("/ideas/suggest", func(ginContext *gin.Context) {
+		suggestIdeas(ginContext, stores, config.SuggestCacheDuration)
+	})
+
+	routes.GET("/ideas/featured", func(ginContext *gin.Context) {
+		getFeaturedIdeas(ginContext, stores)
+	})
+
+	routes.GET("/ideas/launched", func(ginContext *gin.Context) {
+		getLaunchedIdeas(ginContext, listingStores)
+	})
+
+	routes.GET("/ideas/trending", func(ginContext *gin.Context) {
+		getIdeasByMomentum(ginContext, listingStores, ideaSortTrending)
+	})
+
+	if isWithoutMongo == false {
+		routes.GET("/ideas/of-the-day", func(ginContext *gin.Context) {
+			getIdeaOfTheDay(ginContext, databaseClient, stores)
+		})
+	}
+
+	routes.GET("/ideas/rising", func(ginContext *gin.Context) {
+		getIdeasByMomentum(ginContext, listingStores, ideaSortRising)
+	})
+
+	routes.PATCH("/admin/ideas/:ideaID/featured", func(ginContext *gin.Context) {
+		ideaID := ginContext.Param("ideaID")
+		setIdeaFeatured(ginContext, stores, ideaID)
+	})
+
+	if isWithoutMongo == false {
+		routes.GET("/admin/audit", func(ginContext *gin.Context) {
+			getAuditLog(ginContext, databaseClient)
+		})
+	}
+
+	if config.Features.Analytics == true && isWithoutMongo == false {
+		routes.GET("/admin/analytics", func(ginContext *gin.Context) {
+			getRouteTraffic(ginContext, databaseClient)
+		})
+	}
+
+	routes.GET("/ideas/gazed", func(ginContext *gin.Context) {
+		getUserLikedIdeas(ginContext, stores)
 	})
 
 	// router.GET("/user" , func(ginContext *gin.Context)){
 	// 	getUserProfile()
 	// }
 
-	router.PUT("/idea/update/:ideaID", func(ginContext *gin.Context) {
-		ideaID := ginContext.Param("ideaID")
-		updateIdea(ginContext, databaseClient, ideaID)
+	routes.PUT("/idea/update/:ideaID", loadIdea(stores), func(ginContext *gin.Context) {
+		updateIdea(ginContext, databaseClient, stores, getLoadedIdea(ginContext))
 	})
 
-	router.DELETE("/idea/delete/:ideaID", func(ginContext *gin.Context) {
-		ideaID := ginContext.Param("ideaID")
-		deleteIdea(ginContext, databaseClient, ideaID)
+	// Same path as the PUT, gin cannot route PATCH /idea/:ideaID next to PATCH /idea/gaze/:ideaID
+	routes.PATCH("/idea/update/:ideaID", loadIdea(stores), func(ginContext *gin.Context) {
+		patchIdea(ginContext, databaseClient, stores, getLoadedIdea(ginContext))
 	})
 
-	errInStartingServer := router.Run(":" + port)
-	if errInStartingServer != nil {
-		log.Fatal(errInStartingServer, "// Cannot start server")
+	routes.PATCH("/idea/archive/:ideaID", loadIdea(stores), func(ginContext *gin.Context) {
+		setIdeaArchived(ginContext, stores, getLoadedIdea(ginContext), true)
+	})
+
+	routes.PATCH("/idea/unarchive/:ideaID", loadIdea(stores), func(ginContext *gin.Context) {
+		setIdeaArchived(ginContext, stores, getLoadedIdea(ginContext), false)
+	})
+
+	routes.PUT("/ideas/:ideaID/launch", loadIdea(stores), func(ginContext *gin.Context) {
+		launchIdea(ginContext, databaseClient, stores, getLoadedIdea(ginContext))
+	})
+
+	routes.DELETE("/idea/delete/:ideaID", loadIdea(stores), func(ginContext *gin.Context) {
+		deleteIdea(ginContext, stores, databaseClient, blobStorage, getLoadedIdea(ginContext))
+	})
+
+	// Orgs are kept in mongo
+	if isWithoutMongo == false {
+		routes.POST("/orgs", func(ginContext *gin.Context) {
+			createOrg(ginContext, databaseClient, stores)
+		})
+
+		routes.GET("/orgs/:orgSlug", func(ginContext *gin.Context) {
+			getOrg(ginContext, databaseClient, ginContext.Param("orgSlug"))
+		})
+
+		routes.POST("/orgs/:orgSlug/invites", func(ginContext *gin.Context) {
+			inviteOrgMember(ginContext, databaseClient, stores, ginContext.Param("orgSlug"))
+		})
+
+		routes.POST("/orgs/:orgSlug/invites/accept", func(ginContext *gin.Context) {
+			acceptOrgInvite(ginContext, databaseClient, ginContext.Param("orgSlug"))
+		})
+
+		routes.PATCH("/orgs/:orgSlug/members/:userID", func(ginContext *gin.Context) {
+			changeOrgMemberRole(ginContext, databaseClient, ginContext.Param("orgSlug"), ginContext.Param("userID"))
+		})
+
+		routes.DELETE("/orgs/:orgSlug/members/:userID", func(ginContext *gin.Context) {
+			removeOrgMember(ginContext, databaseClient, ginContext.Param("orgSlug"), ginContext.Param("userID"))
+		})
 	}
+
+	return router
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newMemoryTestServer : Server over memory stores without mongo, with a session of a signed in user
+func newMemoryTestServer(t *testing.T) (*gin.Engine, Stores, string) {
+	for key, value := range map[string]string{"GITHUB_CLIENT": "client", "GITHUB_SECRET": "secret",
+		"DB_DRIVER": "memory", "PORT": "8080", "ENVIRONMENT": "dev"} {
+		os.Setenv(key, value)
+	}
+	config, errInConfig := loadConfig("")
+	if errInConfig != nil {
+		t.Fatal(errInConfig)
+	}
+
+	gin.SetMode(gin.TestMode)
+	stores := newMemoryStores()
+	router := NewServer(config, stores)
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Second)
+	defer cancelDBContext()
+	user := UserStructure{UserID: 42, Login: "octocat", Name: "The Octocat", CreatedAt: time.Now().Unix()}
+	if errInAddingUser := stores.Users.Insert(databaseContext, user); errInAddingUser != nil {
+		t.Fatal(errInAddingUser)
+	}
+	// Sessions are kept by the stores, so one created here is found by the server
+	sessionToken, _, errInCreatingSession := newSessionStore(stores.Sessions, config.Session).create(databaseContext, user,
+		identityProviderGithub, "")
+	if errInCreatingSession != nil {
+		t.Fatal(errInCreatingSession)
+	}
+
+	return router, stores, sessionToken
+}
+
+// addTestIdea : Public idea of the signed in user of newMemoryTestServer, at the given version
+func addTestIdea(t *testing.T, stores Stores, version int64) primitive.ObjectID {
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Second)
+	defer cancelDBContext()
+	ideaID, errInAddingIdea := stores.Ideas.Insert(databaseContext, IdeaStructure{Name: "Sardene", Slug: "sardene",
+		Description: "Ideas worth building", RepoURL: "https://github.com/octocat/sardene", Publisher: "octocat",
+		PublisherID: 42, Visibility: ideaVisibilityPublic, Version: version, CreatedAt: time.Now().Unix()})
+	if errInAddingIdea != nil {
+		t.Fatal(errInAddingIdea)
+	}
+	return ideaID
+}
+
+func TestAddIdeaWithMemoryDriver(t *testing.T) {
+	router, stores, sessionToken := newMemoryTestServer(t)
+
+	addRequest := httptest.NewRequest(http.MethodPost, "/idea/add",
+		strings.NewReader(`{"name":"Sardene","description":"Ideas worth building, shared with the makers"}`))
+	addRequest.Header.Set("Authorization", "Bearer "+sessionToken)
+	addRequest.Header.Set("Content-Type", "application/json")
+	addResponse := httptest.NewRecorder()
+	router.ServeHTTP(addResponse, addRequest)
+
+	if addResponse.Code != http.StatusCreated {
+		t.Fatalf("POST /idea/add answered %d: %s", addResponse.Code, addResponse.Body.String())
+	}
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Second)
+	defer cancelDBContext()
+	publishedIdeas, errInListing := stores.Ideas.ListByPublisherSince(databaseContext, 42, 0, 0)
+	if errInListing != nil {
+		t.Fatal(errInListing)
+	}
+	if len(publishedIdeas) != 1 || publishedIdeas[0].Name != "Sardene" {
+		t.Fatalf("Stores hold %+v after adding one idea", publishedIdeas)
+	}
+
+	listRequest := httptest.NewRequest(http.MethodGet, "/ideas", nil)
+	listResponse := httptest.NewRecorder()
+	router.ServeHTTP(listResponse, listRequest)
+
+	var listing struct {
+		Count int64           `json:"count"`
+		Data  []IdeaStructure `json:"data"`
+	}
+	if errInDecoding := json.Unmarshal(listResponse.Body.Bytes(), &listing); errInDecoding != nil {
+		t.Fatal(errInDecoding)
+	}
+	if listResponse.Code != http.StatusOK || listing.Count != 1 {
+		t.Fatalf("GET /ideas answered %d: %s", listResponse.Code, listResponse.Body.String())
+	}
+}
+
+func TestMongoFeaturesAreLeftOutWithMemoryDriver(t *testing.T) {
+	router, _, sessionToken := newMemoryTestServer(t)
+
+	feedRequest := httptest.NewRequest(http.MethodGet, "/feed", nil)
+	feedRequest.Header.Set("Authorization", "Bearer "+sessionToken)
+	feedResponse := httptest.NewRecorder()
+	router.ServeHTTP(feedResponse, feedRequest)
+
+	if feedResponse.Code != http.StatusNotFound {
+		t.Fatalf("GET /feed answered %d without mongo: %s", feedResponse.Code, feedResponse.Body.String())
+	}
+}
+
+func TestUpdateOfStaleVersionIsRefused(t *testing.T) {
+	router, stores, sessionToken := newMemoryTestServer(t)
+	ideaID := addTestIdea(t, stores, 3)
+
+	staleResponse := serveTestRequest(router, http.MethodPut, "/idea/update/"+ideaID.Hex(),
+		`{"description":"Edited over an older read","version":2}`, sessionToken)
+	var conflict struct {
+		Version int64 `json:"version"`
+	}
+	json.Unmarshal(staleResponse.Body.Bytes(), &conflict)
+	if staleResponse.Code != http.StatusConflict || conflict.Version != 3 {
+		t.Fatalf("PUT of a stale version answered %d: %s", staleResponse.Code, staleResponse.Body.String())
+	}
+
+	updateResponse := serveTestRequest(router, http.MethodPut, "/idea/update/"+ideaID.Hex(),
+		`{"description":"Edited over the latest read","version":3}`, sessionToken)
+	var updated struct {
+		Version int64         `json:"version"`
+		Data    IdeaStructure `json:"data"`
+	}
+	json.Unmarshal(updateResponse.Body.Bytes(), &updated)
+	if updateResponse.Code != http.StatusOK || updated.Version != 4 || updated.Data.Description != "Edited over the latest read" {
+		t.Fatalf("PUT of the stored version answered %d: %s", updateResponse.Code, updateResponse.Body.String())
+	}
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Second)
+	defer cancelDBContext()
+	// Store checks the version again, for an edit which came in after the idea was loaded
+	_, errInUpdating := stores.Ideas.UpdateContent(databaseContext, ideaID, IdeaContentUpdate{ExpectedVersion: &conflict.Version})
+	if errInUpdating != errConflictInStore {
+		t.Fatalf("Update of a stale version in the store failed with %v", errInUpdating)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yuin/goldmark"
+)
+
+// Elements whose content is dropped along with them, their text is never meant to be shown
+var dangerousHTMLElements = []string{"script", "style", "iframe", "frame", "frameset", "object", "embed", "applet", "noscript"}
+
+var (
+	dangerousHTMLBlockRegexes = compileDangerousHTMLBlockRegexes()
+	htmlCommentRegex          = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTagRegex              = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9-]*(\s[^<>]*)?/?>`)
+)
+
+// markdownRenderer : Raw HTML is left out and javascript: links are emptied when rendering, it is never trusted
+var markdownRenderer = goldmark.New()
+
+func compileDangerousHTMLBlockRegexes() []*regexp.Regexp {
+	var blockRegexes []*regexp.Regexp
+	for _, element := range dangerousHTMLElements {
+		// Go regexps have no back references, so every element gets its own
+		blockRegexes = append(blockRegexes, regexp.MustCompile(`(?is)<`+element+`\b[^>]*>.*?</`+element+`\s*>`))
+	}
+	return blockRegexes
+}
+
+// sanitizeMarkdown : Markdown as it is stored, scripts and frames are dropped with their content and other HTML tags
+// are stripped, so clients rendering it themselves get no markup they did not expect
+func sanitizeMarkdown(markdown string) string {
+	for _, blockRegex := range dangerousHTMLBlockRegexes {
+		markdown = blockRegex.ReplaceAllString(markdown, "")
+	}
+	markdown = htmlCommentRegex.ReplaceAllString(markdown, "")
+	markdown = htmlTagRegex.ReplaceAllString(markdown, "")
+
+	return strings.TrimSpace(markdown)
+}
+
+// renderMarkdown : Safe HTML of stored Markdown
+func renderMarkdown(markdown string) (string, error) {
+	var renderedHTML bytes.Buffer
+	errInRendering := markdownRenderer.Convert([]byte(markdown), &renderedHTML)
+	return renderedHTML.String(), errInRendering
+}
+
+// isHTMLRenderRequested : Clients that cannot render Markdown ask for ?render=html
+func isHTMLRenderRequested(ginContext *gin.Context) bool {
+	return strings.ToLower(ginContext.Query("render")) == "html"
+}
+
+// renderIdeaDescriptions : Fills in the rendered HTML of every description
+func renderIdeaDescriptions(ideas []IdeaStructure) error {
+	for ideaIndex := range ideas {
+		descriptionHTML, errInRendering := renderMarkdown(ideas[ideaIndex].Description)
+		if errInRendering != nil {
+			return errInRendering
+		}
+		ideas[ideaIndex].DescriptionHTML = descriptionHTML
+	}
+	return nil
+}
@@ -0,0 +1,998 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryDatabase : Ideas, users and gazes kept in the process for DB_DRIVER=memory, lost when it stops.
+// One mutex guards all of them, so gazing and counting the gazers of an idea cannot interleave
+type memoryDatabase struct {
+	mutex sync.RWMutex
+	// Ideas are kept in the order they were added, like mongo returns them without a sort
+	ideas []IdeaStructure
+	users map[int64]UserStructure
+	likes []IdeaLikesStructure
+	// Sessions by the hash of their token
+	sessions map[string]SessionStructure
+}
+
+type memoryIdeasStore struct {
+	database *memoryDatabase
+}
+
+type memoryUsersStore struct {
+	database *memoryDatabase
+}
+
+type memoryLikesStore struct {
+	database *memoryDatabase
+}
+
+type memorySessionsStore struct {
+	database *memoryDatabase
+}
+
+func newMemoryStores() Stores {
+	database := &memoryDatabase{users: make(map[int64]UserStructure), sessions: make(map[string]SessionStructure)}
+
+	return Stores{
+		Ideas:    memoryIdeasStore{database: database},
+		Users:    memoryUsersStore{database: database},
+		Likes:    memoryLikesStore{database: database},
+		Sessions: memorySessionsStore{database: database},
+	}
+}
+
+// copyOfIdea : Ideas handed out must not share slices or maps with the stored ones
+func copyOfIdea(idea IdeaStructure) IdeaStructure {
+	idea.Collaborators = append([]IdeaCollaboratorStructure(nil), idea.Collaborators...)
+	idea.Links = append([]IdeaLinkStructure(nil), idea.Links...)
+	idea.Images = append([]IdeaImageStructure(nil), idea.Images...)
+	if idea.Launch != nil {
+		launch := *idea.Launch
+		idea.Launch = &launch
+	}
+	if idea.Repo != nil {
+		repo := *idea.Repo
+		idea.Repo = &repo
+	}
+	reactions := make(map[string]int64, len(idea.Reactions))
+	for reaction, count := range idea.Reactions {
+		reactions[reaction] = count
+	}
+	idea.Reactions = reactions
+	return idea
+}
+
+func (database *memoryDatabase) indexOfIdea(ideaID primitive.ObjectID) int {
+	for ideaIndex := range database.ideas {
+		if database.ideas[ideaIndex].ID == ideaID {
+			return ideaIndex
+		}
+	}
+	return -1
+}
+
+func (store memoryIdeasStore) ListPublished(databaseContext context.Context, filter IdeaListFilter, fields []string) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var ideas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		if isIdeaVisibleTo(idea, filter.DraftsOf) == false || (idea.Archived == true && filter.IncludeArchived == false) {
+			continue
+		}
+		if filter.Publisher != "" && idea.Publisher != filter.Publisher {
+			continue
+		}
+		if filter.CreatedAfter != 0 && idea.CreatedAt <= filter.CreatedAfter {
+			continue
+		}
+		if filter.CreatedBefore != 0 && idea.CreatedAt >= filter.CreatedBefore {
+			continue
+		}
+		ideas = append(ideas, copyOfIdea(idea))
+	}
+
+	return ideas, nil
+}
+
+func (store memoryIdeasStore) FindByID(databaseContext context.Context, ideaID primitive.ObjectID) (IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return IdeaStructure{}, errNotFoundInStore
+	}
+	return copyOfIdea(store.database.ideas[ideaIndex]), nil
+}
+
+// ideasWithIDs : Copies of the ideas among ideaIDs, the caller holds the mutex
+func (database *memoryDatabase) ideasWithIDs(ideaIDs []primitive.ObjectID) []IdeaStructure {
+	var ideas []IdeaStructure
+	for _, ideaID := range ideaIDs {
+		if ideaIndex := database.indexOfIdea(ideaID); ideaIndex >= 0 {
+			ideas = append(ideas, copyOfIdea(database.ideas[ideaIndex]))
+		}
+	}
+	return ideas
+}
+
+func (store memoryIdeasStore) FindByIDs(databaseContext context.Context, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	return store.database.ideasWithIDs(ideaIDs), nil
+}
+
+func (store memoryIdeasStore) ListLinkingTo(databaseContext context.Context, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var ideas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		for _, ideaID := range ideaIDs {
+			if isLinkedTo(idea, ideaID) {
+				ideas = append(ideas, copyOfIdea(idea))
+				break
+			}
+		}
+	}
+	return ideas, nil
+}
+
+// AddLink : The cycle is checked under the same lock the link is added with
+func (store memoryIdeasStore) AddLink(databaseContext context.Context, ideaID primitive.ObjectID, link IdeaLinkStructure) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	findIdeas := func(ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+		return store.database.ideasWithIDs(ideaIDs), nil
+	}
+	return addLinkWithoutCycle(findIdeas, ideaID, link, func() error {
+		ideaIndex := store.database.indexOfIdea(ideaID)
+		store.database.ideas[ideaIndex].Links = append(store.database.ideas[ideaIndex].Links, link)
+		return nil
+	})
+}
+
+func (store memoryIdeasStore) RemoveLink(databaseContext context.Context, ideaID primitive.ObjectID, linkedIdeaID primitive.ObjectID) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 || isLinkedTo(store.database.ideas[ideaIndex], linkedIdeaID) == false {
+		return errNotFoundInStore
+	}
+
+	remainingLinks := []IdeaLinkStructure{}
+	for _, link := range store.database.ideas[ideaIndex].Links {
+		if link.IdeaID != linkedIdeaID {
+			remainingLinks = append(remainingLinks, link)
+		}
+	}
+	store.database.ideas[ideaIndex].Links = remainingLinks
+	return nil
+}
+
+func (store memoryIdeasStore) ListByPublisherSince(databaseContext context.Context, publisherID int64, since int64, limit int64) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var ideas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		if idea.PublisherID == publisherID && idea.CreatedAt >= since {
+			ideas = append(ideas, copyOfIdea(idea))
+		}
+	}
+	sort.SliceStable(ideas, func(i, j int) bool { return ideas[i].CreatedAt > ideas[j].CreatedAt })
+	if limit > 0 && int64(len(ideas)) > limit {
+		ideas = ideas[:limit]
+	}
+
+	return ideas, nil
+}
+
+func (store memoryIdeasStore) CountByPublisherSince(databaseContext context.Context, publisherID int64, since int64) (int64, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var count int64
+	for _, idea := range store.database.ideas {
+		if idea.PublisherID == publisherID && idea.CreatedAt >= since {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (store memoryIdeasStore) ListPublishedByPublishers(databaseContext context.Context, publisherIDs []int64,
+	skip int64, limit int64) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	isPublisherListed := make(map[int64]bool, len(publisherIDs))
+	for _, publisherID := range publisherIDs {
+		isPublisherListed[publisherID] = true
+	}
+
+	var ideas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		if isPublisherListed[idea.PublisherID] && idea.Archived == false && isIdeaPublic(idea) {
+			ideas = append(ideas, copyOfIdea(idea))
+		}
+	}
+	sort.SliceStable(ideas, func(i, j int) bool {
+		if ideas[i].CreatedAt != ideas[j].CreatedAt {
+			return ideas[i].CreatedAt > ideas[j].CreatedAt
+		}
+		return ideas[i].ID.Hex() > ideas[j].ID.Hex()
+	})
+
+	if skip >= int64(len(ideas)) {
+		return nil, nil
+	}
+	ideas = ideas[skip:]
+	if int64(len(ideas)) > limit {
+		ideas = ideas[:limit]
+	}
+	return ideas, nil
+}
+
+func (store memoryIdeasStore) Insert(databaseContext context.Context, idea IdeaStructure) (primitive.ObjectID, error) {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	idea.ID = primitive.NewObjectID()
+	store.database.ideas = append(store.database.ideas, copyOfIdea(idea))
+	return idea.ID, nil
+}
+
+func (store memoryIdeasStore) UpdateContent(databaseContext context.Context, ideaID primitive.ObjectID, contentUpdate IdeaContentUpdate) (IdeaStructure, error) {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return IdeaStructure{}, errNotFoundInStore
+	}
+	idea := &store.database.ideas[ideaIndex]
+	if contentUpdate.ExpectedVersion != nil && *contentUpdate.ExpectedVersion != idea.Version {
+		return IdeaStructure{}, errConflictInStore
+	}
+
+	idea.Version++
+	idea.UpdatedAt = contentUpdate.UpdatedAt
+	if contentUpdate.Name != nil {
+		idea.Name = *contentUpdate.Name
+		idea.Slug = *contentUpdate.Slug
+	}
+	if contentUpdate.Description != nil {
+		idea.Description = *contentUpdate.Description
+	}
+	if contentUpdate.Visibility != nil {
+		idea.Visibility = *contentUpdate.Visibility
+	}
+	if contentUpdate.RepoURL != nil {
+		idea.RepoURL = *contentUpdate.RepoURL
+		idea.Repo = nil
+	}
+	return copyOfIdea(*idea), nil
+}
+
+func (store memoryIdeasStore) AddCollaborator(databaseContext context.Context, ideaID primitive.ObjectID,
+	collaborator IdeaCollaboratorStructure, maxCollaborators int) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errDuplicateInStore
+	}
+	idea := &store.database.ideas[ideaIndex]
+	if isCollaboratorOfIdea(*idea, collaborator.UserID) || len(idea.Collaborators) >= maxCollaborators {
+		return errDuplicateInStore
+	}
+
+	idea.Collaborators = append(idea.Collaborators, collaborator)
+	return nil
+}
+
+func (store memoryIdeasStore) RemoveCollaborator(databaseContext context.Context, ideaID primitive.ObjectID, userID int64) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 || isCollaboratorOfIdea(store.database.ideas[ideaIndex], userID) == false {
+		return errNotFoundInStore
+	}
+	idea := &store.database.ideas[ideaIndex]
+
+	remainingCollaborators := []IdeaCollaboratorStructure{}
+	for _, collaborator := range idea.Collaborators {
+		if collaborator.UserID != userID {
+			remainingCollaborators = append(remainingCollaborators, collaborator)
+		}
+	}
+	idea.Collaborators = remainingCollaborators
+	return nil
+}
+
+func (store memoryIdeasStore) AddImage(databaseContext context.Context, ideaID primitive.ObjectID, image IdeaImageStructure,
+	maxImages int64, updatedAt int64) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errDuplicateInStore
+	}
+	idea := &store.database.ideas[ideaIndex]
+	if int64(len(idea.Images)) >= maxImages {
+		return errDuplicateInStore
+	}
+	for _, existingImage := range idea.Images {
+		if existingImage.Hash == image.Hash {
+			return errDuplicateInStore
+		}
+	}
+
+	idea.Images = append(idea.Images, image)
+	idea.UpdatedAt = updatedAt
+	return nil
+}
+
+func (store memoryIdeasStore) RemoveImage(databaseContext context.Context, ideaID primitive.ObjectID, imageHash string, updatedAt int64) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errNotFoundInStore
+	}
+	idea := &store.database.ideas[ideaIndex]
+
+	remainingImages := []IdeaImageStructure{}
+	for _, image := range idea.Images {
+		if image.Hash != imageHash {
+			remainingImages = append(remainingImages, image)
+		}
+	}
+	if len(remainingImages) == len(idea.Images) {
+		return errNotFoundInStore
+	}
+	idea.Images = remainingImages
+	idea.UpdatedAt = updatedAt
+	return nil
+}
+
+func (store memoryIdeasStore) SetFeatured(databaseContext context.Context, ideaID primitive.ObjectID, featured bool, featuredAt int64) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errNotFoundInStore
+	}
+	store.database.ideas[ideaIndex].Featured = featured
+	store.database.ideas[ideaIndex].FeaturedAt = featuredAt
+	return nil
+}
+
+func (store memoryIdeasStore) SetArchived(databaseContext context.Context, ideaID primitive.ObjectID, archived bool, archivedAt int64) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errNotFoundInStore
+	}
+	store.database.ideas[ideaIndex].Archived = archived
+	store.database.ideas[ideaIndex].ArchivedAt = archivedAt
+	return nil
+}
+
+func (store memoryIdeasStore) SetGithubIssueURL(databaseContext context.Context, ideaID primitive.ObjectID, issueURL string) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errNotFoundInStore
+	}
+	store.database.ideas[ideaIndex].GithubIssueURL = issueURL
+	return nil
+}
+
+func (store memoryIdeasStore) SetHeldForReview(databaseContext context.Context, ideaID primitive.ObjectID, held bool) (bool, error) {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return false, errNotFoundInStore
+	}
+	if store.database.ideas[ideaIndex].HeldForReview == held {
+		return false, nil
+	}
+	store.database.ideas[ideaIndex].HeldForReview = held
+	return true, nil
+}
+
+func (store memoryIdeasStore) ListWithStaleRepo(databaseContext context.Context, staleBefore int64, limit int64) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	syncedAtOf := func(idea IdeaStructure) int64 {
+		if idea.Repo == nil {
+			return 0
+		}
+		return idea.Repo.SyncedAt
+	}
+
+	var ideas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		if idea.RepoURL != "" && (idea.Repo == nil || idea.Repo.SyncedAt < staleBefore) {
+			ideas = append(ideas, copyOfIdea(idea))
+		}
+	}
+	sort.SliceStable(ideas, func(i, j int) bool { return syncedAtOf(ideas[i]) < syncedAtOf(ideas[j]) })
+	if int64(len(ideas)) > limit {
+		ideas = ideas[:limit]
+	}
+	return ideas, nil
+}
+
+func (store memoryIdeasStore) SetRepo(databaseContext context.Context, ideaID primitive.ObjectID, repo RepoMetadataStructure) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errNotFoundInStore
+	}
+	store.database.ideas[ideaIndex].Repo = &repo
+	return nil
+}
+
+func (store memoryIdeasStore) SetLaunched(databaseContext context.Context, ideaID primitive.ObjectID, launch IdeaLaunchStructure) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errNotFoundInStore
+	}
+	store.database.ideas[ideaIndex].Status = ideaStatusLaunched
+	store.database.ideas[ideaIndex].Launch = &launch
+	return nil
+}
+
+func (store memoryIdeasStore) ListLaunched(databaseContext context.Context, skip int64, limit int64) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var ideas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		if idea.Status == ideaStatusLaunched && idea.Archived == false && isIdeaPublic(idea) {
+			ideas = append(ideas, copyOfIdea(idea))
+		}
+	}
+	launchedAtOf := func(idea IdeaStructure) int64 {
+		if idea.Launch == nil {
+			return 0
+		}
+		return idea.Launch.LaunchedAt
+	}
+	sort.SliceStable(ideas, func(i, j int) bool {
+		if launchedAtOf(ideas[i]) != launchedAtOf(ideas[j]) {
+			return launchedAtOf(ideas[i]) > launchedAtOf(ideas[j])
+		}
+		return ideas[i].ID.Hex() > ideas[j].ID.Hex()
+	})
+
+	if skip >= int64(len(ideas)) {
+		return nil, nil
+	}
+	ideas = ideas[skip:]
+	if int64(len(ideas)) > limit {
+		ideas = ideas[:limit]
+	}
+	return ideas, nil
+}
+
+func (store memoryIdeasStore) ListFeatured(databaseContext context.Context) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var ideas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		if idea.Featured == true && idea.Archived == false && isIdeaPublic(idea) {
+			ideas = append(ideas, copyOfIdea(idea))
+		}
+	}
+	sort.SliceStable(ideas, func(i, j int) bool { return ideas[i].FeaturedAt > ideas[j].FeaturedAt })
+
+	return ideas, nil
+}
+
+// SearchPublished : Ideas are ranked by how many of the words they have, a word in the name counts three times
+func (store memoryIdeasStore) SearchPublished(databaseContext context.Context, words []string, limit int64) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var matchingIdeas []IdeaStructure
+	scoreOfIdea := make(map[primitive.ObjectID]int)
+	for _, idea := range store.database.ideas {
+		if isIdeaPublic(idea) == false {
+			continue
+		}
+		nameWords := wordsOf(idea.Name)
+		descriptionWords := wordsOf(idea.Description)
+		for _, word := range words {
+			word = strings.ToLower(word)
+			if nameWords[word] == true {
+				scoreOfIdea[idea.ID] += 3
+			}
+			if descriptionWords[word] == true {
+				scoreOfIdea[idea.ID]++
+			}
+		}
+		if scoreOfIdea[idea.ID] > 0 {
+			matchingIdeas = append(matchingIdeas, copyOfIdea(idea))
+		}
+	}
+	sort.SliceStable(matchingIdeas, func(i, j int) bool { return scoreOfIdea[matchingIdeas[i].ID] > scoreOfIdea[matchingIdeas[j].ID] })
+	if int64(len(matchingIdeas)) > limit {
+		matchingIdeas = matchingIdeas[:limit]
+	}
+
+	return matchingIdeas, nil
+}
+
+func wordsOf(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range wordsInTextRegex.FindAllString(strings.ToLower(text), -1) {
+		words[word] = true
+	}
+	return words
+}
+
+func (store memoryIdeasStore) SuggestByPrefix(databaseContext context.Context, slugPrefix string,
+	limit int64) ([]IdeaSuggestionStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var matchingIdeas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		if isIdeaPublic(idea) && idea.Archived == false && strings.HasPrefix(idea.Slug, slugPrefix) {
+			matchingIdeas = append(matchingIdeas, idea)
+		}
+	}
+	sort.SliceStable(matchingIdeas, func(i, j int) bool { return matchingIdeas[i].Gazers > matchingIdeas[j].Gazers })
+
+	var suggestions []IdeaSuggestionStructure
+	for _, idea := range matchingIdeas {
+		if limit > 0 && int64(len(suggestions)) >= limit {
+			break
+		}
+		suggestions = append(suggestions, IdeaSuggestionStructure{ID: idea.ID, Name: idea.Name, Slug: idea.Slug})
+	}
+
+	return suggestions, nil
+}
+
+func (store memoryIdeasStore) Delete(databaseContext context.Context, ideaID primitive.ObjectID) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return errNotFoundInStore
+	}
+	store.database.ideas = append(store.database.ideas[:ideaIndex], store.database.ideas[ideaIndex+1:]...)
+	return nil
+}
+
+func (store memoryIdeasStore) IncrementGazers(databaseContext context.Context, ideaID primitive.ObjectID, increment int64) (int64, error) {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex < 0 {
+		return 0, errNotFoundInStore
+	}
+	store.database.ideas[ideaIndex].Gazers += increment
+	store.database.ideas[ideaIndex].GazesLast7d += increment
+	store.database.ideas[ideaIndex].TrendingScore += float64(increment)
+	return store.database.ideas[ideaIndex].Gazers, nil
+}
+
+func (store memoryIdeasStore) AddViews(databaseContext context.Context, viewsOfIdeas map[primitive.ObjectID]int64) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	for ideaID, views := range viewsOfIdeas {
+		ideaIndex := store.database.indexOfIdea(ideaID)
+		if ideaIndex >= 0 {
+			store.database.ideas[ideaIndex].Views += views
+		}
+	}
+	return nil
+}
+
+func (store memoryIdeasStore) IncrementReaction(databaseContext context.Context, ideaID primitive.ObjectID, reaction string,
+	increment int64) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	ideaIndex := store.database.indexOfIdea(ideaID)
+	if ideaIndex >= 0 {
+		if store.database.ideas[ideaIndex].Reactions == nil {
+			store.database.ideas[ideaIndex].Reactions = make(map[string]int64)
+		}
+		store.database.ideas[ideaIndex].Reactions[reaction] += increment
+	}
+	return nil
+}
+
+func (store memoryIdeasStore) ListByMomentum(databaseContext context.Context, sortBy string, limit int64) ([]IdeaStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var ideas []IdeaStructure
+	for _, idea := range store.database.ideas {
+		if idea.GazesLast7d > 0 && idea.Archived == false && isIdeaPublic(idea) {
+			ideas = append(ideas, copyOfIdea(idea))
+		}
+	}
+	// Newest first among ties, like the id tie break of the other stores
+	sort.SliceStable(ideas, func(i, j int) bool { return ideas[i].ID.Hex() > ideas[j].ID.Hex() })
+	sort.SliceStable(ideas, func(i, j int) bool {
+		if sortBy == ideaSortRising {
+			return ideas[i].GazesLast7d > ideas[j].GazesLast7d
+		}
+		return ideas[i].TrendingScore > ideas[j].TrendingScore
+	})
+	if int64(len(ideas)) > limit {
+		ideas = ideas[:limit]
+	}
+
+	return ideas, nil
+}
+
+func (store memoryIdeasStore) RecomputeMomentum(databaseContext context.Context, now int64) (int64, error) {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	since := now - int64(trendingWindow/time.Second)
+	gazesOfIdea := make(map[primitive.ObjectID]int64)
+	scoreOfIdea := make(map[primitive.ObjectID]float64)
+	for _, like := range store.database.likes {
+		if like.CreatedAt < since {
+			continue
+		}
+		gazesOfIdea[like.IdeaID]++
+		scoreOfIdea[like.IdeaID] += math.Pow(0.5, float64(now-like.CreatedAt)/float64(trendingHalfLife/time.Second))
+	}
+
+	var updatedIdeas int64
+	for ideaIndex := range store.database.ideas {
+		idea := &store.database.ideas[ideaIndex]
+		if idea.GazesLast7d != gazesOfIdea[idea.ID] || idea.TrendingScore != scoreOfIdea[idea.ID] {
+			idea.GazesLast7d = gazesOfIdea[idea.ID]
+			idea.TrendingScore = scoreOfIdea[idea.ID]
+			updatedIdeas++
+		}
+	}
+
+	return updatedIdeas, nil
+}
+
+func (store memoryUsersStore) FindByUserID(databaseContext context.Context, userID int64) (UserStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	user, isFound := store.database.users[userID]
+	if isFound == false {
+		return user, errNotFoundInStore
+	}
+	return user, nil
+}
+
+func (store memoryUsersStore) FindByLogin(databaseContext context.Context, login string) (UserStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	for _, user := range store.database.users {
+		if user.Login == login {
+			return user, nil
+		}
+	}
+	return UserStructure{}, errNotFoundInStore
+}
+
+func (store memoryUsersStore) Insert(databaseContext context.Context, user UserStructure) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	if _, isFound := store.database.users[user.UserID]; isFound {
+		return errDuplicateInStore
+	}
+	// Same fields as the other stores keep for a new user
+	store.database.users[user.UserID] = UserStructure{UserID: user.UserID, Login: user.Login, Name: user.Name,
+		CreatedAt: user.CreatedAt, Role: user.Role}
+	return nil
+}
+
+func (store memoryUsersStore) UpdateSettings(databaseContext context.Context, userID int64, settings UserSettingsStructure) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	user, isFound := store.database.users[userID]
+	if isFound == false {
+		return errNotFoundInStore
+	}
+	user.DisplayName = settings.DisplayName
+	user.Bio = settings.Bio
+	user.Website = settings.Website
+	user.Location = settings.Location
+	store.database.users[userID] = user
+	return nil
+}
+
+func (store memoryUsersStore) UpdateSuspension(databaseContext context.Context, userID int64, suspension UserSuspensionStructure) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	user, isFound := store.database.users[userID]
+	if isFound == false {
+		return errNotFoundInStore
+	}
+	user.Banned = suspension.Banned
+	user.SuspendedUntil = suspension.SuspendedUntil
+	user.SuspensionReason = suspension.SuspensionReason
+	store.database.users[userID] = user
+	return nil
+}
+
+func (store memoryUsersStore) UpdateRole(databaseContext context.Context, userID int64, role string) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	user, isFound := store.database.users[userID]
+	if isFound == false {
+		return errNotFoundInStore
+	}
+	user.Role = role
+	store.database.users[userID] = user
+	return nil
+}
+
+func (store memoryUsersStore) DeleteAccount(databaseContext context.Context, userID int64, deleteIdeas bool,
+	now int64) ([]primitive.ObjectID, error) {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	var keptLikes []IdeaLikesStructure
+	for _, like := range store.database.likes {
+		if like.UserID != userID {
+			keptLikes = append(keptLikes, like)
+			continue
+		}
+		ideaIndex := store.database.indexOfIdea(like.IdeaID)
+		if ideaIndex < 0 {
+			continue
+		}
+		gazes, score := momentumOfGaze(like.CreatedAt, now)
+		gazedIdea := &store.database.ideas[ideaIndex]
+		gazedIdea.Gazers--
+		gazedIdea.GazesLast7d -= gazes
+		gazedIdea.TrendingScore = math.Max(gazedIdea.TrendingScore-score, 0)
+		if like.Reaction != "" && gazedIdea.Reactions != nil {
+			gazedIdea.Reactions[like.Reaction]--
+		}
+	}
+	store.database.likes = keptLikes
+
+	var keptIdeas []IdeaStructure
+	isIdeaDeleted := make(map[primitive.ObjectID]bool)
+	for _, idea := range store.database.ideas {
+		var keptCollaborators []IdeaCollaboratorStructure
+		for _, collaborator := range idea.Collaborators {
+			if collaborator.UserID != userID {
+				keptCollaborators = append(keptCollaborators, collaborator)
+			}
+		}
+		idea.Collaborators = keptCollaborators
+
+		if idea.PublisherID == userID {
+			switch {
+			case idea.Org != "":
+				idea.Author = deletedUserLogin
+				idea.PublisherID = 0
+			case deleteIdeas == true:
+				isIdeaDeleted[idea.ID] = true
+				continue
+			default:
+				idea.Publisher = deletedUserLogin
+				idea.PublisherID = 0
+			}
+		}
+		keptIdeas = append(keptIdeas, idea)
+	}
+	store.database.ideas = keptIdeas
+
+	var deletedIdeaIDs []primitive.ObjectID
+	keptLikes = nil
+	for _, like := range store.database.likes {
+		if isIdeaDeleted[like.IdeaID] == false {
+			keptLikes = append(keptLikes, like)
+		}
+	}
+	store.database.likes = keptLikes
+	for ideaID := range isIdeaDeleted {
+		deletedIdeaIDs = append(deletedIdeaIDs, ideaID)
+	}
+
+	delete(store.database.users, userID)
+	return deletedIdeaIDs, nil
+}
+
+func (store memoryLikesStore) Insert(databaseContext context.Context, like IdeaLikesStructure) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	for _, existingLike := range store.database.likes {
+		if existingLike.UserID == like.UserID && existingLike.IdeaID == like.IdeaID {
+			return errDuplicateInStore
+		}
+	}
+	store.database.likes = append(store.database.likes, like)
+	return nil
+}
+
+func (store memoryLikesStore) Find(databaseContext context.Context, userID int64, ideaID primitive.ObjectID) (IdeaLikesStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	for _, like := range store.database.likes {
+		if like.UserID == userID && like.IdeaID == ideaID {
+			return like, nil
+		}
+	}
+	return IdeaLikesStructure{}, errNotFoundInStore
+}
+
+func (store memoryLikesStore) UpdateReaction(databaseContext context.Context, userID int64, ideaID primitive.ObjectID,
+	fromReaction string, toReaction string) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	for likeIndex, like := range store.database.likes {
+		if like.UserID == userID && like.IdeaID == ideaID && like.Reaction == fromReaction {
+			store.database.likes[likeIndex].Reaction = toReaction
+			return nil
+		}
+	}
+	return errNotFoundInStore
+}
+
+func (store memoryLikesStore) Delete(databaseContext context.Context, userID int64, ideaID primitive.ObjectID) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	for likeIndex, like := range store.database.likes {
+		if like.UserID == userID && like.IdeaID == ideaID {
+			store.database.likes = append(store.database.likes[:likeIndex], store.database.likes[likeIndex+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (store memoryLikesStore) DeleteByIdea(databaseContext context.Context, ideaID primitive.ObjectID) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	var keptLikes []IdeaLikesStructure
+	for _, like := range store.database.likes {
+		if like.IdeaID != ideaID {
+			keptLikes = append(keptLikes, like)
+		}
+	}
+	store.database.likes = keptLikes
+	return nil
+}
+
+func (store memoryLikesStore) ListByUser(databaseContext context.Context, userID int64) ([]IdeaLikesStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	var likes []IdeaLikesStructure
+	for _, like := range store.database.likes {
+		if like.UserID == userID {
+			likes = append(likes, like)
+		}
+	}
+	return likes, nil
+}
+
+func (store memoryLikesStore) ListGazedAmong(databaseContext context.Context, userID int64,
+	ideaIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	askedIdeaIDs := make(map[primitive.ObjectID]bool, len(ideaIDs))
+	for _, ideaID := range ideaIDs {
+		askedIdeaIDs[ideaID] = true
+	}
+
+	var gazedIdeaIDs []primitive.ObjectID
+	for _, like := range store.database.likes {
+		if like.UserID == userID && askedIdeaIDs[like.IdeaID] == true {
+			gazedIdeaIDs = append(gazedIdeaIDs, like.IdeaID)
+		}
+	}
+	return gazedIdeaIDs, nil
+}
+
+// Insert : Expired sessions are dropped on each insert, so they do not pile up while the process runs
+func (store memorySessionsStore) Insert(databaseContext context.Context, session SessionStructure) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	now := time.Now()
+	for tokenHash, storedSession := range store.database.sessions {
+		if now.After(storedSession.ExpiresAt) {
+			delete(store.database.sessions, tokenHash)
+		}
+	}
+	if _, isStored := store.database.sessions[session.TokenHash]; isStored == true {
+		return errDuplicateInStore
+	}
+	store.database.sessions[session.TokenHash] = session
+	return nil
+}
+
+func (store memorySessionsStore) FindByTokenHash(databaseContext context.Context, tokenHash string) (SessionStructure, error) {
+	store.database.mutex.RLock()
+	defer store.database.mutex.RUnlock()
+
+	session, isStored := store.database.sessions[tokenHash]
+	if isStored == false {
+		return session, errNotFoundInStore
+	}
+	return session, nil
+}
+
+func (store memorySessionsStore) Delete(databaseContext context.Context, tokenHash string) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	if _, isStored := store.database.sessions[tokenHash]; isStored == false {
+		return errNotFoundInStore
+	}
+	delete(store.database.sessions, tokenHash)
+	return nil
+}
+
+func (store memorySessionsStore) DeleteByUser(databaseContext context.Context, userID int64) error {
+	store.database.mutex.Lock()
+	defer store.database.mutex.Unlock()
+
+	for tokenHash, session := range store.database.sessions {
+		if session.UserID == userID {
+			delete(store.database.sessions, tokenHash)
+		}
+	}
+	return nil
+}
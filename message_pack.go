@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+)
+
+// Largest MessagePack request body turned into JSON, no JSON endpoint takes anything near it
+const maxMessagePackBodySize = 1 << 20
+
+// messagePackHandle : Strings are read back as strings and maps with string keys, so they turn into JSON objects
+var messagePackHandle = func() *codec.MsgpackHandle {
+	handle := &codec.MsgpackHandle{}
+	handle.WriteExt = true
+	handle.RawToString = true
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return handle
+}()
+
+// messagePackWriter : Holds back JSON bodies so they can be sent as MessagePack once the handler is done,
+// anything else is passed through as it is written
+type messagePackWriter struct {
+	gin.ResponseWriter
+	body        bytes.Buffer
+	isDecided   bool
+	isBuffering bool
+}
+
+func (responseWriter *messagePackWriter) Write(data []byte) (int, error) {
+	if responseWriter.isDecided == false {
+		responseWriter.isDecided = true
+		// Compressed bodies are only written by the admin routes, they stay JSON
+		responseWriter.isBuffering = strings.HasPrefix(responseWriter.Header().Get("Content-Type"), gin.MIMEJSON) &&
+			responseWriter.Header().Get("Content-Encoding") == ""
+	}
+	if responseWriter.isBuffering == true {
+		return responseWriter.body.Write(data)
+	}
+	return responseWriter.ResponseWriter.Write(data)
+}
+
+func (responseWriter *messagePackWriter) WriteString(data string) (int, error) {
+	return responseWriter.Write([]byte(data))
+}
+
+// Flush : Streamed exports cannot be held back, what is buffered goes out as JSON and the rest follows as it is written
+func (responseWriter *messagePackWriter) Flush() {
+	if responseWriter.isBuffering == true {
+		responseWriter.isBuffering = false
+		responseWriter.ResponseWriter.Write(responseWriter.body.Bytes())
+		responseWriter.body.Reset()
+	}
+	responseWriter.ResponseWriter.Flush()
+}
+
+// finish : Sends the held back JSON body as MessagePack, or as it is when it cannot be read
+func (responseWriter *messagePackWriter) finish() {
+	if responseWriter.isBuffering == false {
+		return
+	}
+
+	messagePackBody, errInEncoding := jsonToMessagePack(responseWriter.body.Bytes())
+	if errInEncoding != nil {
+		responseWriter.ResponseWriter.Write(responseWriter.body.Bytes())
+		return
+	}
+	responseWriter.Header().Set("Content-Type", binding.MIMEMSGPACK2)
+	responseWriter.ResponseWriter.Write(messagePackBody)
+}
+
+// jsonToMessagePack : Numbers without a fraction are written as integers, so ids and counters stay compact
+func jsonToMessagePack(jsonBody []byte) ([]byte, error) {
+	jsonDecoder := json.NewDecoder(bytes.NewReader(jsonBody))
+	jsonDecoder.UseNumber()
+
+	var document interface{}
+	errInDecoding := jsonDecoder.Decode(&document)
+	if errInDecoding != nil {
+		return nil, errInDecoding
+	}
+
+	var messagePackBody []byte
+	errInEncoding := codec.NewEncoderBytes(&messagePackBody, messagePackHandle).Encode(withTypedNumbers(document))
+	return messagePackBody, errInEncoding
+}
+
+func withTypedNumbers(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, nestedValue := range typedValue {
+			typedValue[key] = withTypedNumbers(nestedValue)
+		}
+	case []interface{}:
+		for index, nestedValue := range typedValue {
+			typedValue[index] = withTypedNumbers(nestedValue)
+		}
+	case json.Number:
+		if integer, errInParsing := strconv.ParseInt(string(typedValue), 10, 64); errInParsing == nil {
+			return integer
+		}
+		float, _ := typedValue.Float64()
+		return float
+	}
+	return value
+}
+
+func isMessagePack(mimeType string) bool {
+	return mimeType == binding.MIMEMSGPACK || mimeType == binding.MIMEMSGPACK2
+}
+
+// negotiateMessagePack : Handlers only speak JSON, MessagePack request bodies are turned into JSON before they bind
+// and JSON responses into MessagePack when the client accepts it ahead of JSON
+func negotiateMessagePack() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		ginContext.Header("Vary", "Accept")
+		if isMessagePack(ginContext.NegotiateFormat(gin.MIMEJSON, binding.MIMEMSGPACK2, binding.MIMEMSGPACK)) {
+			messagePackWriter := &messagePackWriter{ResponseWriter: ginContext.Writer}
+			ginContext.Writer = messagePackWriter
+			defer messagePackWriter.finish()
+		}
+
+		if isMessagePack(ginContext.ContentType()) {
+			messagePackBody, errInReadingBody := ioutil.ReadAll(io.LimitReader(ginContext.Request.Body, maxMessagePackBodySize+1))
+			if errInReadingBody != nil || len(messagePackBody) > maxMessagePackBodySize {
+				ginContext.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"status": http.StatusRequestEntityTooLarge,
+					"error": "MessagePack body should be at most " + strconv.Itoa(maxMessagePackBodySize) + " bytes"})
+				return
+			}
+
+			var document interface{}
+			errInDecoding := codec.NewDecoderBytes(messagePackBody, messagePackHandle).Decode(&document)
+			var jsonBody []byte
+			if errInDecoding == nil {
+				jsonBody, errInDecoding = json.Marshal(document)
+			}
+			if errInDecoding != nil {
+				ginContext.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+					"error": "Error, Body is not valid MessagePack", "errorDetails": errInDecoding.Error()})
+				return
+			}
+
+			ginContext.Request.Body = ioutil.NopCloser(bytes.NewReader(jsonBody))
+			ginContext.Request.ContentLength = int64(len(jsonBody))
+			ginContext.Request.Header.Set("Content-Type", gin.MIMEJSON)
+		}
+
+		ginContext.Next()
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errMigrationsLocked = errors.New("Migrations are being applied by another instance")
+
+// MigrationConfig : Limits of migrations, backfills go in batches with pauses so serving traffic isn't starved
+type MigrationConfig struct {
+	BatchSize     int64
+	BatchPause    time.Duration
+	LeaseDuration time.Duration
+	Timeout       time.Duration
+}
+
+// Migration : One change to the shape of stored documents, applied once per database in version order
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(context.Context, *mongo.Database, MigrationConfig) error
+}
+
+// AppliedMigrationStructure : Record in migrations collection of a migration that has run
+type AppliedMigrationStructure struct {
+	Version   int64  `json:"version" bson:"_id"`
+	Name      string `json:"name" bson:"name"`
+	AppliedAt int64  `json:"applied_at" bson:"applied_at"`
+}
+
+// migrations : New migrations are appended with the next version, applied ones are never edited
+var migrations = []Migration{
+	{Version: 1, Name: "backfill ideas updated_at from created_at", Up: backfillIdeasUpdatedAt},
+	{Version: 2, Name: "backfill ideas slug from name", Up: backfillIdeasSlug},
+	{Version: 3, Name: "backfill ideas status as open", Up: backfillIdeasStatus},
+	{Version: 4, Name: "backfill ideas visibility as public", Up: backfillIdeasVisibility},
+	{Version: 5, Name: "backfill likes reaction as eyes", Up: backfillLikesReaction},
+	{Version: 6, Name: "backfill ideas reactions from gazers", Up: backfillIdeasReactions},
+}
+
+func loadMigrationConfig(configLoader *ConfigLoader) MigrationConfig {
+	var migrationConfig MigrationConfig
+
+	migrationConfig.BatchSize = configLoader.Int("MIGRATION_BATCH_SIZE", 500)
+	if migrationConfig.BatchSize <= 0 {
+		configLoader.Invalid("MIGRATION_BATCH_SIZE", "should be more than 0")
+	}
+	migrationConfig.BatchPause = time.Duration(configLoader.Int("MIGRATION_BATCH_PAUSE_MS", 200)) * time.Millisecond
+	migrationConfig.LeaseDuration = 2 * time.Minute
+	migrationConfig.Timeout = time.Duration(configLoader.Int("MIGRATION_TIMEOUT_MINUTES", 60)) * time.Minute
+
+	return migrationConfig
+}
+
+// backfillInBatches : Walks the documents matching filter in _id order, a batch at a time with a pause in between
+func backfillInBatches(databaseContext context.Context, collection *mongo.Collection, filter bson.M, migrationConfig MigrationConfig,
+	updateOf func(bson.Raw) (bson.M, error)) error {
+	var lastID interface{}
+	var backfilledCount int64
+
+	for {
+		batchFilter := bson.M{"$and": bson.A{filter}}
+		if lastID != nil {
+			batchFilter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$gt": lastID}}}}
+		}
+		findOptions := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(migrationConfig.BatchSize)
+
+		batchCursor, errInFinding := collection.Find(databaseContext, batchFilter, findOptions)
+		if errInFinding != nil {
+			return errInFinding
+		}
+
+		var documentsInBatch int64
+		for batchCursor.Next(databaseContext) {
+			documentsInBatch++
+			lastID = batchCursor.Current.Lookup("_id")
+
+			documentUpdate, errInUpdateOf := updateOf(batchCursor.Current)
+			if errInUpdateOf != nil {
+				batchCursor.Close(databaseContext)
+				return errInUpdateOf
+			}
+
+			_, errInUpdating := collection.UpdateOne(databaseContext, bson.M{"_id": lastID}, documentUpdate)
+			if errInUpdating != nil {
+				batchCursor.Close(databaseContext)
+				return errInUpdating
+			}
+		}
+		errInCursor := batchCursor.Err()
+		batchCursor.Close(databaseContext)
+		if errInCursor != nil {
+			return errInCursor
+		}
+
+		backfilledCount = backfilledCount + documentsInBatch
+		if documentsInBatch < migrationConfig.BatchSize {
+			log.Println("Backfilled", backfilledCount, "documents of", collection.Name())
+			return nil
+		}
+
+		select {
+		case <-time.After(migrationConfig.BatchPause):
+		case <-databaseContext.Done():
+			return databaseContext.Err()
+		}
+	}
+}
+
+func backfillIdeasUpdatedAt(databaseContext context.Context, sardeneDatabase *mongo.Database, migrationConfig MigrationConfig) error {
+	ideasCollection := sardeneDatabase.Collection("ideas")
+
+	return backfillInBatches(databaseContext, ideasCollection, bson.M{"updated_at": bson.M{"$exists": false}}, migrationConfig,
+		func(idea bson.Raw) (bson.M, error) {
+			var ideaCreation struct {
+				CreatedAt int64 `bson:"created_at"`
+			}
+			errInDecoding := bson.Unmarshal(idea, &ideaCreation)
+			return bson.M{"$set": bson.M{"updated_at": ideaCreation.CreatedAt}}, errInDecoding
+		})
+}
+
+func backfillIdeasSlug(databaseContext context.Context, sardeneDatabase *mongo.Database, migrationConfig MigrationConfig) error {
+	ideasCollection := sardeneDatabase.Collection("ideas")
+
+	return backfillInBatches(databaseContext, ideasCollection, bson.M{"slug": bson.M{"$exists": false}}, migrationConfig,
+		func(idea bson.Raw) (bson.M, error) {
+			var ideaName struct {
+				Name string `bson:"name"`
+			}
+			errInDecoding := bson.Unmarshal(idea, &ideaName)
+			return bson.M{"$set": bson.M{"slug": slugOf(ideaName.Name)}}, errInDecoding
+		})
+}
+
+func backfillIdeasStatus(databaseContext context.Context, sardeneDatabase *mongo.Database, migrationConfig MigrationConfig) error {
+	ideasCollection := sardeneDatabase.Collection("ideas")
+
+	return backfillInBatches(databaseContext, ideasCollection, bson.M{"status": bson.M{"$exists": false}}, migrationConfig,
+		func(idea bson.Raw) (bson.M, error) {
+			return bson.M{"$set": bson.M{"status": ideaStatusOpen}}, nil
+		})
+}
+
+func backfillIdeasVisibility(databaseContext context.Context, sardeneDatabase *mongo.Database, migrationConfig MigrationConfig) error {
+	ideasCollection := sardeneDatabase.Collection("ideas")
+
+	return backfillInBatches(databaseContext, ideasCollection, bson.M{"visibility": bson.M{"$exists": false}}, migrationConfig,
+		func(idea bson.Raw) (bson.M, error) {
+			return bson.M{"$set": bson.M{"visibility": ideaVisibilityPublic}}, nil
+		})
+}
+
+// backfillLikesReaction : Gazes from before reactions are eyes reactions
+func backfillLikesReaction(databaseContext context.Context, sardeneDatabase *mongo.Database, migrationConfig MigrationConfig) error {
+	likesCollection := sardeneDatabase.Collection("likes")
+
+	return backfillInBatches(databaseContext, likesCollection, bson.M{"reaction": bson.M{"$exists": false}}, migrationConfig,
+		func(like bson.Raw) (bson.M, error) {
+			return bson.M{"$set": bson.M{"reaction": reactionEyes}}, nil
+		})
+}
+
+// backfillIdeasReactions : Every gaze so far is an eyes reaction, so the eyes count starts as gazers
+func backfillIdeasReactions(databaseContext context.Context, sardeneDatabase *mongo.Database, migrationConfig MigrationConfig) error {
+	ideasCollection := sardeneDatabase.Collection("ideas")
+
+	return backfillInBatches(databaseContext, ideasCollection, bson.M{"reactions": bson.M{"$exists": false}}, migrationConfig,
+		func(idea bson.Raw) (bson.M, error) {
+			var ideaGazers struct {
+				Gazers int64 `bson:"gazers"`
+			}
+			errInDecoding := bson.Unmarshal(idea, &ideaGazers)
+			return bson.M{"$set": bson.M{"reactions": bson.M{reactionEyes: ideaGazers.Gazers}}}, errInDecoding
+		})
+}
+
+func getAppliedMigrationVersions(databaseContext context.Context, migrationsCollection *mongo.Collection) (map[int64]bool, error) {
+	appliedVersions := make(map[int64]bool)
+
+	migrationsCursor, errInFinding := migrationsCollection.Find(databaseContext, bson.M{}, options.Find())
+	if errInFinding != nil {
+		return appliedVersions, errInFinding
+	}
+	defer migrationsCursor.Close(databaseContext)
+
+	for migrationsCursor.Next(databaseContext) {
+		var appliedMigration AppliedMigrationStructure
+		errInDecoding := migrationsCursor.Decode(&appliedMigration)
+		if errInDecoding != nil {
+			return appliedVersions, errInDecoding
+		}
+		appliedVersions[appliedMigration.Version] = true
+	}
+
+	return appliedVersions, migrationsCursor.Err()
+}
+
+const migrationsLeaseName = "migrations"
+
+// runMigrations : Applies pending migrations in order, each one is recorded only after it has finished
+func runMigrations(databaseClient *mongo.Client, migrationConfig MigrationConfig) error {
+	sardeneDatabase := databaseClient.Database("sardene-db")
+	migrationsCollection := sardeneDatabase.Collection("migrations")
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), migrationConfig.Timeout)
+	defer cancelDBContext()
+
+	// Only one instance migrates, the others keep serving with code that handles both document shapes
+	isLeaseAcquired, errInAcquiring := acquireLease(databaseContext, databaseClient, migrationsLeaseName, migrationConfig.LeaseDuration)
+	if errInAcquiring != nil {
+		return errInAcquiring
+	}
+	if isLeaseAcquired == false {
+		return errMigrationsLocked
+	}
+	stopRenewingLease := make(chan struct{})
+	go keepLeaseRenewed(databaseClient, migrationsLeaseName, migrationConfig.LeaseDuration, stopRenewingLease)
+	defer func() {
+		close(stopRenewingLease)
+		releaseLease(context.Background(), databaseClient, migrationsLeaseName)
+	}()
+
+	appliedVersions, errInListing := getAppliedMigrationVersions(databaseContext, migrationsCollection)
+	if errInListing != nil {
+		return errInListing
+	}
+
+	var lastVersion int64
+	for _, migration := range migrations {
+		if migration.Version <= lastVersion {
+			return fmt.Errorf("Migration versions are not in increasing order at %d", migration.Version)
+		}
+		lastVersion = migration.Version
+
+		if appliedVersions[migration.Version] == true {
+			continue
+		}
+
+		log.Println("Applying migration", migration.Version, migration.Name)
+
+		// Migrations are written to be safe to run again if a failure stops them before they are recorded
+		errInMigrating := migration.Up(databaseContext, sardeneDatabase, migrationConfig)
+		if errInMigrating != nil {
+			return fmt.Errorf("Migration %d failed: %v", migration.Version, errInMigrating)
+		}
+
+		appliedMigration := AppliedMigrationStructure{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now().Unix(),
+		}
+		_, errInRecording := migrationsCollection.InsertOne(databaseContext, appliedMigration)
+		if errInRecording != nil {
+			return errInRecording
+		}
+
+		log.Println("Applied migration", migration.Version, migration.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	userRoleAdmin = "admin"
+
+	moderationStatusOpen     = "open"
+	moderationStatusApproved = "approved"
+	moderationStatusRejected = "rejected"
+	// Reports of vote analysis are settled by one of these
+	moderationStatusDismissed  = "dismissed"
+	moderationStatusGazesReset = "gazes_reset"
+
+	moderationReporterSystem = "system"
+)
+
+// ModerationReportStructure : Structure of report in moderation queue collection
+type ModerationReportStructure struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	IdeaID     primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+	Reasons    []string           `json:"reasons" bson:"reasons"`
+	Reporter   string             `json:"reporter" bson:"reporter"`
+	Evidence   bson.M             `json:"evidence" bson:"evidence"`
+	Status     string             `json:"status" bson:"status"`
+	CreatedAt  int64              `json:"created_at" bson:"created_at"`
+	ResolvedBy int64              `json:"resolved_by" bson:"resolved_by"`
+	ResolvedAt int64              `json:"resolved_at" bson:"resolved_at"`
+}
+
+// ModerationDecisionInput : Structure for incoming decision on a report
+type ModerationDecisionInput struct {
+	Action string `json:"action"`
+}
+
+func isUserAdmin(databaseContext context.Context, githubUser GithubUserProfileStructure, stores Stores) (bool, error) {
+
+	userInDB, errInFindingUser := stores.Users.FindByUserID(databaseContext, githubUser.UserID)
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			return false, nil
+		}
+		return false, errInFindingUser
+	}
+
+	return userInDB.Role == userRoleAdmin, nil
+}
+
+func fileModerationReport(databaseContext context.Context, databaseClient *mongo.Client, ideaID primitive.ObjectID, reasons []string, reporter string, evidence bson.M) error {
+	moderationCollection := databaseClient.Database("sardene-db").Collection("moderation_queue")
+
+	reportToAdd := bson.M{
+		"idea_id":     ideaID,
+		"reasons":     reasons,
+		"reporter":    reporter,
+		"evidence":    evidence,
+		"status":      moderationStatusOpen,
+		"created_at":  time.Now().Unix(),
+		"resolved_by": 0,
+		"resolved_at": 0,
+	}
+
+	_, errInAdding := moderationCollection.InsertOne(databaseContext, reportToAdd)
+	return errInAdding
+}
+
+func getModerationQueue(ginContext *gin.Context, databaseClient *mongo.Client) {
+
+	moderationCollection := databaseClient.Database("sardene-db").Collection("moderation_queue")
+	databaseContext := ginContext.Request.Context()
+
+	reportStatus := ginContext.DefaultQuery("status", moderationStatusOpen)
+	reportsOptions := options.Find().SetSort(bson.M{"created_at": 1})
+
+	reportsCursor, errInFinding := moderationCollection.Find(databaseContext, bson.M{"status": reportStatus}, reportsOptions)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	defer reportsCursor.Close(databaseContext)
+
+	reports := []*ModerationReportStructure{}
+	for reportsCursor.Next(databaseContext) {
+		var report ModerationReportStructure
+		errInDecoding := reportsCursor.Decode(&report)
+		if errInDecoding != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in decoding database", "errorDetails": errInDecoding.Error()})
+			return
+		}
+		reports = append(reports, &report)
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": reports, "count": len(reports)})
+}
+
+func resolveModerationReport(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, reportID string) {
+	hexReportID, errInValidatingID := primitive.ObjectIDFromHex(reportID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Report id is not valid"})
+		return
+	}
+
+	admin := getAuthenticatedUser(ginContext)
+
+	var jsonInput ModerationDecisionInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Action is missing", "errorDetails": errInInputJSON.Error()})
+		return
+	}
+
+	moderationCollection := databaseClient.Database("sardene-db").Collection("moderation_queue")
+	databaseContext := ginContext.Request.Context()
+
+	var report ModerationReportStructure
+	reportFilter := bson.M{"_id": hexReportID, "status": moderationStatusOpen}
+	errInDecodingReport := moderationCollection.FindOne(databaseContext, reportFilter).Decode(&report)
+	if errInDecodingReport != nil {
+		if errInDecodingReport.Error() == "mongo: no documents in result" {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, Open report does not exists"})
+			return
+		}
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error in decoding database", "errorDetails": errInDecodingReport.Error()})
+		return
+	}
+
+	if report.Reporter == moderationReporterVoteAnalysis {
+		resolveVoteManipulationReport(ginContext, databaseClient, stores, report, jsonInput.Action)
+		return
+	}
+
+	if jsonInput.Action != "approve" && jsonInput.Action != "reject" {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Action should be either approve or reject"})
+		return
+	}
+
+	resolvedStatus := moderationStatusApproved
+	// Reports of vote analysis are about the gazes, not the content, they stay open until decided on their own
+	settledReportsFilter := bson.M{"idea_id": report.IdeaID, "status": moderationStatusOpen,
+		"reporter": bson.M{"$ne": moderationReporterVoteAnalysis}}
+	if jsonInput.Action == "approve" {
+		isPublished, errInPublishing := stores.Ideas.SetHeldForReview(databaseContext, report.IdeaID, false)
+		if errInPublishing != nil && errInPublishing != errNotFoundInStore {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error while saving to database"})
+			return
+		}
+
+		// Reports of ideas which were never held, or were published by an earlier decision, publish nothing
+		if isPublished == true {
+			approvedIdea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, report.IdeaID)
+			if errInFindingIdea == nil {
+				errInRecordingActivity := recordActivity(databaseContext, databaseClient, activityKindIdeaPublished, approvedIdea,
+					approvedIdea.Publisher)
+				if errInRecordingActivity != nil {
+					log.Println(errInRecordingActivity, "Failed to add publishing of idea to activity", report.IdeaID.Hex())
+				}
+			}
+		}
+	} else {
+		resolvedStatus = moderationStatusRejected
+		// Nothing is left to decide on an idea which is gone, reports of vote analysis included
+		delete(settledReportsFilter, "reporter")
+
+		errInDeleting := stores.Ideas.Delete(databaseContext, report.IdeaID)
+		if errInDeleting != nil && errInDeleting != errNotFoundInStore {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error while deleting from database", "errorDetails": errInDeleting.Error()})
+			return
+		}
+		errInDeletingDependents := deleteDependentsOfIdea(databaseContext, databaseClient, stores, report.IdeaID)
+		if errInDeletingDependents != nil {
+			log.Println(errInDeletingDependents, "Failed to delete gazes, bookmarks and subscriptions of rejected idea", report.IdeaID.Hex())
+		}
+	}
+
+	// Every open report of the idea is settled by the same decision
+	errInResolving := settleModerationReports(databaseContext, moderationCollection, settledReportsFilter, resolvedStatus, admin.UserID)
+	if errInResolving != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Report " + resolvedStatus})
+}
+
+// resolveVoteManipulationReport : The idea is the target of the gazes, not their author, so it is never deleted here.
+// Either the report is dismissed or the gazes of the suspicious users are taken back
+func resolveVoteManipulationReport(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores,
+	report ModerationReportStructure, action string) {
+	if action != "dismiss" && action != "reset_gazes" {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Action should be either dismiss or reset_gazes for reports of vote analysis"})
+		return
+	}
+
+	admin := getAuthenticatedUser(ginContext)
+	moderationCollection := databaseClient.Database("sardene-db").Collection("moderation_queue")
+	databaseContext := ginContext.Request.Context()
+
+	resolvedStatus := moderationStatusDismissed
+	if action == "reset_gazes" {
+		resolvedStatus = moderationStatusGazesReset
+		for _, suspiciousUserID := range suspiciousUsersOfReport(report) {
+			errInResetting := resetGazeOfUser(databaseContext, stores, suspiciousUserID, report.IdeaID)
+			if errInResetting != nil {
+				ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+					"error": "Error while saving to database", "errorDetails": errInResetting.Error()})
+				return
+			}
+		}
+	}
+
+	// Other reports of the idea name other users, only this one is settled
+	errInResolving := settleModerationReports(databaseContext, moderationCollection,
+		bson.M{"_id": report.ID, "status": moderationStatusOpen}, resolvedStatus, admin.UserID)
+	if errInResolving != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Report " + resolvedStatus})
+}
+
+func settleModerationReports(databaseContext context.Context, moderationCollection *mongo.Collection, reportsFilter bson.M,
+	resolvedStatus string, resolvedBy int64) error {
+	resolveReportsUpdate := bson.M{"$set": bson.M{
+		"status":      resolvedStatus,
+		"resolved_by": resolvedBy,
+		"resolved_at": time.Now().Unix(),
+	}}
+	_, errInResolving := moderationCollection.UpdateMany(databaseContext, reportsFilter, resolveReportsUpdate)
+	return errInResolving
+}
+
+// suspiciousUsersOfReport : Users named in the evidence, reports filed before user_ids was used name them in new_account_ids
+func suspiciousUsersOfReport(report ModerationReportStructure) []int64 {
+	var suspiciousUserIDs []int64
+	for _, evidenceKey := range []string{"user_ids", "new_account_ids"} {
+		userIDs, isList := report.Evidence[evidenceKey].(primitive.A)
+		if isList == false {
+			continue
+		}
+		for _, userID := range userIDs {
+			switch typedUserID := userID.(type) {
+			case int64:
+				suspiciousUserIDs = append(suspiciousUserIDs, typedUserID)
+			case int32:
+				suspiciousUserIDs = append(suspiciousUserIDs, int64(typedUserID))
+			}
+		}
+	}
+	return suspiciousUserIDs
+}
+
+// resetGazeOfUser : Takes back the gaze and its reaction, a user who already took it back is skipped
+func resetGazeOfUser(databaseContext context.Context, stores Stores, userID int64, ideaID primitive.ObjectID) error {
+	like, errInFinding := stores.Likes.Find(databaseContext, userID, ideaID)
+	if errInFinding == errNotFoundInStore {
+		return nil
+	}
+	if errInFinding != nil {
+		return errInFinding
+	}
+
+	errInDeleting := stores.Likes.Delete(databaseContext, userID, ideaID)
+	if errInDeleting != nil {
+		return errInDeleting
+	}
+	_, errInCounting := stores.Ideas.IncrementGazers(databaseContext, ideaID, -1)
+	if errInCounting == errNotFoundInStore {
+		return nil
+	}
+	if errInCounting != nil {
+		return errInCounting
+	}
+	if like.Reaction == "" {
+		return nil
+	}
+	return stores.Ideas.IncrementReaction(databaseContext, ideaID, like.Reaction, -1)
+}
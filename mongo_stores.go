@@ -0,0 +1,893 @@
+package main
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoIdeasStore struct {
+	ideasCollection *mongo.Collection
+}
+
+type mongoUsersStore struct {
+	usersCollection *mongo.Collection
+}
+
+type mongoLikesStore struct {
+	likesCollection *mongo.Collection
+}
+
+type mongoSessionsStore struct {
+	sessionsCollection *mongo.Collection
+}
+
+// ideaLinkWrites : Leases are held per instance, requests of one instance take turns on this before taking the lease
+var ideaLinkWrites sync.Mutex
+
+const ideaLinksLease = "idea_links"
+
+func newMongoStores(databaseClient *mongo.Client) Stores {
+	return newMongoStoresOf(databaseClient.Database("sardene-db"))
+}
+
+// newMongoStoresOf : Stores reading with the read preference of the database
+func newMongoStoresOf(sardeneDatabase *mongo.Database) Stores {
+	return Stores{
+		Ideas:    mongoIdeasStore{ideasCollection: sardeneDatabase.Collection("ideas")},
+		Users:    mongoUsersStore{usersCollection: sardeneDatabase.Collection("users")},
+		Likes:    mongoLikesStore{likesCollection: sardeneDatabase.Collection("likes")},
+		Sessions: mongoSessionsStore{sessionsCollection: sardeneDatabase.Collection("sessions")},
+	}
+}
+
+func findIdeasInCollection(databaseContext context.Context, ideasCollection *mongo.Collection, filter bson.M,
+	findOptions *options.FindOptions) ([]IdeaStructure, error) {
+	var ideas []IdeaStructure
+
+	ideasCursor, errInFinding := ideasCollection.Find(databaseContext, filter, findOptions)
+	if errInFinding != nil {
+		return ideas, errInFinding
+	}
+	defer ideasCursor.Close(databaseContext)
+
+	for ideasCursor.Next(databaseContext) {
+		var idea IdeaStructure
+		errInDecoding := ideasCursor.Decode(&idea)
+		if errInDecoding != nil {
+			return ideas, errInDecoding
+		}
+		ideas = append(ideas, idea)
+	}
+
+	return ideas, ideasCursor.Err()
+}
+
+func (store mongoIdeasStore) ListPublished(databaseContext context.Context, filter IdeaListFilter, fields []string) ([]IdeaStructure, error) {
+	publishedIdeasFilter := ideasVisibleToFilter(filter.DraftsOf)
+	if filter.IncludeArchived == false {
+		publishedIdeasFilter["archived"] = bson.M{"$ne": true}
+	}
+	if filter.Publisher != "" {
+		publishedIdeasFilter["publisher"] = filter.Publisher
+	}
+	createdAtFilter := bson.M{}
+	if filter.CreatedAfter != 0 {
+		createdAtFilter["$gt"] = filter.CreatedAfter
+	}
+	if filter.CreatedBefore != 0 {
+		createdAtFilter["$lt"] = filter.CreatedBefore
+	}
+	if len(createdAtFilter) > 0 {
+		publishedIdeasFilter["created_at"] = createdAtFilter
+	}
+
+	findOptions := options.Find()
+	if projection := ideaProjection(fields); projection != nil {
+		findOptions.SetProjection(projection)
+	}
+
+	return findIdeasInCollection(databaseContext, store.ideasCollection, publishedIdeasFilter, findOptions)
+}
+
+func (store mongoIdeasStore) FindByID(databaseContext context.Context, ideaID primitive.ObjectID) (IdeaStructure, error) {
+	var idea IdeaStructure
+
+	errInDecoding := store.ideasCollection.FindOne(databaseContext, bson.M{"_id": ideaID}, options.FindOne()).Decode(&idea)
+	if errInDecoding != nil {
+		if errInDecoding.Error() == "mongo: no documents in result" {
+			return idea, errNotFoundInStore
+		}
+		return idea, errInDecoding
+	}
+
+	return idea, nil
+}
+
+func (store mongoIdeasStore) FindByIDs(databaseContext context.Context, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+	return findIdeasInCollection(databaseContext, store.ideasCollection, bson.M{"_id": bson.M{"$in": ideaIDs}}, options.Find())
+}
+
+func (store mongoIdeasStore) ListLinkingTo(databaseContext context.Context, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+	return findIdeasInCollection(databaseContext, store.ideasCollection, bson.M{"links.idea_id": bson.M{"$in": ideaIDs}}, options.Find())
+}
+
+// AddLink : Two links added at once could close a cycle together, so instances take turns on a lease
+func (store mongoIdeasStore) AddLink(databaseContext context.Context, ideaID primitive.ObjectID, link IdeaLinkStructure) error {
+	ideaLinkWrites.Lock()
+	defer ideaLinkWrites.Unlock()
+
+	databaseClient := store.ideasCollection.Database().Client()
+	errInWaiting := waitForLease(databaseContext, databaseClient, ideaLinksLease, 10*time.Second)
+	if errInWaiting != nil {
+		return errInWaiting
+	}
+	defer func() {
+		releaseContext, cancelReleaseContext := context.WithTimeout(context.Background(), 5*time.Second)
+		releaseLease(releaseContext, databaseClient, ideaLinksLease)
+		cancelReleaseContext()
+	}()
+
+	findIdeas := func(ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+		return store.FindByIDs(databaseContext, ideaIDs)
+	}
+	return addLinkWithoutCycle(findIdeas, ideaID, link, func() error {
+		result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext,
+			bson.M{"_id": ideaID, "links.idea_id": bson.M{"$ne": link.IdeaID}}, bson.M{"$push": bson.M{"links": link}})
+		if errInUpdating != nil {
+			return errInUpdating
+		}
+		if result.MatchedCount == 0 {
+			return errNotFoundInStore
+		}
+		return nil
+	})
+}
+
+func (store mongoIdeasStore) RemoveLink(databaseContext context.Context, ideaID primitive.ObjectID, linkedIdeaID primitive.ObjectID) error {
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID, "links.idea_id": linkedIdeaID},
+		bson.M{"$pull": bson.M{"links": bson.M{"idea_id": linkedIdeaID}}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) ListByPublisherSince(databaseContext context.Context, publisherID int64, since int64, limit int64) ([]IdeaStructure, error) {
+	publisherIdeasFilter := bson.M{"publisher_id": publisherID, "created_at": bson.M{"$gte": since}}
+	findOptions := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit)
+
+	return findIdeasInCollection(databaseContext, store.ideasCollection, publisherIdeasFilter, findOptions)
+}
+
+func (store mongoIdeasStore) CountByPublisherSince(databaseContext context.Context, publisherID int64, since int64) (int64, error) {
+	publisherIdeasFilter := bson.M{"publisher_id": publisherID, "created_at": bson.M{"$gte": since}}
+	return store.ideasCollection.CountDocuments(databaseContext, publisherIdeasFilter, options.Count())
+}
+
+func (store mongoIdeasStore) ListPublishedByPublishers(databaseContext context.Context, publisherIDs []int64,
+	skip int64, limit int64) ([]IdeaStructure, error) {
+	publishedIdeasFilter := publicIdeasFilter()
+	publishedIdeasFilter["archived"] = bson.M{"$ne": true}
+	publishedIdeasFilter["publisher_id"] = bson.M{"$in": publisherIDs}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	return findIdeasInCollection(databaseContext, store.ideasCollection, publishedIdeasFilter, findOptions)
+}
+
+func (store mongoIdeasStore) Insert(databaseContext context.Context, idea IdeaStructure) (primitive.ObjectID, error) {
+	idea.ID = primitive.NewObjectID()
+
+	_, errInAdding := store.ideasCollection.InsertOne(databaseContext, idea)
+	return idea.ID, errInAdding
+}
+
+func (store mongoIdeasStore) UpdateContent(databaseContext context.Context, ideaID primitive.ObjectID, contentUpdate IdeaContentUpdate) (IdeaStructure, error) {
+	changedFields := bson.M{"updated_at": contentUpdate.UpdatedAt}
+	if contentUpdate.Name != nil {
+		changedFields["name"] = *contentUpdate.Name
+		changedFields["slug"] = *contentUpdate.Slug
+	}
+	if contentUpdate.Description != nil {
+		changedFields["description"] = *contentUpdate.Description
+	}
+	if contentUpdate.Visibility != nil {
+		changedFields["visibility"] = *contentUpdate.Visibility
+	}
+	ideaUpdate := bson.M{"$set": changedFields, "$inc": bson.M{"version": 1}}
+	if contentUpdate.RepoURL != nil {
+		changedFields["repo_url"] = *contentUpdate.RepoURL
+		ideaUpdate["$unset"] = bson.M{"repo": ""}
+	}
+
+	ideaFilter := bson.M{"_id": ideaID}
+	if contentUpdate.ExpectedVersion != nil {
+		ideaFilter["version"] = *contentUpdate.ExpectedVersion
+		// Ideas saved before versions have none, they are at version 0
+		if *contentUpdate.ExpectedVersion == 0 {
+			ideaFilter["version"] = bson.M{"$in": bson.A{0, nil}}
+		}
+	}
+
+	var updatedIdea IdeaStructure
+	errInUpdating := store.ideasCollection.FindOneAndUpdate(databaseContext, ideaFilter, ideaUpdate,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updatedIdea)
+	if errInUpdating != mongo.ErrNoDocuments {
+		return updatedIdea, errInUpdating
+	}
+	if contentUpdate.ExpectedVersion == nil {
+		return updatedIdea, errNotFoundInStore
+	}
+
+	// Nothing matched either because the idea is gone or because it is at another version
+	matchingIdeas, errInCounting := store.ideasCollection.CountDocuments(databaseContext, bson.M{"_id": ideaID})
+	if errInCounting != nil {
+		return updatedIdea, errInCounting
+	}
+	if matchingIdeas == 0 {
+		return updatedIdea, errNotFoundInStore
+	}
+	return updatedIdea, errConflictInStore
+}
+
+// AddCollaborator : Returns errDuplicateInStore when the user is a collaborator already or the idea is full
+func (store mongoIdeasStore) AddCollaborator(databaseContext context.Context, ideaID primitive.ObjectID,
+	collaborator IdeaCollaboratorStructure, maxCollaborators int) error {
+	// Both checks are part of the filter, so parallel requests cannot go past them
+	ideaWithRoomFilter := bson.M{
+		"_id":                   ideaID,
+		"collaborators.user_id": bson.M{"$ne": collaborator.UserID},
+		"collaborators." + strconv.Itoa(maxCollaborators-1): bson.M{"$exists": false},
+	}
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, ideaWithRoomFilter,
+		bson.M{"$push": bson.M{"collaborators": collaborator}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errDuplicateInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) RemoveCollaborator(databaseContext context.Context, ideaID primitive.ObjectID, userID int64) error {
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext,
+		bson.M{"_id": ideaID, "collaborators.user_id": userID},
+		bson.M{"$pull": bson.M{"collaborators": bson.M{"user_id": userID}}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+// AddImage : Limit and duplicate checks are part of the filter, so parallel uploads cannot go past them
+func (store mongoIdeasStore) AddImage(databaseContext context.Context, ideaID primitive.ObjectID, image IdeaImageStructure,
+	maxImages int64, updatedAt int64) error {
+	ideaWithRoomFilter := bson.M{
+		"_id":         ideaID,
+		"images.hash": bson.M{"$ne": image.Hash},
+		"images." + strconv.FormatInt(maxImages-1, 10): bson.M{"$exists": false},
+	}
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, ideaWithRoomFilter,
+		bson.M{"$push": bson.M{"images": image}, "$set": bson.M{"updated_at": updatedAt}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errDuplicateInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) RemoveImage(databaseContext context.Context, ideaID primitive.ObjectID, imageHash string, updatedAt int64) error {
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID, "images.hash": imageHash},
+		bson.M{"$pull": bson.M{"images": bson.M{"hash": imageHash}}, "$set": bson.M{"updated_at": updatedAt}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) SetFeatured(databaseContext context.Context, ideaID primitive.ObjectID, featured bool, featuredAt int64) error {
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID},
+		bson.M{"$set": bson.M{"featured": featured, "featured_at": featuredAt}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) SetArchived(databaseContext context.Context, ideaID primitive.ObjectID, archived bool, archivedAt int64) error {
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID},
+		bson.M{"$set": bson.M{"archived": archived, "archived_at": archivedAt}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) SetGithubIssueURL(databaseContext context.Context, ideaID primitive.ObjectID, issueURL string) error {
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID},
+		bson.M{"$set": bson.M{"github_issue_url": issueURL}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+// SetHeldForReview : Ideas from before reviews have no held_for_review, they count as not held
+func (store mongoIdeasStore) SetHeldForReview(databaseContext context.Context, ideaID primitive.ObjectID, held bool) (bool, error) {
+	changingFilter := bson.M{"_id": ideaID, "held_for_review": bson.M{"$ne": true}}
+	if held == false {
+		changingFilter["held_for_review"] = true
+	}
+
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, changingFilter,
+		bson.M{"$set": bson.M{"held_for_review": held}})
+	if errInUpdating != nil {
+		return false, errInUpdating
+	}
+	if result.ModifiedCount > 0 {
+		return true, nil
+	}
+
+	ideasCount, errInCounting := store.ideasCollection.CountDocuments(databaseContext, bson.M{"_id": ideaID}, options.Count())
+	if errInCounting != nil {
+		return false, errInCounting
+	}
+	if ideasCount == 0 {
+		return false, errNotFoundInStore
+	}
+	return false, nil
+}
+
+func (store mongoIdeasStore) ListWithStaleRepo(databaseContext context.Context, staleBefore int64, limit int64) ([]IdeaStructure, error) {
+	staleReposFilter := bson.M{
+		"repo_url": bson.M{"$nin": bson.A{"", nil}},
+		"$or": bson.A{
+			bson.M{"repo": nil},
+			bson.M{"repo.synced_at": bson.M{"$lt": staleBefore}},
+		},
+	}
+	findOptions := options.Find().SetSort(bson.M{"repo.synced_at": 1}).SetLimit(limit)
+
+	return findIdeasInCollection(databaseContext, store.ideasCollection, staleReposFilter, findOptions)
+}
+
+func (store mongoIdeasStore) SetRepo(databaseContext context.Context, ideaID primitive.ObjectID, repo RepoMetadataStructure) error {
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID},
+		bson.M{"$set": bson.M{"repo": repo}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) SetLaunched(databaseContext context.Context, ideaID primitive.ObjectID, launch IdeaLaunchStructure) error {
+	result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID},
+		bson.M{"$set": bson.M{"status": ideaStatusLaunched, "launch": launch}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) ListLaunched(databaseContext context.Context, skip int64, limit int64) ([]IdeaStructure, error) {
+	launchedIdeasFilter := publicIdeasFilter()
+	launchedIdeasFilter["status"] = ideaStatusLaunched
+	launchedIdeasFilter["archived"] = bson.M{"$ne": true}
+	findOptions := options.Find().SetSort(bson.D{{Key: "launch.launched_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(skip).SetLimit(limit)
+
+	return findIdeasInCollection(databaseContext, store.ideasCollection, launchedIdeasFilter, findOptions)
+}
+
+func (store mongoIdeasStore) ListFeatured(databaseContext context.Context) ([]IdeaStructure, error) {
+	featuredIdeasFilter := publicIdeasFilter()
+	featuredIdeasFilter["featured"] = true
+	featuredIdeasFilter["archived"] = bson.M{"$ne": true}
+	findOptions := options.Find().SetSort(bson.M{"featured_at": -1})
+
+	return findIdeasInCollection(databaseContext, store.ideasCollection, featuredIdeasFilter, findOptions)
+}
+
+func (store mongoIdeasStore) SuggestByPrefix(databaseContext context.Context, slugPrefix string,
+	limit int64) ([]IdeaSuggestionStructure, error) {
+	var suggestions []IdeaSuggestionStructure
+
+	// Regex anchored at the start is answered from the slug index
+	suggestionsFilter := publicIdeasFilter()
+	suggestionsFilter["slug"] = bson.M{"$regex": "^" + regexp.QuoteMeta(slugPrefix)}
+	suggestionsFilter["archived"] = bson.M{"$ne": true}
+	findOptions := options.Find().SetProjection(bson.M{"name": 1, "slug": 1}).SetSort(bson.M{"gazers": -1}).SetLimit(limit)
+
+	suggestionsCursor, errInFinding := store.ideasCollection.Find(databaseContext, suggestionsFilter, findOptions)
+	if errInFinding != nil {
+		return suggestions, errInFinding
+	}
+	defer suggestionsCursor.Close(databaseContext)
+
+	for suggestionsCursor.Next(databaseContext) {
+		var suggestion IdeaSuggestionStructure
+		errInDecoding := suggestionsCursor.Decode(&suggestion)
+		if errInDecoding != nil {
+			return suggestions, errInDecoding
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, suggestionsCursor.Err()
+}
+
+// SearchPublished : Goes through the text index, where the name weighs more than the description
+func (store mongoIdeasStore) SearchPublished(databaseContext context.Context, words []string, limit int64) ([]IdeaStructure, error) {
+	searchFilter := publicIdeasFilter()
+	searchFilter["$text"] = bson.M{"$search": strings.Join(words, " ")}
+	textScore := bson.M{"$meta": "textScore"}
+	findOptions := options.Find().SetProjection(bson.M{"score": textScore}).SetSort(bson.M{"score": textScore}).SetLimit(limit)
+
+	return findIdeasInCollection(databaseContext, store.ideasCollection, searchFilter, findOptions)
+}
+
+func (store mongoIdeasStore) Delete(databaseContext context.Context, ideaID primitive.ObjectID) error {
+	result, errInDeleting := store.ideasCollection.DeleteOne(databaseContext, bson.M{"_id": ideaID})
+	if errInDeleting != nil {
+		return errInDeleting
+	}
+	if result.DeletedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoIdeasStore) IncrementGazers(databaseContext context.Context, ideaID primitive.ObjectID, increment int64) (int64, error) {
+	var updatedIdea IdeaStructure
+	// A new gaze has its full weight, the trending job decays it later
+	errInUpdating := store.ideasCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": ideaID},
+		bson.M{"$inc": bson.M{"gazers": increment, "gazes_last_7d": increment, "trending_score": float64(increment)}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"gazers": 1})).Decode(&updatedIdea)
+	if errInUpdating == mongo.ErrNoDocuments {
+		return 0, errNotFoundInStore
+	}
+	return updatedIdea.Gazers, errInUpdating
+}
+
+func (store mongoIdeasStore) AddViews(databaseContext context.Context, viewsOfIdeas map[primitive.ObjectID]int64) error {
+	viewUpdates := make([]mongo.WriteModel, 0, len(viewsOfIdeas))
+	for ideaID, views := range viewsOfIdeas {
+		viewUpdates = append(viewUpdates, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": ideaID}).
+			SetUpdate(bson.M{"$inc": bson.M{"views": views}}))
+	}
+
+	_, errInUpdating := store.ideasCollection.BulkWrite(databaseContext, viewUpdates, options.BulkWrite().SetOrdered(false))
+	return errInUpdating
+}
+
+func (store mongoIdeasStore) IncrementReaction(databaseContext context.Context, ideaID primitive.ObjectID, reaction string,
+	increment int64) error {
+	// reaction is one of ideaReactions, never input
+	_, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaID},
+		bson.M{"$inc": bson.M{"reactions." + reaction: increment}})
+	return errInUpdating
+}
+
+func (store mongoIdeasStore) ListByMomentum(databaseContext context.Context, sortBy string, limit int64) ([]IdeaStructure, error) {
+	momentumFilter := publicIdeasFilter()
+	momentumFilter["gazes_last_7d"] = bson.M{"$gt": 0}
+	momentumFilter["archived"] = bson.M{"$ne": true}
+	findOptions := options.Find().SetSort(bson.D{{Key: sortBy, Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit)
+
+	return findIdeasInCollection(databaseContext, store.ideasCollection, momentumFilter, findOptions)
+}
+
+func (store mongoIdeasStore) RecomputeMomentum(databaseContext context.Context, now int64) (int64, error) {
+	likesCollection := store.ideasCollection.Database().Collection("likes")
+	since := now - int64(trendingWindow/time.Second)
+
+	momentumPipeline := bson.A{
+		bson.M{"$match": bson.M{"created_at": bson.M{"$gte": since}}},
+		bson.M{"$group": bson.M{
+			"_id":   "$ideaID",
+			"gazes": bson.M{"$sum": 1},
+			"score": bson.M{"$sum": bson.M{"$pow": bson.A{0.5,
+				bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, "$created_at"}}, int64(trendingHalfLife / time.Second)}}}}},
+		}},
+	}
+	momentumCursor, errInAggregating := likesCollection.Aggregate(databaseContext, momentumPipeline, options.Aggregate())
+	if errInAggregating != nil {
+		return 0, errInAggregating
+	}
+	defer momentumCursor.Close(databaseContext)
+
+	var updatedIdeas int64
+	var gazedIdeaIDs []primitive.ObjectID
+	for momentumCursor.Next(databaseContext) {
+		var ideaMomentum struct {
+			IdeaID primitive.ObjectID `bson:"_id"`
+			Gazes  int64              `bson:"gazes"`
+			Score  float64            `bson:"score"`
+		}
+		errInDecoding := momentumCursor.Decode(&ideaMomentum)
+		if errInDecoding != nil {
+			return updatedIdeas, errInDecoding
+		}
+		gazedIdeaIDs = append(gazedIdeaIDs, ideaMomentum.IdeaID)
+
+		result, errInUpdating := store.ideasCollection.UpdateOne(databaseContext, bson.M{"_id": ideaMomentum.IdeaID},
+			bson.M{"$set": bson.M{"gazes_last_7d": ideaMomentum.Gazes, "trending_score": ideaMomentum.Score}})
+		if errInUpdating != nil {
+			return updatedIdeas, errInUpdating
+		}
+		updatedIdeas += result.ModifiedCount
+	}
+	if errInCursor := momentumCursor.Err(); errInCursor != nil {
+		return updatedIdeas, errInCursor
+	}
+
+	// Ideas whose last gaze left the window
+	staleIdeasFilter := bson.M{"$or": bson.A{bson.M{"gazes_last_7d": bson.M{"$ne": 0}}, bson.M{"trending_score": bson.M{"$ne": 0}}}}
+	if len(gazedIdeaIDs) > 0 {
+		staleIdeasFilter["_id"] = bson.M{"$nin": gazedIdeaIDs}
+	}
+	result, errInResetting := store.ideasCollection.UpdateMany(databaseContext, staleIdeasFilter,
+		bson.M{"$set": bson.M{"gazes_last_7d": 0, "trending_score": 0}})
+	if errInResetting != nil {
+		return updatedIdeas, errInResetting
+	}
+
+	return updatedIdeas + result.ModifiedCount, nil
+}
+
+func (store mongoUsersStore) FindByUserID(databaseContext context.Context, userID int64) (UserStructure, error) {
+	var user UserStructure
+
+	errInDecoding := store.usersCollection.FindOne(databaseContext, bson.M{"userID": userID}, options.FindOne()).Decode(&user)
+	if errInDecoding != nil {
+		if errInDecoding.Error() == "mongo: no documents in result" {
+			return user, errNotFoundInStore
+		}
+		return user, errInDecoding
+	}
+
+	return user, nil
+}
+
+func (store mongoUsersStore) FindByLogin(databaseContext context.Context, login string) (UserStructure, error) {
+	var user UserStructure
+
+	errInDecoding := store.usersCollection.FindOne(databaseContext, bson.M{"login": login}, options.FindOne()).Decode(&user)
+	if errInDecoding != nil {
+		if errInDecoding.Error() == "mongo: no documents in result" {
+			return user, errNotFoundInStore
+		}
+		return user, errInDecoding
+	}
+
+	return user, nil
+}
+
+func (store mongoUsersStore) UpdateSettings(databaseContext context.Context, userID int64, settings UserSettingsStructure) error {
+	updateResult, errInUpdating := store.usersCollection.UpdateOne(databaseContext, bson.M{"userID": userID}, bson.M{"$set": bson.M{
+		"display_name": settings.DisplayName,
+		"bio":          settings.Bio,
+		"website":      settings.Website,
+		"location":     settings.Location,
+	}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updateResult.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoUsersStore) UpdateSuspension(databaseContext context.Context, userID int64, suspension UserSuspensionStructure) error {
+	updateResult, errInUpdating := store.usersCollection.UpdateOne(databaseContext, bson.M{"userID": userID}, bson.M{"$set": bson.M{
+		"banned":            suspension.Banned,
+		"suspended_until":   suspension.SuspendedUntil,
+		"suspension_reason": suspension.SuspensionReason,
+	}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updateResult.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoUsersStore) UpdateRole(databaseContext context.Context, userID int64, role string) error {
+	updateResult, errInUpdating := store.usersCollection.UpdateOne(databaseContext, bson.M{"userID": userID}, bson.M{"$set": bson.M{"role": role}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updateResult.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+// DeleteAccount : Runs in a transaction, mongo without a replica set cannot run one and gets the same steps one after
+// the other. Each gaze is deleted before it is taken back from its idea, so a retry after a failure does not take it back twice
+func (store mongoUsersStore) DeleteAccount(databaseContext context.Context, userID int64, deleteIdeas bool,
+	now int64) ([]primitive.ObjectID, error) {
+	var deletedIdeaIDs []primitive.ObjectID
+	errInSession := store.usersCollection.Database().Client().UseSession(databaseContext, func(sessionContext mongo.SessionContext) error {
+		errInStarting := sessionContext.StartTransaction()
+		if errInStarting != nil {
+			return errInStarting
+		}
+
+		var errInDeleting error
+		deletedIdeaIDs, errInDeleting = store.deleteAccount(sessionContext, userID, deleteIdeas, now)
+		if errInDeleting != nil {
+			sessionContext.AbortTransaction(sessionContext)
+			return errInDeleting
+		}
+		return sessionContext.CommitTransaction(sessionContext)
+	})
+	if isTransactionNotSupportedError(errInSession) {
+		log.Println("Mongo does not support transactions, deleting account of user", userID, "step by step")
+		return store.deleteAccount(databaseContext, userID, deleteIdeas, now)
+	}
+	return deletedIdeaIDs, errInSession
+}
+
+func (store mongoUsersStore) deleteAccount(databaseContext context.Context, userID int64, deleteIdeas bool,
+	now int64) ([]primitive.ObjectID, error) {
+	ideasCollection := store.usersCollection.Database().Collection("ideas")
+	likesCollection := store.usersCollection.Database().Collection("likes")
+
+	likesCursor, errInFinding := likesCollection.Find(databaseContext, bson.M{"userID": userID}, options.Find())
+	if errInFinding != nil {
+		return nil, errInFinding
+	}
+	defer likesCursor.Close(databaseContext)
+
+	var likes []IdeaLikesStructure
+	for likesCursor.Next(databaseContext) {
+		var like IdeaLikesStructure
+		errInDecoding := likesCursor.Decode(&like)
+		if errInDecoding != nil {
+			return nil, errInDecoding
+		}
+		likes = append(likes, like)
+	}
+	if errInCursor := likesCursor.Err(); errInCursor != nil {
+		return nil, errInCursor
+	}
+
+	for _, like := range likes {
+		deleteResult, errInDeletingLike := likesCollection.DeleteOne(databaseContext, bson.M{"userID": userID, "ideaID": like.IdeaID})
+		if errInDeletingLike != nil {
+			return nil, errInDeletingLike
+		}
+		if deleteResult.DeletedCount == 0 {
+			continue
+		}
+
+		gazes, score := momentumOfGaze(like.CreatedAt, now)
+		gazeIncrements := bson.M{"gazers": -1, "gazes_last_7d": -gazes, "trending_score": -score}
+		if like.Reaction != "" {
+			gazeIncrements["reactions."+like.Reaction] = -1
+		}
+		_, errInTakingBack := ideasCollection.UpdateOne(databaseContext, bson.M{"_id": like.IdeaID}, bson.M{"$inc": gazeIncrements})
+		if errInTakingBack != nil {
+			return nil, errInTakingBack
+		}
+	}
+
+	_, errInRemovingCollaborator := ideasCollection.UpdateMany(databaseContext, bson.M{"collaborators.user_id": userID},
+		bson.M{"$pull": bson.M{"collaborators": bson.M{"user_id": userID}}})
+	if errInRemovingCollaborator != nil {
+		return nil, errInRemovingCollaborator
+	}
+
+	// Ideas published under an org stay with it whatever happens to the other ideas of the user
+	_, errInAnonymizingOrgIdeas := ideasCollection.UpdateMany(databaseContext,
+		bson.M{"publisher_id": userID, "org": bson.M{"$nin": bson.A{"", nil}}},
+		bson.M{"$set": bson.M{"author": deletedUserLogin, "publisher_id": 0}})
+	if errInAnonymizingOrgIdeas != nil {
+		return nil, errInAnonymizingOrgIdeas
+	}
+
+	var deletedIdeaIDs []primitive.ObjectID
+	if deleteIdeas == true {
+		publishedIdeaIDs, errInFindingIdeas := ideasCollection.Distinct(databaseContext, "_id", bson.M{"publisher_id": userID}, options.Distinct())
+		if errInFindingIdeas != nil {
+			return nil, errInFindingIdeas
+		}
+		for _, publishedIdeaID := range publishedIdeaIDs {
+			if ideaID, isObjectID := publishedIdeaID.(primitive.ObjectID); isObjectID == true {
+				deletedIdeaIDs = append(deletedIdeaIDs, ideaID)
+			}
+		}
+
+		if len(deletedIdeaIDs) != 0 {
+			_, errInDeletingLikes := likesCollection.DeleteMany(databaseContext, bson.M{"ideaID": bson.M{"$in": deletedIdeaIDs}})
+			if errInDeletingLikes != nil {
+				return nil, errInDeletingLikes
+			}
+			_, errInDeletingIdeas := ideasCollection.DeleteMany(databaseContext, bson.M{"_id": bson.M{"$in": deletedIdeaIDs}})
+			if errInDeletingIdeas != nil {
+				return nil, errInDeletingIdeas
+			}
+		}
+	} else {
+		_, errInAnonymizingIdeas := ideasCollection.UpdateMany(databaseContext, bson.M{"publisher_id": userID},
+			bson.M{"$set": bson.M{"publisher": deletedUserLogin, "publisher_id": 0}})
+		if errInAnonymizingIdeas != nil {
+			return nil, errInAnonymizingIdeas
+		}
+	}
+
+	_, errInDeletingUser := store.usersCollection.DeleteOne(databaseContext, bson.M{"userID": userID})
+	return deletedIdeaIDs, errInDeletingUser
+}
+
+func (store mongoUsersStore) Insert(databaseContext context.Context, user UserStructure) error {
+	userToAdd := bson.M{
+		"userID":     user.UserID,
+		"login":      user.Login,
+		"name":       user.Name,
+		"created_at": user.CreatedAt,
+	}
+	// Role is only given by the create-admin command, so it is left out of new users
+	if len(user.Role) != 0 {
+		userToAdd["role"] = user.Role
+	}
+
+	_, errInAdding := store.usersCollection.InsertOne(databaseContext, userToAdd, options.InsertOne())
+	if errInAdding != nil && isDuplicateKeyError(errInAdding) {
+		return errDuplicateInStore
+	}
+	return errInAdding
+}
+
+func (store mongoLikesStore) Insert(databaseContext context.Context, like IdeaLikesStructure) error {
+	_, errInAdding := store.likesCollection.InsertOne(databaseContext, like)
+	if errInAdding != nil && isDuplicateKeyError(errInAdding) {
+		return errDuplicateInStore
+	}
+	return errInAdding
+}
+
+func (store mongoLikesStore) Find(databaseContext context.Context, userID int64, ideaID primitive.ObjectID) (IdeaLikesStructure, error) {
+	var like IdeaLikesStructure
+
+	errInDecoding := store.likesCollection.FindOne(databaseContext, bson.M{"userID": userID, "ideaID": ideaID}).Decode(&like)
+	if errInDecoding == mongo.ErrNoDocuments {
+		return like, errNotFoundInStore
+	}
+	return like, errInDecoding
+}
+
+func (store mongoLikesStore) UpdateReaction(databaseContext context.Context, userID int64, ideaID primitive.ObjectID,
+	fromReaction string, toReaction string) error {
+	result, errInUpdating := store.likesCollection.UpdateOne(databaseContext,
+		bson.M{"userID": userID, "ideaID": ideaID, "reaction": fromReaction}, bson.M{"$set": bson.M{"reaction": toReaction}})
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if result.MatchedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoLikesStore) Delete(databaseContext context.Context, userID int64, ideaID primitive.ObjectID) error {
+	_, errInDeleting := store.likesCollection.DeleteOne(databaseContext, bson.M{"userID": userID, "ideaID": ideaID})
+	return errInDeleting
+}
+
+func (store mongoLikesStore) DeleteByIdea(databaseContext context.Context, ideaID primitive.ObjectID) error {
+	_, errInDeleting := store.likesCollection.DeleteMany(databaseContext, bson.M{"ideaID": ideaID})
+	return errInDeleting
+}
+
+func (store mongoLikesStore) ListByUser(databaseContext context.Context, userID int64) ([]IdeaLikesStructure, error) {
+	var likes []IdeaLikesStructure
+
+	likesCursor, errInFinding := store.likesCollection.Find(databaseContext, bson.M{"userID": userID}, options.Find())
+	if errInFinding != nil {
+		return likes, errInFinding
+	}
+	defer likesCursor.Close(databaseContext)
+
+	for likesCursor.Next(databaseContext) {
+		var like IdeaLikesStructure
+		errInDecoding := likesCursor.Decode(&like)
+		if errInDecoding != nil {
+			return likes, errInDecoding
+		}
+		likes = append(likes, like)
+	}
+
+	return likes, likesCursor.Err()
+}
+
+func (store mongoLikesStore) ListGazedAmong(databaseContext context.Context, userID int64,
+	ideaIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var gazedIdeaIDs []primitive.ObjectID
+
+	gazedIdeas, errInFinding := store.likesCollection.Distinct(databaseContext, "ideaID",
+		bson.M{"userID": userID, "ideaID": bson.M{"$in": ideaIDs}}, options.Distinct())
+	if errInFinding != nil {
+		return gazedIdeaIDs, errInFinding
+	}
+	for _, gazedIdea := range gazedIdeas {
+		if gazedIdeaID, isObjectID := gazedIdea.(primitive.ObjectID); isObjectID {
+			gazedIdeaIDs = append(gazedIdeaIDs, gazedIdeaID)
+		}
+	}
+
+	return gazedIdeaIDs, nil
+}
+
+func (store mongoSessionsStore) Insert(databaseContext context.Context, session SessionStructure) error {
+	_, errInInserting := store.sessionsCollection.InsertOne(databaseContext, session)
+	return errInInserting
+}
+
+// FindByTokenHash : Expired sessions are removed by the TTL index on expires_at
+func (store mongoSessionsStore) FindByTokenHash(databaseContext context.Context, tokenHash string) (SessionStructure, error) {
+	var session SessionStructure
+	errInFinding := store.sessionsCollection.FindOne(databaseContext, bson.M{"_id": tokenHash}).Decode(&session)
+	if errInFinding == mongo.ErrNoDocuments {
+		return session, errNotFoundInStore
+	}
+	return session, errInFinding
+}
+
+func (store mongoSessionsStore) Delete(databaseContext context.Context, tokenHash string) error {
+	result, errInDeleting := store.sessionsCollection.DeleteOne(databaseContext, bson.M{"_id": tokenHash})
+	if errInDeleting != nil {
+		return errInDeleting
+	}
+	if result.DeletedCount == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store mongoSessionsStore) DeleteByUser(databaseContext context.Context, userID int64) error {
+	_, errInDeleting := store.sessionsCollection.DeleteMany(databaseContext, bson.M{"user_id": userID})
+	return errInDeleting
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	notificationKindIdeaUpdated  = "idea.updated"
+	notificationKindIdeaGazed    = "idea.gazed"
+	notificationKindIdeaLaunched = "idea.launched"
+)
+
+// NotificationStructure : Structure of notification in notifications collection, one per receiving user
+type NotificationStructure struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID     int64              `json:"user_id" bson:"user_id"`
+	Kind       string             `json:"kind" bson:"kind"`
+	IdeaID     primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+	IdeaName   string             `json:"idea_name" bson:"idea_name"`
+	ActorLogin string             `json:"actor_login" bson:"actor_login"`
+	Read       bool               `json:"read" bson:"read"`
+	CreatedAt  int64              `json:"created_at" bson:"created_at"`
+}
+
+// addNotifications : Same notification for each of the users, the actor is never notified of their own change
+// and users who turned off the kind in their preferences are left out. Nothing is sent without mongo
+func addNotifications(databaseContext context.Context, databaseClient *mongo.Client, userIDs []int64, kind string,
+	idea IdeaStructure, actor GithubUserProfileStructure) error {
+	if databaseClient == nil {
+		return nil
+	}
+	notificationsCollection := databaseClient.Database("sardene-db").Collection("notifications")
+
+	optedOut, errInFindingPreferences := usersOptedOutOf(databaseContext, databaseClient, userIDs, "in_app", kind)
+	if errInFindingPreferences != nil {
+		return errInFindingPreferences
+	}
+
+	createdAt := time.Now().Unix()
+	notificationsToAdd := []interface{}{}
+	for _, userID := range userIDs {
+		if userID == actor.UserID || optedOut[userID] == true {
+			continue
+		}
+		notificationsToAdd = append(notificationsToAdd, NotificationStructure{
+			UserID:     userID,
+			Kind:       kind,
+			IdeaID:     idea.ID,
+			IdeaName:   idea.Name,
+			ActorLogin: actor.Login,
+			CreatedAt:  createdAt,
+		})
+	}
+	if len(notificationsToAdd) == 0 {
+		return nil
+	}
+
+	_, errInAdding := notificationsCollection.InsertMany(databaseContext, notificationsToAdd)
+	return errInAdding
+}
+
+// getNotifications : Newest first, ?unread=true leaves out the ones already read
+func getNotifications(ginContext *gin.Context, databaseClient *mongo.Client) {
+	user := getAuthenticatedUser(ginContext)
+
+	pagination, errInPagination := getPaginationFromQuery(ginContext, 20, 100)
+	if errInPagination != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong pagination", "errorDetails": errInPagination.Error()})
+		return
+	}
+
+	notificationsFilter := bson.M{"user_id": user.UserID}
+	if ginContext.Query("unread") == "true" {
+		notificationsFilter["read"] = false
+	}
+
+	notificationsCollection := databaseClient.Database("sardene-db").Collection("notifications")
+	databaseContext := ginContext.Request.Context()
+
+	notifications := []*NotificationStructure{}
+	pageTotals, errInFinding := findPageWithTotals(databaseContext, notificationsCollection, notificationsFilter,
+		bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, pagination, &notifications)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": notifications, "count": len(notifications),
+		"page": pagination.Page, "limit": pagination.Limit,
+		"total_count": pageTotals.TotalCount, "total_pages": pageTotals.TotalPages})
+}
+
+func markNotificationsRead(ginContext *gin.Context, databaseClient *mongo.Client) {
+	user := getAuthenticatedUser(ginContext)
+	notificationsCollection := databaseClient.Database("sardene-db").Collection("notifications")
+	databaseContext := ginContext.Request.Context()
+
+	_, errInMarking := notificationsCollection.UpdateMany(databaseContext, bson.M{"user_id": user.UserID, "read": false},
+		bson.M{"$set": bson.M{"read": true}})
+	if errInMarking != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Notifications marked as read"})
+}
+
+func deleteUserNotifications(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	notificationsCollection := databaseClient.Database("sardene-db").Collection("notifications")
+
+	_, errInDeleting := notificationsCollection.DeleteMany(databaseContext, bson.M{"user_id": userID})
+	return errInDeleting
+}
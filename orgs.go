@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	orgRoleOwner  = "owner"
+	orgRoleMember = "member"
+	// Upper bound of members and pending invites of one org together
+	maxMembersOfOrg = 100
+)
+
+// Same shape as GitHub logins, since the slug takes the place of the login as publisher of org ideas
+var orgSlugRegex = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9]|-[a-z0-9]){1,38}$`)
+
+// OrgStructure : Structure of organization in orgs collection, ideas published under it carry its slug
+type OrgStructure struct {
+	Slug      string               `json:"slug" bson:"_id"`
+	Name      string               `json:"name" bson:"name"`
+	CreatedBy int64                `json:"created_by" bson:"created_by"`
+	CreatedAt int64                `json:"created_at" bson:"created_at"`
+	Members   []OrgMemberStructure `json:"members" bson:"members"`
+	// Invites are only shown to owners of the org
+	Invites []OrgMemberStructure `json:"invites,omitempty" bson:"invites"`
+}
+
+// OrgMemberStructure : Member of an org or user invited to be one, in the role they have or will have
+type OrgMemberStructure struct {
+	UserID int64  `json:"user_id" bson:"user_id"`
+	Login  string `json:"login" bson:"login"`
+	Role   string `json:"role" bson:"role"`
+	// Time they joined, or were invited for an invite
+	AddedAt int64 `json:"added_at" bson:"added_at"`
+}
+
+// OrgInput : Structure for incoming org
+type OrgInput struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// OrgMemberInput : Structure for incoming invite or role change, users are found by their GitHub login
+type OrgMemberInput struct {
+	Login string `json:"login"`
+	Role  string `json:"role"`
+}
+
+func isValidOrgRole(role string) bool {
+	return role == orgRoleOwner || role == orgRoleMember
+}
+
+func roleInOrg(org OrgStructure, userID int64) string {
+	for _, member := range org.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// findOrg : Returns mongo.ErrNoDocuments when there is no org with the slug
+func findOrg(databaseContext context.Context, databaseClient *mongo.Client, orgSlug string) (OrgStructure, error) {
+	orgsCollection := databaseClient.Database("sardene-db").Collection("orgs")
+
+	var org OrgStructure
+	errInFinding := orgsCollection.FindOne(databaseContext, bson.M{"_id": orgSlug}).Decode(&org)
+	return org, errInFinding
+}
+
+// orgRoleOfUser : Role of the user in the org, empty when they are not a member or the org does not exist
+func orgRoleOfUser(databaseContext context.Context, databaseClient *mongo.Client, orgSlug string, userID int64) (string, error) {
+	org, errInFinding := findOrg(databaseContext, databaseClient, orgSlug)
+	if errInFinding == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	if errInFinding != nil {
+		return "", errInFinding
+	}
+	return roleInOrg(org, userID), nil
+}
+
+// findOrgOfOwner : Writes the response and returns false unless the org exists and the user owns it
+func findOrgOfOwner(ginContext *gin.Context, databaseClient *mongo.Client, orgSlug string, userID int64) (OrgStructure, bool) {
+	org, errInFinding := findOrg(ginContext.Request.Context(), databaseClient, orgSlug)
+	if errInFinding == mongo.ErrNoDocuments {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Org not found"})
+		return org, false
+	}
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return org, false
+	}
+	if roleInOrg(org, userID) != orgRoleOwner {
+		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "error": "Only owners of the org can do this"})
+		return org, false
+	}
+	return org, true
+}
+
+// createOrg : Creator becomes its first owner
+func createOrg(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores) {
+	user := getAuthenticatedUser(ginContext)
+
+	var jsonInput OrgInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	slug := strings.ToLower(strings.TrimSpace(jsonInput.Slug))
+	name := strings.TrimSpace(jsonInput.Name)
+	if errInInputJSON != nil || orgSlugRegex.MatchString(slug) == false || lengthInRunes(name) == 0 || lengthInRunes(name) > maxIdeaNameLength {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Slug should be 2 to 39 lowercase letters, digits and single dashes, and name at most " +
+				strconv.Itoa(maxIdeaNameLength) + " characters long"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	// Publisher of an idea is either a login or an org slug, they must not be mistaken for each other
+	_, errInFindingUser := stores.Users.FindByLogin(databaseContext, slug)
+	if errInFindingUser == nil {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict, "error": "Error, Slug is the login of a user"})
+		return
+	}
+	if errInFindingUser != errNotFoundInStore {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUser.Error()})
+		return
+	}
+
+	createdAt := time.Now().Unix()
+	org := OrgStructure{Slug: slug, Name: name, CreatedBy: user.UserID, CreatedAt: createdAt,
+		Members: []OrgMemberStructure{{UserID: user.UserID, Login: user.Login, Role: orgRoleOwner, AddedAt: createdAt}},
+		Invites: []OrgMemberStructure{}}
+
+	orgsCollection := databaseClient.Database("sardene-db").Collection("orgs")
+	_, errInAdding := orgsCollection.InsertOne(databaseContext, org)
+	if isDuplicateKeyError(errInAdding) {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict, "error": "Error, Slug is taken by another org"})
+		return
+	}
+	if errInAdding != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInAdding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": org})
+}
+
+func getOrg(ginContext *gin.Context, databaseClient *mongo.Client, orgSlug string) {
+	org, errInFinding := findOrg(ginContext.Request.Context(), databaseClient, strings.ToLower(orgSlug))
+	if errInFinding == mongo.ErrNoDocuments {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, Org not found"})
+		return
+	}
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	if roleInOrg(org, getAuthenticatedUser(ginContext).UserID) != orgRoleOwner {
+		org.Invites = nil
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": org})
+}
+
+// inviteOrgMember : Only users who have signed in once can be invited, the invite waits until they accept it
+func inviteOrgMember(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, orgSlug string) {
+	user := getAuthenticatedUser(ginContext)
+
+	var jsonInput OrgMemberInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if jsonInput.Role == "" {
+		jsonInput.Role = orgRoleMember
+	}
+	if errInInputJSON != nil || len(strings.TrimSpace(jsonInput.Login)) == 0 || isValidOrgRole(jsonInput.Role) == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Login of the user and a role of owner or member should be provided in the post"})
+		return
+	}
+
+	org, isOwner := findOrgOfOwner(ginContext, databaseClient, strings.ToLower(orgSlug), user.UserID)
+	if isOwner == false {
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+	invitedUser, errInFindingUser := stores.Users.FindByLogin(databaseContext, strings.TrimSpace(jsonInput.Login))
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, No user with this login has signed in"})
+			return
+		}
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUser.Error()})
+		return
+	}
+
+	invite := OrgMemberStructure{UserID: invitedUser.UserID, Login: invitedUser.Login, Role: jsonInput.Role,
+		AddedAt: time.Now().Unix()}
+
+	// Conditions in the filter keep a user from being invited twice even when two invites race
+	orgsCollection := databaseClient.Database("sardene-db").Collection("orgs")
+	inviteFilter := bson.M{
+		"_id":             org.Slug,
+		"members.user_id": bson.M{"$ne": invitedUser.UserID},
+		"invites.user_id": bson.M{"$ne": invitedUser.UserID},
+		"$expr":           bson.M{"$lt": bson.A{bson.M{"$add": bson.A{bson.M{"$size": "$members"}, bson.M{"$size": "$invites"}}}, maxMembersOfOrg}},
+	}
+	result, errInInviting := orgsCollection.UpdateOne(databaseContext, inviteFilter, bson.M{"$push": bson.M{"invites": invite}})
+	if errInInviting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInInviting.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "Error, User is already a member or invited, or the org has " + strconv.Itoa(maxMembersOfOrg) + " members"})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": invite})
+}
+
+func acceptOrgInvite(ginContext *gin.Context, databaseClient *mongo.Client, orgSlug string) {
+	user := getAuthenticatedUser(ginContext)
+	databaseContext := ginContext.Request.Context()
+
+	org, errInFinding := findOrg(databaseContext, databaseClient, strings.ToLower(orgSlug))
+	if errInFinding != nil && errInFinding != mongo.ErrNoDocuments {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	var invite *OrgMemberStructure
+	for inviteIndex := range org.Invites {
+		if org.Invites[inviteIndex].UserID == user.UserID {
+			invite = &org.Invites[inviteIndex]
+		}
+	}
+	if invite == nil {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound, "error": "Error, No invite to this org"})
+		return
+	}
+
+	member := OrgMemberStructure{UserID: user.UserID, Login: user.Login, Role: invite.Role, AddedAt: time.Now().Unix()}
+	orgsCollection := databaseClient.Database("sardene-db").Collection("orgs")
+	_, errInJoining := orgsCollection.UpdateOne(databaseContext,
+		bson.M{"_id": org.Slug, "invites.user_id": user.UserID, "members.user_id": bson.M{"$ne": user.UserID}},
+		bson.M{"$pull": bson.M{"invites": bson.M{"user_id": user.UserID}}, "$push": bson.M{"members": member}})
+	if errInJoining != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInJoining.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": member})
+}
+
+// changeOrgMemberRole : An org always keeps an owner, the last one cannot step down
+func changeOrgMemberRole(ginContext *gin.Context, databaseClient *mongo.Client, orgSlug string, memberID string) {
+	user := getAuthenticatedUser(ginContext)
+
+	memberUserID, errInUserID := strconv.ParseInt(memberID, 10, 64)
+	if errInUserID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "error": "Error, User id is not valid"})
+		return
+	}
+	var jsonInput OrgMemberInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil || isValidOrgRole(jsonInput.Role) == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "error": "Role should be owner or member"})
+		return
+	}
+
+	org, isOwner := findOrgOfOwner(ginContext, databaseClient, strings.ToLower(orgSlug), user.UserID)
+	if isOwner == false {
+		return
+	}
+
+	memberFilter := bson.M{"_id": org.Slug, "members.user_id": memberUserID}
+	if jsonInput.Role != orgRoleOwner {
+		memberFilter["members"] = bson.M{"$elemMatch": bson.M{"user_id": bson.M{"$ne": memberUserID}, "role": orgRoleOwner}}
+	}
+	orgsCollection := databaseClient.Database("sardene-db").Collection("orgs")
+	result, errInUpdating := orgsCollection.UpdateOne(ginContext.Request.Context(), memberFilter,
+		bson.M{"$set": bson.M{"members.$[member].role": jsonInput.Role}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"member.user_id": memberUserID}}}))
+	if errInUpdating != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInUpdating.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, User is not a member of the org or is its last owner"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Role of member changed successfully"})
+}
+
+// removeOrgMember : Owners remove members and cancel invites, members can leave on their own
+func removeOrgMember(ginContext *gin.Context, databaseClient *mongo.Client, orgSlug string, memberID string) {
+	user := getAuthenticatedUser(ginContext)
+
+	memberUserID, errInUserID := strconv.ParseInt(memberID, 10, 64)
+	if errInUserID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "error": "Error, User id is not valid"})
+		return
+	}
+
+	orgSlug = strings.ToLower(orgSlug)
+	if memberUserID != user.UserID {
+		if _, isOwner := findOrgOfOwner(ginContext, databaseClient, orgSlug, user.UserID); isOwner == false {
+			return
+		}
+	}
+
+	// Filter keeps the last owner from leaving the org without owners
+	memberFilter := bson.M{"_id": orgSlug, "$or": bson.A{
+		bson.M{"invites.user_id": memberUserID},
+		bson.M{"members": bson.M{"$elemMatch": bson.M{"user_id": memberUserID, "role": orgRoleMember}}},
+		bson.M{"$and": bson.A{
+			bson.M{"members.user_id": memberUserID},
+			bson.M{"members": bson.M{"$elemMatch": bson.M{"user_id": bson.M{"$ne": memberUserID}, "role": orgRoleOwner}}},
+		}},
+	}}
+	orgsCollection := databaseClient.Database("sardene-db").Collection("orgs")
+	result, errInRemoving := orgsCollection.UpdateOne(ginContext.Request.Context(), memberFilter,
+		bson.M{"$pull": bson.M{"members": bson.M{"user_id": memberUserID}, "invites": bson.M{"user_id": memberUserID}}})
+	if errInRemoving != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInRemoving.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, User is not a member of the org or is its last owner"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Member removed from org successfully"})
+}
+
+// removeUserOrgMemberships : Deleted users leave their orgs and their invites are dropped
+func removeUserOrgMemberships(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	orgsCollection := databaseClient.Database("sardene-db").Collection("orgs")
+
+	_, errInUpdating := orgsCollection.UpdateMany(databaseContext,
+		bson.M{"$or": bson.A{bson.M{"members.user_id": userID}, bson.M{"invites.user_id": userID}}},
+		bson.M{"$pull": bson.M{"members": bson.M{"user_id": userID}, "invites": bson.M{"user_id": userID}}})
+	return errInUpdating
+}
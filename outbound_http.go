@@ -0,0 +1,239 @@
+package main
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OutboundHTTPConfig : Settings shared by every request the API makes to other services
+type OutboundHTTPConfig struct {
+	Timeout             time.Duration
+	MaxRetries          int
+	BaseBackoff         time.Duration
+	MaxConnsPerHost     int
+	MaxIdleConnsPerHost int
+	// Failures in a row which open the circuit of a host, 0 turns the circuit breaker off
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	Recording       GithubRecordingConfig
+}
+
+// DestinationMetrics : Counters of outbound requests to one host
+type DestinationMetrics struct {
+	Requests         int64 `json:"requests"`
+	Failures         int64 `json:"failures"`
+	Retries          int64 `json:"retries"`
+	TotalLatencyInMs int64 `json:"total_latency_ms"`
+	// Requests refused without being sent while the circuit of the host was open
+	Rejected    int64 `json:"rejected"`
+	CircuitOpen bool  `json:"circuit_open"`
+}
+
+// OutboundHTTPClient : Single client for GitHub and any other outside service, with retries for idempotent calls
+type OutboundHTTPClient struct {
+	httpClient   *http.Client
+	config       OutboundHTTPConfig
+	metricsMutex sync.Mutex
+	metrics      map[string]*DestinationMetrics
+	breaker      *CircuitBreaker
+}
+
+var outboundHTTPClient *OutboundHTTPClient
+
+func loadOutboundHTTPConfig(configLoader *ConfigLoader) OutboundHTTPConfig {
+	var outboundHTTPConfig OutboundHTTPConfig
+
+	outboundHTTPConfig.Timeout = time.Duration(configLoader.Int("OUTBOUND_HTTP_TIMEOUT_SECONDS", 10)) * time.Second
+	outboundHTTPConfig.MaxRetries = int(configLoader.Int("OUTBOUND_HTTP_MAX_RETRIES", 2))
+	outboundHTTPConfig.BaseBackoff = time.Duration(configLoader.Int("OUTBOUND_HTTP_BACKOFF_MS", 200)) * time.Millisecond
+	outboundHTTPConfig.MaxConnsPerHost = int(configLoader.Int("OUTBOUND_HTTP_MAX_CONNS_PER_HOST", 20))
+	outboundHTTPConfig.MaxIdleConnsPerHost = int(configLoader.Int("OUTBOUND_HTTP_MAX_IDLE_CONNS_PER_HOST", 5))
+	outboundHTTPConfig.BreakerFailures = int(configLoader.Int("OUTBOUND_HTTP_BREAKER_FAILURES", 5))
+	outboundHTTPConfig.BreakerCooldown = time.Duration(configLoader.Int("OUTBOUND_HTTP_BREAKER_COOLDOWN_SECONDS", 30)) * time.Second
+	if outboundHTTPConfig.Timeout <= 0 {
+		configLoader.Invalid("OUTBOUND_HTTP_TIMEOUT_SECONDS", "should be more than 0")
+	}
+	if outboundHTTPConfig.MaxRetries < 0 {
+		configLoader.Invalid("OUTBOUND_HTTP_MAX_RETRIES", "should be 0 or more")
+	}
+	if outboundHTTPConfig.BaseBackoff <= 0 {
+		configLoader.Invalid("OUTBOUND_HTTP_BACKOFF_MS", "should be more than 0")
+	}
+	if outboundHTTPConfig.BreakerFailures > 0 && outboundHTTPConfig.BreakerCooldown <= 0 {
+		configLoader.Invalid("OUTBOUND_HTTP_BREAKER_COOLDOWN_SECONDS", "should be more than 0 while the circuit breaker is on")
+	}
+
+	outboundHTTPConfig.Recording = loadGithubRecordingConfig(configLoader)
+
+	return outboundHTTPConfig
+}
+
+func newOutboundHTTPClient(outboundHTTPConfig OutboundHTTPConfig) *OutboundHTTPClient {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxConnsPerHost:       outboundHTTPConfig.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   outboundHTTPConfig.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: outboundHTTPConfig.Timeout,
+	}
+
+	return &OutboundHTTPClient{
+		httpClient: &http.Client{Transport: newGithubRecordingTransport(transport, outboundHTTPConfig.Recording), Timeout: outboundHTTPConfig.Timeout},
+		config:     outboundHTTPConfig,
+		metrics:    make(map[string]*DestinationMetrics),
+		breaker:    newCircuitBreaker(outboundHTTPConfig.BreakerFailures, outboundHTTPConfig.BreakerCooldown),
+	}
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// backoffWithJitter : Doubles the wait for every attempt and spreads it randomly so retries don't line up
+func backoffWithJitter(baseBackoff time.Duration, attempt int) time.Duration {
+	backoff := baseBackoff * time.Duration(1<<uint(attempt))
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func (client *OutboundHTTPClient) recordRejected(host string) {
+	client.metricsMutex.Lock()
+	defer client.metricsMutex.Unlock()
+
+	destinationMetrics, isKnownHost := client.metrics[host]
+	if isKnownHost == false {
+		destinationMetrics = &DestinationMetrics{}
+		client.metrics[host] = destinationMetrics
+	}
+	destinationMetrics.Rejected++
+}
+
+func (client *OutboundHTTPClient) recordMetrics(host string, latency time.Duration, isFailure bool, isRetry bool) {
+	client.metricsMutex.Lock()
+	defer client.metricsMutex.Unlock()
+
+	destinationMetrics, isKnownHost := client.metrics[host]
+	if isKnownHost == false {
+		destinationMetrics = &DestinationMetrics{}
+		client.metrics[host] = destinationMetrics
+	}
+
+	destinationMetrics.Requests++
+	destinationMetrics.TotalLatencyInMs += latency.Nanoseconds() / int64(time.Millisecond)
+	if isFailure {
+		destinationMetrics.Failures++
+	}
+	if isRetry {
+		destinationMetrics.Retries++
+	}
+}
+
+// Metrics : Copy of the counters, safe to read while requests are going on
+func (client *OutboundHTTPClient) Metrics() map[string]DestinationMetrics {
+	client.metricsMutex.Lock()
+	metricsCopy := make(map[string]DestinationMetrics, len(client.metrics))
+	for host, destinationMetrics := range client.metrics {
+		metricsCopy[host] = *destinationMetrics
+	}
+	client.metricsMutex.Unlock()
+
+	for host, destinationMetrics := range metricsCopy {
+		destinationMetrics.CircuitOpen = client.breaker.IsOpen(host)
+		metricsCopy[host] = destinationMetrics
+	}
+	return metricsCopy
+}
+
+// Do : Sends the request, idempotent ones are retried with backoff on network errors and 5xx/429 responses
+func (client *OutboundHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	maxAttempts := 1
+	if isIdempotentMethod(request.Method) {
+		maxAttempts = maxAttempts + client.config.MaxRetries
+	}
+	return client.send(request, maxAttempts)
+}
+
+// DoRetrying : Sends the request with retries whatever its method, for calls which are safe to repeat,
+// like exchanging an OAuth code that GitHub refuses the second time if the first one went through
+func (client *OutboundHTTPClient) DoRetrying(request *http.Request) (*http.Response, error) {
+	return client.send(request, 1+client.config.MaxRetries)
+}
+
+func (client *OutboundHTTPClient) send(request *http.Request, maxAttempts int) (*http.Response, error) {
+	var response *http.Response
+	var errInRequest error
+
+	for attempt := 0; ; attempt++ {
+		if client.breaker.Allow(request.URL.Host) == false {
+			client.recordRejected(request.URL.Host)
+			return nil, &UpstreamUnavailableError{Host: request.URL.Host, Cause: "it failed repeatedly, calls are paused for a while"}
+		}
+
+		requestStart := time.Now()
+		response, errInRequest = client.httpClient.Do(request)
+
+		isFailure := errInRequest != nil || isRetryableStatus(response.StatusCode)
+		client.recordMetrics(request.URL.Host, time.Since(requestStart), isFailure, attempt > 0)
+		// Rate limits and requests given up by the caller say nothing about the health of the host
+		if request.Context().Err() != nil {
+			client.breaker.Abandon(request.URL.Host)
+		} else {
+			client.breaker.Record(request.URL.Host, errInRequest != nil || response.StatusCode >= http.StatusInternalServerError)
+		}
+
+		// Body can only be sent again if the request knows how to recreate it
+		canResendBody := request.Body == nil || request.GetBody != nil
+		if isFailure == false || attempt >= maxAttempts-1 || canResendBody == false {
+			break
+		}
+		if response != nil {
+			response.Body.Close()
+		}
+
+		select {
+		case <-time.After(backoffWithJitter(client.config.BaseBackoff, attempt)):
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+
+		if request.Body != nil {
+			freshBody, errInBody := request.GetBody()
+			if errInBody != nil {
+				return nil, errInBody
+			}
+			request.Body = freshBody
+		}
+	}
+
+	if errInRequest != nil {
+		// Errors of the transport quote the whole URL, secrets in its query included, only what failed is kept
+		causeOfFailure := errInRequest
+		if urlError, isURLError := errInRequest.(*url.Error); isURLError == true {
+			causeOfFailure = urlError.Err
+		}
+		return nil, &UpstreamUnavailableError{Host: request.URL.Host, Cause: causeOfFailure.Error()}
+	}
+	return response, nil
+}
+
+func getOutboundMetrics(ginContext *gin.Context) {
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": outboundHTTPClient.Metrics()})
+}
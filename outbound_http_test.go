@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnreachableHostDoesNotQuoteTheURL(t *testing.T) {
+	client := newOutboundHTTPClient(OutboundHTTPConfig{Timeout: time.Second, BaseBackoff: time.Millisecond,
+		Recording: GithubRecordingConfig{Mode: "off"}})
+
+	// Nothing listens on port 1, the request fails in the transport
+	request, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:1/login/oauth/access_token?client_secret=SUPERSECRET", nil)
+	_, errInRequest := client.DoRetrying(request)
+
+	if isUpstreamUnavailable(errInRequest) == false {
+		t.Fatalf("Unreachable host failed with %v", errInRequest)
+	}
+	if strings.Contains(errInRequest.Error(), "SUPERSECRET") == true {
+		t.Fatalf("Error of an unreachable host quotes the secret: %s", errInRequest.Error())
+	}
+}
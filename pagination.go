@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PaginationStructure : Page requested by the client through query params
+type PaginationStructure struct {
+	Page  int64 `json:"page"`
+	Limit int64 `json:"limit"`
+}
+
+// PageTotalsStructure : Counts of every matching document, for clients to render page controls
+type PageTotalsStructure struct {
+	TotalCount int64 `json:"total_count"`
+	TotalPages int64 `json:"total_pages"`
+}
+
+// Skip : Number of documents before the requested page
+func (pagination PaginationStructure) Skip() int64 {
+	return (pagination.Page - 1) * pagination.Limit
+}
+
+// totalsOf : No documents still make zero pages
+func (pagination PaginationStructure) totalsOf(totalCount int64) PageTotalsStructure {
+	return PageTotalsStructure{
+		TotalCount: totalCount,
+		TotalPages: (totalCount + pagination.Limit - 1) / pagination.Limit,
+	}
+}
+
+func getPaginationFromQuery(ginContext *gin.Context, defaultLimit int64, maxLimit int64) (PaginationStructure, error) {
+	var pagination PaginationStructure
+
+	page, errInPage := strconv.ParseInt(ginContext.DefaultQuery("page", "1"), 10, 64)
+	if errInPage != nil || page < 1 {
+		return pagination, fmt.Errorf("Page should be a number greater than 0")
+	}
+
+	limit, errInLimit := strconv.ParseInt(ginContext.DefaultQuery("limit", strconv.FormatInt(defaultLimit, 10)), 10, 64)
+	if errInLimit != nil || limit < 1 || limit > maxLimit {
+		return pagination, fmt.Errorf("Limit should be a number between 1 and %d", maxLimit)
+	}
+
+	pagination.Page = page
+	pagination.Limit = limit
+
+	return pagination, nil
+}
+
+// findPageWithTotals : Documents of the page decoded into pageDocuments, a pointer to a slice, and the count of
+// all matching ones. Both come from one aggregation with $facet instead of a find and a separate count.
+// The page is returned inside a single document, so it has to stay under the 16MB limit of mongo
+func findPageWithTotals(databaseContext context.Context, collection *mongo.Collection, filter bson.M, sort bson.D,
+	pagination PaginationStructure, pageDocuments interface{}) (PageTotalsStructure, error) {
+	pagePipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"documents": bson.A{
+				bson.M{"$sort": sort},
+				bson.M{"$skip": pagination.Skip()},
+				bson.M{"$limit": pagination.Limit},
+			},
+		}}},
+	}
+
+	pageCursor, errInAggregating := collection.Aggregate(databaseContext, pagePipeline, options.Aggregate())
+	if errInAggregating != nil {
+		return PageTotalsStructure{}, errInAggregating
+	}
+	defer pageCursor.Close(databaseContext)
+
+	// $facet always outputs exactly one document, $count leaves its array empty when nothing matched
+	var facetResult struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Documents bson.RawValue `bson:"documents"`
+	}
+	if pageCursor.Next(databaseContext) == false {
+		if pageCursor.Err() != nil {
+			return PageTotalsStructure{}, pageCursor.Err()
+		}
+		return PageTotalsStructure{}, fmt.Errorf("Aggregation of the page returned no result")
+	}
+	errInDecoding := pageCursor.Decode(&facetResult)
+	if errInDecoding != nil {
+		return PageTotalsStructure{}, errInDecoding
+	}
+	errInDecoding = facetResult.Documents.Unmarshal(pageDocuments)
+	if errInDecoding != nil {
+		return PageTotalsStructure{}, errInDecoding
+	}
+
+	var totalCount int64
+	if len(facetResult.Total) > 0 {
+		totalCount = facetResult.Total[0].Count
+	}
+	return pagination.totalsOf(totalCount), nil
+}
@@ -0,0 +1,1067 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// postgresUniqueViolation : Error code of postgres when a unique constraint is violated
+const postgresUniqueViolation = "23505"
+
+// postgresSchema : Tables of the stores, ids of ideas stay object ids in hex so urls are the same on both backends
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS ideas (
+	id              TEXT PRIMARY KEY,
+	name            TEXT NOT NULL,
+	description     TEXT NOT NULL,
+	publisher       TEXT NOT NULL,
+	publisher_id    BIGINT NOT NULL,
+	makers          BIGINT NOT NULL DEFAULT 0,
+	gazers          BIGINT NOT NULL DEFAULT 0,
+	created_at      BIGINT NOT NULL,
+	updated_at      BIGINT NOT NULL,
+	slug            TEXT NOT NULL DEFAULT '',
+	status          TEXT NOT NULL DEFAULT 'open',
+	held_for_review BOOLEAN NOT NULL DEFAULT FALSE,
+	links           JSONB NOT NULL DEFAULT '[]',
+	repo_url        TEXT NOT NULL DEFAULT '',
+	repo            JSONB,
+	visibility      TEXT NOT NULL DEFAULT 'public',
+	collaborators   JSONB NOT NULL DEFAULT '[]',
+	featured        BOOLEAN NOT NULL DEFAULT FALSE,
+	featured_at     BIGINT NOT NULL DEFAULT 0
+);
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS repo_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS repo JSONB;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS collaborators JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS featured BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS featured_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS gazes_last_7d BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS trending_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS org TEXT NOT NULL DEFAULT '';
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS author TEXT NOT NULL DEFAULT '';
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS reactions JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS archived_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS github_issue_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS views BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS launch JSONB;
+ALTER TABLE ideas ADD COLUMN IF NOT EXISTS images JSONB NOT NULL DEFAULT '[]';
+CREATE INDEX IF NOT EXISTS ideas_featured ON ideas (featured_at DESC) WHERE featured;
+CREATE INDEX IF NOT EXISTS ideas_trending_score ON ideas (trending_score DESC) WHERE gazes_last_7d > 0;
+CREATE INDEX IF NOT EXISTS ideas_gazes_last_7d ON ideas (gazes_last_7d DESC) WHERE gazes_last_7d > 0;
+CREATE INDEX IF NOT EXISTS ideas_created_at ON ideas (created_at DESC);
+CREATE INDEX IF NOT EXISTS ideas_slug ON ideas (slug text_pattern_ops);
+CREATE INDEX IF NOT EXISTS ideas_publisher_id ON ideas (publisher_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS users (
+	user_id    BIGINT PRIMARY KEY,
+	login      TEXT NOT NULL,
+	name       TEXT NOT NULL DEFAULT '',
+	created_at BIGINT NOT NULL DEFAULT 0,
+	role       TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS users_login ON users (login);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS website TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS location TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspension_reason TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS likes (
+	user_id    BIGINT NOT NULL,
+	idea_id    TEXT NOT NULL,
+	ip_hash    TEXT NOT NULL DEFAULT '',
+	created_at BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (user_id, idea_id)
+);
+CREATE INDEX IF NOT EXISTS likes_idea_id ON likes (idea_id);
+CREATE INDEX IF NOT EXISTS likes_created_at ON likes (created_at DESC);
+ALTER TABLE likes ADD COLUMN IF NOT EXISTS reaction TEXT NOT NULL DEFAULT '👀';
+UPDATE ideas SET reactions = jsonb_build_object('👀', gazers) WHERE reactions = '{}' AND gazers > 0;
+
+CREATE TABLE IF NOT EXISTS sessions (
+	token_hash   TEXT PRIMARY KEY,
+	user_id      BIGINT NOT NULL,
+	login        TEXT NOT NULL,
+	name         TEXT NOT NULL DEFAULT '',
+	provider     TEXT NOT NULL,
+	github_token TEXT NOT NULL DEFAULT '',
+	created_at   BIGINT NOT NULL,
+	expires_at   TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_expires_at ON sessions (expires_at);
+`
+
+const userColumns = "user_id, login, name, created_at, role, display_name, bio, website, location, banned, suspended_until, suspension_reason"
+
+const ideaColumns = "id, name, description, publisher, publisher_id, makers, gazers, created_at, updated_at, slug, status, held_for_review, links, repo_url, repo, visibility, collaborators, featured, featured_at, gazes_last_7d, trending_score, version, org, author, reactions, archived, archived_at, github_issue_url, views, launch, images"
+
+type postgresIdeasStore struct {
+	sqlDatabase *sql.DB
+}
+
+type postgresUsersStore struct {
+	sqlDatabase *sql.DB
+}
+
+type postgresLikesStore struct {
+	sqlDatabase *sql.DB
+}
+
+type postgresSessionsStore struct {
+	sqlDatabase *sql.DB
+}
+
+func connectToPostgres(postgresURL string) *sql.DB {
+	sqlDatabase, errInOpening := sql.Open("postgres", postgresURL)
+	if errInOpening != nil {
+		log.Fatal(errInOpening, "Failed to connect to postgres")
+	}
+
+	connectContext, cancelConnectContext := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelConnectContext()
+
+	errInPing := sqlDatabase.PingContext(connectContext)
+	if errInPing != nil {
+		log.Fatal(errInPing, "Postgres not found")
+	}
+
+	_, errInSchema := sqlDatabase.ExecContext(connectContext, postgresSchema)
+	if errInSchema != nil {
+		log.Fatal(errInSchema, "Failed to create postgres schema")
+	}
+
+	return sqlDatabase
+}
+
+func newPostgresStores(sqlDatabase *sql.DB) Stores {
+	return Stores{
+		Ideas:    postgresIdeasStore{sqlDatabase: sqlDatabase},
+		Users:    postgresUsersStore{sqlDatabase: sqlDatabase},
+		Likes:    postgresLikesStore{sqlDatabase: sqlDatabase},
+		Sessions: postgresSessionsStore{sqlDatabase: sqlDatabase},
+	}
+}
+
+func isPostgresUniqueViolation(err error) bool {
+	postgresError, isPostgresError := err.(*pq.Error)
+	return isPostgresError && postgresError.Code == postgresUniqueViolation
+}
+
+// rowScanner : Single row or rows of a query, both scan the same way
+type rowScanner interface {
+	Scan(destinations ...interface{}) error
+}
+
+func scanIdea(row rowScanner) (IdeaStructure, error) {
+	var idea IdeaStructure
+	var ideaID string
+	var linksInJSON []byte
+	var repoInJSON []byte
+	var collaboratorsInJSON []byte
+	var reactionsInJSON []byte
+	var launchInJSON []byte
+	var imagesInJSON []byte
+
+	errInScanning := row.Scan(&ideaID, &idea.Name, &idea.Description, &idea.Publisher, &idea.PublisherID, &idea.Makers,
+		&idea.Gazers, &idea.CreatedAt, &idea.UpdatedAt, &idea.Slug, &idea.Status, &idea.HeldForReview, &linksInJSON,
+		&idea.RepoURL, &repoInJSON, &idea.Visibility, &collaboratorsInJSON,
+		&idea.Featured, &idea.FeaturedAt, &idea.GazesLast7d, &idea.TrendingScore, &idea.Version,
+		&idea.Org, &idea.Author, &reactionsInJSON, &idea.Archived, &idea.ArchivedAt, &idea.GithubIssueURL, &idea.Views, &launchInJSON,
+		&imagesInJSON)
+	if errInScanning != nil {
+		return idea, errInScanning
+	}
+	if repoInJSON != nil {
+		idea.Repo = &RepoMetadataStructure{}
+		errInDecodingRepo := json.Unmarshal(repoInJSON, idea.Repo)
+		if errInDecodingRepo != nil {
+			return idea, errInDecodingRepo
+		}
+	}
+
+	if launchInJSON != nil {
+		idea.Launch = &IdeaLaunchStructure{}
+		errInDecodingLaunch := json.Unmarshal(launchInJSON, idea.Launch)
+		if errInDecodingLaunch != nil {
+			return idea, errInDecodingLaunch
+		}
+	}
+
+	errInDecodingCollaborators := json.Unmarshal(collaboratorsInJSON, &idea.Collaborators)
+	if errInDecodingCollaborators != nil {
+		return idea, errInDecodingCollaborators
+	}
+	errInDecodingReactions := json.Unmarshal(reactionsInJSON, &idea.Reactions)
+	if errInDecodingReactions != nil {
+		return idea, errInDecodingReactions
+	}
+	errInDecodingImages := json.Unmarshal(imagesInJSON, &idea.Images)
+	if errInDecodingImages != nil {
+		return idea, errInDecodingImages
+	}
+
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		return idea, errInValidatingID
+	}
+	idea.ID = hexIdeaID
+
+	return idea, json.Unmarshal(linksInJSON, &idea.Links)
+}
+
+// postgresQuerier : Database or transaction, ideas are read the same way in both
+type postgresQuerier interface {
+	QueryContext(databaseContext context.Context, query string, arguments ...interface{}) (*sql.Rows, error)
+}
+
+func queryIdeas(databaseContext context.Context, sqlDatabase postgresQuerier, query string, arguments ...interface{}) ([]IdeaStructure, error) {
+	var ideas []IdeaStructure
+
+	ideaRows, errInQuerying := sqlDatabase.QueryContext(databaseContext, query, arguments...)
+	if errInQuerying != nil {
+		return ideas, errInQuerying
+	}
+	defer ideaRows.Close()
+
+	for ideaRows.Next() {
+		idea, errInScanning := scanIdea(ideaRows)
+		if errInScanning != nil {
+			return ideas, errInScanning
+		}
+		ideas = append(ideas, idea)
+	}
+
+	return ideas, ideaRows.Err()
+}
+
+// ListPublished : Rows are read whole, the fields are only picked when the response is written
+func (store postgresIdeasStore) ListPublished(databaseContext context.Context, filter IdeaListFilter, fields []string) ([]IdeaStructure, error) {
+	query := "SELECT " + ideaColumns + " FROM ideas WHERE held_for_review = FALSE"
+	var arguments []interface{}
+
+	if filter.DraftsOf != 0 {
+		arguments = append(arguments, filter.DraftsOf)
+		query += " AND (visibility = 'public' OR publisher_id = $" + strconv.Itoa(len(arguments)) +
+			" OR collaborators @> jsonb_build_array(jsonb_build_object('user_id', $" + strconv.Itoa(len(arguments)) + "::bigint)))"
+	} else {
+		query += " AND visibility = 'public'"
+	}
+
+	if filter.IncludeArchived == false {
+		query += " AND archived = FALSE"
+	}
+	if filter.Publisher != "" {
+		arguments = append(arguments, filter.Publisher)
+		query += " AND publisher = $" + strconv.Itoa(len(arguments))
+	}
+	if filter.CreatedAfter != 0 {
+		arguments = append(arguments, filter.CreatedAfter)
+		query += " AND created_at > $" + strconv.Itoa(len(arguments))
+	}
+	if filter.CreatedBefore != 0 {
+		arguments = append(arguments, filter.CreatedBefore)
+		query += " AND created_at < $" + strconv.Itoa(len(arguments))
+	}
+
+	return queryIdeas(databaseContext, store.sqlDatabase, query, arguments...)
+}
+
+func (store postgresIdeasStore) FindByID(databaseContext context.Context, ideaID primitive.ObjectID) (IdeaStructure, error) {
+	ideaRow := store.sqlDatabase.QueryRowContext(databaseContext,
+		"SELECT "+ideaColumns+" FROM ideas WHERE id = $1", ideaID.Hex())
+
+	idea, errInScanning := scanIdea(ideaRow)
+	if errInScanning == sql.ErrNoRows {
+		return idea, errNotFoundInStore
+	}
+	return idea, errInScanning
+}
+
+func hexOfIdeaIDs(ideaIDs []primitive.ObjectID) []string {
+	hexIdeaIDs := make([]string, len(ideaIDs))
+	for index, ideaID := range ideaIDs {
+		hexIdeaIDs[index] = ideaID.Hex()
+	}
+	return hexIdeaIDs
+}
+
+func findIdeasByIDs(databaseContext context.Context, sqlDatabase postgresQuerier, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+	return queryIdeas(databaseContext, sqlDatabase, "SELECT "+ideaColumns+" FROM ideas WHERE id = ANY($1)", pq.Array(hexOfIdeaIDs(ideaIDs)))
+}
+
+func (store postgresIdeasStore) FindByIDs(databaseContext context.Context, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+	return findIdeasByIDs(databaseContext, store.sqlDatabase, ideaIDs)
+}
+
+func (store postgresIdeasStore) ListLinkingTo(databaseContext context.Context, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+	return queryIdeas(databaseContext, store.sqlDatabase,
+		"SELECT "+ideaColumns+" FROM ideas WHERE EXISTS (SELECT 1 FROM jsonb_array_elements(links) AS link WHERE link->>'idea_id' = ANY($1))",
+		pq.Array(hexOfIdeaIDs(ideaIDs)))
+}
+
+// AddLink : Link writes take turns on an advisory lock held until the transaction ends
+func (store postgresIdeasStore) AddLink(databaseContext context.Context, ideaID primitive.ObjectID, link IdeaLinkStructure) error {
+	linkInJSON, errInEncoding := json.Marshal([]IdeaLinkStructure{link})
+	if errInEncoding != nil {
+		return errInEncoding
+	}
+
+	transaction, errInBeginning := store.sqlDatabase.BeginTx(databaseContext, nil)
+	if errInBeginning != nil {
+		return errInBeginning
+	}
+	defer transaction.Rollback()
+
+	_, errInLocking := transaction.ExecContext(databaseContext, "SELECT pg_advisory_xact_lock(hashtext('idea_links'))")
+	if errInLocking != nil {
+		return errInLocking
+	}
+
+	findIdeas := func(ideaIDs []primitive.ObjectID) ([]IdeaStructure, error) {
+		return findIdeasByIDs(databaseContext, transaction, ideaIDs)
+	}
+	errInAdding := addLinkWithoutCycle(findIdeas, ideaID, link, func() error {
+		_, errInUpdating := transaction.ExecContext(databaseContext,
+			"UPDATE ideas SET links = links || $1::jsonb WHERE id = $2", string(linkInJSON), ideaID.Hex())
+		return errInUpdating
+	})
+	if errInAdding != nil {
+		return errInAdding
+	}
+	return transaction.Commit()
+}
+
+func (store postgresIdeasStore) RemoveLink(databaseContext context.Context, ideaID primitive.ObjectID, linkedIdeaID primitive.ObjectID) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		`UPDATE ideas SET links = (
+			SELECT COALESCE(jsonb_agg(link), '[]') FROM jsonb_array_elements(links) AS link WHERE link->>'idea_id' <> $1)
+		WHERE id = $2 AND EXISTS (SELECT 1 FROM jsonb_array_elements(links) AS link WHERE link->>'idea_id' = $1)`,
+		linkedIdeaID.Hex(), ideaID.Hex())
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) ListByPublisherSince(databaseContext context.Context, publisherID int64, since int64, limit int64) ([]IdeaStructure, error) {
+	return queryIdeas(databaseContext, store.sqlDatabase,
+		"SELECT "+ideaColumns+" FROM ideas WHERE publisher_id = $1 AND created_at >= $2 ORDER BY created_at DESC LIMIT NULLIF($3, 0)",
+		publisherID, since, limit)
+}
+
+func (store postgresIdeasStore) CountByPublisherSince(databaseContext context.Context, publisherID int64, since int64) (int64, error) {
+	var ideasCount int64
+
+	errInCounting := store.sqlDatabase.QueryRowContext(databaseContext,
+		"SELECT COUNT(*) FROM ideas WHERE publisher_id = $1 AND created_at >= $2", publisherID, since).Scan(&ideasCount)
+	return ideasCount, errInCounting
+}
+
+func (store postgresIdeasStore) ListPublishedByPublishers(databaseContext context.Context, publisherIDs []int64,
+	skip int64, limit int64) ([]IdeaStructure, error) {
+	return queryIdeas(databaseContext, store.sqlDatabase,
+		"SELECT "+ideaColumns+" FROM ideas WHERE publisher_id = ANY($1) AND NOT archived AND held_for_review = FALSE AND visibility = 'public' ORDER BY created_at DESC, id DESC OFFSET $2 LIMIT $3",
+		pq.Array(publisherIDs), skip, limit)
+}
+
+func (store postgresIdeasStore) Insert(databaseContext context.Context, idea IdeaStructure) (primitive.ObjectID, error) {
+	idea.ID = primitive.NewObjectID()
+
+	if idea.Links == nil {
+		idea.Links = []IdeaLinkStructure{}
+	}
+	linksInJSON, errInEncoding := json.Marshal(idea.Links)
+	if errInEncoding != nil {
+		return idea.ID, errInEncoding
+	}
+
+	_, errInAdding := store.sqlDatabase.ExecContext(databaseContext,
+		"INSERT INTO ideas ("+ideaColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULL, $15, '[]', FALSE, 0, 0, 0, 0, $16, $17, '{}', FALSE, 0, '', 0, NULL, '[]')",
+		idea.ID.Hex(), idea.Name, idea.Description, idea.Publisher, idea.PublisherID, idea.Makers, idea.Gazers,
+		idea.CreatedAt, idea.UpdatedAt, idea.Slug, idea.Status, idea.HeldForReview, linksInJSON, idea.RepoURL, idea.Visibility,
+		idea.Org, idea.Author)
+	return idea.ID, errInAdding
+}
+
+func (store postgresIdeasStore) UpdateContent(databaseContext context.Context, ideaID primitive.ObjectID, contentUpdate IdeaContentUpdate) (IdeaStructure, error) {
+	changedColumns := []string{"updated_at = $1", "version = version + 1"}
+	arguments := []interface{}{contentUpdate.UpdatedAt}
+
+	if contentUpdate.Name != nil {
+		arguments = append(arguments, *contentUpdate.Name, *contentUpdate.Slug)
+		changedColumns = append(changedColumns, "name = $2", "slug = $3")
+	}
+	if contentUpdate.Description != nil {
+		arguments = append(arguments, *contentUpdate.Description)
+		changedColumns = append(changedColumns, "description = $"+strconv.Itoa(len(arguments)))
+	}
+	if contentUpdate.RepoURL != nil {
+		arguments = append(arguments, *contentUpdate.RepoURL)
+		changedColumns = append(changedColumns, "repo_url = $"+strconv.Itoa(len(arguments)), "repo = NULL")
+	}
+	if contentUpdate.Visibility != nil {
+		arguments = append(arguments, *contentUpdate.Visibility)
+		changedColumns = append(changedColumns, "visibility = $"+strconv.Itoa(len(arguments)))
+	}
+	arguments = append(arguments, ideaID.Hex())
+	ideaCondition := "id = $" + strconv.Itoa(len(arguments))
+	if contentUpdate.ExpectedVersion != nil {
+		arguments = append(arguments, *contentUpdate.ExpectedVersion)
+		ideaCondition += " AND version = $" + strconv.Itoa(len(arguments))
+	}
+
+	updatedIdea, errInUpdating := scanIdea(store.sqlDatabase.QueryRowContext(databaseContext,
+		"UPDATE ideas SET "+strings.Join(changedColumns, ", ")+" WHERE "+ideaCondition+" RETURNING "+ideaColumns, arguments...))
+	if errInUpdating != sql.ErrNoRows {
+		return updatedIdea, errInUpdating
+	}
+	if contentUpdate.ExpectedVersion == nil {
+		return updatedIdea, errNotFoundInStore
+	}
+
+	// Nothing matched either because the idea is gone or because it is at another version
+	var isIdeaThere bool
+	errInChecking := store.sqlDatabase.QueryRowContext(databaseContext,
+		"SELECT EXISTS (SELECT 1 FROM ideas WHERE id = $1)", ideaID.Hex()).Scan(&isIdeaThere)
+	if errInChecking != nil {
+		return updatedIdea, errInChecking
+	}
+	if isIdeaThere == false {
+		return updatedIdea, errNotFoundInStore
+	}
+	return updatedIdea, errConflictInStore
+}
+
+// AddCollaborator : Returns errDuplicateInStore when the user is a collaborator already or the idea is full
+func (store postgresIdeasStore) AddCollaborator(databaseContext context.Context, ideaID primitive.ObjectID,
+	collaborator IdeaCollaboratorStructure, maxCollaborators int) error {
+	collaboratorInJSON, errInEncoding := json.Marshal([]IdeaCollaboratorStructure{collaborator})
+	if errInEncoding != nil {
+		return errInEncoding
+	}
+
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		`UPDATE ideas SET collaborators = collaborators || $1::jsonb WHERE id = $2
+		AND NOT collaborators @> jsonb_build_array(jsonb_build_object('user_id', $3::bigint))
+		AND jsonb_array_length(collaborators) < $4`,
+		string(collaboratorInJSON), ideaID.Hex(), collaborator.UserID, maxCollaborators)
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errDuplicateInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) RemoveCollaborator(databaseContext context.Context, ideaID primitive.ObjectID, userID int64) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		`UPDATE ideas SET collaborators = (
+			SELECT COALESCE(jsonb_agg(collaborator), '[]') FROM jsonb_array_elements(collaborators) AS collaborator
+			WHERE (collaborator->>'user_id')::bigint <> $1)
+		WHERE id = $2 AND collaborators @> jsonb_build_array(jsonb_build_object('user_id', $1::bigint))`,
+		userID, ideaID.Hex())
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+// AddImage : Returns errDuplicateInStore when the idea has the image already or is full
+func (store postgresIdeasStore) AddImage(databaseContext context.Context, ideaID primitive.ObjectID, image IdeaImageStructure,
+	maxImages int64, updatedAt int64) error {
+	imageInJSON, errInEncoding := json.Marshal([]IdeaImageStructure{image})
+	if errInEncoding != nil {
+		return errInEncoding
+	}
+
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		`UPDATE ideas SET images = images || $1::jsonb, updated_at = $2 WHERE id = $3
+		AND NOT images @> jsonb_build_array(jsonb_build_object('hash', $4::text))
+		AND jsonb_array_length(images) < $5`,
+		string(imageInJSON), updatedAt, ideaID.Hex(), image.Hash, maxImages)
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errDuplicateInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) RemoveImage(databaseContext context.Context, ideaID primitive.ObjectID, imageHash string, updatedAt int64) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		`UPDATE ideas SET images = (
+			SELECT COALESCE(jsonb_agg(image), '[]') FROM jsonb_array_elements(images) AS image
+			WHERE image->>'hash' <> $1), updated_at = $2
+		WHERE id = $3 AND images @> jsonb_build_array(jsonb_build_object('hash', $1::text))`,
+		imageHash, updatedAt, ideaID.Hex())
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) SetFeatured(databaseContext context.Context, ideaID primitive.ObjectID, featured bool, featuredAt int64) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE ideas SET featured = $1, featured_at = $2 WHERE id = $3", featured, featuredAt, ideaID.Hex())
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) SetArchived(databaseContext context.Context, ideaID primitive.ObjectID, archived bool, archivedAt int64) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE ideas SET archived = $1, archived_at = $2 WHERE id = $3", archived, archivedAt, ideaID.Hex())
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) SetGithubIssueURL(databaseContext context.Context, ideaID primitive.ObjectID, issueURL string) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE ideas SET github_issue_url = $1 WHERE id = $2", issueURL, ideaID.Hex())
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) SetHeldForReview(databaseContext context.Context, ideaID primitive.ObjectID, held bool) (bool, error) {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE ideas SET held_for_review = $1 WHERE id = $2 AND held_for_review <> $1", held, ideaID.Hex())
+	if errInUpdating != nil {
+		return false, errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows > 0 {
+		return true, nil
+	}
+
+	var isIdeaFound bool
+	errInFinding := store.sqlDatabase.QueryRowContext(databaseContext,
+		"SELECT EXISTS (SELECT 1 FROM ideas WHERE id = $1)", ideaID.Hex()).Scan(&isIdeaFound)
+	if errInFinding != nil {
+		return false, errInFinding
+	}
+	if isIdeaFound == false {
+		return false, errNotFoundInStore
+	}
+	return false, nil
+}
+
+func (store postgresIdeasStore) ListWithStaleRepo(databaseContext context.Context, staleBefore int64, limit int64) ([]IdeaStructure, error) {
+	return queryIdeas(databaseContext, store.sqlDatabase,
+		"SELECT "+ideaColumns+" FROM ideas WHERE repo_url <> '' AND (repo IS NULL OR (repo->>'synced_at')::BIGINT < $1) ORDER BY (repo->>'synced_at')::BIGINT NULLS FIRST LIMIT $2",
+		staleBefore, limit)
+}
+
+func (store postgresIdeasStore) SetRepo(databaseContext context.Context, ideaID primitive.ObjectID, repo RepoMetadataStructure) error {
+	repoInJSON, errInEncoding := json.Marshal(repo)
+	if errInEncoding != nil {
+		return errInEncoding
+	}
+
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE ideas SET repo = $1 WHERE id = $2", repoInJSON, ideaID.Hex())
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) SetLaunched(databaseContext context.Context, ideaID primitive.ObjectID, launch IdeaLaunchStructure) error {
+	launchInJSON, errInEncoding := json.Marshal(launch)
+	if errInEncoding != nil {
+		return errInEncoding
+	}
+
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE ideas SET status = $1, launch = $2 WHERE id = $3", ideaStatusLaunched, launchInJSON, ideaID.Hex())
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) ListLaunched(databaseContext context.Context, skip int64, limit int64) ([]IdeaStructure, error) {
+	return queryIdeas(databaseContext, store.sqlDatabase,
+		"SELECT "+ideaColumns+" FROM ideas WHERE status = $1 AND NOT archived AND held_for_review = FALSE AND visibility = 'public' ORDER BY (launch->>'launched_at')::BIGINT DESC NULLS LAST, id DESC OFFSET $2 LIMIT $3",
+		ideaStatusLaunched, skip, limit)
+}
+
+func (store postgresIdeasStore) ListFeatured(databaseContext context.Context) ([]IdeaStructure, error) {
+	return queryIdeas(databaseContext, store.sqlDatabase,
+		"SELECT "+ideaColumns+" FROM ideas WHERE featured AND NOT archived AND held_for_review = FALSE AND visibility = 'public' ORDER BY featured_at DESC")
+}
+
+// SearchPublished : Words are letters and digits only, so they are joined into the query as they are
+func (store postgresIdeasStore) SearchPublished(databaseContext context.Context, words []string, limit int64) ([]IdeaStructure, error) {
+	lowerCaseWords := make([]string, len(words))
+	for index, word := range words {
+		lowerCaseWords[index] = strings.ToLower(word)
+	}
+
+	return queryIdeas(databaseContext, store.sqlDatabase,
+		`SELECT `+ideaColumns+` FROM ideas, to_tsquery('simple', $1) AS search
+		WHERE held_for_review = FALSE AND visibility = 'public' AND to_tsvector('simple', name || ' ' || description) @@ search
+		ORDER BY ts_rank(setweight(to_tsvector('simple', name), 'A') || to_tsvector('simple', description), search) DESC, id DESC
+		LIMIT $2`,
+		strings.Join(lowerCaseWords, " | "), limit)
+}
+
+func (store postgresIdeasStore) SuggestByPrefix(databaseContext context.Context, slugPrefix string,
+	limit int64) ([]IdeaSuggestionStructure, error) {
+	var suggestions []IdeaSuggestionStructure
+
+	// Slugs have no % or _ to escape
+	suggestionRows, errInQuerying := store.sqlDatabase.QueryContext(databaseContext,
+		"SELECT id, name, slug FROM ideas WHERE slug LIKE $1 AND NOT archived AND held_for_review = FALSE AND visibility = 'public' ORDER BY gazers DESC LIMIT $2",
+		slugPrefix+"%", limit)
+	if errInQuerying != nil {
+		return suggestions, errInQuerying
+	}
+	defer suggestionRows.Close()
+
+	for suggestionRows.Next() {
+		var suggestion IdeaSuggestionStructure
+		var ideaID string
+		errInScanning := suggestionRows.Scan(&ideaID, &suggestion.Name, &suggestion.Slug)
+		if errInScanning != nil {
+			return suggestions, errInScanning
+		}
+		suggestion.ID, errInScanning = primitive.ObjectIDFromHex(ideaID)
+		if errInScanning != nil {
+			return suggestions, errInScanning
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, suggestionRows.Err()
+}
+
+func (store postgresIdeasStore) Delete(databaseContext context.Context, ideaID primitive.ObjectID) error {
+	result, errInDeleting := store.sqlDatabase.ExecContext(databaseContext, "DELETE FROM ideas WHERE id = $1", ideaID.Hex())
+	if errInDeleting != nil {
+		return errInDeleting
+	}
+	if deletedRows, _ := result.RowsAffected(); deletedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresIdeasStore) IncrementGazers(databaseContext context.Context, ideaID primitive.ObjectID, increment int64) (int64, error) {
+	var gazers int64
+	errInUpdating := store.sqlDatabase.QueryRowContext(databaseContext,
+		"UPDATE ideas SET gazers = gazers + $1, gazes_last_7d = gazes_last_7d + $1, trending_score = trending_score + $1 WHERE id = $2 RETURNING gazers",
+		increment, ideaID.Hex()).Scan(&gazers)
+	if errInUpdating == sql.ErrNoRows {
+		return 0, errNotFoundInStore
+	}
+	return gazers, errInUpdating
+}
+
+func (store postgresIdeasStore) AddViews(databaseContext context.Context, viewsOfIdeas map[primitive.ObjectID]int64) error {
+	ideaIDs := make([]string, 0, len(viewsOfIdeas))
+	views := make([]int64, 0, len(viewsOfIdeas))
+	for ideaID, viewsOfIdea := range viewsOfIdeas {
+		ideaIDs = append(ideaIDs, ideaID.Hex())
+		views = append(views, viewsOfIdea)
+	}
+
+	_, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE ideas SET views = ideas.views + viewed.views FROM unnest($1::TEXT[], $2::BIGINT[]) AS viewed (id, views) WHERE ideas.id = viewed.id",
+		pq.Array(ideaIDs), pq.Array(views))
+	return errInUpdating
+}
+
+func (store postgresIdeasStore) IncrementReaction(databaseContext context.Context, ideaID primitive.ObjectID, reaction string,
+	increment int64) error {
+	_, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE ideas SET reactions = jsonb_set(reactions, ARRAY[$1], to_jsonb(COALESCE((reactions->>$1)::BIGINT, 0) + $2)) WHERE id = $3",
+		reaction, increment, ideaID.Hex())
+	return errInUpdating
+}
+
+func (store postgresIdeasStore) ListByMomentum(databaseContext context.Context, sortBy string, limit int64) ([]IdeaStructure, error) {
+	// sortBy is one of the sort constants, never input
+	return queryIdeas(databaseContext, store.sqlDatabase,
+		"SELECT "+ideaColumns+" FROM ideas WHERE gazes_last_7d > 0 AND NOT archived AND held_for_review = FALSE AND visibility = 'public' ORDER BY "+
+			sortBy+" DESC, id DESC LIMIT $1", limit)
+}
+
+func (store postgresIdeasStore) RecomputeMomentum(databaseContext context.Context, now int64) (int64, error) {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext, `
+		WITH momentum AS (
+			SELECT idea_id, COUNT(*) AS gazes, SUM(POWER(0.5, ($1 - created_at)::DOUBLE PRECISION / $3)) AS score
+			FROM likes WHERE created_at >= $2 GROUP BY idea_id
+		), recomputed AS (
+			SELECT ideas.id, COALESCE(momentum.gazes, 0) AS gazes, COALESCE(momentum.score, 0) AS score
+			FROM ideas LEFT JOIN momentum ON momentum.idea_id = ideas.id
+		)
+		UPDATE ideas SET gazes_last_7d = recomputed.gazes, trending_score = recomputed.score FROM recomputed
+		WHERE ideas.id = recomputed.id AND (ideas.gazes_last_7d <> recomputed.gazes OR ideas.trending_score <> recomputed.score)`,
+		now, now-int64(trendingWindow/time.Second), int64(trendingHalfLife/time.Second))
+	if errInUpdating != nil {
+		return 0, errInUpdating
+	}
+	return result.RowsAffected()
+}
+
+func (store postgresUsersStore) FindByUserID(databaseContext context.Context, userID int64) (UserStructure, error) {
+	var user UserStructure
+
+	errInScanning := store.sqlDatabase.QueryRowContext(databaseContext,
+		"SELECT "+userColumns+" FROM users WHERE user_id = $1", userID).
+		Scan(&user.UserID, &user.Login, &user.Name, &user.CreatedAt, &user.Role, &user.DisplayName, &user.Bio, &user.Website, &user.Location,
+			&user.Banned, &user.SuspendedUntil, &user.SuspensionReason)
+	if errInScanning == sql.ErrNoRows {
+		return user, errNotFoundInStore
+	}
+	return user, errInScanning
+}
+
+func (store postgresUsersStore) FindByLogin(databaseContext context.Context, login string) (UserStructure, error) {
+	var user UserStructure
+
+	errInScanning := store.sqlDatabase.QueryRowContext(databaseContext,
+		"SELECT "+userColumns+" FROM users WHERE login = $1", login).
+		Scan(&user.UserID, &user.Login, &user.Name, &user.CreatedAt, &user.Role, &user.DisplayName, &user.Bio, &user.Website, &user.Location,
+			&user.Banned, &user.SuspendedUntil, &user.SuspensionReason)
+	if errInScanning == sql.ErrNoRows {
+		return user, errNotFoundInStore
+	}
+	return user, errInScanning
+}
+
+func (store postgresUsersStore) Insert(databaseContext context.Context, user UserStructure) error {
+	_, errInAdding := store.sqlDatabase.ExecContext(databaseContext,
+		"INSERT INTO users (user_id, login, name, created_at, role) VALUES ($1, $2, $3, $4, $5)",
+		user.UserID, user.Login, user.Name, user.CreatedAt, user.Role)
+	if errInAdding != nil && isPostgresUniqueViolation(errInAdding) {
+		return errDuplicateInStore
+	}
+	return errInAdding
+}
+
+func (store postgresUsersStore) UpdateSettings(databaseContext context.Context, userID int64, settings UserSettingsStructure) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE users SET display_name = $1, bio = $2, website = $3, location = $4 WHERE user_id = $5",
+		settings.DisplayName, settings.Bio, settings.Website, settings.Location, userID)
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresUsersStore) UpdateSuspension(databaseContext context.Context, userID int64, suspension UserSuspensionStructure) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE users SET banned = $1, suspended_until = $2, suspension_reason = $3 WHERE user_id = $4",
+		suspension.Banned, suspension.SuspendedUntil, suspension.SuspensionReason, userID)
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresUsersStore) UpdateRole(databaseContext context.Context, userID int64, role string) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext, "UPDATE users SET role = $1 WHERE user_id = $2", role, userID)
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	if updatedRows, _ := result.RowsAffected(); updatedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresUsersStore) DeleteAccount(databaseContext context.Context, userID int64, deleteIdeas bool,
+	now int64) ([]primitive.ObjectID, error) {
+	transaction, errInBeginning := store.sqlDatabase.BeginTx(databaseContext, nil)
+	if errInBeginning != nil {
+		return nil, errInBeginning
+	}
+	defer transaction.Rollback()
+
+	// A user gazes an idea once, so every idea is updated by a single deleted gaze
+	_, errInTakingBack := transaction.ExecContext(databaseContext, `
+		WITH deleted AS (DELETE FROM likes WHERE user_id = $1 RETURNING idea_id, reaction, created_at)
+		UPDATE ideas SET gazers = gazers - 1,
+			gazes_last_7d = gazes_last_7d - CASE WHEN deleted.created_at >= $2 THEN 1 ELSE 0 END,
+			trending_score = GREATEST(trending_score - CASE WHEN deleted.created_at >= $2
+				THEN POWER(0.5, ($3 - deleted.created_at)::DOUBLE PRECISION / $4) ELSE 0 END, 0),
+			reactions = jsonb_set(reactions, ARRAY[deleted.reaction], to_jsonb(COALESCE((reactions->>deleted.reaction)::BIGINT, 0) - 1))
+		FROM deleted WHERE ideas.id = deleted.idea_id`,
+		userID, now-int64(trendingWindow/time.Second), now, int64(trendingHalfLife/time.Second))
+	if errInTakingBack != nil {
+		return nil, errInTakingBack
+	}
+
+	_, errInRemovingCollaborator := transaction.ExecContext(databaseContext,
+		`UPDATE ideas SET collaborators = (
+			SELECT COALESCE(jsonb_agg(collaborator), '[]') FROM jsonb_array_elements(collaborators) AS collaborator
+			WHERE (collaborator->>'user_id')::bigint <> $1)
+		WHERE collaborators @> jsonb_build_array(jsonb_build_object('user_id', $1::bigint))`, userID)
+	if errInRemovingCollaborator != nil {
+		return nil, errInRemovingCollaborator
+	}
+
+	// Ideas published under an org stay with it whatever happens to the other ideas of the user
+	_, errInAnonymizingOrgIdeas := transaction.ExecContext(databaseContext,
+		"UPDATE ideas SET author = $1, publisher_id = 0 WHERE publisher_id = $2 AND org <> ''", deletedUserLogin, userID)
+	if errInAnonymizingOrgIdeas != nil {
+		return nil, errInAnonymizingOrgIdeas
+	}
+
+	var deletedIdeaIDs []primitive.ObjectID
+	if deleteIdeas == true {
+		deletedRows, errInDeletingIdeas := transaction.QueryContext(databaseContext,
+			"DELETE FROM ideas WHERE publisher_id = $1 RETURNING id", userID)
+		if errInDeletingIdeas != nil {
+			return nil, errInDeletingIdeas
+		}
+		var hexIdeaIDs []string
+		for deletedRows.Next() {
+			var hexIdeaID string
+			if errInScanning := deletedRows.Scan(&hexIdeaID); errInScanning != nil {
+				deletedRows.Close()
+				return nil, errInScanning
+			}
+			hexIdeaIDs = append(hexIdeaIDs, hexIdeaID)
+			if ideaID, errInID := primitive.ObjectIDFromHex(hexIdeaID); errInID == nil {
+				deletedIdeaIDs = append(deletedIdeaIDs, ideaID)
+			}
+		}
+		deletedRows.Close()
+		if errInRows := deletedRows.Err(); errInRows != nil {
+			return nil, errInRows
+		}
+
+		_, errInDeletingLikes := transaction.ExecContext(databaseContext, "DELETE FROM likes WHERE idea_id = ANY($1)", pq.Array(hexIdeaIDs))
+		if errInDeletingLikes != nil {
+			return nil, errInDeletingLikes
+		}
+	} else {
+		_, errInAnonymizingIdeas := transaction.ExecContext(databaseContext,
+			"UPDATE ideas SET publisher = $1, publisher_id = 0 WHERE publisher_id = $2", deletedUserLogin, userID)
+		if errInAnonymizingIdeas != nil {
+			return nil, errInAnonymizingIdeas
+		}
+	}
+
+	_, errInDeletingUser := transaction.ExecContext(databaseContext, "DELETE FROM users WHERE user_id = $1", userID)
+	if errInDeletingUser != nil {
+		return nil, errInDeletingUser
+	}
+
+	return deletedIdeaIDs, transaction.Commit()
+}
+
+func (store postgresLikesStore) Insert(databaseContext context.Context, like IdeaLikesStructure) error {
+	_, errInAdding := store.sqlDatabase.ExecContext(databaseContext,
+		"INSERT INTO likes (user_id, idea_id, ip_hash, reaction, created_at) VALUES ($1, $2, $3, $4, $5)",
+		like.UserID, like.IdeaID.Hex(), like.IPHash, like.Reaction, like.CreatedAt)
+	if errInAdding != nil && isPostgresUniqueViolation(errInAdding) {
+		return errDuplicateInStore
+	}
+	return errInAdding
+}
+
+func (store postgresLikesStore) Find(databaseContext context.Context, userID int64, ideaID primitive.ObjectID) (IdeaLikesStructure, error) {
+	like := IdeaLikesStructure{UserID: userID, IdeaID: ideaID}
+
+	errInScanning := store.sqlDatabase.QueryRowContext(databaseContext,
+		"SELECT ip_hash, reaction, created_at FROM likes WHERE user_id = $1 AND idea_id = $2", userID, ideaID.Hex()).
+		Scan(&like.IPHash, &like.Reaction, &like.CreatedAt)
+	if errInScanning == sql.ErrNoRows {
+		return like, errNotFoundInStore
+	}
+	return like, errInScanning
+}
+
+func (store postgresLikesStore) UpdateReaction(databaseContext context.Context, userID int64, ideaID primitive.ObjectID,
+	fromReaction string, toReaction string) error {
+	result, errInUpdating := store.sqlDatabase.ExecContext(databaseContext,
+		"UPDATE likes SET reaction = $1 WHERE user_id = $2 AND idea_id = $3 AND reaction = $4",
+		toReaction, userID, ideaID.Hex(), fromReaction)
+	if errInUpdating != nil {
+		return errInUpdating
+	}
+	changedRows, errInCounting := result.RowsAffected()
+	if errInCounting != nil {
+		return errInCounting
+	}
+	if changedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresLikesStore) Delete(databaseContext context.Context, userID int64, ideaID primitive.ObjectID) error {
+	_, errInDeleting := store.sqlDatabase.ExecContext(databaseContext,
+		"DELETE FROM likes WHERE user_id = $1 AND idea_id = $2", userID, ideaID.Hex())
+	return errInDeleting
+}
+
+func (store postgresLikesStore) DeleteByIdea(databaseContext context.Context, ideaID primitive.ObjectID) error {
+	_, errInDeleting := store.sqlDatabase.ExecContext(databaseContext, "DELETE FROM likes WHERE idea_id = $1", ideaID.Hex())
+	return errInDeleting
+}
+
+func (store postgresLikesStore) ListByUser(databaseContext context.Context, userID int64) ([]IdeaLikesStructure, error) {
+	var likes []IdeaLikesStructure
+
+	likeRows, errInQuerying := store.sqlDatabase.QueryContext(databaseContext,
+		"SELECT user_id, idea_id, ip_hash, reaction, created_at FROM likes WHERE user_id = $1", userID)
+	if errInQuerying != nil {
+		return likes, errInQuerying
+	}
+	defer likeRows.Close()
+
+	for likeRows.Next() {
+		var like IdeaLikesStructure
+		var ideaID string
+		errInScanning := likeRows.Scan(&like.UserID, &ideaID, &like.IPHash, &like.Reaction, &like.CreatedAt)
+		if errInScanning != nil {
+			return likes, errInScanning
+		}
+
+		like.IdeaID, errInScanning = primitive.ObjectIDFromHex(ideaID)
+		if errInScanning != nil {
+			return likes, errInScanning
+		}
+		likes = append(likes, like)
+	}
+
+	return likes, likeRows.Err()
+}
+
+func (store postgresLikesStore) ListGazedAmong(databaseContext context.Context, userID int64,
+	ideaIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	var gazedIdeaIDs []primitive.ObjectID
+
+	likeRows, errInQuerying := store.sqlDatabase.QueryContext(databaseContext,
+		"SELECT idea_id FROM likes WHERE user_id = $1 AND idea_id = ANY($2)", userID, pq.Array(hexOfIdeaIDs(ideaIDs)))
+	if errInQuerying != nil {
+		return gazedIdeaIDs, errInQuerying
+	}
+	defer likeRows.Close()
+
+	for likeRows.Next() {
+		var ideaID string
+		errInScanning := likeRows.Scan(&ideaID)
+		if errInScanning != nil {
+			return gazedIdeaIDs, errInScanning
+		}
+
+		gazedIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+		if errInValidatingID != nil {
+			return gazedIdeaIDs, errInValidatingID
+		}
+		gazedIdeaIDs = append(gazedIdeaIDs, gazedIdeaID)
+	}
+
+	return gazedIdeaIDs, likeRows.Err()
+}
+
+// Insert : Postgres has no TTL index, expired sessions of the user are deleted along with each new one
+func (store postgresSessionsStore) Insert(databaseContext context.Context, session SessionStructure) error {
+	transaction, errInBeginning := store.sqlDatabase.BeginTx(databaseContext, nil)
+	if errInBeginning != nil {
+		return errInBeginning
+	}
+	defer transaction.Rollback()
+
+	_, errInDeletingExpired := transaction.ExecContext(databaseContext,
+		"DELETE FROM sessions WHERE user_id = $1 AND expires_at < NOW()", session.UserID)
+	if errInDeletingExpired != nil {
+		return errInDeletingExpired
+	}
+
+	_, errInAdding := transaction.ExecContext(databaseContext,
+		"INSERT INTO sessions (token_hash, user_id, login, name, provider, github_token, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		session.TokenHash, session.UserID, session.Login, session.Name, session.Provider, session.GithubToken,
+		session.CreatedAt, session.ExpiresAt)
+	if isPostgresUniqueViolation(errInAdding) {
+		return errDuplicateInStore
+	}
+	if errInAdding != nil {
+		return errInAdding
+	}
+	return transaction.Commit()
+}
+
+func (store postgresSessionsStore) FindByTokenHash(databaseContext context.Context, tokenHash string) (SessionStructure, error) {
+	var session SessionStructure
+	errInFinding := store.sqlDatabase.QueryRowContext(databaseContext,
+		"SELECT token_hash, user_id, login, name, provider, github_token, created_at, expires_at FROM sessions WHERE token_hash = $1",
+		tokenHash).Scan(&session.TokenHash, &session.UserID, &session.Login, &session.Name, &session.Provider,
+		&session.GithubToken, &session.CreatedAt, &session.ExpiresAt)
+	if errInFinding == sql.ErrNoRows {
+		return session, errNotFoundInStore
+	}
+	return session, errInFinding
+}
+
+func (store postgresSessionsStore) Delete(databaseContext context.Context, tokenHash string) error {
+	result, errInDeleting := store.sqlDatabase.ExecContext(databaseContext, "DELETE FROM sessions WHERE token_hash = $1", tokenHash)
+	if errInDeleting != nil {
+		return errInDeleting
+	}
+	if deletedRows, _ := result.RowsAffected(); deletedRows == 0 {
+		return errNotFoundInStore
+	}
+	return nil
+}
+
+func (store postgresSessionsStore) DeleteByUser(databaseContext context.Context, userID int64) error {
+	_, errInDeleting := store.sqlDatabase.ExecContext(databaseContext, "DELETE FROM sessions WHERE user_id = $1", userID)
+	return errInDeleting
+}
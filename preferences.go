@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NotificationEventPreferences : Which events are let through on one channel
+type NotificationEventPreferences struct {
+	Gazes       bool `json:"gazes" bson:"gazes"`
+	Comments    bool `json:"comments" bson:"comments"`
+	Mentions    bool `json:"mentions" bson:"mentions"`
+	IdeaUpdates bool `json:"idea_updates" bson:"idea_updates"`
+	Digests     bool `json:"digests" bson:"digests"`
+}
+
+// UserPreferencesStructure : Structure of settings document in user_preferences collection, one per user
+type UserPreferencesStructure struct {
+	UserID    int64                        `json:"-" bson:"_id"`
+	InApp     NotificationEventPreferences `json:"in_app" bson:"in_app"`
+	Email     NotificationEventPreferences `json:"email" bson:"email"`
+	UpdatedAt int64                        `json:"updated_at" bson:"updated_at"`
+}
+
+// NotificationEventPreferencesInput : Structure for incoming changes of one channel, only the events sent are changed
+type NotificationEventPreferencesInput struct {
+	Gazes       *bool `json:"gazes"`
+	Comments    *bool `json:"comments"`
+	Mentions    *bool `json:"mentions"`
+	IdeaUpdates *bool `json:"idea_updates"`
+	Digests     *bool `json:"digests"`
+}
+
+// UserPreferencesInput : Structure for incoming preferences
+type UserPreferencesInput struct {
+	InApp *NotificationEventPreferencesInput `json:"in_app"`
+	Email *NotificationEventPreferencesInput `json:"email"`
+}
+
+// notificationPreferenceOfKind : Field of NotificationEventPreferences deciding on each kind of notification
+var notificationPreferenceOfKind = map[string]string{
+	notificationKindIdeaUpdated:  "idea_updates",
+	notificationKindIdeaProgress: "idea_updates",
+	notificationKindIdeaLaunched: "idea_updates",
+	notificationKindIdeaGazed:    "gazes",
+}
+
+// defaultUserPreferences : Users who never saved preferences get every in-app notification and no emails
+func defaultUserPreferences(userID int64) UserPreferencesStructure {
+	return UserPreferencesStructure{
+		UserID: userID,
+		InApp:  NotificationEventPreferences{Gazes: true, Comments: true, Mentions: true, IdeaUpdates: true, Digests: true},
+	}
+}
+
+func (preferences *NotificationEventPreferences) apply(input *NotificationEventPreferencesInput) {
+	if input == nil {
+		return
+	}
+	if input.Gazes != nil {
+		preferences.Gazes = *input.Gazes
+	}
+	if input.Comments != nil {
+		preferences.Comments = *input.Comments
+	}
+	if input.Mentions != nil {
+		preferences.Mentions = *input.Mentions
+	}
+	if input.IdeaUpdates != nil {
+		preferences.IdeaUpdates = *input.IdeaUpdates
+	}
+	if input.Digests != nil {
+		preferences.Digests = *input.Digests
+	}
+}
+
+func findUserPreferences(databaseContext context.Context, databaseClient *mongo.Client, userID int64) (UserPreferencesStructure, error) {
+	preferencesCollection := databaseClient.Database("sardene-db").Collection("user_preferences")
+
+	preferences := defaultUserPreferences(userID)
+	errInDecoding := preferencesCollection.FindOne(databaseContext, bson.M{"_id": userID}).Decode(&preferences)
+	if errInDecoding != nil && errInDecoding != mongo.ErrNoDocuments {
+		return preferences, errInDecoding
+	}
+	return preferences, nil
+}
+
+// usersOptedOutOf : Users among userIDs who turned off the channel for the kind of notification
+func usersOptedOutOf(databaseContext context.Context, databaseClient *mongo.Client, userIDs []int64, channel string,
+	kind string) (map[int64]bool, error) {
+	optedOut := map[int64]bool{}
+	preference, hasPreference := notificationPreferenceOfKind[kind]
+	if hasPreference == false || len(userIDs) == 0 {
+		return optedOut, nil
+	}
+
+	preferencesCollection := databaseClient.Database("sardene-db").Collection("user_preferences")
+	optedOutIDs, errInFinding := preferencesCollection.Distinct(databaseContext, "_id",
+		bson.M{"_id": bson.M{"$in": userIDs}, channel + "." + preference: false}, options.Distinct())
+	if errInFinding != nil {
+		return optedOut, errInFinding
+	}
+	for _, optedOutID := range optedOutIDs {
+		if userID, isUserID := optedOutID.(int64); isUserID {
+			optedOut[userID] = true
+		}
+	}
+	return optedOut, nil
+}
+
+func getUserPreferences(ginContext *gin.Context, databaseClient *mongo.Client) {
+	user := getAuthenticatedUser(ginContext)
+
+	preferences, errInFinding := findUserPreferences(ginContext.Request.Context(), databaseClient, user.UserID)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": preferences})
+}
+
+func updateUserPreferences(ginContext *gin.Context, databaseClient *mongo.Client) {
+	user := getAuthenticatedUser(ginContext)
+
+	var jsonInput UserPreferencesInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil || (jsonInput.InApp == nil && jsonInput.Email == nil) {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Preferences of in_app or email notifications are not provided"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	preferences, errInFinding := findUserPreferences(databaseContext, databaseClient, user.UserID)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	preferences.InApp.apply(jsonInput.InApp)
+	preferences.Email.apply(jsonInput.Email)
+	preferences.UpdatedAt = time.Now().Unix()
+
+	preferencesCollection := databaseClient.Database("sardene-db").Collection("user_preferences")
+	_, errInSaving := preferencesCollection.ReplaceOne(databaseContext, bson.M{"_id": user.UserID}, preferences,
+		options.Replace().SetUpsert(true))
+	if errInSaving != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": preferences})
+}
+
+func deleteUserPreferences(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	preferencesCollection := databaseClient.Database("sardene-db").Collection("user_preferences")
+
+	_, errInDeleting := preferencesCollection.DeleteOne(databaseContext, bson.M{"_id": userID})
+	return errInDeleting
+}
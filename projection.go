@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ideaFieldsInDB : Fields of an idea that can be asked for with ?fields=, by their json name
+var ideaFieldsInDB = map[string]string{
+	"id":          "_id",
+	"name":        "name",
+	"description": "description",
+	// Rendered from the description with ?render=html
+	"description_html": "description",
+	"publisher":        "publisher",
+	"publisher_id":     "publisher_id",
+	"org":              "org",
+	"author":           "author",
+	"makers":           "makers",
+	"gazers":           "gazers",
+	"views":            "views",
+	"reactions":        "reactions",
+	"created_at":       "created_at",
+	"updated_at":       "updated_at",
+	"slug":             "slug",
+	"status":           "status",
+	"held_for_review":  "held_for_review",
+	"visibility":       "visibility",
+	"featured":         "featured",
+	"featured_at":      "featured_at",
+	"archived":         "archived",
+	"archived_at":      "archived_at",
+	"gazes_last_7d":    "gazes_last_7d",
+	"trending_score":   "trending_score",
+	"version":          "version",
+	"collaborators":    "collaborators",
+	"links":            "links",
+	"repo_url":         "repo_url",
+	"repo":             "repo",
+	"github_issue_url": "github_issue_url",
+	"launch":           "launch",
+	"images":           "images",
+	// Looked up for the signed in caller by the id of the idea
+	"gazed_by_me": "_id",
+	"made_by_me":  "_id",
+}
+
+// parseIdeaFields : Fields asked for in ?fields=name,gazers, none means the whole idea
+func parseIdeaFields(fieldsQuery string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(fieldsQuery, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if _, isKnownField := ideaFieldsInDB[field]; isKnownField == false {
+			return nil, errors.New("Unknown field " + field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func isFieldAskedFor(fields []string, field string) bool {
+	for _, askedField := range fields {
+		if askedField == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ideaProjection : Mongo projection of the fields, nil when the whole idea is needed
+func ideaProjection(fields []string) bson.M {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	projection := bson.M{"_id": 0}
+	for _, field := range fields {
+		projection[ideaFieldsInDB[field]] = 1
+	}
+	return projection
+}
+
+// selectIdeaFields : Ideas with only the asked fields, so the ones not read from the database are not sent as zero values
+func selectIdeaFields(ideas []IdeaStructure, fields []string) ([]map[string]interface{}, error) {
+	selectedIdeas := make([]map[string]interface{}, 0, len(ideas))
+
+	for _, idea := range ideas {
+		ideaInJSON, errInEncoding := json.Marshal(idea)
+		if errInEncoding != nil {
+			return nil, errInEncoding
+		}
+		var ideaFields map[string]interface{}
+		errInDecoding := json.Unmarshal(ideaInJSON, &ideaFields)
+		if errInDecoding != nil {
+			return nil, errInDecoding
+		}
+
+		selectedIdea := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			selectedIdea[field] = ideaFields[field]
+		}
+		selectedIdeas = append(selectedIdeas, selectedIdea)
+	}
+
+	return selectedIdeas, nil
+}
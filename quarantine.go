@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	quarantineLinkPolicyHold  = "hold"
+	quarantineLinkPolicyStrip = "strip"
+)
+
+// QuarantineConfig : Limits applied to accounts that are younger than the configured age
+type QuarantineConfig struct {
+	AccountAgeSeconds int64
+	DailyIdeaQuota    int64
+	LinkPolicy        string
+}
+
+var linksInTextRegex = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+
+func loadQuarantineConfig(configLoader *ConfigLoader) QuarantineConfig {
+	var quarantineConfig QuarantineConfig
+
+	quarantineConfig.AccountAgeSeconds = configLoader.Int("QUARANTINE_ACCOUNT_AGE_HOURS", 72) * 60 * 60
+	quarantineConfig.DailyIdeaQuota = configLoader.Int("QUARANTINE_DAILY_IDEAS", 3)
+	quarantineConfig.LinkPolicy = configLoader.OneOf("QUARANTINE_LINK_POLICY", quarantineLinkPolicyHold,
+		quarantineLinkPolicyHold, quarantineLinkPolicyStrip)
+
+	return quarantineConfig
+}
+
+// isUserQuarantined : A user is quarantined until their account is older than the configured age,
+// after which the limits are lifted without any moderator action
+func isUserQuarantined(databaseContext context.Context, githubUser GithubUserProfileStructure, stores Stores, quarantineConfig QuarantineConfig) (bool, error) {
+	if quarantineConfig.AccountAgeSeconds <= 0 {
+		return false, nil
+	}
+
+	userInDB, errInFindingUser := stores.Users.FindByUserID(databaseContext, githubUser.UserID)
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			// User has not gone through /auth yet, so they are as new as it gets
+			return true, nil
+		}
+		return false, errInFindingUser
+	}
+
+	// Accounts created before registration time was recorded are treated as established
+	if userInDB.CreatedAt == 0 {
+		return false, nil
+	}
+
+	accountAge := time.Now().Unix() - userInDB.CreatedAt
+
+	return accountAge < quarantineConfig.AccountAgeSeconds, nil
+}
+
+func countIdeasPublishedToday(databaseContext context.Context, githubUser GithubUserProfileStructure, stores Stores) (int64, error) {
+
+	dayAgo := time.Now().Add(-24 * time.Hour).Unix()
+
+	return stores.Ideas.CountByPublisherSince(databaseContext, githubUser.UserID, dayAgo)
+}
+
+func containsLinks(text string) bool {
+	return linksInTextRegex.MatchString(text)
+}
+
+func stripLinks(text string) string {
+	return strings.TrimSpace(linksInTextRegex.ReplaceAllString(text, ""))
+}
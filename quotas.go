@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	quotaActionIdeas = "ideas"
+	quotaActionGazes = "gazes"
+)
+
+// QuotaConfig : Writes a user can make per UTC day, 0 lifts the limit of an action
+type QuotaConfig struct {
+	DailyIdeas int64
+	DailyGazes int64
+}
+
+// UserQuotaStructure : Count of one action of a user on one day, removed by the TTL index a day after it ends
+type UserQuotaStructure struct {
+	ID        string    `bson:"_id"`
+	UserID    int64     `bson:"user_id"`
+	Action    string    `bson:"action"`
+	Day       string    `bson:"day"`
+	Count     int64     `bson:"count"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func loadQuotaConfig(configLoader *ConfigLoader) QuotaConfig {
+	var quotaConfig QuotaConfig
+
+	quotaConfig.DailyIdeas = configLoader.Int("QUOTA_DAILY_IDEAS", 10)
+	quotaConfig.DailyGazes = configLoader.Int("QUOTA_DAILY_GAZES", 200)
+	if quotaConfig.DailyIdeas < 0 {
+		configLoader.Invalid("QUOTA_DAILY_IDEAS", "should be 0 or more")
+	}
+	if quotaConfig.DailyGazes < 0 {
+		configLoader.Invalid("QUOTA_DAILY_GAZES", "should be 0 or more")
+	}
+
+	return quotaConfig
+}
+
+func quotaIDOf(userID int64, action string, day string) string {
+	return strconv.FormatInt(userID, 10) + ":" + action + ":" + day
+}
+
+// consumeQuota : Counts one more action for today and answers 429 when it goes past the limit.
+// False means the response is already written, the count is taken back so a refused write does not use up the quota.
+// Counts are kept in mongo, without it there is no quota
+func consumeQuota(ginContext *gin.Context, databaseClient *mongo.Client, userID int64, action string, dailyLimit int64) bool {
+	if dailyLimit <= 0 || databaseClient == nil {
+		return true
+	}
+
+	quotasCollection := databaseClient.Database("sardene-db").Collection("user_quotas")
+	databaseContext := ginContext.Request.Context()
+
+	now := time.Now().UTC()
+	day := now.Format("2006-01-02")
+	resetsAt := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	countUpdate := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"user_id": userID, "action": action, "day": day, "expires_at": resetsAt.Add(24 * time.Hour)},
+	}
+	countOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var quota UserQuotaStructure
+	errInCounting := quotasCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": quotaIDOf(userID, action, day)},
+		countUpdate, countOptions).Decode(&quota)
+	// First two actions of a day can race to insert the counter, the loser only has to increase it
+	if isDuplicateKeyError(errInCounting) {
+		errInCounting = quotasCollection.FindOneAndUpdate(databaseContext, bson.M{"_id": quotaIDOf(userID, action, day)},
+			countUpdate, countOptions).Decode(&quota)
+	}
+	if errInCounting != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in checking daily limit", "errorDetails": errInCounting.Error()})
+		return false
+	}
+
+	remaining := dailyLimit - quota.Count
+	if remaining < 0 {
+		remaining = 0
+	}
+	ginContext.Header("X-RateLimit-Limit", strconv.FormatInt(dailyLimit, 10))
+	ginContext.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	ginContext.Header("X-RateLimit-Reset", strconv.FormatInt(resetsAt.Unix(), 10))
+
+	if quota.Count <= dailyLimit {
+		return true
+	}
+
+	refundQuota(databaseContext, databaseClient, userID, action)
+	ginContext.Header("Retry-After", strconv.FormatInt(int64(resetsAt.Sub(now).Seconds())+1, 10))
+	ginContext.JSON(http.StatusTooManyRequests, gin.H{"status": http.StatusTooManyRequests,
+		"error":     "Daily limit of " + strconv.FormatInt(dailyLimit, 10) + " " + action + " reached",
+		"limit":     dailyLimit,
+		"resets_at": resetsAt.Unix()})
+	return false
+}
+
+// refundQuota : Takes back an action counted for a write which did not go through
+func refundQuota(databaseContext context.Context, databaseClient *mongo.Client, userID int64, action string) {
+	if databaseClient == nil {
+		return
+	}
+	quotasCollection := databaseClient.Database("sardene-db").Collection("user_quotas")
+	day := time.Now().UTC().Format("2006-01-02")
+
+	_, _ = quotasCollection.UpdateOne(databaseContext, bson.M{"_id": quotaIDOf(userID, action, day), "count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"count": -1}})
+}
+
+func deleteUserQuotas(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	quotasCollection := databaseClient.Database("sardene-db").Collection("user_quotas")
+
+	_, errInDeleting := quotasCollection.DeleteMany(databaseContext, bson.M{"user_id": userID})
+	return errInDeleting
+}
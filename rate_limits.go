@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// RateLimitConfig : Requests a client can make per window, by the tier of the client, 0 lifts the limit of a tier
+type RateLimitConfig struct {
+	Window time.Duration
+	// Anonymous clients are counted by their address, authenticated ones by their user id
+	AnonymousPerWindow     int64
+	AuthenticatedPerWindow int64
+	// Registered API keys with their own budget, sent in the X-API-Key header
+	APIKeyBudgets map[string]int64
+}
+
+// RateLimiter : Fixed window counters kept in memory of each instance, nil when rate limits are switched off,
+// so a client spread over instances gets the budget of each
+type RateLimiter struct {
+	mutex         sync.Mutex
+	config        RateLimitConfig
+	windowStart   time.Time
+	requestCounts map[string]int64
+}
+
+var requestRateLimiter *RateLimiter
+
+func loadRateLimitConfig(configLoader *ConfigLoader) RateLimitConfig {
+	var rateLimitConfig RateLimitConfig
+
+	rateLimitConfig.Window = time.Duration(configLoader.Int("RATE_LIMIT_WINDOW_SECONDS", 60)) * time.Second
+	rateLimitConfig.AnonymousPerWindow = configLoader.Int("RATE_LIMIT_ANONYMOUS", 60)
+	rateLimitConfig.AuthenticatedPerWindow = configLoader.Int("RATE_LIMIT_AUTHENTICATED", 300)
+	if rateLimitConfig.Window <= 0 {
+		configLoader.Invalid("RATE_LIMIT_WINDOW_SECONDS", "should be more than 0")
+	}
+	if rateLimitConfig.AnonymousPerWindow < 0 {
+		configLoader.Invalid("RATE_LIMIT_ANONYMOUS", "should be 0 or more")
+	}
+	if rateLimitConfig.AuthenticatedPerWindow < 0 {
+		configLoader.Invalid("RATE_LIMIT_AUTHENTICATED", "should be 0 or more")
+	}
+
+	// Keys are listed as key=budget, like RATE_LIMIT_API_KEYS=partner-key=5000,dashboard-key=1000
+	rateLimitConfig.APIKeyBudgets = make(map[string]int64)
+	for _, apiKeyBudget := range configLoader.List("RATE_LIMIT_API_KEYS", "") {
+		separatorIndex := strings.LastIndex(apiKeyBudget, "=")
+		if separatorIndex <= 0 {
+			configLoader.Invalid("RATE_LIMIT_API_KEYS", "should be a list of key=budget")
+			continue
+		}
+		budget, errInBudget := strconv.ParseInt(apiKeyBudget[separatorIndex+1:], 10, 64)
+		if errInBudget != nil || budget < 0 {
+			configLoader.Invalid("RATE_LIMIT_API_KEYS", "budget of each key should be 0 or more")
+			continue
+		}
+		rateLimitConfig.APIKeyBudgets[apiKeyBudget[:separatorIndex]] = budget
+	}
+
+	return rateLimitConfig
+}
+
+func newRateLimiter(rateLimitConfig RateLimitConfig) *RateLimiter {
+	return &RateLimiter{config: rateLimitConfig, requestCounts: make(map[string]int64)}
+}
+
+// count : Counts one more request of the client in the current window, returns the count and when the window ends
+func (rateLimiter *RateLimiter) count(clientKey string) (int64, time.Time) {
+	rateLimiter.mutex.Lock()
+	defer rateLimiter.mutex.Unlock()
+
+	// Every client shares the window, so counts of the last one are all stale at once
+	windowStart := time.Now().Truncate(rateLimiter.config.Window)
+	if windowStart.Equal(rateLimiter.windowStart) == false {
+		rateLimiter.windowStart = windowStart
+		rateLimiter.requestCounts = make(map[string]int64)
+	}
+
+	rateLimiter.requestCounts[clientKey]++
+	return rateLimiter.requestCounts[clientKey], windowStart.Add(rateLimiter.config.Window)
+}
+
+// limitRequestRate : Runs after authorize so authenticated users are counted by their id and not their address
+func limitRequestRate() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		if requestRateLimiter == nil {
+			ginContext.Next()
+			return
+		}
+
+		clientKey, budget := "ip:"+ginContext.ClientIP(), requestRateLimiter.config.AnonymousPerWindow
+		if apiKey := ginContext.GetHeader(apiKeyHeader); apiKey != "" {
+			apiKeyBudget, isRegistered := requestRateLimiter.config.APIKeyBudgets[apiKey]
+			if isRegistered == false {
+				ginContext.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized,
+					"error": "API key is not registered"})
+				return
+			}
+			clientKey, budget = "key:"+apiKey, apiKeyBudget
+		} else if user := getAuthenticatedUser(ginContext); user.UserID != 0 {
+			clientKey, budget = "user:"+strconv.FormatInt(user.UserID, 10), requestRateLimiter.config.AuthenticatedPerWindow
+		}
+		if budget == 0 {
+			ginContext.Next()
+			return
+		}
+
+		requestCount, resetsAt := requestRateLimiter.count(clientKey)
+		remaining := budget - requestCount
+		if remaining < 0 {
+			remaining = 0
+		}
+		ginContext.Header("X-RateLimit-Limit", strconv.FormatInt(budget, 10))
+		ginContext.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		ginContext.Header("X-RateLimit-Reset", strconv.FormatInt(resetsAt.Unix(), 10))
+
+		if requestCount > budget {
+			ginContext.Header("Retry-After", strconv.FormatInt(int64(time.Until(resetsAt).Seconds())+1, 10))
+			ginContext.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": http.StatusTooManyRequests,
+				"error":     "Limit of " + strconv.FormatInt(budget, 10) + " requests reached, try again once it resets",
+				"limit":     budget,
+				"resets_at": resetsAt.Unix()})
+			return
+		}
+		ginContext.Next()
+	}
+}
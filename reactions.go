@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// A gaze is an eyes reaction, each user has one reaction per idea and gazers counts them all
+const (
+	reactionFire  = "🔥"
+	reactionIdea  = "💡"
+	reactionEyes  = "👀"
+	reactionHeart = "❤️"
+)
+
+var ideaReactions = []string{reactionFire, reactionIdea, reactionEyes, reactionHeart}
+
+// ReactionInput : Structure for incoming reaction
+type ReactionInput struct {
+	Reaction string `json:"reaction"`
+}
+
+// reactionOf : Keyboards send the heart with or without the emoji variation selector, both are the same reaction
+func reactionOf(input string) (string, bool) {
+	input = strings.TrimSuffix(strings.TrimSpace(input), "\ufe0f")
+	for _, reaction := range ideaReactions {
+		if input == strings.TrimSuffix(reaction, "\ufe0f") {
+			return reaction, true
+		}
+	}
+	return "", false
+}
+
+// reactToIdea : A first reaction counts as a gaze, a later one replaces the reaction without changing gazers
+func reactToIdea(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, idea IdeaStructure, quotaConfig QuotaConfig) {
+	var jsonInput ReactionInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	reaction, isReaction := reactionOf(jsonInput.Reaction)
+	if errInInputJSON != nil || isReaction == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Reaction should be one of " + strings.Join(ideaReactions, " ")})
+		return
+	}
+
+	// Drafts and private ideas cannot be reacted to
+	if isIdeaPublic(idea) == false {
+		abortIdeaNotFound(ginContext)
+		return
+	}
+	hexIdeaID := idea.ID
+
+	user := getAuthenticatedUser(ginContext)
+	databaseContext := ginContext.Request.Context()
+
+	existingLike, errInFindingLike := stores.Likes.Find(databaseContext, user.UserID, hexIdeaID)
+	if errInFindingLike == errNotFoundInStore {
+		likeAnIdea(ginContext, databaseClient, stores, idea, reaction, quotaConfig)
+		return
+	}
+	if errInFindingLike != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingLike.Error()})
+		return
+	}
+	if existingLike.Reaction == reaction {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "Error, User already reacted to the idea with " + reaction})
+		return
+	}
+
+	if idea.Archived == true {
+		abortIdeaArchived(ginContext)
+		return
+	}
+
+	// Changing only from the reaction read keeps counts right when two changes race, the loser is told to retry
+	errInChanging := stores.Likes.UpdateReaction(databaseContext, user.UserID, hexIdeaID, existingLike.Reaction, reaction)
+	if errInChanging == errNotFoundInStore {
+		ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+			"error": "Error, Reaction changed at the same time, please try again"})
+		return
+	}
+	if errInChanging != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInChanging.Error()})
+		return
+	}
+
+	// Counts which drift from a failure here are repaired by the counter reconciliation job
+	errInCounting := stores.Ideas.IncrementReaction(databaseContext, hexIdeaID, existingLike.Reaction, -1)
+	if errInCounting == nil {
+		errInCounting = stores.Ideas.IncrementReaction(databaseContext, hexIdeaID, reaction, 1)
+	}
+	if errInCounting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database", "errorDetails": errInCounting.Error()})
+		return
+	}
+
+	describeAuditedMutation(ginContext, auditActionIdeaGazed, hexIdeaID.Hex(), existingLike.Reaction, reaction)
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": gin.H{"reaction": reaction},
+		"message": "Changed reaction to idea"})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// assignRequestID : Keeps the ID a proxy already gave the request, or makes one, and echoes it back to the client
+func assignRequestID() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		requestID := ginContext.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			randomBytes := make([]byte, 8)
+			rand.Read(randomBytes)
+			requestID = hex.EncodeToString(randomBytes)
+		}
+
+		ginContext.Set("requestID", requestID)
+		ginContext.Header(requestIDHeader, requestID)
+		ginContext.Next()
+	}
+}
+
+// recoverWithJSON : Turns a panic in a handler into the same 500 envelope as every other error,
+// the stack is logged with the request ID so the client's report can be matched to it
+func recoverWithJSON() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			requestID := ginContext.GetString("requestID")
+			log.Printf("Panic in %s %s, request %s: %v\n%s", ginContext.Request.Method, ginContext.Request.URL.Path,
+				requestID, recovered, debug.Stack())
+			reportPanic(ginContext, recovered)
+
+			ginContext.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Something went wrong on our side", "requestID": requestID})
+		}()
+
+		ginContext.Next()
+	}
+}
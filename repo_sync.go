@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RepoMetadataStructure : Health of the GitHub repo linked to an idea, as of the last sync
+type RepoMetadataStructure struct {
+	Stars    int64 `json:"stars" bson:"stars"`
+	Forks    int64 `json:"forks" bson:"forks"`
+	PushedAt int64 `json:"pushed_at" bson:"pushed_at"`
+	// Missing repos were deleted or made private after they were linked
+	Missing  bool  `json:"missing" bson:"missing"`
+	SyncedAt int64 `json:"synced_at" bson:"synced_at"`
+}
+
+// RepoSyncConfig : How often linked repos are refreshed from the GitHub API
+type RepoSyncConfig struct {
+	Interval  time.Duration
+	BatchSize int64
+	// Token raises the GitHub limit from 60 to 5000 requests an hour, it needs no scopes
+	GithubToken string
+	// Run stops when fewer requests than this are left, so signing in with GitHub keeps working
+	ReservedRateLimit int64
+}
+
+var errGithubRateLimited = errors.New("GitHub rate limit reached")
+
+var githubRepoURLRegex = regexp.MustCompile(`^https://github\.com/([A-Za-z0-9-]+)/([A-Za-z0-9._-]+?)(\.git)?/?$`)
+
+func loadRepoSyncConfig(configLoader *ConfigLoader) RepoSyncConfig {
+	var repoSyncConfig RepoSyncConfig
+
+	repoSyncConfig.Interval = time.Duration(configLoader.Int("REPO_SYNC_INTERVAL_MINUTES", 60)) * time.Minute
+	repoSyncConfig.BatchSize = configLoader.Int("REPO_SYNC_BATCH_SIZE", 50)
+	if repoSyncConfig.BatchSize <= 0 {
+		configLoader.Invalid("REPO_SYNC_BATCH_SIZE", "should be more than 0")
+	}
+	repoSyncConfig.GithubToken = configLoader.String("REPO_SYNC_GITHUB_TOKEN", "")
+	repoSyncConfig.ReservedRateLimit = configLoader.Int("REPO_SYNC_RESERVED_RATE_LIMIT", 10)
+
+	return repoSyncConfig
+}
+
+// normalizeRepoURL : https://github.com/owner/name of a repo url, empty when the url is not a GitHub repo
+func normalizeRepoURL(repoURL string) string {
+	repoMatch := githubRepoURLRegex.FindStringSubmatch(strings.TrimSpace(repoURL))
+	if repoMatch == nil {
+		return ""
+	}
+	return "https://github.com/" + repoMatch[1] + "/" + repoMatch[2]
+}
+
+func runRepoSyncJob(databaseClient *mongo.Client, stores Stores, repoSyncConfig RepoSyncConfig) {
+	runScheduledJob(databaseClient, "repo_sync", repoSyncConfig.Interval, func() error {
+		return syncLinkedRepos(stores, repoSyncConfig)
+	})
+}
+
+// syncLinkedRepos : Refreshes the repos synced longest ago, a batch per run
+func syncLinkedRepos(stores Stores, repoSyncConfig RepoSyncConfig) error {
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), repoSyncConfig.Interval*9/10)
+	defer cancelDBContext()
+
+	staleBefore := time.Now().Add(-repoSyncConfig.Interval).Unix()
+	staleIdeas, errInFinding := stores.Ideas.ListWithStaleRepo(databaseContext, staleBefore, repoSyncConfig.BatchSize)
+	if errInFinding != nil {
+		return errInFinding
+	}
+
+	for _, idea := range staleIdeas {
+		repoMetadata, isRateLimited, errInFetching := fetchRepoMetadata(databaseContext, idea.RepoURL, repoSyncConfig)
+		if errInFetching != nil && errInFetching != errGithubRateLimited {
+			// One broken repo should not hold back the rest of the batch
+			log.Println(errInFetching, "Failed to sync repo of idea "+idea.ID.Hex())
+			continue
+		}
+
+		if errInFetching == nil {
+			errInSaving := stores.Ideas.SetRepo(databaseContext, idea.ID, repoMetadata)
+			if errInSaving != nil && errInSaving != errNotFoundInStore {
+				return errInSaving
+			}
+			invalidateCachedResponses("GET /ideas")
+		}
+
+		// Repos after this one wait for the next run
+		if isRateLimited {
+			log.Println("Repo sync stopped early, the GitHub rate limit is almost used up")
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// fetchRepoMetadata : Also tells whether the requests left in the GitHub rate limit are down to the reserved ones
+func fetchRepoMetadata(requestContext context.Context, repoURL string, repoSyncConfig RepoSyncConfig) (RepoMetadataStructure, bool, error) {
+	repoMetadata := RepoMetadataStructure{SyncedAt: time.Now().Unix()}
+
+	repoMatch := githubRepoURLRegex.FindStringSubmatch(repoURL)
+	if repoMatch == nil {
+		return repoMetadata, false, fmt.Errorf("%s is not a GitHub repo", repoURL)
+	}
+
+	requestRepo, errInRequestingRepo := http.NewRequestWithContext(requestContext, "GET",
+		"https://api.github.com/repos/"+repoMatch[1]+"/"+repoMatch[2], nil)
+	if errInRequestingRepo != nil {
+		return repoMetadata, false, errInRequestingRepo
+	}
+	requestRepo.Header.Set("Accept", "application/vnd.github.v3+json")
+	if repoSyncConfig.GithubToken != "" {
+		requestRepo.Header.Set("Authorization", "token "+repoSyncConfig.GithubToken)
+	}
+
+	responseWithRepo, errInResponseFromGithub := outboundHTTPClient.Do(requestRepo)
+	if errInResponseFromGithub != nil {
+		return repoMetadata, false, errInResponseFromGithub
+	}
+	defer responseWithRepo.Body.Close()
+
+	remainingRequests, errInRateLimit := strconv.ParseInt(responseWithRepo.Header.Get("X-RateLimit-Remaining"), 10, 64)
+	isRateLimited := errInRateLimit == nil && remainingRequests <= repoSyncConfig.ReservedRateLimit
+
+	switch responseWithRepo.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		repoMetadata.Missing = true
+		return repoMetadata, isRateLimited, nil
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if isRateLimited || errInRateLimit != nil {
+			return repoMetadata, true, errGithubRateLimited
+		}
+		return repoMetadata, isRateLimited, fmt.Errorf("GitHub answered %d for %s", responseWithRepo.StatusCode, repoURL)
+	default:
+		return repoMetadata, isRateLimited, fmt.Errorf("GitHub answered %d for %s", responseWithRepo.StatusCode, repoURL)
+	}
+
+	responseBytesWithRepo, errInResponseBody := ioutil.ReadAll(responseWithRepo.Body)
+	if errInResponseBody != nil {
+		return repoMetadata, isRateLimited, errInResponseBody
+	}
+
+	var githubRepo struct {
+		Stars    int64     `json:"stargazers_count"`
+		Forks    int64     `json:"forks_count"`
+		PushedAt time.Time `json:"pushed_at"`
+	}
+	errInDecodingJSON := json.Unmarshal(responseBytesWithRepo, &githubRepo)
+	if errInDecodingJSON != nil {
+		return repoMetadata, isRateLimited, errInDecodingJSON
+	}
+
+	repoMetadata.Stars = githubRepo.Stars
+	repoMetadata.Forks = githubRepo.Forks
+	repoMetadata.PushedAt = githubRepo.PushedAt.Unix()
+
+	return repoMetadata, isRateLimited, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeTimeouts : Routes which stream or cascade over many documents and need longer than the default timeout
+var routeTimeouts = map[string]time.Duration{
+	"GET /user/export":              5 * time.Minute,
+	"GET /admin/likes":              2 * time.Minute,
+	"GET /admin/export/:collection": 10 * time.Minute,
+	"DELETE /user":                  2 * time.Minute,
+	"POST /attachments":             time.Minute,
+	"POST /ideas/:ideaID/images":    time.Minute,
+}
+
+// limitRequestTime : Database and outbound calls made with the request context stop once the timeout passes
+// or the client goes away, whichever is first
+func limitRequestTime(timeout time.Duration) gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		requestContext, cancelRequestContext := context.WithTimeout(ginContext.Request.Context(), timeout)
+		defer cancelRequestContext()
+
+		ginContext.Request = ginContext.Request.WithContext(requestContext)
+		ginContext.Next()
+	}
+}
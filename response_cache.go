@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeCacheDurations : GET routes whose responses to anonymous callers are cached, and for how long at most
+var routeCacheDurations = map[string]time.Duration{
+	"GET /oembed":                5 * time.Minute,
+	"GET /ideas":                 30 * time.Second,
+	"GET /ideas/featured":        time.Minute,
+	"GET /ideas/launched":        time.Minute,
+	"GET /activity":              time.Minute,
+	"GET /stats/timeseries":      5 * time.Minute,
+	"GET /ideas/trending":        time.Minute,
+	"GET /ideas/rising":          time.Minute,
+	"GET /ideas/of-the-day":      time.Minute,
+	"GET /idea/:ideaID/card.png": 5 * time.Minute,
+	"GET /idea/:ideaID/graph":    time.Minute,
+	"GET /idea/:ideaID/similar":  5 * time.Minute,
+}
+
+// routesKeepingCachedResponses : Mutating routes which change nothing a cached route answers with,
+// every other successful mutation drops the whole cache
+var routesKeepingCachedResponses = map[string]bool{
+	"POST /auth":                            true,
+	"POST /auth/email":                      true,
+	"POST /attachments":                     true,
+	"DELETE /attachments/:hash":             true,
+	"POST /admin/incidents":                 true,
+	"PATCH /admin/incidents/:incidentID":    true,
+	"PATCH /user/preferences":               true,
+	"PATCH /user":                           true,
+	"POST /admin/backups":                   true,
+	"PATCH /admin/users/:userID/suspension": true,
+	"POST /notifications/read":              true,
+	"POST /idea/bookmark/:ideaID":           true,
+	"DELETE /idea/bookmark/:ideaID":         true,
+	"POST /idea/subscribe/:ideaID":          true,
+	"DELETE /idea/subscribe/:ideaID":        true,
+	"POST /idea/gazed/check":                true,
+	"POST /users/:login/follow":             true,
+	"DELETE /users/:login/follow":           true,
+	"POST /orgs":                            true,
+	"POST /orgs/:orgSlug/invites":           true,
+	"POST /orgs/:orgSlug/invites/accept":    true,
+	"PATCH /orgs/:orgSlug/members/:userID":  true,
+	"DELETE /orgs/:orgSlug/members/:userID": true,
+}
+
+// ResponseCacheConfig : Size of the in-process response cache
+type ResponseCacheConfig struct {
+	MaxEntries int64
+}
+
+type cachedResponse struct {
+	route       string
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+// ResponseCache : Responses kept in memory of each instance, nil when the cache is switched off,
+// other instances only see a change once their copy expires
+type ResponseCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	entries    map[string]cachedResponse
+}
+
+var responseCache *ResponseCache
+
+// responseCapturingWriter : Passes the response on while keeping a copy of the body
+type responseCapturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (responseWriter *responseCapturingWriter) Write(data []byte) (int, error) {
+	responseWriter.body.Write(data)
+	return responseWriter.ResponseWriter.Write(data)
+}
+
+func (responseWriter *responseCapturingWriter) WriteString(data string) (int, error) {
+	responseWriter.body.WriteString(data)
+	return responseWriter.ResponseWriter.WriteString(data)
+}
+
+func loadResponseCacheConfig(configLoader *ConfigLoader) ResponseCacheConfig {
+	var responseCacheConfig ResponseCacheConfig
+
+	responseCacheConfig.MaxEntries = configLoader.Int("RESPONSE_CACHE_MAX_ENTRIES", 1000)
+	if responseCacheConfig.MaxEntries <= 0 {
+		configLoader.Invalid("RESPONSE_CACHE_MAX_ENTRIES", "should be more than 0")
+	}
+
+	return responseCacheConfig
+}
+
+func newResponseCache(responseCacheConfig ResponseCacheConfig) *ResponseCache {
+	return &ResponseCache{maxEntries: int(responseCacheConfig.MaxEntries), entries: make(map[string]cachedResponse)}
+}
+
+func (cache *ResponseCache) get(key string) (cachedResponse, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cached, isCached := cache.entries[key]
+	if isCached == false || time.Now().After(cached.expiresAt) {
+		return cached, false
+	}
+	return cached, true
+}
+
+// set : Expired entries are dropped once the cache is full, and everything when that is not enough
+func (cache *ResponseCache) set(key string, response cachedResponse) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if len(cache.entries) >= cache.maxEntries {
+		for cachedKey, cached := range cache.entries {
+			if time.Now().After(cached.expiresAt) {
+				delete(cache.entries, cachedKey)
+			}
+		}
+		if len(cache.entries) >= cache.maxEntries {
+			cache.entries = make(map[string]cachedResponse)
+		}
+	}
+	cache.entries[key] = response
+}
+
+// Invalidate : Drops the cached responses of the routes, of every route when none is given
+func (cache *ResponseCache) Invalidate(routes ...string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if len(routes) == 0 {
+		cache.entries = make(map[string]cachedResponse)
+		return
+	}
+	isInvalidated := make(map[string]bool)
+	for _, route := range routes {
+		isInvalidated[route] = true
+	}
+	for cachedKey, cached := range cache.entries {
+		if isInvalidated[cached.route] == true {
+			delete(cache.entries, cachedKey)
+		}
+	}
+}
+
+// invalidateCachedResponses : For writes made outside of a request, like scheduled jobs
+func invalidateCachedResponses(routes ...string) {
+	if responseCache != nil {
+		responseCache.Invalidate(routes...)
+	}
+}
+
+// cacheResponses : Middleware answering anonymous GET requests from the cache, keyed by the path and the sorted query
+func cacheResponses(route string, cacheDuration time.Duration) gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		// Signed in callers may see their drafts and own flags, those responses are never shared
+		if responseCache == nil || ginContext.GetHeader("Authorization") != "" {
+			ginContext.Next()
+			return
+		}
+
+		cacheKey := ginContext.Request.URL.Path + "?" + ginContext.Request.URL.Query().Encode()
+		if cached, isCached := responseCache.get(cacheKey); isCached == true {
+			ginContext.Header("X-Cache", "HIT")
+			ginContext.Data(http.StatusOK, cached.contentType, cached.body)
+			ginContext.Abort()
+			return
+		}
+
+		ginContext.Header("X-Cache", "MISS")
+		capturingWriter := &responseCapturingWriter{ResponseWriter: ginContext.Writer}
+		ginContext.Writer = capturingWriter
+		ginContext.Next()
+
+		if ginContext.Writer.Status() != http.StatusOK {
+			return
+		}
+		responseCache.set(cacheKey, cachedResponse{
+			route:       route,
+			contentType: ginContext.Writer.Header().Get("Content-Type"),
+			body:        capturingWriter.body.Bytes(),
+			expiresAt:   time.Now().Add(cacheDuration),
+		})
+	}
+}
+
+// invalidateResponsesOnWrite : Middleware dropping cached responses once a mutation succeeded
+func invalidateResponsesOnWrite() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		ginContext.Next()
+
+		if ginContext.Writer.Status() < http.StatusBadRequest {
+			invalidateCachedResponses()
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdeaRevisionStructure : Structure of revision in idea revisions collection, holds the idea as it was before an edit
+type IdeaRevisionStructure struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	IdeaID      primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description" bson:"description"`
+	EditorID    int64              `json:"editor_id" bson:"editor_id"`
+	Editor      string             `json:"editor" bson:"editor"`
+	// Gazes the idea had collected when it was edited
+	GazersAtEdit int64 `json:"gazers_at_edit" bson:"gazers_at_edit"`
+	EditedAt     int64 `json:"edited_at" bson:"edited_at"`
+}
+
+// addIdeaRevision : History is kept in mongo only, without it edits are saved with no revision
+func addIdeaRevision(databaseContext context.Context, databaseClient *mongo.Client, ideaBeforeEdit IdeaStructure, editor GithubUserProfileStructure) error {
+	if databaseClient == nil {
+		return nil
+	}
+	revisionsCollection := databaseClient.Database("sardene-db").Collection("idea_revisions")
+
+	revisionToAdd := bson.M{
+		"idea_id":        ideaBeforeEdit.ID,
+		"name":           ideaBeforeEdit.Name,
+		"description":    ideaBeforeEdit.Description,
+		"editor_id":      editor.UserID,
+		"editor":         editor.Login,
+		"gazers_at_edit": ideaBeforeEdit.Gazers,
+		"edited_at":      time.Now().Unix(),
+	}
+
+	_, errInAdding := revisionsCollection.InsertOne(databaseContext, revisionToAdd)
+	return errInAdding
+}
+
+func getIdeaRevisions(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	revisionsCollection := databaseClient.Database("sardene-db").Collection("idea_revisions")
+	databaseContext := ginContext.Request.Context()
+
+	// History of drafts and private ideas is as hidden as the ideas themselves
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea == nil && isIdeaVisibleTo(idea, getAuthenticatedUser(ginContext).UserID) == false {
+		errInFindingIdea = errNotFoundInStore
+	}
+	if errInFindingIdea == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+	if errInFindingIdea != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+		return
+	}
+
+	revisionsOptions := options.Find().SetSort(bson.M{"edited_at": -1})
+	revisionsCursor, errInFinding := revisionsCollection.Find(databaseContext, bson.M{"idea_id": hexIdeaID}, revisionsOptions)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	defer revisionsCursor.Close(databaseContext)
+
+	revisions := []*IdeaRevisionStructure{}
+	for revisionsCursor.Next(databaseContext) {
+		var revision IdeaRevisionStructure
+		errInDecoding := revisionsCursor.Decode(&revision)
+		if errInDecoding != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in decoding database", "errorDetails": errInDecoding.Error()})
+			return
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": revisions, "count": len(revisions)})
+}
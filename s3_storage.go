@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	blobStorageGridFS = "gridfs"
+	blobStorageS3     = "s3"
+)
+
+// S3Config : Bucket of an S3 compatible storage such as AWS S3 or minio
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	KeyPrefix       string
+}
+
+// s3BlobStorage : Keeps blobs as objects of a bucket, requests are signed with AWS signature version 4.
+// Objects are addressed by path, as minio expects, which AWS S3 supports as well.
+type s3BlobStorage struct {
+	config   S3Config
+	endpoint *url.URL
+}
+
+func loadS3Config(configLoader *ConfigLoader) S3Config {
+	var s3Config S3Config
+
+	s3Config.Endpoint = configLoader.Required("S3_ENDPOINT", "like https://s3.eu-west-1.amazonaws.com or http://localhost:9000 for minio")
+	if endpoint, errInEndpoint := url.Parse(strings.TrimRight(s3Config.Endpoint, "/")); s3Config.Endpoint != "" &&
+		(errInEndpoint != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" || endpoint.Path != "") {
+		configLoader.Invalid("S3_ENDPOINT", "should be an http or https url without a path, got "+s3Config.Endpoint)
+	}
+	s3Config.Region = configLoader.String("S3_REGION", "us-east-1")
+	s3Config.Bucket = configLoader.Required("S3_BUCKET", "bucket in which attachments and backups are kept")
+	s3Config.AccessKeyID = configLoader.Required("S3_ACCESS_KEY_ID", "access key of the bucket")
+	s3Config.SecretAccessKey = configLoader.Required("S3_SECRET_ACCESS_KEY", "secret of the access key")
+	s3Config.KeyPrefix = configLoader.String("S3_KEY_PREFIX", "attachments/")
+
+	return s3Config
+}
+
+func newS3BlobStorage(s3Config S3Config) (s3BlobStorage, error) {
+	endpoint, errInParsing := url.Parse(strings.TrimRight(s3Config.Endpoint, "/"))
+	if errInParsing != nil {
+		return s3BlobStorage{}, errInParsing
+	}
+	return s3BlobStorage{config: s3Config, endpoint: endpoint}, nil
+}
+
+func (blobStorage s3BlobStorage) objectURL(blobKey string) *url.URL {
+	objectURL := *blobStorage.endpoint
+	objectURL.Path = "/" + blobStorage.config.Bucket + "/" + blobStorage.config.KeyPrefix + blobKey
+	return &objectURL
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	hashOfData := sha256.Sum256(data)
+	return hex.EncodeToString(hashOfData[:])
+}
+
+// signRequest : Adds the AWS signature version 4 headers, payloadHash is the hex sha256 of the body
+func (blobStorage s3BlobStorage) signRequest(request *http.Request, payloadHash string) {
+	requestTime := time.Now().UTC()
+	amzDate := requestTime.Format("20060102T150405Z")
+	scopeDate := requestTime.Format("20060102")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + request.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	credentialScope := scopeDate + "/" + blobStorage.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + credentialScope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+blobStorage.config.SecretAccessKey), scopeDate)
+	signingKey = hmacSHA256(signingKey, blobStorage.config.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+blobStorage.config.AccessKeyID+"/"+credentialScope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func (blobStorage s3BlobStorage) sendRequest(method string, blobKey string, body []byte, contentType string) (*http.Response, error) {
+	request, errInRequest := http.NewRequest(method, blobStorage.objectURL(blobKey).String(), bytes.NewReader(body))
+	if errInRequest != nil {
+		return nil, errInRequest
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	blobStorage.signRequest(request, sha256Hex(body))
+
+	response, errInResponse := outboundHTTPClient.Do(request)
+	if errInResponse != nil {
+		return nil, errInResponse
+	}
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		responseBody, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("S3 answered %d for %s %s: %s", response.StatusCode, method, blobKey, responseBody)
+	}
+	return response, nil
+}
+
+func (blobStorage s3BlobStorage) Save(blobKey string, contentType string, blobReader io.Reader) error {
+	// Attachments are small and already in memory, the whole body is needed for the signature anyway
+	blobBytes, errInReading := ioutil.ReadAll(blobReader)
+	if errInReading != nil {
+		return errInReading
+	}
+
+	response, errInSaving := blobStorage.sendRequest(http.MethodPut, blobKey, blobBytes, contentType)
+	if errInSaving != nil {
+		return errInSaving
+	}
+	return response.Body.Close()
+}
+
+func (blobStorage s3BlobStorage) Open(blobKey string) (io.ReadCloser, error) {
+	response, errInOpening := blobStorage.sendRequest(http.MethodGet, blobKey, nil, "")
+	if errInOpening != nil {
+		return nil, errInOpening
+	}
+	return response.Body, nil
+}
+
+func (blobStorage s3BlobStorage) Remove(blobKey string) error {
+	response, errInRemoving := blobStorage.sendRequest(http.MethodDelete, blobKey, nil, "")
+	if errInRemoving != nil {
+		return errInRemoving
+	}
+	return response.Body.Close()
+}
+
+// SaveFile : Uploads a file too large to keep in memory, payloadHash is the hex sha256 of its content
+func (blobStorage s3BlobStorage) SaveFile(blobKey string, contentType string, blobFile *os.File, payloadHash string) error {
+	fileInfo, errInStat := blobFile.Stat()
+	if errInStat != nil {
+		return errInStat
+	}
+	rewindFile := func() (io.ReadCloser, error) {
+		_, errInSeeking := blobFile.Seek(0, io.SeekStart)
+		return ioutil.NopCloser(blobFile), errInSeeking
+	}
+	fileBody, errInRewinding := rewindFile()
+	if errInRewinding != nil {
+		return errInRewinding
+	}
+
+	request, errInRequest := http.NewRequest(http.MethodPut, blobStorage.objectURL(blobKey).String(), fileBody)
+	if errInRequest != nil {
+		return errInRequest
+	}
+	request.ContentLength = fileInfo.Size()
+	// Retries send the file again from its start
+	request.GetBody = rewindFile
+	request.Header.Set("Content-Type", contentType)
+	blobStorage.signRequest(request, payloadHash)
+
+	response, errInResponse := outboundHTTPClient.Do(request)
+	if errInResponse != nil {
+		return errInResponse
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		responseBody, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("S3 answered %d for PUT %s: %s", response.StatusCode, blobKey, responseBody)
+	}
+	return nil
+}
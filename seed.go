@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Seeded users get ids far above real GitHub ids, so they never collide with someone signing in
+const (
+	seedUserIDStart  = 9000000001
+	seedIdeasPerUser = 3
+	seedTimeout      = 2 * time.Minute
+)
+
+// seedUsers : Fake accounts created by -seed, the first one is an admin
+var seedUsers = []UserStructure{
+	{Login: "ada-seed", Name: "Ada Lindqvist", Bio: "Builds developer tools, mostly in Go", Location: "Stockholm", Role: userRoleAdmin},
+	{Login: "kofi-seed", Name: "Kofi Mensah", Bio: "Frontend engineer who likes small, fast apps", Location: "Accra"},
+	{Login: "mei-seed", Name: "Mei Tanaka", Bio: "Data engineer and weekend tinkerer", Location: "Osaka"},
+	{Login: "rafael-seed", Name: "Rafael Souza", Bio: "Open source maintainer", Website: "https://example.com/rafael", Location: "Recife"},
+	{Login: "priya-seed", Name: "Priya Raman", Bio: "Mobile developer, coffee enthusiast", Location: "Bengaluru"},
+	{Login: "lukas-seed", Name: "Lukas Becker", Location: "Leipzig"},
+}
+
+// seedIdeas : Names and descriptions handed out to the seeded users in turn
+var seedIdeas = []struct {
+	name        string
+	description string
+}{
+	{"Commit message linter", "A bot which comments on pull requests whose commit messages do not follow the conventions of the repo."},
+	{"Offline first notes", "Note taking app which keeps working without a connection and syncs **only the changes** once it is back."},
+	{"Dependency update digest", "Weekly email of the dependencies which got new releases, grouped by how risky the upgrade looks."},
+	{"Meetup finder for maintainers", "Lists meetups and conferences near you where maintainers of the projects you use are speaking."},
+	{"Terminal pomodoro", "A pomodoro timer which lives in the terminal and pauses when the screen locks."},
+	{"Recipe scaler", "Paste a recipe and get it scaled to any number of servings, with units converted."},
+	{"Issue triage helper", "Suggests labels and duplicates for new issues from the ones already closed."},
+	{"Shared grocery list", "Grocery list for a household which sorts items by the aisle of the store you are in."},
+	{"Flaky test tracker", "Collects test results from CI runs and points out the tests which fail without code changes."},
+	{"Plant watering reminder", "Reminds you to water each plant based on its kind, the season and the weather outside."},
+	{"Changelog generator", "Writes a changelog from merged pull requests, with breaking changes listed first."},
+	{"Local first budget", "Budgeting app which keeps everything on the device and exports plain CSV."},
+	{"Code review roulette", "Assigns reviewers across teams at random, so knowledge of the codebase spreads."},
+	{"Accessible color picker", "Color picker which warns when a text and background pair does not meet contrast guidelines."},
+	{"Book club planner", "Polls members for the next book and spreads the chapters over the weeks until the meeting."},
+	{"API mock server", "Serves fake responses from an OpenAPI file, so frontends can be built before the backend exists."},
+	{"Bike route planner", "Plans city bike routes which avoid steep hills and busy roads."},
+	{"Standup bot", "Collects async standup notes in chat and posts a summary to the team channel."},
+}
+
+var errSeedOutsideDev = errors.New("Seeding is only allowed when ENVIRONMENT is dev")
+
+// seedDatabase : Fills the stores with fake users, ideas and gazes for local development.
+// Running it again is harmless, users who already exist keep their ideas and gazes
+func seedDatabase(stores Stores, environment string) error {
+	if environment != "dev" {
+		return errSeedOutsideDev
+	}
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), seedTimeout)
+	defer cancelDBContext()
+
+	// Same data on every run, so screenshots and bug reports line up between developers
+	random := rand.New(rand.NewSource(1))
+	now := time.Now()
+
+	var seededUserIDs []int64
+	for userIndex, seedUser := range seedUsers {
+		seedUser.UserID = seedUserIDStart + int64(userIndex)
+		seedUser.DisplayName = seedUser.Name
+		seedUser.CreatedAt = now.AddDate(0, 0, -90+userIndex*7).Unix()
+
+		errInAddingUser := stores.Users.Insert(databaseContext, seedUser)
+		if errInAddingUser == errDuplicateInStore {
+			log.Println("Seed user " + seedUser.Login + " already exists, skipping")
+			continue
+		}
+		if errInAddingUser != nil {
+			return errInAddingUser
+		}
+		// Insert of the postgres store only keeps what comes from GitHub
+		errInUpdatingSettings := stores.Users.UpdateSettings(databaseContext, seedUser.UserID, UserSettingsStructure{
+			DisplayName: seedUser.DisplayName, Bio: seedUser.Bio, Website: seedUser.Website, Location: seedUser.Location})
+		if errInUpdatingSettings != nil {
+			return errInUpdatingSettings
+		}
+		seededUserIDs = append(seededUserIDs, seedUser.UserID)
+	}
+
+	var seededIdeas []IdeaStructure
+	for userIndex, userID := range seededUserIDs {
+		seedUser := seedUsers[userID-seedUserIDStart]
+		for ideaNumber := 0; ideaNumber < seedIdeasPerUser; ideaNumber++ {
+			seedIdea := seedIdeas[(userIndex*seedIdeasPerUser+ideaNumber)%len(seedIdeas)]
+			createdTime := now.Add(-time.Duration(random.Intn(60*24)) * time.Hour).Unix()
+
+			idea := IdeaStructure{
+				Name:          seedIdea.name,
+				Slug:          slugOf(seedIdea.name),
+				Description:   seedIdea.description,
+				Publisher:     seedUser.Login,
+				PublisherID:   userID,
+				CreatedAt:     createdTime,
+				UpdatedAt:     createdTime,
+				Status:        ideaStatusOpen,
+				Visibility:    ideaVisibilityPublic,
+				Links:         []IdeaLinkStructure{},
+				Collaborators: []IdeaCollaboratorStructure{},
+			}
+			// A few of them launched and one draft, so every filter has something to show
+			isLaunched := ideaNumber == 1 && userIndex%2 == 0
+			if ideaNumber == 2 && userIndex == 0 {
+				idea.Visibility = ideaVisibilityDraft
+			}
+
+			ideaID, errInAddingIdea := stores.Ideas.Insert(databaseContext, idea)
+			if errInAddingIdea != nil {
+				return errInAddingIdea
+			}
+			idea.ID = ideaID
+
+			if isLaunched == true {
+				launch := IdeaLaunchStructure{
+					ProductURL: "https://example.com/" + idea.Slug,
+					LaunchedAt: time.Unix(createdTime, 0).UTC().Truncate(24 * time.Hour).Unix(),
+					Tagline:    seedIdea.name + ", shipped",
+				}
+				errInLaunching := stores.Ideas.SetLaunched(databaseContext, ideaID, launch)
+				if errInLaunching != nil {
+					return errInLaunching
+				}
+				idea.Status = ideaStatusLaunched
+				idea.Launch = &launch
+			}
+			seededIdeas = append(seededIdeas, idea)
+		}
+	}
+
+	seededGazes := 0
+	for _, userID := range seededUserIDs {
+		for _, idea := range seededIdeas {
+			if idea.PublisherID == userID || idea.Visibility != ideaVisibilityPublic || random.Intn(2) == 0 {
+				continue
+			}
+			like := IdeaLikesStructure{UserID: userID, IdeaID: idea.ID, CreatedAt: idea.CreatedAt + int64(random.Intn(3600*24))}
+			errInGazing := stores.Likes.Insert(databaseContext, like)
+			if errInGazing == errDuplicateInStore {
+				continue
+			}
+			if errInGazing != nil {
+				return errInGazing
+			}
+			_, errInCounting := stores.Ideas.IncrementGazers(databaseContext, idea.ID, 1)
+			if errInCounting != nil {
+				return errInCounting
+			}
+			seededGazes++
+		}
+	}
+
+	log.Printf("Seeded %d users, %d ideas and %d gazes", len(seededUserIDs), len(seededIdeas), seededGazes)
+	return nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sessions are told apart from GitHub access tokens by the prefix, both are sent as Bearer tokens
+const sessionTokenPrefix = "sds_"
+
+var (
+	errSessionNotValid    = errors.New("Session is not valid or has expired")
+	errNoGithubInSession  = errors.New("Session was not signed in with GitHub")
+	errGithubTokenNotKept = errors.New("GitHub tokens are not kept with sessions, send a GitHub access token instead")
+)
+
+// SessionConfig : Lifetime of sessions and the secret GitHub tokens kept with them are encrypted with.
+// Without a secret, sessions keep no GitHub token
+type SessionConfig struct {
+	Lifetime         time.Duration
+	EncryptionSecret string
+}
+
+// SessionStructure : Sign in of a user, kept by the hash of its token. GitHub sign ins keep the GitHub access token
+// encrypted with it, so the token never reaches the browser
+type SessionStructure struct {
+	TokenHash   string    `bson:"_id"`
+	UserID      int64     `bson:"user_id"`
+	Login       string    `bson:"login"`
+	Name        string    `bson:"name"`
+	Provider    string    `bson:"provider"`
+	GithubToken string    `bson:"github_token,omitempty"`
+	CreatedAt   int64     `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// SessionInfoStructure : What the frontend is told about the session it holds
+type SessionInfoStructure struct {
+	UserID    int64  `json:"userID"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// SessionAuthUser : User of a new session and its token, sent as a Bearer token like a GitHub access token
+type SessionAuthUser struct {
+	UserID      int64  `json:"userID"`
+	Login       string `json:"login"`
+	Name        string `json:"name"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// SessionStore : Sessions in the stores, with the cipher of the GitHub tokens kept with them
+type SessionStore struct {
+	sessions SessionsStore
+	lifetime time.Duration
+	// Nil when SESSION_SECRET is not set
+	tokenCipher cipher.AEAD
+}
+
+func loadSessionConfig(configLoader *ConfigLoader) SessionConfig {
+	var sessionConfig SessionConfig
+
+	sessionConfig.Lifetime = time.Duration(configLoader.Int("SESSION_DAYS", 30)) * 24 * time.Hour
+	if sessionConfig.Lifetime <= 0 {
+		configLoader.Invalid("SESSION_DAYS", "should be more than 0")
+	}
+	// Optional so deployments from before sessions keep starting, GitHub tokens are only kept once it is set
+	sessionConfig.EncryptionSecret = configLoader.String("SESSION_SECRET", "")
+	if sessionConfig.EncryptionSecret != "" && len(sessionConfig.EncryptionSecret) < 32 {
+		configLoader.Invalid("SESSION_SECRET", "should be at least 32 characters")
+	}
+
+	return sessionConfig
+}
+
+// newSessionStore : Key of the cipher is derived from the secret, so a secret of any length makes an AES-256 key
+func newSessionStore(sessions SessionsStore, sessionConfig SessionConfig) *SessionStore {
+	sessionStore := &SessionStore{sessions: sessions, lifetime: sessionConfig.Lifetime}
+	if sessionConfig.EncryptionSecret == "" {
+		log.Println("SESSION_SECRET is not set, sessions signed in with GitHub will not keep the GitHub token " +
+			"and exporting ideas to GitHub will need a GitHub access token")
+		return sessionStore
+	}
+
+	encryptionKey := sha256.Sum256([]byte(sessionConfig.EncryptionSecret))
+	blockCipher, _ := aes.NewCipher(encryptionKey[:])
+	sessionStore.tokenCipher, _ = cipher.NewGCM(blockCipher)
+	return sessionStore
+}
+
+func isSessionToken(accessToken string) bool {
+	return strings.HasPrefix(accessToken, sessionTokenPrefix)
+}
+
+// create : Token is only returned here, the database keeps its hash. githubToken is empty for sign ins without GitHub,
+// and is dropped when there is no secret to encrypt it with
+func (sessionStore *SessionStore) create(databaseContext context.Context, user UserStructure, provider string,
+	githubToken string) (string, time.Time, error) {
+	randomBytes := make([]byte, 32)
+	_, errInGenerating := rand.Read(randomBytes)
+	if errInGenerating != nil {
+		return "", time.Time{}, errInGenerating
+	}
+	sessionToken := sessionTokenPrefix + hex.EncodeToString(randomBytes)
+
+	session := SessionStructure{
+		TokenHash: hashOfAccessToken(sessionToken),
+		UserID:    user.UserID,
+		Login:     user.Login,
+		Name:      user.Name,
+		Provider:  provider,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: time.Now().Add(sessionStore.lifetime),
+	}
+	if githubToken != "" && sessionStore.tokenCipher != nil {
+		encryptedToken, errInEncrypting := sessionStore.encrypt(githubToken, session.TokenHash)
+		if errInEncrypting != nil {
+			return "", time.Time{}, errInEncrypting
+		}
+		session.GithubToken = encryptedToken
+	}
+
+	errInInserting := sessionStore.sessions.Insert(databaseContext, session)
+	if errInInserting != nil {
+		return "", time.Time{}, errInInserting
+	}
+	return sessionToken, session.ExpiresAt, nil
+}
+
+// find : Expired sessions are refused even before the store removes them
+func (sessionStore *SessionStore) find(databaseContext context.Context, sessionToken string) (SessionStructure, error) {
+	session, errInFinding := sessionStore.sessions.FindByTokenHash(databaseContext, hashOfAccessToken(sessionToken))
+	if errInFinding == errNotFoundInStore || (errInFinding == nil && time.Now().After(session.ExpiresAt)) {
+		return SessionStructure{}, errSessionNotValid
+	}
+	return session, errInFinding
+}
+
+// delete : Session is gone at once, the token is refused by every instance from then on
+func (sessionStore *SessionStore) delete(databaseContext context.Context, sessionToken string) error {
+	errInDeleting := sessionStore.sessions.Delete(databaseContext, hashOfAccessToken(sessionToken))
+	if errInDeleting == errNotFoundInStore {
+		return errSessionNotValid
+	}
+	return errInDeleting
+}
+
+func (sessionStore *SessionStore) userOfSession(databaseContext context.Context, sessionToken string) (GithubUserProfileStructure, error) {
+	session, errInFinding := sessionStore.find(databaseContext, sessionToken)
+	if errInFinding != nil {
+		return GithubUserProfileStructure{}, errInFinding
+	}
+	return GithubUserProfileStructure{UserID: session.UserID, Login: session.Login, Name: session.Name}, nil
+}
+
+// githubTokenOfSession : GitHub access token the session was signed in with, for calls to GitHub on behalf of the user
+func (sessionStore *SessionStore) githubTokenOfSession(databaseContext context.Context, sessionToken string) (string, error) {
+	session, errInFinding := sessionStore.find(databaseContext, sessionToken)
+	if errInFinding != nil {
+		return "", errInFinding
+	}
+	if session.Provider != identityProviderGithub {
+		return "", errNoGithubInSession
+	}
+	// Tokens kept before the secret was removed cannot be read anymore either
+	if session.GithubToken == "" || sessionStore.tokenCipher == nil {
+		return "", errGithubTokenNotKept
+	}
+	return sessionStore.decrypt(session.GithubToken, session.TokenHash)
+}
+
+// encrypt : Sealed with the hash of the session token as additional data, so it cannot be moved to another session
+func (sessionStore *SessionStore) encrypt(plainText string, tokenHash string) (string, error) {
+	nonce := make([]byte, sessionStore.tokenCipher.NonceSize())
+	_, errInGenerating := rand.Read(nonce)
+	if errInGenerating != nil {
+		return "", errInGenerating
+	}
+
+	sealed := sessionStore.tokenCipher.Seal(nonce, nonce, []byte(plainText), []byte(tokenHash))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (sessionStore *SessionStore) decrypt(encrypted string, tokenHash string) (string, error) {
+	sealed, errInDecoding := base64.StdEncoding.DecodeString(encrypted)
+	if errInDecoding != nil {
+		return "", errInDecoding
+	}
+	nonceSize := sessionStore.tokenCipher.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("Encrypted token is too short")
+	}
+
+	plainText, errInOpening := sessionStore.tokenCipher.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(tokenHash))
+	if errInOpening != nil {
+		return "", errInOpening
+	}
+	return string(plainText), nil
+}
+
+// getAuthSession : Lets the frontend restore who is signed in from the session token alone
+func getAuthSession(ginContext *gin.Context, userSessions *SessionStore) {
+	// Route requires a user, so the header is already known to be well formed
+	sessionToken, _ := extractAuthHeader(ginContext)
+	if isSessionToken(sessionToken) == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Signed in with a GitHub access token and not a session"})
+		return
+	}
+
+	session, errInFinding := userSessions.find(ginContext.Request.Context(), sessionToken)
+	if errInFinding == errSessionNotValid {
+		ginContext.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized, "error": errInFinding.Error()})
+		return
+	}
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": SessionInfoStructure{UserID: session.UserID,
+		Login: session.Login, Name: session.Name, Provider: session.Provider, CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt.Unix()}})
+}
+
+// deleteAuthSession : Signs out of the session the request is made with, other sessions of the user stay
+func deleteAuthSession(ginContext *gin.Context, userSessions *SessionStore) {
+	// Route requires a user, so the header is already known to be well formed
+	sessionToken, _ := extractAuthHeader(ginContext)
+	if isSessionToken(sessionToken) == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Signed in with a GitHub access token and not a session"})
+		return
+	}
+
+	errInDeleting := userSessions.delete(ginContext.Request.Context(), sessionToken)
+	if errInDeleting == errSessionNotValid {
+		ginContext.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized, "error": errInDeleting.Error()})
+		return
+	}
+	if errInDeleting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while deleting from database", "errorDetails": errInDeleting.Error()})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Signed out successfully"})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testSessionSecret = "0123456789abcdef0123456789abcdef"
+
+func newTestSessionStore(sessionConfig SessionConfig) *SessionStore {
+	return newSessionStore(newMemoryStores().Sessions, sessionConfig)
+}
+
+func TestGithubTokenOfSessionRoundTrip(t *testing.T) {
+	userSessions := newTestSessionStore(SessionConfig{Lifetime: time.Hour, EncryptionSecret: testSessionSecret})
+	user := UserStructure{UserID: 42, Login: "octocat", Name: "The Octocat"}
+
+	sessionToken, _, errInCreating := userSessions.create(context.Background(), user, identityProviderGithub, "gho_kept")
+	if errInCreating != nil {
+		t.Fatal(errInCreating)
+	}
+	session, errInFinding := userSessions.find(context.Background(), sessionToken)
+	if errInFinding != nil {
+		t.Fatal(errInFinding)
+	}
+	if session.GithubToken == "" || session.GithubToken == "gho_kept" {
+		t.Fatalf("GitHub token is kept as %q", session.GithubToken)
+	}
+
+	githubToken, errInReading := userSessions.githubTokenOfSession(context.Background(), sessionToken)
+	if errInReading != nil || githubToken != "gho_kept" {
+		t.Fatalf("GitHub token of the session is %q, %v", githubToken, errInReading)
+	}
+}
+
+func TestGithubTokenSealedForAnotherSessionIsRefused(t *testing.T) {
+	userSessions := newTestSessionStore(SessionConfig{Lifetime: time.Hour, EncryptionSecret: testSessionSecret})
+
+	encrypted, errInEncrypting := userSessions.encrypt("gho_kept", hashOfAccessToken("sds_first"))
+	if errInEncrypting != nil {
+		t.Fatal(errInEncrypting)
+	}
+	if _, errInDecrypting := userSessions.decrypt(encrypted, hashOfAccessToken("sds_first")); errInDecrypting != nil {
+		t.Fatal(errInDecrypting)
+	}
+	if githubToken, errInDecrypting := userSessions.decrypt(encrypted, hashOfAccessToken("sds_second")); errInDecrypting == nil {
+		t.Fatalf("Token sealed for one session was opened with another as %q", githubToken)
+	}
+}
+
+func TestSessionWithoutSecretKeepsNoGithubToken(t *testing.T) {
+	userSessions := newTestSessionStore(SessionConfig{Lifetime: time.Hour})
+	user := UserStructure{UserID: 42, Login: "octocat", Name: "The Octocat"}
+
+	sessionToken, _, errInCreating := userSessions.create(context.Background(), user, identityProviderGithub, "gho_dropped")
+	if errInCreating != nil {
+		t.Fatal(errInCreating)
+	}
+	session, _ := userSessions.find(context.Background(), sessionToken)
+	if session.GithubToken != "" {
+		t.Fatalf("GitHub token is kept without a secret as %q", session.GithubToken)
+	}
+	if _, errInReading := userSessions.githubTokenOfSession(context.Background(), sessionToken); errInReading != errGithubTokenNotKept {
+		t.Fatalf("GitHub token of the session failed with %v", errInReading)
+	}
+}
+
+func TestExpiredSessionIsRefused(t *testing.T) {
+	router, stores, _ := newMemoryTestServer(t)
+	expiredSessions := newSessionStore(stores.Sessions, SessionConfig{Lifetime: -time.Minute})
+	user := UserStructure{UserID: 42, Login: "octocat", Name: "The Octocat"}
+
+	sessionToken, _, errInCreating := expiredSessions.create(context.Background(), user, identityProviderGithub, "")
+	if errInCreating != nil {
+		t.Fatal(errInCreating)
+	}
+	if _, errInFinding := expiredSessions.find(context.Background(), sessionToken); errInFinding != errSessionNotValid {
+		t.Fatalf("Expired session was found with %v", errInFinding)
+	}
+
+	sessionResponse := serveTestRequest(router, http.MethodGet, "/auth/session", "", sessionToken)
+	if sessionResponse.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /auth/session with an expired session answered %d: %s", sessionResponse.Code, sessionResponse.Body.String())
+	}
+}
+
+func TestDeletedSessionIsRefused(t *testing.T) {
+	router, _, sessionToken := newMemoryTestServer(t)
+
+	if sessionResponse := serveTestRequest(router, http.MethodGet, "/auth/session", "", sessionToken); sessionResponse.Code != http.StatusOK {
+		t.Fatalf("GET /auth/session answered %d: %s", sessionResponse.Code, sessionResponse.Body.String())
+	}
+	if deleteResponse := serveTestRequest(router, http.MethodDelete, "/auth/session", "", sessionToken); deleteResponse.Code != http.StatusOK {
+		t.Fatalf("DELETE /auth/session answered %d: %s", deleteResponse.Code, deleteResponse.Body.String())
+	}
+
+	sessionResponse := serveTestRequest(router, http.MethodGet, "/auth/session", "", sessionToken)
+	if sessionResponse.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /auth/session after signing out answered %d: %s", sessionResponse.Code, sessionResponse.Body.String())
+	}
+}
+
+func TestGithubAccessTokenHasNoSession(t *testing.T) {
+	router := newReplayTestServer(t)
+
+	// Recorded GitHub profile signs the caller in, but not with a session
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		sessionResponse := serveTestRequest(router, method, "/auth/session", "", recordedGithubToken)
+		if sessionResponse.Code != http.StatusBadRequest {
+			t.Fatalf("%s /auth/session with a GitHub access token answered %d: %s", method, sessionResponse.Code,
+				sessionResponse.Body.String())
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Size OpenGraph and Twitter cards are shown at
+const (
+	shareCardWidth  = 1200
+	shareCardHeight = 630
+	shareCardMargin = 80
+	// Lines of the idea name before it is cut with dots
+	maxShareCardNameLines = 3
+	oEmbedCacheAge        = 3600
+)
+
+var (
+	shareCardBackgroundColor = color.RGBA{R: 0x1b, G: 0x1f, B: 0x2a, A: 0xff}
+	shareCardAccentColor     = color.RGBA{R: 0xf5, G: 0xa6, B: 0x23, A: 0xff}
+	shareCardTextColor       = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	shareCardMutedColor      = color.RGBA{R: 0xa0, G: 0xa8, B: 0xb8, A: 0xff}
+)
+
+// shareCardGlyphs : 5x7 bitmap font, each row is 5 bits with the leftmost pixel highest,
+// letters are drawn in upper case and anything else missing here is left out
+var shareCardGlyphs = map[rune][7]uint8{
+	'A': {0x0e, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11}, 'B': {0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e},
+	'C': {0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e}, 'D': {0x1c, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1c},
+	'E': {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f}, 'F': {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10},
+	'G': {0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f}, 'H': {0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'I': {0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e}, 'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11}, 'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f},
+	'M': {0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11}, 'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e}, 'P': {0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10},
+	'Q': {0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d}, 'R': {0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11},
+	'S': {0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e}, 'T': {0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e}, 'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a}, 'X': {0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0a, 0x04, 0x04, 0x04}, 'Z': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f},
+	'0': {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e}, '1': {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f}, '3': {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4': {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02}, '5': {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6': {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e}, '7': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e}, '9': {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	' ': {}, '-': {0, 0, 0, 0x1f, 0, 0, 0}, '.': {0, 0, 0, 0, 0, 0x0c, 0x0c}, ',': {0, 0, 0, 0, 0x0c, 0x04, 0x08},
+	'!': {0x04, 0x04, 0x04, 0x04, 0x04, 0, 0x04}, '?': {0x0e, 0x11, 0x01, 0x02, 0x04, 0, 0x04},
+	'\'': {0x04, 0x04, 0x08, 0, 0, 0, 0}, '"': {0x0a, 0x0a, 0, 0, 0, 0, 0}, ':': {0, 0x0c, 0x0c, 0, 0x0c, 0x0c, 0},
+	'/': {0, 0x01, 0x02, 0x04, 0x08, 0x10, 0}, '&': {0x0c, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0d},
+	'@': {0x0e, 0x11, 0x01, 0x0d, 0x15, 0x15, 0x0e}, '#': {0x0a, 0x0a, 0x1f, 0x0a, 0x1f, 0x0a, 0x0a},
+	'(': {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02}, ')': {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'+': {0, 0x04, 0x04, 0x1f, 0x04, 0x04, 0}, '_': {0, 0, 0, 0, 0, 0, 0x1f},
+}
+
+// OEmbedStructure : Link type of oEmbed 1.0, with the share card as thumbnail
+type OEmbedStructure struct {
+	Version         string `json:"version"`
+	Type            string `json:"type"`
+	Title           string `json:"title"`
+	AuthorName      string `json:"author_name,omitempty"`
+	AuthorURL       string `json:"author_url,omitempty"`
+	ProviderName    string `json:"provider_name"`
+	ProviderURL     string `json:"provider_url"`
+	CacheAge        int    `json:"cache_age"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+}
+
+// shareCardTextOf : Upper case with accents taken off, so as much of the text as the font has is drawn
+func shareCardTextOf(text string) string {
+	var drawableText strings.Builder
+	for _, character := range norm.NFD.String(strings.ToUpper(text)) {
+		if unicode.Is(unicode.Mn, character) {
+			continue
+		}
+		if _, hasGlyph := shareCardGlyphs[character]; hasGlyph == true {
+			drawableText.WriteRune(character)
+		}
+	}
+	return strings.Join(strings.Fields(drawableText.String()), " ")
+}
+
+// drawShareCardText : Each pixel of a glyph is scale pixels wide, glyphs are a pixel apart
+func drawShareCardText(card *image.RGBA, text string, left int, top int, scale int, textColor color.Color) {
+	for index, character := range []rune(text) {
+		glyph := shareCardGlyphs[character]
+		glyphLeft := left + index*6*scale
+		for row, rowBits := range glyph {
+			for column := 0; column < 5; column++ {
+				if rowBits&(0x10>>uint(column)) == 0 {
+					continue
+				}
+				pixel := image.Rect(glyphLeft+column*scale, top+row*scale, glyphLeft+(column+1)*scale, top+(row+1)*scale)
+				draw.Draw(card, pixel, &image.Uniform{C: textColor}, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
+
+// wrapShareCardText : Breaks at spaces into lines of at most lineLength characters, words longer than a line are cut
+func wrapShareCardText(text string, lineLength int, maxLines int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		for len(word) > lineLength {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, word[:lineLength])
+			word = word[lineLength:]
+		}
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= lineLength:
+			line = line + " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lastLine := lines[maxLines-1]
+		if len(lastLine) > lineLength-3 {
+			lastLine = strings.TrimRight(lastLine[:lineLength-3], " ")
+		}
+		lines[maxLines-1] = lastLine + "..."
+	}
+	return lines
+}
+
+// authorOfIdea : Login of whoever wrote the idea, members write the ideas of orgs
+func authorOfIdea(idea IdeaStructure) string {
+	if idea.Org != "" && idea.Author != "" {
+		return idea.Author
+	}
+	return idea.Publisher
+}
+
+func renderShareCard(idea IdeaStructure, apiName string) ([]byte, error) {
+	card := image.NewRGBA(image.Rect(0, 0, shareCardWidth, shareCardHeight))
+	draw.Draw(card, card.Bounds(), &image.Uniform{C: shareCardBackgroundColor}, image.Point{}, draw.Src)
+	draw.Draw(card, image.Rect(0, 0, shareCardWidth, 16), &image.Uniform{C: shareCardAccentColor}, image.Point{}, draw.Src)
+
+	nameScale := 8
+	nameLineLength := (shareCardWidth - 2*shareCardMargin) / (6 * nameScale)
+	for index, line := range wrapShareCardText(shareCardTextOf(idea.Name), nameLineLength, maxShareCardNameLines) {
+		drawShareCardText(card, line, shareCardMargin, 110+index*10*nameScale, nameScale, shareCardTextColor)
+	}
+
+	publisher := shareCardTextOf(idea.Publisher)
+	if idea.Org != "" && idea.Author != "" {
+		publisher = shareCardTextOf(idea.Author + " / " + idea.Org)
+	}
+	for _, line := range wrapShareCardText("BY "+publisher, (shareCardWidth-2*shareCardMargin)/(6*4), 1) {
+		drawShareCardText(card, line, shareCardMargin, 400, 4, shareCardMutedColor)
+	}
+
+	gazesLabel := " GAZES"
+	if idea.Gazers == 1 {
+		gazesLabel = " GAZE"
+	}
+	drawShareCardText(card, strconv.FormatInt(idea.Gazers, 10)+gazesLabel, shareCardMargin, 480, 6, shareCardAccentColor)
+	drawShareCardText(card, shareCardTextOf(apiName), shareCardMargin, shareCardHeight-shareCardMargin, 3, shareCardMutedColor)
+
+	var cardInPNG bytes.Buffer
+	errInEncoding := png.Encode(&cardInPNG, card)
+	return cardInPNG.Bytes(), errInEncoding
+}
+
+// findSharedIdea : Only public ideas have previews, the rest answer as if they did not exist
+func findSharedIdea(ginContext *gin.Context, stores Stores, ideaID string) (IdeaStructure, bool) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return IdeaStructure{}, false
+	}
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(ginContext.Request.Context(), hexIdeaID)
+	if errInFindingIdea == nil && isIdeaPublic(idea) == false {
+		errInFindingIdea = errNotFoundInStore
+	}
+	if errInFindingIdea == errNotFoundInStore {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return idea, false
+	}
+	if errInFindingIdea != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingIdea.Error()})
+		return idea, false
+	}
+	return idea, true
+}
+
+func getIdeaShareCard(ginContext *gin.Context, stores Stores, ideaID string, brandingConfig BrandingConfig) {
+	idea, isFound := findSharedIdea(ginContext, stores, ideaID)
+	if isFound == false {
+		return
+	}
+
+	cardInPNG, errInRendering := renderShareCard(idea, brandingConfig.APIName)
+	if errInRendering != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error in rendering share card", "errorDetails": errInRendering.Error()})
+		return
+	}
+	ginContext.Data(http.StatusOK, "image/png", cardInPNG)
+}
+
+// requestOrigin : Scheme and host the caller reached the API at, proxies in front of it send the scheme along
+func requestOrigin(ginContext *gin.Context) string {
+	scheme := "http"
+	if ginContext.Request.TLS != nil || ginContext.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + ginContext.Request.Host
+}
+
+// getOEmbed : Answers for the frontend pages of ideas, /idea/<id> with or without the slug after it,
+// the response is the bare oEmbed document as consumers expect it
+func getOEmbed(ginContext *gin.Context, stores Stores, brandingConfig BrandingConfig) {
+	if format := ginContext.Query("format"); format != "" && format != "json" {
+		ginContext.JSON(http.StatusNotImplemented, gin.H{"status": http.StatusNotImplemented,
+			"error": "Only json format is supported"})
+		return
+	}
+
+	frontendOrigin := strings.TrimRight(brandingConfig.FrontendOrigin, "/")
+	embeddedURL, errInURL := url.Parse(ginContext.Query("url"))
+	var pathSegments []string
+	if errInURL == nil {
+		pathSegments = strings.Split(strings.Trim(embeddedURL.Path, "/"), "/")
+	}
+	if errInURL != nil || embeddedURL.Scheme+"://"+embeddedURL.Host != frontendOrigin || len(pathSegments) < 2 ||
+		len(pathSegments) > 3 || pathSegments[0] != "idea" {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, url should be the page of an idea on " + frontendOrigin})
+		return
+	}
+
+	idea, isFound := findSharedIdea(ginContext, stores, pathSegments[1])
+	if isFound == false {
+		return
+	}
+
+	oEmbed := OEmbedStructure{
+		Version:      "1.0",
+		Type:         "link",
+		Title:        idea.Name,
+		ProviderName: brandingConfig.APIName,
+		ProviderURL:  frontendOrigin,
+		CacheAge:     oEmbedCacheAge,
+	}
+	if author := authorOfIdea(idea); author != "" && author != deletedUserLogin {
+		oEmbed.AuthorName = author
+		oEmbed.AuthorURL = userPageURL(frontendOrigin, author)
+	}
+
+	// Consumers asking for something smaller than the card get no thumbnail rather than a larger one
+	maxWidth, _ := strconv.Atoi(ginContext.Query("maxwidth"))
+	maxHeight, _ := strconv.Atoi(ginContext.Query("maxheight"))
+	if (maxWidth == 0 || maxWidth >= shareCardWidth) && (maxHeight == 0 || maxHeight >= shareCardHeight) {
+		oEmbed.ThumbnailURL = requestOrigin(ginContext) + "/idea/" + idea.ID.Hex() + "/card.png"
+		oEmbed.ThumbnailWidth = shareCardWidth
+		oEmbed.ThumbnailHeight = shareCardHeight
+	}
+
+	ginContext.JSON(http.StatusOK, oEmbed)
+}
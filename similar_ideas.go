@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxSimilarCandidates = 30
+	maxSimilarIdeasKept  = 5
+	minSimilarityScore   = 0.1
+	// Each idea both link to, or a link between the two, counts as much as this much text similarity
+	sharedLinkScore = 0.2
+)
+
+// similarIdeaScore : Related idea as precomputed in similar_ideas
+type similarIdeaScore struct {
+	IdeaID primitive.ObjectID `bson:"idea_id"`
+	Score  float64            `bson:"score"`
+}
+
+// similarIdeasDocument : Structure of similar_ideas collection, one document per public idea
+type similarIdeasDocument struct {
+	IdeaID     primitive.ObjectID `bson:"_id"`
+	Similar    []similarIdeaScore `bson:"similar"`
+	ComputedAt int64              `bson:"computed_at"`
+}
+
+// SimilarIdeaStructure : Structure of a related idea in the response
+type SimilarIdeaStructure struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	Name   string             `json:"name" bson:"name"`
+	Slug   string             `json:"slug" bson:"slug"`
+	Gazers int64              `json:"gazers" bson:"gazers"`
+	Score  float64            `json:"score" bson:"-"`
+}
+
+func runSimilarIdeasJob(databaseClient *mongo.Client, stores Stores, interval time.Duration) {
+	runScheduledJob(databaseClient, "similar_ideas", interval, func() error {
+		return computeSimilarIdeas(databaseClient, stores, interval)
+	})
+}
+
+// similarityOf : Text similarity of both ideas raised by the links they share
+func similarityOf(idea IdeaStructure, candidate IdeaStructure) float64 {
+	score := trigramSimilarity(idea.Name+" "+idea.Description, candidate.Name+" "+candidate.Description)
+
+	linkedByIdea := make(map[primitive.ObjectID]bool)
+	for _, link := range idea.Links {
+		linkedByIdea[link.IdeaID] = true
+	}
+	if linkedByIdea[candidate.ID] == true {
+		score = score + sharedLinkScore
+	}
+	for _, link := range candidate.Links {
+		if link.IdeaID == idea.ID || linkedByIdea[link.IdeaID] == true {
+			score = score + sharedLinkScore
+		}
+	}
+
+	return score
+}
+
+// findSimilarIdeas : Search of the stores narrows down candidates among public ideas, the best scored are kept
+func findSimilarIdeas(databaseContext context.Context, stores Stores, idea IdeaStructure) ([]similarIdeaScore, error) {
+	similarIdeas := []similarIdeaScore{}
+
+	searchWords := wordsInTextRegex.FindAllString(idea.Name+" "+idea.Description, -1)
+	if len(searchWords) == 0 {
+		return similarIdeas, nil
+	}
+
+	// One more than needed, the idea itself is among the matches
+	candidates, errInFinding := stores.Ideas.SearchPublished(databaseContext, searchWords, maxSimilarCandidates+1)
+	if errInFinding != nil {
+		return similarIdeas, errInFinding
+	}
+
+	for _, candidate := range candidates {
+		if candidate.ID == idea.ID {
+			continue
+		}
+		score := similarityOf(idea, candidate)
+		if score >= minSimilarityScore {
+			similarIdeas = append(similarIdeas, similarIdeaScore{IdeaID: candidate.ID, Score: score})
+		}
+	}
+
+	sort.Slice(similarIdeas, func(first, second int) bool {
+		return similarIdeas[first].Score > similarIdeas[second].Score
+	})
+	if len(similarIdeas) > maxSimilarIdeasKept {
+		similarIdeas = similarIdeas[:maxSimilarIdeasKept]
+	}
+
+	return similarIdeas, nil
+}
+
+// computeSimilarIdeas : Recomputes related ideas of every public idea, documents of ideas no longer public are dropped.
+// Ideas come from the stores, what is computed is kept in mongo
+func computeSimilarIdeas(databaseClient *mongo.Client, stores Stores, interval time.Duration) error {
+	similarIdeasCollection := databaseClient.Database("sardene-db").Collection("similar_ideas")
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), interval*9/10)
+	defer cancelDBContext()
+
+	runStartedAt := time.Now().Unix()
+
+	publicIdeas, errInFinding := stores.Ideas.ListPublished(databaseContext, IdeaListFilter{IncludeArchived: true},
+		[]string{"id", "name", "description", "links"})
+	if errInFinding != nil {
+		return errInFinding
+	}
+
+	for _, idea := range publicIdeas {
+		similarIdeas, errInFindingSimilar := findSimilarIdeas(databaseContext, stores, idea)
+		if errInFindingSimilar != nil {
+			return errInFindingSimilar
+		}
+
+		similarIdeasOfIdea := similarIdeasDocument{IdeaID: idea.ID, Similar: similarIdeas, ComputedAt: time.Now().Unix()}
+		_, errInSaving := similarIdeasCollection.ReplaceOne(databaseContext, bson.M{"_id": idea.ID}, similarIdeasOfIdea,
+			options.Replace().SetUpsert(true))
+		if errInSaving != nil {
+			return errInSaving
+		}
+	}
+
+	_, errInDeleting := similarIdeasCollection.DeleteMany(databaseContext, bson.M{"computed_at": bson.M{"$lt": runStartedAt}})
+	return errInDeleting
+}
+
+// getSimilarIdeas : Reads what the job computed, ideas which stopped being public since are left out
+func getSimilarIdeas(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea != nil || isIdeaPublic(idea) == false {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+
+	similarIdeasCollection := databaseClient.Database("sardene-db").Collection("similar_ideas")
+
+	var similarIdeasOfIdea similarIdeasDocument
+	errInDecodingSimilar := similarIdeasCollection.FindOne(databaseContext, bson.M{"_id": hexIdeaID}).Decode(&similarIdeasOfIdea)
+	if errInDecodingSimilar != nil && errInDecodingSimilar != mongo.ErrNoDocuments {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInDecodingSimilar.Error()})
+		return
+	}
+
+	similarIdeas := []SimilarIdeaStructure{}
+	if len(similarIdeasOfIdea.Similar) == 0 {
+		ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": similarIdeas, "count": 0})
+		return
+	}
+
+	scoreOfIdea := make(map[primitive.ObjectID]float64)
+	similarIdeaIDs := make([]primitive.ObjectID, 0, len(similarIdeasOfIdea.Similar))
+	for _, similarIdea := range similarIdeasOfIdea.Similar {
+		scoreOfIdea[similarIdea.IdeaID] = similarIdea.Score
+		similarIdeaIDs = append(similarIdeaIDs, similarIdea.IdeaID)
+	}
+
+	ideasFound, errInFinding := stores.Ideas.FindByIDs(databaseContext, similarIdeaIDs)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+
+	for _, similarIdea := range ideasFound {
+		if isIdeaPublic(similarIdea) == false {
+			continue
+		}
+		similarIdeas = append(similarIdeas, SimilarIdeaStructure{ID: similarIdea.ID, Name: similarIdea.Name, Slug: similarIdea.Slug,
+			Gazers: similarIdea.Gazers, Score: scoreOfIdea[similarIdea.ID]})
+	}
+
+	sort.Slice(similarIdeas, func(first, second int) bool {
+		return similarIdeas[first].Score > similarIdeas[second].Score
+	})
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": similarIdeas, "count": len(similarIdeas)})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	// Search engines read no more than this many urls from one sitemap
+	maxSitemapURLs = 50000
+)
+
+// SitemapURLStructure : One page of the frontend in the sitemap
+type SitemapURLStructure struct {
+	Location     string `xml:"loc"`
+	LastModified string `xml:"lastmod,omitempty"`
+}
+
+// SitemapStructure : urlset document of the sitemap protocol
+type SitemapStructure struct {
+	XMLName   xml.Name              `xml:"urlset"`
+	Namespace string                `xml:"xmlns,attr"`
+	URLs      []SitemapURLStructure `xml:"url"`
+}
+
+// Sitemap : Last generated sitemap, every instance generates its own as it only reads public ideas
+type Sitemap struct {
+	mutex          sync.Mutex
+	stores         Stores
+	frontendOrigin string
+	body           []byte
+	generatedAt    time.Time
+}
+
+var sitemap *Sitemap
+
+// startSitemapGeneration : Generates the sitemap right away and again every interval, 0 only generates it on the first request
+func startSitemapGeneration(stores Stores, frontendOrigin string, interval time.Duration) {
+	sitemap = &Sitemap{stores: stores, frontendOrigin: strings.TrimRight(frontendOrigin, "/")}
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		for {
+			errInGenerating := sitemap.generate()
+			if errInGenerating != nil {
+				log.Println(errInGenerating, "Failed to generate sitemap")
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// ideaPageURL : Frontend page of an idea, the id finds it and the slug keeps the url readable
+func ideaPageURL(frontendOrigin string, idea IdeaStructure) string {
+	if idea.Slug == "" {
+		return frontendOrigin + "/idea/" + idea.ID.Hex()
+	}
+	return frontendOrigin + "/idea/" + idea.ID.Hex() + "/" + idea.Slug
+}
+
+func userPageURL(frontendOrigin string, login string) string {
+	return frontendOrigin + "/users/" + url.PathEscape(login)
+}
+
+func (sitemap *Sitemap) generate() error {
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelDBContext()
+
+	ideas, errInListing := sitemap.stores.Ideas.ListPublished(databaseContext, IdeaListFilter{},
+		[]string{"id", "slug", "publisher", "publisher_id", "org", "author", "updated_at", "created_at"})
+	if errInListing != nil {
+		return errInListing
+	}
+	// Recently changed ideas are kept when there are more than fit
+	sort.Slice(ideas, func(i, j int) bool {
+		return lastChangeOf(ideas[i]) > lastChangeOf(ideas[j])
+	})
+
+	urlset := SitemapStructure{Namespace: sitemapNamespace}
+	// Users are only listed once they published a public idea, the profile of everyone else has nothing to index
+	lastChangeOfUser := make(map[string]int64)
+	for _, idea := range ideas {
+		if len(urlset.URLs) == maxSitemapURLs {
+			break
+		}
+		urlset.URLs = append(urlset.URLs, SitemapURLStructure{Location: ideaPageURL(sitemap.frontendOrigin, idea),
+			LastModified: sitemapDate(lastChangeOf(idea))})
+
+		login := authorOfIdea(idea)
+		if login != "" && login != deletedUserLogin && lastChangeOf(idea) > lastChangeOfUser[login] {
+			lastChangeOfUser[login] = lastChangeOf(idea)
+		}
+	}
+
+	logins := make([]string, 0, len(lastChangeOfUser))
+	for login := range lastChangeOfUser {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+	for _, login := range logins {
+		if len(urlset.URLs) == maxSitemapURLs {
+			break
+		}
+		urlset.URLs = append(urlset.URLs, SitemapURLStructure{Location: userPageURL(sitemap.frontendOrigin, login),
+			LastModified: sitemapDate(lastChangeOfUser[login])})
+	}
+
+	sitemapInXML, errInEncoding := xml.Marshal(urlset)
+	if errInEncoding != nil {
+		return errInEncoding
+	}
+
+	sitemap.mutex.Lock()
+	sitemap.body = append([]byte(xml.Header), sitemapInXML...)
+	sitemap.generatedAt = time.Now()
+	sitemap.mutex.Unlock()
+	return nil
+}
+
+func lastChangeOf(idea IdeaStructure) int64 {
+	if idea.UpdatedAt > idea.CreatedAt {
+		return idea.UpdatedAt
+	}
+	return idea.CreatedAt
+}
+
+func sitemapDate(unixTime int64) string {
+	if unixTime == 0 {
+		return ""
+	}
+	return time.Unix(unixTime, 0).UTC().Format("2006-01-02")
+}
+
+// getSitemap : Served from the last generation, the first request generates it when the background one has not finished yet
+func getSitemap(ginContext *gin.Context) {
+	// Nil for servers built without their services, as in tests
+	if sitemap == nil {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Sitemap is not generated on this server"})
+		return
+	}
+
+	sitemap.mutex.Lock()
+	body, generatedAt := sitemap.body, sitemap.generatedAt
+	sitemap.mutex.Unlock()
+
+	if body == nil {
+		errInGenerating := sitemap.generate()
+		if errInGenerating != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in generating sitemap", "errorDetails": errInGenerating.Error()})
+			return
+		}
+		sitemap.mutex.Lock()
+		body, generatedAt = sitemap.body, sitemap.generatedAt
+		sitemap.mutex.Unlock()
+	}
+
+	ginContext.Header("Last-Modified", generatedAt.UTC().Format(http.TimeFormat))
+	ginContext.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	ideaStatusLaunched = "launched"
+
+	statsDaysShown = 30
+)
+
+// DailyIdeasCount : Ideas created on one UTC day
+type DailyIdeasCount struct {
+	Day   string `json:"day" bson:"_id"`
+	Ideas int64  `json:"ideas" bson:"ideas"`
+}
+
+// CommunityStatsStructure : Numbers the landing page shows about the community
+type CommunityStatsStructure struct {
+	TotalIdeas    int64             `json:"total_ideas"`
+	TotalUsers    int64             `json:"total_users"`
+	TotalGazes    int64             `json:"total_gazes"`
+	LaunchedIdeas int64             `json:"launched_ideas"`
+	IdeasPerDay   []DailyIdeasCount `json:"ideas_per_day"`
+	ComputedAt    int64             `json:"computed_at"`
+}
+
+// communityStatsCache : Stats scan whole collections, so they are computed at most once per cache duration
+type communityStatsCache struct {
+	mutex     sync.Mutex
+	stats     CommunityStatsStructure
+	expiresAt time.Time
+}
+
+var cachedCommunityStats = &communityStatsCache{}
+
+// countFacet : Facet which counts the documents of another collection, run once on the first idea
+func countFacet(collectionName string) bson.A {
+	return bson.A{
+		bson.M{"$limit": 1},
+		bson.M{"$lookup": bson.M{"from": collectionName, "pipeline": bson.A{bson.M{"$count": "count"}}, "as": "counted"}},
+		bson.M{"$unwind": "$counted"},
+		bson.M{"$project": bson.M{"_id": 0, "count": "$counted.count"}},
+	}
+}
+
+func computeCommunityStats(ginContext *gin.Context, sardeneDatabase *mongo.Database) (CommunityStatsStructure, error) {
+	stats := CommunityStatsStructure{IdeasPerDay: []DailyIdeasCount{}, ComputedAt: time.Now().Unix()}
+
+	ideasCollection := sardeneDatabase.Collection("ideas")
+	databaseContext := ginContext.Request.Context()
+
+	firstDayShown := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(statsDaysShown - 1))
+	createdAtAsDate := bson.M{"$add": bson.A{time.Unix(0, 0), bson.M{"$multiply": bson.A{"$created_at", 1000}}}}
+
+	statsPipeline := bson.A{
+		bson.M{"$facet": bson.M{
+			"ideas":    bson.A{bson.M{"$match": publicIdeasFilter()}, bson.M{"$count": "count"}},
+			"launched": bson.A{bson.M{"$match": publicIdeasFilter()}, bson.M{"$match": bson.M{"status": ideaStatusLaunched}}, bson.M{"$count": "count"}},
+			"users":    countFacet("users"),
+			"gazes":    countFacet("likes"),
+			"per_day": bson.A{
+				bson.M{"$match": publicIdeasFilter()},
+				bson.M{"$match": bson.M{"created_at": bson.M{"$gte": firstDayShown.Unix()}}},
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": createdAtAsDate}},
+					"ideas": bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+		}},
+	}
+
+	statsCursor, errInAggregating := ideasCollection.Aggregate(databaseContext, statsPipeline)
+	if errInAggregating != nil {
+		return stats, errInAggregating
+	}
+	defer statsCursor.Close(databaseContext)
+
+	type countResult struct {
+		Count int64 `bson:"count"`
+	}
+	var facets struct {
+		Ideas    []countResult     `bson:"ideas"`
+		Launched []countResult     `bson:"launched"`
+		Users    []countResult     `bson:"users"`
+		Gazes    []countResult     `bson:"gazes"`
+		PerDay   []DailyIdeasCount `bson:"per_day"`
+	}
+	if statsCursor.Next(databaseContext) {
+		errInDecoding := statsCursor.Decode(&facets)
+		if errInDecoding != nil {
+			return stats, errInDecoding
+		}
+	}
+	if errInCursor := statsCursor.Err(); errInCursor != nil {
+		return stats, errInCursor
+	}
+
+	firstCount := func(counts []countResult) int64 {
+		if len(counts) == 0 {
+			return 0
+		}
+		return counts[0].Count
+	}
+	stats.TotalIdeas = firstCount(facets.Ideas)
+	stats.LaunchedIdeas = firstCount(facets.Launched)
+	stats.TotalUsers = firstCount(facets.Users)
+	stats.TotalGazes = firstCount(facets.Gazes)
+
+	// Days without ideas are filled in, so the chart has all of them
+	ideasOnDay := make(map[string]int64, len(facets.PerDay))
+	for _, dailyCount := range facets.PerDay {
+		ideasOnDay[dailyCount.Day] = dailyCount.Ideas
+	}
+	for dayIndex := 0; dayIndex < statsDaysShown; dayIndex++ {
+		day := firstDayShown.AddDate(0, 0, dayIndex).Format("2006-01-02")
+		stats.IdeasPerDay = append(stats.IdeasPerDay, DailyIdeasCount{Day: day, Ideas: ideasOnDay[day]})
+	}
+
+	return stats, nil
+}
+
+// getCommunityStats : Read from the listing database, stats are cached for a while anyway
+func getCommunityStats(ginContext *gin.Context, sardeneDatabase *mongo.Database, statsCacheDuration time.Duration) {
+	cachedCommunityStats.mutex.Lock()
+	defer cachedCommunityStats.mutex.Unlock()
+
+	if time.Now().After(cachedCommunityStats.expiresAt) {
+		stats, errInComputing := computeCommunityStats(ginContext, sardeneDatabase)
+		if errInComputing != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in computing stats", "errorDetails": errInComputing.Error()})
+			return
+		}
+		cachedCommunityStats.stats = stats
+		cachedCommunityStats.expiresAt = time.Now().Add(statsCacheDuration)
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": cachedCommunityStats.stats})
+}
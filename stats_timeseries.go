@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	intervalDay   = "day"
+	intervalWeek  = "week"
+	intervalMonth = "month"
+
+	maxTimeseriesRangeInDays = 730
+)
+
+// TimeseriesMetric : Collection counted for a metric, by its created_at
+type TimeseriesMetric struct {
+	Collection string
+	Filter     func() bson.M
+}
+
+var timeseriesMetrics = map[string]TimeseriesMetric{
+	"ideas_created": {Collection: "ideas", Filter: publicIdeasFilter},
+	"gazes":         {Collection: "likes", Filter: func() bson.M { return bson.M{} }},
+	"new_users":     {Collection: "users", Filter: func() bson.M { return bson.M{} }},
+}
+
+// TimeseriesBucket : Count of one interval, starting on the UTC date of start
+type TimeseriesBucket struct {
+	Start string `json:"start"`
+	Count int64  `json:"count"`
+}
+
+// parseTimeseriesRange : Range like 90d or 12w, counted back from today
+func parseTimeseriesRange(rangeQuery string) (int, error) {
+	if len(rangeQuery) < 2 {
+		return 0, errors.New("Range should be like 90d or 12w, got " + strconv.Quote(rangeQuery))
+	}
+	amount, errInParsing := strconv.Atoi(rangeQuery[:len(rangeQuery)-1])
+	if errInParsing != nil || amount <= 0 {
+		return 0, errors.New("Range should be like 90d or 12w, got " + strconv.Quote(rangeQuery))
+	}
+
+	var rangeInDays int
+	switch strings.ToLower(rangeQuery[len(rangeQuery)-1:]) {
+	case "d":
+		rangeInDays = amount
+	case "w":
+		rangeInDays = amount * 7
+	default:
+		return 0, errors.New("Range should be in days or weeks like 90d or 12w, got " + strconv.Quote(rangeQuery))
+	}
+	if rangeInDays > maxTimeseriesRangeInDays {
+		return 0, errors.New("Range should be at most " + strconv.Itoa(maxTimeseriesRangeInDays) + " days")
+	}
+	return rangeInDays, nil
+}
+
+// startOfInterval : Same start as $dateTrunc in UTC, weeks start on monday
+func startOfInterval(moment time.Time, interval string) time.Time {
+	day := time.Date(moment.Year(), moment.Month(), moment.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case intervalWeek:
+		daysSinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -daysSinceMonday)
+	case intervalMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func nextInterval(start time.Time, interval string) time.Time {
+	switch interval {
+	case intervalWeek:
+		return start.AddDate(0, 0, 7)
+	case intervalMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// getStatsTimeseries : Counts per interval for growth charts, intervals without anything are 0 so charts have all of them
+func getStatsTimeseries(ginContext *gin.Context, sardeneDatabase *mongo.Database) {
+	metricName := ginContext.DefaultQuery("metric", "ideas_created")
+	metric, isKnownMetric := timeseriesMetrics[metricName]
+	if isKnownMetric == false {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Metric should be one of ideas_created, gazes or new_users"})
+		return
+	}
+	interval := ginContext.DefaultQuery("interval", intervalDay)
+	if interval != intervalDay && interval != intervalWeek && interval != intervalMonth {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Interval should be one of day, week or month"})
+		return
+	}
+	rangeQuery := ginContext.DefaultQuery("range", "90d")
+	rangeInDays, errInRange := parseTimeseriesRange(rangeQuery)
+	if errInRange != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong range", "errorDetails": errInRange.Error()})
+		return
+	}
+
+	now := time.Now().UTC()
+	firstStart := startOfInterval(now.AddDate(0, 0, -(rangeInDays-1)), interval)
+
+	countedCollection := sardeneDatabase.Collection(metric.Collection)
+	databaseContext := ginContext.Request.Context()
+
+	createdAtAsDate := bson.M{"$toDate": bson.M{"$multiply": bson.A{"$created_at", 1000}}}
+	timeseriesPipeline := bson.A{
+		bson.M{"$match": metric.Filter()},
+		bson.M{"$match": bson.M{"created_at": bson.M{"$gte": firstStart.Unix()}}},
+		bson.M{"$group": bson.M{
+			"_id": bson.M{"$dateTrunc": bson.M{"date": createdAtAsDate, "unit": interval, "timezone": "UTC",
+				"startOfWeek": "monday"}},
+			"count": bson.M{"$sum": 1},
+		}},
+	}
+	bucketsCursor, errInAggregating := countedCollection.Aggregate(databaseContext, timeseriesPipeline)
+	if errInAggregating != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in computing stats", "errorDetails": errInAggregating.Error()})
+		return
+	}
+	defer bucketsCursor.Close(databaseContext)
+
+	countOfStart := make(map[string]int64)
+	for bucketsCursor.Next(databaseContext) {
+		var bucket struct {
+			Start time.Time `bson:"_id"`
+			Count int64     `bson:"count"`
+		}
+		errInDecoding := bucketsCursor.Decode(&bucket)
+		if errInDecoding != nil {
+			ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+				"error": "Error in decoding database", "errorDetails": errInDecoding.Error()})
+			return
+		}
+		countOfStart[bucket.Start.UTC().Format("2006-01-02")] = bucket.Count
+	}
+	if errInCursor := bucketsCursor.Err(); errInCursor != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in computing stats", "errorDetails": errInCursor.Error()})
+		return
+	}
+
+	buckets := []TimeseriesBucket{}
+	for start := firstStart; start.After(now) == false; start = nextInterval(start, interval) {
+		day := start.Format("2006-01-02")
+		buckets = append(buckets, TimeseriesBucket{Start: day, Count: countOfStart[day]})
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": buckets, "metric": metricName,
+		"interval": interval, "range": rangeQuery})
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	statusWindowMinutes          = 5
+	maxLatenciesPerMinute        = 2000
+	statusOperational            = "operational"
+	statusDegraded               = "degraded"
+	statusMajorOutage            = "major_outage"
+	incidentSeverityMinor        = "minor"
+	incidentSeverityMajor        = "major"
+	degradedErrorRate            = 0.05
+	degradedDependencyErrorRate  = 0.5
+	resolvedIncidentsShownForSec = 24 * 60 * 60
+)
+
+// requestMetricsBucket : Requests served during one minute
+type requestMetricsBucket struct {
+	minute    int64
+	requests  int64
+	errors    int64
+	latencies []time.Duration
+}
+
+// RequestMetrics : Rolling window of served requests, kept in memory of each instance
+type RequestMetrics struct {
+	mutex   sync.Mutex
+	buckets [statusWindowMinutes]requestMetricsBucket
+}
+
+// RequestMetricsSnapshot : Error rate and latency over the rolling window
+type RequestMetricsSnapshot struct {
+	WindowInMinutes int64   `json:"window_minutes"`
+	Requests        int64   `json:"requests"`
+	ErrorRate       float64 `json:"error_rate"`
+	P95LatencyInMs  int64   `json:"p95_latency_ms"`
+}
+
+// DependencyHealth : State of a service the API needs
+type DependencyHealth struct {
+	Status      string  `json:"status"`
+	LatencyInMs int64   `json:"latency_ms,omitempty"`
+	ErrorRate   float64 `json:"error_rate,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	CheckedAt   int64   `json:"checked_at,omitempty"`
+}
+
+// IncidentStructure : Incident flagged by an admin, shown on the status page until some time after it is resolved
+type IncidentStructure struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	Title      string             `json:"title" bson:"title"`
+	Message    string             `json:"message" bson:"message"`
+	Severity   string             `json:"severity" bson:"severity"`
+	CreatedBy  int64              `json:"-" bson:"created_by"`
+	CreatedAt  int64              `json:"created_at" bson:"created_at"`
+	ResolvedAt int64              `json:"resolved_at" bson:"resolved_at"`
+}
+
+// IncidentInput : Structure for incoming incident from an admin
+type IncidentInput struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+}
+
+// StatusPageStructure : Everything a public status page shows
+type StatusPageStructure struct {
+	Status       string                      `json:"status"`
+	Requests     RequestMetricsSnapshot      `json:"requests"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+	Incidents    []IncidentStructure         `json:"incidents"`
+	CheckedAt    int64                       `json:"checked_at"`
+}
+
+// statusPageCache : Status page is public, so dependency checks are shared by requests within a few seconds
+type statusPageCache struct {
+	mutex      sync.Mutex
+	statusPage StatusPageStructure
+	expiresAt  time.Time
+}
+
+var requestMetrics = &RequestMetrics{}
+
+var cachedStatusPage = &statusPageCache{}
+
+// Record : Adds a served request to the bucket of its minute
+func (metrics *RequestMetrics) Record(statusCode int, latency time.Duration) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	minute := time.Now().Unix() / 60
+	bucket := &metrics.buckets[minute%statusWindowMinutes]
+	if bucket.minute != minute {
+		*bucket = requestMetricsBucket{minute: minute, latencies: bucket.latencies[:0]}
+	}
+
+	bucket.requests++
+	if statusCode >= http.StatusInternalServerError {
+		bucket.errors++
+	}
+	if len(bucket.latencies) < maxLatenciesPerMinute {
+		bucket.latencies = append(bucket.latencies, latency)
+	}
+}
+
+// Snapshot : Totals over the minutes of the window that have passed
+func (metrics *RequestMetrics) Snapshot() RequestMetricsSnapshot {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	snapshot := RequestMetricsSnapshot{WindowInMinutes: statusWindowMinutes}
+	oldestMinute := time.Now().Unix()/60 - statusWindowMinutes + 1
+
+	var errorsInWindow int64
+	var latencies []time.Duration
+	for _, bucket := range metrics.buckets {
+		if bucket.minute < oldestMinute {
+			continue
+		}
+		snapshot.Requests = snapshot.Requests + bucket.requests
+		errorsInWindow = errorsInWindow + bucket.errors
+		latencies = append(latencies, bucket.latencies...)
+	}
+
+	if snapshot.Requests != 0 {
+		snapshot.ErrorRate = float64(errorsInWindow) / float64(snapshot.Requests)
+	}
+	if len(latencies) != 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		snapshot.P95LatencyInMs = latencies[len(latencies)*95/100].Nanoseconds() / int64(time.Millisecond)
+	}
+
+	return snapshot
+}
+
+// recordRequestMetrics : Middleware counting every request for the status page
+func recordRequestMetrics() gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		requestStart := time.Now()
+		ginContext.Next()
+		requestMetrics.Record(ginContext.Writer.Status(), time.Since(requestStart))
+	}
+}
+
+func checkMongoHealth(databaseClient *mongo.Client) DependencyHealth {
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelDBContext()
+
+	pingStart := time.Now()
+	errInPing := databaseClient.Ping(databaseContext, nil)
+	if errInPing != nil {
+		return DependencyHealth{Status: statusMajorOutage}
+	}
+
+	return DependencyHealth{Status: statusOperational, LatencyInMs: time.Since(pingStart).Nanoseconds() / int64(time.Millisecond)}
+}
+
+// checkGithubHealth : GitHub is not called from here, its health comes from the calls the API already made
+func checkGithubHealth() DependencyHealth {
+	var githubMetrics DestinationMetrics
+	outboundMetrics := outboundHTTPClient.Metrics()
+	for _, githubHost := range []string{"github.com", "api.github.com"} {
+		githubMetrics.Requests = githubMetrics.Requests + outboundMetrics[githubHost].Requests
+		githubMetrics.Failures = githubMetrics.Failures + outboundMetrics[githubHost].Failures
+		githubMetrics.TotalLatencyInMs = githubMetrics.TotalLatencyInMs + outboundMetrics[githubHost].TotalLatencyInMs
+	}
+	if outboundHTTPClient.breaker.IsOpen("github.com") || outboundHTTPClient.breaker.IsOpen("api.github.com") {
+		return DependencyHealth{Status: statusMajorOutage, Error: "Calls to GitHub are paused after repeated failures"}
+	}
+	if githubMetrics.Requests == 0 {
+		return DependencyHealth{Status: statusOperational}
+	}
+
+	githubHealth := DependencyHealth{
+		Status:      statusOperational,
+		LatencyInMs: githubMetrics.TotalLatencyInMs / githubMetrics.Requests,
+		ErrorRate:   float64(githubMetrics.Failures) / float64(githubMetrics.Requests),
+	}
+	if githubHealth.ErrorRate > degradedDependencyErrorRate {
+		githubHealth.Status = statusDegraded
+	}
+
+	return githubHealth
+}
+
+func findIncidentsForStatusPage(databaseClient *mongo.Client) ([]IncidentStructure, error) {
+	incidentsCollection := databaseClient.Database("sardene-db").Collection("status_incidents")
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDBContext()
+
+	shownIncidentsFilter := bson.M{"$or": bson.A{
+		bson.M{"resolved_at": 0},
+		bson.M{"resolved_at": bson.M{"$gte": time.Now().Unix() - resolvedIncidentsShownForSec}},
+	}}
+	incidentsOptions := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(20)
+
+	incidentsCursor, errInFinding := incidentsCollection.Find(databaseContext, shownIncidentsFilter, incidentsOptions)
+	if errInFinding != nil {
+		return nil, errInFinding
+	}
+	defer incidentsCursor.Close(databaseContext)
+
+	incidents := []IncidentStructure{}
+	for incidentsCursor.Next(databaseContext) {
+		var incident IncidentStructure
+		errInDecoding := incidentsCursor.Decode(&incident)
+		if errInDecoding != nil {
+			return incidents, errInDecoding
+		}
+		incidents = append(incidents, incident)
+	}
+
+	return incidents, incidentsCursor.Err()
+}
+
+func buildStatusPage(databaseClient *mongo.Client) StatusPageStructure {
+	statusPage := StatusPageStructure{
+		Status:       statusOperational,
+		Requests:     requestMetrics.Snapshot(),
+		Dependencies: dependencyProbes.Snapshot(),
+		Incidents:    []IncidentStructure{},
+		CheckedAt:    time.Now().Unix(),
+	}
+
+	if statusPage.Dependencies["mongo"].Status == statusMajorOutage {
+		statusPage.Status = statusMajorOutage
+		return statusPage
+	}
+
+	incidents, errInFindingIncidents := findIncidentsForStatusPage(databaseClient)
+	if errInFindingIncidents == nil {
+		statusPage.Incidents = incidents
+	}
+
+	if statusPage.Requests.ErrorRate > degradedErrorRate || statusPage.Dependencies["github"].Status != statusOperational ||
+		statusPage.Dependencies["mongo"].Status != statusOperational {
+		statusPage.Status = statusDegraded
+	}
+	for _, incident := range statusPage.Incidents {
+		if incident.ResolvedAt != 0 {
+			continue
+		}
+		if incident.Severity == incidentSeverityMajor {
+			statusPage.Status = statusMajorOutage
+			break
+		}
+		statusPage.Status = statusDegraded
+	}
+
+	return statusPage
+}
+
+func getStatusPage(ginContext *gin.Context, databaseClient *mongo.Client, statusCacheDuration time.Duration) {
+	cachedStatusPage.mutex.Lock()
+	if time.Now().After(cachedStatusPage.expiresAt) {
+		cachedStatusPage.statusPage = buildStatusPage(databaseClient)
+		cachedStatusPage.expiresAt = time.Now().Add(statusCacheDuration)
+	}
+	statusPage := cachedStatusPage.statusPage
+	cachedStatusPage.mutex.Unlock()
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": statusPage})
+}
+
+func addIncident(ginContext *gin.Context, databaseClient *mongo.Client) {
+	admin := getAuthenticatedUser(ginContext)
+
+	var jsonInput IncidentInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil || len(strings.TrimSpace(jsonInput.Title)) == 0 {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Title of the incident is not provided in the post"})
+		return
+	}
+	if jsonInput.Severity != incidentSeverityMinor && jsonInput.Severity != incidentSeverityMajor {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Severity should be either minor or major"})
+		return
+	}
+
+	incidentsCollection := databaseClient.Database("sardene-db").Collection("status_incidents")
+	databaseContext := ginContext.Request.Context()
+
+	incident := IncidentStructure{
+		ID:        primitive.NewObjectID(),
+		Title:     strings.TrimSpace(jsonInput.Title),
+		Message:   strings.TrimSpace(jsonInput.Message),
+		Severity:  jsonInput.Severity,
+		CreatedBy: admin.UserID,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	_, errInAdding := incidentsCollection.InsertOne(databaseContext, incident)
+	if errInAdding != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": incident})
+}
+
+func resolveIncident(ginContext *gin.Context, databaseClient *mongo.Client, incidentID string) {
+	hexIncidentID, errInValidatingID := primitive.ObjectIDFromHex(incidentID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Incident id is not valid"})
+		return
+	}
+
+	incidentsCollection := databaseClient.Database("sardene-db").Collection("status_incidents")
+	databaseContext := ginContext.Request.Context()
+
+	resolveResult, errInResolving := incidentsCollection.UpdateOne(databaseContext,
+		bson.M{"_id": hexIncidentID, "resolved_at": 0}, bson.M{"$set": bson.M{"resolved_at": time.Now().Unix()}})
+	if errInResolving != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+	if resolveResult.MatchedCount == 0 {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Open incident does not exists"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Incident resolved"})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Errors every store returns in place of backend specific ones, so handlers don't depend on the backend
+var (
+	errNotFoundInStore  = errors.New("Not found in store")
+	errDuplicateInStore = errors.New("Already exists in store")
+	errConflictInStore  = errors.New("Changed in store since it was read")
+)
+
+// IdeaContentUpdate : Changed content of an idea, nil fields are left as they are
+type IdeaContentUpdate struct {
+	Name        *string
+	Slug        *string
+	Description *string
+	// A new repo url drops the metadata synced for the previous one, an empty one unlinks the repo
+	RepoURL    *string
+	Visibility *string
+	UpdatedAt  int64
+	// Version the editor read, nil updates whatever version is stored
+	ExpectedVersion *int64
+}
+
+// IdeasStore : Storage of ideas
+type IdeasStore interface {
+	// ListPublished : Public ideas which are not held for review and match the filter, with only fields read when any are given
+	ListPublished(databaseContext context.Context, filter IdeaListFilter, fields []string) ([]IdeaStructure, error)
+	FindByID(databaseContext context.Context, ideaID primitive.ObjectID) (IdeaStructure, error)
+	// FindByIDs : Ideas among ideaIDs whatever their visibility, ideas which do not exist are left out
+	FindByIDs(databaseContext context.Context, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error)
+	// ListLinkingTo : Ideas with a link to any of ideaIDs, whatever their visibility
+	ListLinkingTo(databaseContext context.Context, ideaIDs []primitive.ObjectID) ([]IdeaStructure, error)
+	// AddLink : Checks for a cycle and adds the link with no other link added in between, see doesLinkCreateCycle.
+	// Returns errNotFoundInStore if either idea does not exist, errDuplicateInStore if they are linked already
+	// and errConflictInStore if the link would close a cycle
+	AddLink(databaseContext context.Context, ideaID primitive.ObjectID, link IdeaLinkStructure) error
+	// RemoveLink : Returns errNotFoundInStore if the idea does not exist or has no link to linkedIdeaID
+	RemoveLink(databaseContext context.Context, ideaID primitive.ObjectID, linkedIdeaID primitive.ObjectID) error
+	// ListByPublisherSince : Ideas of publisher created at or after since, newest first, a limit of 0 lists them all
+	ListByPublisherSince(databaseContext context.Context, publisherID int64, since int64, limit int64) ([]IdeaStructure, error)
+	CountByPublisherSince(databaseContext context.Context, publisherID int64, since int64) (int64, error)
+	// ListPublishedByPublishers : Public ideas which are not archived of any of publisherIDs, newest first
+	ListPublishedByPublishers(databaseContext context.Context, publisherIDs []int64, skip int64, limit int64) ([]IdeaStructure, error)
+	// Insert : Saves the idea with a newly generated id and returns the id
+	Insert(databaseContext context.Context, idea IdeaStructure) (primitive.ObjectID, error)
+	// UpdateContent : Increments the version and returns the idea as it is after the update.
+	// Returns errNotFoundInStore if the idea does not exist and errConflictInStore if the version is not the expected one
+	UpdateContent(databaseContext context.Context, ideaID primitive.ObjectID, contentUpdate IdeaContentUpdate) (IdeaStructure, error)
+	// AddCollaborator : Returns errDuplicateInStore if the user is a collaborator already or the idea has maxCollaborators
+	AddCollaborator(databaseContext context.Context, ideaID primitive.ObjectID, collaborator IdeaCollaboratorStructure, maxCollaborators int) error
+	// RemoveCollaborator : Returns errNotFoundInStore if the user is not a collaborator of the idea
+	RemoveCollaborator(databaseContext context.Context, ideaID primitive.ObjectID, userID int64) error
+	// AddImage : Returns errDuplicateInStore when the idea has the image already, has maxImages or does not exist
+	AddImage(databaseContext context.Context, ideaID primitive.ObjectID, image IdeaImageStructure, maxImages int64, updatedAt int64) error
+	// RemoveImage : Returns errNotFoundInStore if the idea does not exist or has no image with imageHash
+	RemoveImage(databaseContext context.Context, ideaID primitive.ObjectID, imageHash string, updatedAt int64) error
+	// SetFeatured : featuredAt is 0 when the idea stops being featured
+	SetFeatured(databaseContext context.Context, ideaID primitive.ObjectID, featured bool, featuredAt int64) error
+	// SetArchived : archivedAt is 0 when the idea is unarchived, returns errNotFoundInStore if the idea does not exist
+	SetArchived(databaseContext context.Context, ideaID primitive.ObjectID, archived bool, archivedAt int64) error
+	// SetGithubIssueURL : Returns errNotFoundInStore if the idea does not exist
+	SetGithubIssueURL(databaseContext context.Context, ideaID primitive.ObjectID, issueURL string) error
+	// SetHeldForReview : Returns whether held changed, so only one of two concurrent decisions sees the change.
+	// Returns errNotFoundInStore if the idea does not exist
+	SetHeldForReview(databaseContext context.Context, ideaID primitive.ObjectID, held bool) (bool, error)
+	// ListWithStaleRepo : Ideas linked to a repo which was never synced or last synced before staleBefore, synced longest ago first
+	ListWithStaleRepo(databaseContext context.Context, staleBefore int64, limit int64) ([]IdeaStructure, error)
+	// SetRepo : Returns errNotFoundInStore if the idea does not exist
+	SetRepo(databaseContext context.Context, ideaID primitive.ObjectID, repo RepoMetadataStructure) error
+	// SetLaunched : Moves the idea to launched with its launch, returns errNotFoundInStore if the idea does not exist
+	SetLaunched(databaseContext context.Context, ideaID primitive.ObjectID, launch IdeaLaunchStructure) error
+	// ListLaunched : Public launched ideas which are not archived, the latest launch first
+	ListLaunched(databaseContext context.Context, skip int64, limit int64) ([]IdeaStructure, error)
+	// ListFeatured : Public featured ideas which are not archived, the last featured first
+	ListFeatured(databaseContext context.Context) ([]IdeaStructure, error)
+	// SearchPublished : Public ideas whose name or description has any of words, archived ones included, the best matches first
+	SearchPublished(databaseContext context.Context, words []string, limit int64) ([]IdeaStructure, error)
+	// SuggestByPrefix : Public ideas which are not archived whose slug starts with slugPrefix, the most gazed first
+	SuggestByPrefix(databaseContext context.Context, slugPrefix string, limit int64) ([]IdeaSuggestionStructure, error)
+	// Delete : Returns errNotFoundInStore if the idea does not exist
+	Delete(databaseContext context.Context, ideaID primitive.ObjectID) error
+	// IncrementGazers : Also adds the gazes to the gazes of the last 7 days and to the trending score.
+	// Returns the gazers after the increment, or errNotFoundInStore if the idea does not exist
+	IncrementGazers(databaseContext context.Context, ideaID primitive.ObjectID, increment int64) (int64, error)
+	// AddViews : Adds the views of each idea, ideas deleted since they were viewed are left out
+	AddViews(databaseContext context.Context, viewsOfIdeas map[primitive.ObjectID]int64) error
+	// IncrementReaction : Only the count of the reaction, a first reaction of a user increments gazers too
+	IncrementReaction(databaseContext context.Context, ideaID primitive.ObjectID, reaction string, increment int64) error
+	// ListByMomentum : Public ideas which are not archived gazed in the last 7 days, sorted by ideaSortTrending or ideaSortRising
+	ListByMomentum(databaseContext context.Context, sortBy string, limit int64) ([]IdeaStructure, error)
+	// RecomputeMomentum : Recounts gazes of the last 7 days and their decayed score from gazes, returns the ideas changed
+	RecomputeMomentum(databaseContext context.Context, now int64) (int64, error)
+}
+
+// IdeaListFilter : Narrows listed ideas, zero values do not filter
+type IdeaListFilter struct {
+	Publisher     string
+	CreatedAfter  int64
+	CreatedBefore int64
+	// Drafts and private ideas this user publishes or collaborates on are listed along with public ones
+	DraftsOf int64
+	// Archived ideas are only listed when asked for
+	IncludeArchived bool
+}
+
+// UsersStore : Storage of users who have signed in
+type UsersStore interface {
+	FindByUserID(databaseContext context.Context, userID int64) (UserStructure, error)
+	// FindByLogin : Login is the GitHub login, matched exactly
+	FindByLogin(databaseContext context.Context, login string) (UserStructure, error)
+	// Insert : Returns errDuplicateInStore if the user already exists
+	Insert(databaseContext context.Context, user UserStructure) error
+	// UpdateSettings : Replaces the fields the user sets, returns errNotFoundInStore if the user does not exist
+	UpdateSettings(databaseContext context.Context, userID int64, settings UserSettingsStructure) error
+	// UpdateSuspension : Returns errNotFoundInStore if the user does not exist
+	UpdateSuspension(databaseContext context.Context, userID int64, suspension UserSuspensionStructure) error
+	// UpdateRole : Returns errNotFoundInStore if the user does not exist
+	UpdateRole(databaseContext context.Context, userID int64, role string) error
+	// DeleteAccount : Deletes the user at once with their gazes, taken back from the gazers, reactions and momentum of ideas,
+	// and with their collaborations. Ideas published under an org stay and are anonymized, the other ideas of the user are
+	// deleted with their gazes when deleteIdeas and anonymized otherwise. Returns the ids of the deleted ideas,
+	// a user who does not exist is no error so a deletion can be retried
+	DeleteAccount(databaseContext context.Context, userID int64, deleteIdeas bool, now int64) ([]primitive.ObjectID, error)
+}
+
+// LikesStore : Storage of gazes, one per user and idea, each with the reaction of the user
+type LikesStore interface {
+	// Find : Returns errNotFoundInStore if the user has not gazed the idea
+	Find(databaseContext context.Context, userID int64, ideaID primitive.ObjectID) (IdeaLikesStructure, error)
+	// Insert : Returns errDuplicateInStore if the user already gazed the idea
+	Insert(databaseContext context.Context, like IdeaLikesStructure) error
+	// UpdateReaction : Returns errNotFoundInStore unless the user gazed the idea with fromReaction
+	UpdateReaction(databaseContext context.Context, userID int64, ideaID primitive.ObjectID, fromReaction string, toReaction string) error
+	Delete(databaseContext context.Context, userID int64, ideaID primitive.ObjectID) error
+	// DeleteByIdea : Removes every gaze of the idea, for when it is deleted
+	DeleteByIdea(databaseContext context.Context, ideaID primitive.ObjectID) error
+	ListByUser(databaseContext context.Context, userID int64) ([]IdeaLikesStructure, error)
+	// ListGazedAmong : Ideas among ideaIDs which the user gazed
+	ListGazedAmong(databaseContext context.Context, userID int64, ideaIDs []primitive.ObjectID) ([]primitive.ObjectID, error)
+}
+
+// SessionsStore : Storage of sessions by the hash of their token, expired ones are removed by the store in its own time
+type SessionsStore interface {
+	Insert(databaseContext context.Context, session SessionStructure) error
+	// FindByTokenHash : Returns errNotFoundInStore if there is no session, expired sessions may still be returned
+	FindByTokenHash(databaseContext context.Context, tokenHash string) (SessionStructure, error)
+	// Delete : Returns errNotFoundInStore if there is no session
+	Delete(databaseContext context.Context, tokenHash string) error
+	DeleteByUser(databaseContext context.Context, userID int64) error
+}
+
+// Stores : Storage handed to handlers, built once in main for the configured backend.
+// Ideas, users and gazes are only reached through the stores, except by the jobs and admin tools which work on the
+// mongo collections as they are: migrations, indexes, backups, admin export, admin gaze listing, counter
+// reconciliation, stats and vote analysis. Those only run with the mongo driver
+type Stores struct {
+	Ideas    IdeasStore
+	Users    UsersStore
+	Likes    LikesStore
+	Sessions SessionsStore
+}
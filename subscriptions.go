@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IdeaSubscriptionStructure : Structure of subscription in idea_subscriptions collection, unlike a gaze it is not counted anywhere
+type IdeaSubscriptionStructure struct {
+	UserID    int64              `json:"user_id" bson:"user_id"`
+	IdeaID    primitive.ObjectID `json:"idea_id" bson:"idea_id"`
+	CreatedAt int64              `json:"created_at" bson:"created_at"`
+}
+
+func subscribeToIdea(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	user := getAuthenticatedUser(ginContext)
+	databaseContext := ginContext.Request.Context()
+
+	idea, errInFindingIdea := stores.Ideas.FindByID(databaseContext, hexIdeaID)
+	if errInFindingIdea != nil || isIdeaVisibleTo(idea, user.UserID) == false {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Idea does not exists"})
+		return
+	}
+
+	subscriptionsCollection := databaseClient.Database("sardene-db").Collection("idea_subscriptions")
+
+	subscriptionToAdd := IdeaSubscriptionStructure{UserID: user.UserID, IdeaID: hexIdeaID, CreatedAt: time.Now().Unix()}
+	_, errInAdding := subscriptionsCollection.InsertOne(databaseContext, subscriptionToAdd)
+	if errInAdding != nil {
+		if isDuplicateKeyError(errInAdding) {
+			ginContext.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict,
+				"error": "Error, User is already subscribed to the idea"})
+			return
+		}
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "message": "Subscribed to idea successfully"})
+}
+
+func unsubscribeFromIdea(ginContext *gin.Context, databaseClient *mongo.Client, ideaID string) {
+	hexIdeaID, errInValidatingID := primitive.ObjectIDFromHex(ideaID)
+	if errInValidatingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, Idea id is not valid"})
+		return
+	}
+
+	user := getAuthenticatedUser(ginContext)
+	subscriptionsCollection := databaseClient.Database("sardene-db").Collection("idea_subscriptions")
+	databaseContext := ginContext.Request.Context()
+
+	resultOfDeleting, errInDeleting := subscriptionsCollection.DeleteOne(databaseContext, bson.M{"user_id": user.UserID, "idea_id": hexIdeaID})
+	if errInDeleting != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+	if resultOfDeleting.DeletedCount == 0 {
+		ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, Subscription to idea not found"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "message": "Unsubscribed from idea successfully"})
+}
+
+// notifyIdeaSubscribers : Subscribers who can no longer see the idea, after it turned private for example, are skipped
+func notifyIdeaSubscribers(databaseContext context.Context, databaseClient *mongo.Client, idea IdeaStructure, kind string,
+	actor GithubUserProfileStructure) error {
+	if databaseClient == nil {
+		return nil
+	}
+	subscriptionsCollection := databaseClient.Database("sardene-db").Collection("idea_subscriptions")
+
+	subscriptionsCursor, errInFinding := subscriptionsCollection.Find(databaseContext, bson.M{"idea_id": idea.ID})
+	if errInFinding != nil {
+		return errInFinding
+	}
+	defer subscriptionsCursor.Close(databaseContext)
+
+	subscriberIDs := []int64{}
+	for subscriptionsCursor.Next(databaseContext) {
+		var subscription IdeaSubscriptionStructure
+		errInDecoding := subscriptionsCursor.Decode(&subscription)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+		if isIdeaVisibleTo(idea, subscription.UserID) {
+			subscriberIDs = append(subscriberIDs, subscription.UserID)
+		}
+	}
+	if errInCursor := subscriptionsCursor.Err(); errInCursor != nil {
+		return errInCursor
+	}
+
+	return addNotifications(databaseContext, databaseClient, subscriberIDs, kind, idea, actor)
+}
+
+func deleteUserSubscriptions(databaseContext context.Context, databaseClient *mongo.Client, userID int64) error {
+	subscriptionsCollection := databaseClient.Database("sardene-db").Collection("idea_subscriptions")
+
+	_, errInDeleting := subscriptionsCollection.DeleteMany(databaseContext, bson.M{"user_id": userID})
+	return errInDeleting
+}
+
+// deleteSubscriptionsOfIdea : Subscriptions and notifications are left without a target once the idea goes
+func deleteSubscriptionsOfIdea(databaseContext context.Context, databaseClient *mongo.Client, ideaIDs []interface{}) error {
+	subscriptionsCollection := databaseClient.Database("sardene-db").Collection("idea_subscriptions")
+	notificationsCollection := databaseClient.Database("sardene-db").Collection("notifications")
+
+	_, errInDeleting := subscriptionsCollection.DeleteMany(databaseContext, bson.M{"idea_id": bson.M{"$in": ideaIDs}})
+	if errInDeleting != nil {
+		return errInDeleting
+	}
+
+	_, errInDeletingNotifications := notificationsCollection.DeleteMany(databaseContext, bson.M{"idea_id": bson.M{"$in": ideaIDs}})
+	return errInDeletingNotifications
+}
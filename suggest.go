@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxSuggestionsOfIdeas  = 10
+	minSuggestPrefixLength = 2
+	maxCachedSuggestions   = 5000
+)
+
+// IdeaSuggestionStructure : Just enough of an idea for the search box to offer it
+type IdeaSuggestionStructure struct {
+	ID   primitive.ObjectID `json:"id" bson:"_id"`
+	Name string             `json:"name" bson:"name"`
+	Slug string             `json:"slug" bson:"slug"`
+}
+
+type cachedSuggestions struct {
+	suggestions []IdeaSuggestionStructure
+	expiresAt   time.Time
+}
+
+// suggestionsCache : Every keystroke asks for suggestions, the same prefixes are answered from memory
+type suggestionsCache struct {
+	mutex    sync.Mutex
+	byPrefix map[string]cachedSuggestions
+}
+
+var cachedIdeaSuggestions = &suggestionsCache{byPrefix: make(map[string]cachedSuggestions)}
+
+func (cache *suggestionsCache) get(prefix string) ([]IdeaSuggestionStructure, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cached, isCached := cache.byPrefix[prefix]
+	if isCached == false || time.Now().After(cached.expiresAt) {
+		return nil, false
+	}
+	return cached.suggestions, true
+}
+
+// set : Expired prefixes are dropped once the cache is full, and everything when that is not enough
+func (cache *suggestionsCache) set(prefix string, suggestions []IdeaSuggestionStructure, cacheDuration time.Duration) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if len(cache.byPrefix) >= maxCachedSuggestions {
+		for cachedPrefix, cached := range cache.byPrefix {
+			if time.Now().After(cached.expiresAt) {
+				delete(cache.byPrefix, cachedPrefix)
+			}
+		}
+		if len(cache.byPrefix) >= maxCachedSuggestions {
+			cache.byPrefix = make(map[string]cachedSuggestions)
+		}
+	}
+	cache.byPrefix[prefix] = cachedSuggestions{suggestions: suggestions, expiresAt: time.Now().Add(cacheDuration)}
+}
+
+// suggestIdeas : Public ideas whose name starts like ?q=, matched on the slug so case and punctuation do not matter
+func suggestIdeas(ginContext *gin.Context, stores Stores, suggestCacheDuration time.Duration) {
+	slugPrefix := slugOf(ginContext.Query("q"))
+	if len(slugPrefix) < minSuggestPrefixLength {
+		ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": []IdeaSuggestionStructure{}, "count": 0})
+		return
+	}
+
+	suggestions, isCached := cachedIdeaSuggestions.get(slugPrefix)
+	if isCached == false {
+		var errInFinding error
+		suggestions, errInFinding = stores.Ideas.SuggestByPrefix(ginContext.Request.Context(), slugPrefix, maxSuggestionsOfIdeas)
+		if errInFinding != nil {
+			ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+				"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+			return
+		}
+		if suggestions == nil {
+			suggestions = []IdeaSuggestionStructure{}
+		}
+		cachedIdeaSuggestions.set(slugPrefix, suggestions, suggestCacheDuration)
+	}
+
+	ginContext.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(suggestCacheDuration.Seconds())))
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": suggestions, "count": len(suggestions)})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditActionUserSuspended  = "user.suspended"
+	auditActionUserReinstated = "user.reinstated"
+
+	accountBannedCode    = "ACCOUNT_BANNED"
+	accountSuspendedCode = "ACCOUNT_SUSPENDED"
+)
+
+// SuspensionConfig : How far the restrictions of suspended and banned accounts go
+type SuspensionConfig struct {
+	// Suspended accounts can always read, unless this is set
+	BlockReads bool
+}
+
+// UserSuspensionStructure : Standing of an account set by an admin, a ban lasts until it is lifted
+type UserSuspensionStructure struct {
+	Banned           bool   `json:"banned"`
+	SuspendedUntil   int64  `json:"suspended_until"`
+	SuspensionReason string `json:"suspension_reason"`
+}
+
+// SuspensionInput : Structure for incoming suspension from an admin, an empty one reinstates the account
+type SuspensionInput struct {
+	Banned         bool   `json:"banned"`
+	SuspendedUntil int64  `json:"suspended_until"`
+	Reason         string `json:"reason"`
+}
+
+// routesAllowedWhileSuspended : Users can still take their data out and leave
+var routesAllowedWhileSuspended = map[string]bool{
+	"GET /user/export": true,
+	"DELETE /user":     true,
+}
+
+// AccountSuspendedError : Account is banned or suspended, requests it sends are refused
+type AccountSuspendedError struct {
+	UserSuspensionStructure
+}
+
+func (errSuspended *AccountSuspendedError) Error() string {
+	if errSuspended.Banned == true {
+		return "Account is banned"
+	}
+	return "Account is suspended until " + time.Unix(errSuspended.SuspendedUntil, 0).UTC().Format(time.RFC3339)
+}
+
+func loadSuspensionConfig(configLoader *ConfigLoader) SuspensionConfig {
+	var suspensionConfig SuspensionConfig
+
+	suspensionConfig.BlockReads = configLoader.Bool("SUSPENSION_BLOCKS_READS", false)
+
+	return suspensionConfig
+}
+
+func isUserSuspended(user UserStructure) bool {
+	return user.Banned == true || user.SuspendedUntil > time.Now().Unix()
+}
+
+// checkAccountStanding : AccountSuspendedError while the user is banned or suspended, users not yet signed up are fine
+func checkAccountStanding(databaseContext context.Context, githubUser GithubUserProfileStructure, stores Stores) error {
+	userInDB, errInFindingUser := stores.Users.FindByUserID(databaseContext, githubUser.UserID)
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			return nil
+		}
+		return errInFindingUser
+	}
+
+	if isUserSuspended(userInDB) == false {
+		return nil
+	}
+	return &AccountSuspendedError{UserSuspensionStructure{Banned: userInDB.Banned, SuspendedUntil: userInDB.SuspendedUntil,
+		SuspensionReason: userInDB.SuspensionReason}}
+}
+
+func abortAccountSuspended(ginContext *gin.Context, errSuspended *AccountSuspendedError) {
+	errorCode := accountSuspendedCode
+	if errSuspended.Banned == true {
+		errorCode = accountBannedCode
+	}
+	ginContext.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden, "code": errorCode,
+		"error": errSuspended.Error(), "suspended_until": errSuspended.SuspendedUntil, "reason": errSuspended.SuspensionReason})
+}
+
+// setUserSuspension : Bans, suspends or reinstates a user, admins cannot be suspended this way
+func setUserSuspension(ginContext *gin.Context, stores Stores, userID string) {
+	admin := getAuthenticatedUser(ginContext)
+
+	suspendedUserID, errInParsingID := strconv.ParseInt(userID, 10, 64)
+	if errInParsingID != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Error, User id is not valid"})
+		return
+	}
+
+	var jsonInput SuspensionInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong structure of posted data", "errorDetails": errInInputJSON.Error()})
+		return
+	}
+	if jsonInput.SuspendedUntil != 0 && jsonInput.SuspendedUntil <= time.Now().Unix() {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Suspended until should be unix seconds in the future, or 0 to lift the suspension"})
+		return
+	}
+	if suspendedUserID == admin.UserID {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Admins cannot suspend themselves"})
+		return
+	}
+
+	databaseContext := ginContext.Request.Context()
+
+	userInDB, errInFindingUser := stores.Users.FindByUserID(databaseContext, suspendedUserID)
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, User does not exists"})
+			return
+		}
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUser.Error()})
+		return
+	}
+	if userInDB.Role == userRoleAdmin {
+		ginContext.JSON(http.StatusForbidden, gin.H{"status": http.StatusForbidden,
+			"error": "Admins cannot be suspended"})
+		return
+	}
+
+	suspension := UserSuspensionStructure{Banned: jsonInput.Banned, SuspendedUntil: jsonInput.SuspendedUntil,
+		SuspensionReason: strings.TrimSpace(jsonInput.Reason)}
+	if suspension.Banned == false && suspension.SuspendedUntil == 0 {
+		suspension.SuspensionReason = ""
+	}
+
+	errInUpdating := stores.Users.UpdateSuspension(databaseContext, suspendedUserID, suspension)
+	if errInUpdating != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	auditAction := auditActionUserSuspended
+	if suspension.Banned == false && suspension.SuspendedUntil == 0 {
+		auditAction = auditActionUserReinstated
+	}
+	previousSuspension := UserSuspensionStructure{Banned: userInDB.Banned, SuspendedUntil: userInDB.SuspendedUntil,
+		SuspensionReason: userInDB.SuspensionReason}
+	describeAuditedMutation(ginContext, auditAction, userID, previousSuspension, suspension)
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": suspension})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxIdeaNameLength        = 120
+	maxIdeaDescriptionLength = 10000
+	zeroWidthJoiner          = '\u200d'
+	zeroWidthNonJoiner       = '\u200c'
+)
+
+// invisibleRunes : Characters which only hide or reorder text, bidi overrides included so names cannot read backwards
+var invisibleRunes = map[rune]bool{
+	'\u200b': true, '\u2060': true, '\ufeff': true, '\u180e': true, '\u00ad': true,
+	'\u202a': true, '\u202b': true, '\u202c': true, '\u202d': true, '\u202e': true,
+	'\u2066': true, '\u2067': true, '\u2068': true, '\u2069': true,
+}
+
+// normalizeText : NFC text without control and invisible characters, joiners are kept only alone between letters
+// since emoji and some scripts need them, line breaks and tabs are kept when keepLineBreaks is true
+func normalizeText(text string, keepLineBreaks bool) string {
+	runesOfText := []rune(norm.NFC.String(strings.ToValidUTF8(text, "")))
+
+	var normalizedText strings.Builder
+	var previousRune rune
+	for index, character := range runesOfText {
+		if character == '\r' {
+			continue
+		}
+		if character == '\n' || character == '\t' {
+			if keepLineBreaks == false {
+				character = ' '
+			}
+		} else if unicode.IsControl(character) || invisibleRunes[character] == true {
+			continue
+		}
+
+		if character == zeroWidthJoiner || character == zeroWidthNonJoiner {
+			isBetweenVisibleRunes := index > 0 && index+1 < len(runesOfText) &&
+				unicode.IsSpace(previousRune) == false && unicode.IsSpace(runesOfText[index+1]) == false
+			isRepeated := previousRune == zeroWidthJoiner || previousRune == zeroWidthNonJoiner
+			if isBetweenVisibleRunes == false || isRepeated == true {
+				continue
+			}
+		}
+
+		normalizedText.WriteRune(character)
+		previousRune = character
+	}
+
+	return normalizedText.String()
+}
+
+// normalizeIdeaName : Names are a single line with single spaces
+func normalizeIdeaName(ideaName string) string {
+	return strings.Join(strings.Fields(normalizeText(ideaName, false)), " ")
+}
+
+// normalizeIdeaDescription : Descriptions keep their lines for markdown
+func normalizeIdeaDescription(ideaDescription string) string {
+	return strings.TrimSpace(normalizeText(ideaDescription, true))
+}
+
+// lengthInRunes : Length as people count characters, not the bytes of their encoding
+func lengthInRunes(text string) int {
+	return utf8.RuneCountInString(text)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// Only gazes of this window count towards the momentum of an idea
+	trendingWindow = 7 * 24 * time.Hour
+	// Weight of a gaze in the trending score halves every half life
+	trendingHalfLife = 24 * time.Hour
+
+	// Sorts of ideas by momentum, the names are the stored fields they sort by
+	ideaSortTrending = "trending_score"
+	ideaSortRising   = "gazes_last_7d"
+)
+
+// momentumOfGaze : What a gaze adds to gazes_last_7d and the trending score as of now, for taking it back
+func momentumOfGaze(createdAt int64, now int64) (int64, float64) {
+	if createdAt < now-int64(trendingWindow/time.Second) {
+		return 0, 0
+	}
+	return 1, math.Pow(0.5, float64(now-createdAt)/float64(trendingHalfLife/time.Second))
+}
+
+func runTrendingJob(databaseClient *mongo.Client, stores Stores, interval time.Duration) {
+	runScheduledJob(databaseClient, "trending", interval, func() error {
+		databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancelDBContext()
+
+		// Gazes add their full weight as they happen, recomputing lets them decay and drop out of the window
+		updatedIdeas, errInRecomputing := stores.Ideas.RecomputeMomentum(databaseContext, time.Now().Unix())
+		if errInRecomputing != nil {
+			return errInRecomputing
+		}
+		if updatedIdeas > 0 {
+			invalidateCachedResponses()
+			log.Printf("Recomputed trending score of %d ideas", updatedIdeas)
+		}
+		return nil
+	})
+}
+
+// getIdeasByMomentum : Public ideas gazed in the last 7 days, by trending score or by the number of those gazes
+func getIdeasByMomentum(ginContext *gin.Context, stores Stores, sortBy string) {
+	limit, errInLimit := strconv.ParseInt(ginContext.DefaultQuery("limit", "20"), 10, 64)
+	if errInLimit != nil || limit < 1 || limit > 100 {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Limit should be a number from 1 to 100"})
+		return
+	}
+
+	ideas, errInFinding := stores.Ideas.ListByMomentum(ginContext.Request.Context(), sortBy, limit)
+	if errInFinding != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFinding.Error()})
+		return
+	}
+	if ideas == nil {
+		ideas = []IdeaStructure{}
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": ideas, "count": len(ideas)})
+}
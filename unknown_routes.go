@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleUnknownRoutes : Paths and methods without a route are answered in the JSON of every other error,
+// instead of the plain text of gin
+func handleUnknownRoutes(router *gin.Engine) {
+	router.HandleMethodNotAllowed = true
+
+	router.NoRoute(func(ginContext *gin.Context) {
+		ginContext.AbortWithStatusJSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+			"error": "Error, No route for " + ginContext.Request.Method + " " + ginContext.Request.URL.Path})
+	})
+
+	router.NoMethod(func(ginContext *gin.Context) {
+		// Routes are read on every call, so routes added after this are allowed as well
+		ginContext.Header("Allow", strings.Join(allowedMethodsOf(router.Routes(), ginContext.Request.URL.Path), ", "))
+		ginContext.AbortWithStatusJSON(http.StatusMethodNotAllowed, gin.H{"status": http.StatusMethodNotAllowed,
+			"error": "Error, " + ginContext.Request.Method + " is not allowed on " + ginContext.Request.URL.Path})
+	})
+}
+
+// allowedMethodsOf : Methods of the routes whose pattern matches the path, in the order they were added
+func allowedMethodsOf(routes gin.RoutesInfo, requestPath string) []string {
+	allowedMethods := []string{}
+	isAllowed := make(map[string]bool)
+	for _, route := range routes {
+		if isAllowed[route.Method] == true || isRoutePathMatching(route.Path, requestPath) == false {
+			continue
+		}
+		isAllowed[route.Method] = true
+		allowedMethods = append(allowedMethods, route.Method)
+	}
+	return allowedMethods
+}
+
+// isRoutePathMatching : :param stands for one segment and *param for all the rest, like in the router of gin
+func isRoutePathMatching(routePath string, requestPath string) bool {
+	routeSegments := strings.Split(strings.Trim(routePath, "/"), "/")
+	requestSegments := strings.Split(strings.Trim(requestPath, "/"), "/")
+
+	for segmentIndex, routeSegment := range routeSegments {
+		if strings.HasPrefix(routeSegment, "*") {
+			return true
+		}
+		if segmentIndex >= len(requestSegments) {
+			return false
+		}
+		if strings.HasPrefix(routeSegment, ":") {
+			if requestSegments[segmentIndex] == "" {
+				return false
+			}
+			continue
+		}
+		if routeSegment != requestSegments[segmentIndex] {
+			return false
+		}
+	}
+	return len(routeSegments) == len(requestSegments)
+}
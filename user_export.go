@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// streamCursorAsJSONArray : Decodes every document into a new value from newDocument and writes it as an array element
+func streamCursorAsJSONArray(responseWriter io.Writer, databaseContext context.Context, documentsCursor *mongo.Cursor, newDocument func() interface{}) error {
+	defer documentsCursor.Close(databaseContext)
+
+	_, _ = io.WriteString(responseWriter, "[")
+	isFirstDocument := true
+
+	for documentsCursor.Next(databaseContext) {
+		document := newDocument()
+		errInDecoding := documentsCursor.Decode(document)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+
+		documentInJSON, errInEncoding := json.Marshal(document)
+		if errInEncoding != nil {
+			return errInEncoding
+		}
+
+		if isFirstDocument == false {
+			_, _ = io.WriteString(responseWriter, ",")
+		}
+		isFirstDocument = false
+		_, _ = responseWriter.Write(documentInJSON)
+	}
+
+	_, _ = io.WriteString(responseWriter, "]")
+	return documentsCursor.Err()
+}
+
+func exportUserData(ginContext *gin.Context, databaseClient *mongo.Client, stores Stores) {
+	user := getAuthenticatedUser(ginContext)
+
+	databaseContext := ginContext.Request.Context()
+
+	userInDB, errInFindingUser := stores.Users.FindByUserID(databaseContext, user.UserID)
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, User not found"})
+			return
+		}
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUser.Error()})
+		return
+	}
+
+	// Ideas and gazes are in the stores, they are read before the response starts so a failure is still answered
+	publishedIdeas, errInFindingIdeas := stores.Ideas.ListByPublisherSince(databaseContext, user.UserID, 0, 0)
+	if errInFindingIdeas != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingIdeas.Error()})
+		return
+	}
+	gazes, errInFindingGazes := stores.Likes.ListByUser(databaseContext, user.UserID)
+	if errInFindingGazes != nil {
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingGazes.Error()})
+		return
+	}
+	storedSections := []struct {
+		name      string
+		documents interface{}
+	}{
+		{"ideas", append([]IdeaStructure{}, publishedIdeas...)},
+		{"gazes", append([]IdeaLikesStructure{}, gazes...)},
+	}
+
+	// Each other section of the archive is a collection, filtered down to what belongs to the user
+	exportSections := []struct {
+		name        string
+		collection  string
+		filter      bson.M
+		newDocument func() interface{}
+	}{
+		{"bookmarks", "bookmarks", bson.M{"user_id": user.UserID}, func() interface{} { return &IdeaBookmarkStructure{} }},
+		{"follows", "follows", bson.M{"follower_id": user.UserID}, func() interface{} { return &FollowStructure{} }},
+		{"idea_subscriptions", "idea_subscriptions", bson.M{"user_id": user.UserID}, func() interface{} { return &IdeaSubscriptionStructure{} }},
+		{"preferences", "user_preferences", bson.M{"_id": user.UserID}, func() interface{} { return &UserPreferencesStructure{} }},
+		{"identities", "user_identities", bson.M{"user_id": user.UserID}, func() interface{} { return &UserIdentityStructure{} }},
+		{"notifications", "notifications", bson.M{"user_id": user.UserID}, func() interface{} { return &NotificationStructure{} }},
+		{"revisions", "idea_revisions", bson.M{"editor_id": user.UserID}, func() interface{} { return &IdeaRevisionStructure{} }},
+		{"idea_updates", "idea_updates", bson.M{"user_id": user.UserID}, func() interface{} { return &IdeaProgressUpdateStructure{} }},
+		{"attachments", "attachment_refs", bson.M{"user_id": user.UserID}, func() interface{} { return &bson.M{} }},
+	}
+
+	// Collections other than the stores are all in mongo
+	if databaseClient == nil {
+		exportSections = nil
+	}
+
+	profileInJSON, errInEncodingProfile := json.Marshal(userInDB)
+	if errInEncodingProfile != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while exporting data", "errorDetails": errInEncodingProfile.Error()})
+		return
+	}
+
+	ginContext.Header("Content-Type", "application/json")
+	ginContext.Header("Content-Disposition", "attachment; filename="+user.Login+"-sardene-export.json")
+	ginContext.Status(http.StatusOK)
+
+	responseWriter := ginContext.Writer
+	_, _ = io.WriteString(responseWriter, `{"exported_at":`)
+	_, _ = io.WriteString(responseWriter, strconv.FormatInt(time.Now().Unix(), 10))
+	_, _ = io.WriteString(responseWriter, `,"profile":`)
+	_, _ = responseWriter.Write(profileInJSON)
+
+	for _, storedSection := range storedSections {
+		sectionInJSON, errInEncoding := json.Marshal(storedSection.documents)
+		if errInEncoding != nil {
+			// Status is already sent, an unterminated document tells the client the export failed
+			return
+		}
+		_, _ = io.WriteString(responseWriter, `,"`+storedSection.name+`":`)
+		_, _ = responseWriter.Write(sectionInJSON)
+	}
+
+	for _, exportSection := range exportSections {
+		_, _ = io.WriteString(responseWriter, `,"`+exportSection.name+`":`)
+
+		sectionCursor, errInFinding := databaseClient.Database("sardene-db").Collection(exportSection.collection).Find(databaseContext, exportSection.filter, options.Find())
+		if errInFinding != nil {
+			// Status is already sent, an unterminated document tells the client the export failed
+			return
+		}
+		errInStreaming := streamCursorAsJSONArray(responseWriter, databaseContext, sectionCursor, exportSection.newDocument)
+		if errInStreaming != nil {
+			return
+		}
+		responseWriter.Flush()
+	}
+
+	_, _ = io.WriteString(responseWriter, "}")
+}
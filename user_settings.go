@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 300
+	maxWebsiteLength     = 200
+	maxLocationLength    = 100
+)
+
+// UserSettingsStructure : Parts of the profile the user sets instead of copying them from GitHub
+type UserSettingsStructure struct {
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Website     string `json:"website"`
+	Location    string `json:"location"`
+}
+
+// UserSettingsInput : Fields left out keep their value, empty strings clear them
+type UserSettingsInput struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	Website     *string `json:"website"`
+	Location    *string `json:"location"`
+}
+
+// isValidWebsite : Only absolute http urls, so the profile never links to javascript: or similar
+func isValidWebsite(website string) bool {
+	parsedURL, errInParsing := url.Parse(website)
+	return errInParsing == nil && (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != ""
+}
+
+// applySettingsInput : Normalized settings with the given fields changed, or the problem with one of them
+func applySettingsInput(settings UserSettingsStructure, jsonInput UserSettingsInput) (UserSettingsStructure, string) {
+	if jsonInput.DisplayName != nil {
+		settings.DisplayName = normalizeIdeaName(*jsonInput.DisplayName)
+		if lengthInRunes(settings.DisplayName) > maxDisplayNameLength {
+			return settings, "Display name can be at most " + strconv.Itoa(maxDisplayNameLength) + " characters"
+		}
+	}
+	if jsonInput.Bio != nil {
+		settings.Bio = strings.TrimSpace(normalizeText(*jsonInput.Bio, true))
+		if lengthInRunes(settings.Bio) > maxBioLength {
+			return settings, "Bio can be at most " + strconv.Itoa(maxBioLength) + " characters"
+		}
+	}
+	if jsonInput.Website != nil {
+		settings.Website = strings.TrimSpace(*jsonInput.Website)
+		if lengthInRunes(settings.Website) > maxWebsiteLength {
+			return settings, "Website can be at most " + strconv.Itoa(maxWebsiteLength) + " characters"
+		}
+		if settings.Website != "" && isValidWebsite(settings.Website) == false {
+			return settings, "Website should be an http or https url"
+		}
+	}
+	if jsonInput.Location != nil {
+		settings.Location = normalizeIdeaName(*jsonInput.Location)
+		if lengthInRunes(settings.Location) > maxLocationLength {
+			return settings, "Location can be at most " + strconv.Itoa(maxLocationLength) + " characters"
+		}
+	}
+	return settings, ""
+}
+
+func updateUserSettings(ginContext *gin.Context, stores Stores) {
+	user := getAuthenticatedUser(ginContext)
+	databaseContext := ginContext.Request.Context()
+
+	var jsonInput UserSettingsInput
+	errInInputJSON := ginContext.ShouldBindJSON(&jsonInput)
+	if errInInputJSON != nil {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest,
+			"error": "Wrong structure of posted data", "errorDetails": errInInputJSON.Error()})
+		return
+	}
+
+	userInDB, errInFindingUser := stores.Users.FindByUserID(databaseContext, user.UserID)
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, User does not exists, sign in first"})
+			return
+		}
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUser.Error()})
+		return
+	}
+
+	currentSettings := UserSettingsStructure{DisplayName: userInDB.DisplayName, Bio: userInDB.Bio,
+		Website: userInDB.Website, Location: userInDB.Location}
+	settings, problemInSettings := applySettingsInput(currentSettings, jsonInput)
+	if problemInSettings != "" {
+		ginContext.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "error": problemInSettings})
+		return
+	}
+
+	errInUpdating := stores.Users.UpdateSettings(databaseContext, user.UserID, settings)
+	if errInUpdating != nil {
+		ginContext.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError,
+			"error": "Error while saving to database"})
+		return
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": settings})
+}
+
+// getPublicUserProfile : Profile of a user by their GitHub login, with what they set themselves
+func getPublicUserProfile(ginContext *gin.Context, stores Stores, login string) {
+	userInDB, errInFindingUser := stores.Users.FindByLogin(ginContext.Request.Context(), login)
+	if errInFindingUser != nil {
+		if errInFindingUser == errNotFoundInStore {
+			ginContext.JSON(http.StatusNotFound, gin.H{"status": http.StatusNotFound,
+				"error": "Error, User does not exists"})
+			return
+		}
+		ginContext.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable,
+			"error": "Error in searching database", "errorDetails": errInFindingUser.Error()})
+		return
+	}
+
+	publicProfile := PublicUserProfile{
+		UserID:      userInDB.UserID,
+		Login:       userInDB.Login,
+		Name:        userInDB.Name,
+		CreatedAt:   userInDB.CreatedAt,
+		DisplayName: userInDB.DisplayName,
+		Bio:         userInDB.Bio,
+		Website:     userInDB.Website,
+		Location:    userInDB.Location,
+	}
+
+	ginContext.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "data": publicProfile})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ViewCounter : Views of idea detail pages, counted in memory and added to the ideas every flush interval.
+// Views not yet written when the API stops are lost, nil when counting is switched off
+type ViewCounter struct {
+	stores        Stores
+	mutex         sync.Mutex
+	pendingViews  map[primitive.ObjectID]int64
+	flushInterval time.Duration
+}
+
+var ideaViews *ViewCounter
+
+func newViewCounter(stores Stores, flushInterval time.Duration) *ViewCounter {
+	viewCounter := &ViewCounter{
+		stores:        stores,
+		pendingViews:  make(map[primitive.ObjectID]int64),
+		flushInterval: flushInterval,
+	}
+	go viewCounter.run()
+
+	return viewCounter
+}
+
+// Record : Counts one view and returns the views of the idea not yet written, so responses can include them
+func (viewCounter *ViewCounter) Record(ideaID primitive.ObjectID) int64 {
+	viewCounter.mutex.Lock()
+	defer viewCounter.mutex.Unlock()
+
+	viewCounter.pendingViews[ideaID]++
+	return viewCounter.pendingViews[ideaID]
+}
+
+func (viewCounter *ViewCounter) run() {
+	flushTicker := time.NewTicker(viewCounter.flushInterval)
+	defer flushTicker.Stop()
+
+	for range flushTicker.C {
+		viewCounter.flush()
+	}
+}
+
+// flush : Views of a failed write are counted again, to be written with the next flush
+func (viewCounter *ViewCounter) flush() {
+	viewCounter.mutex.Lock()
+	viewsToWrite := viewCounter.pendingViews
+	viewCounter.pendingViews = make(map[primitive.ObjectID]int64)
+	viewCounter.mutex.Unlock()
+
+	if len(viewsToWrite) == 0 {
+		return
+	}
+
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelDBContext()
+
+	errInAdding := viewCounter.stores.Ideas.AddViews(databaseContext, viewsToWrite)
+	if errInAdding == nil {
+		return
+	}
+	log.Println(errInAdding, "Failed to write views of", len(viewsToWrite), "ideas")
+
+	viewCounter.mutex.Lock()
+	defer viewCounter.mutex.Unlock()
+	for ideaID, views := range viewsToWrite {
+		viewCounter.pendingViews[ideaID] += views
+	}
+}
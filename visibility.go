@@ -0,0 +1,49 @@
+package main
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	ideaVisibilityPublic = "public"
+	// Drafts and private ideas are only shown to their publisher
+	ideaVisibilityDraft   = "draft"
+	ideaVisibilityPrivate = "private"
+)
+
+var notPublicVisibilities = bson.A{ideaVisibilityDraft, ideaVisibilityPrivate}
+
+func isValidIdeaVisibility(visibility string) bool {
+	return visibility == ideaVisibilityPublic || visibility == ideaVisibilityDraft || visibility == ideaVisibilityPrivate
+}
+
+// isIdeaPublic : Ideas saved before visibility existed have none and are public
+func isIdeaPublic(idea IdeaStructure) bool {
+	return idea.HeldForReview == false && (idea.Visibility == "" || idea.Visibility == ideaVisibilityPublic)
+}
+
+// isIdeaVisibleTo : Publishers and collaborators see drafts and private ideas, userID 0 is an anonymous visitor
+func isIdeaVisibleTo(idea IdeaStructure, userID int64) bool {
+	if isIdeaPublic(idea) {
+		return true
+	}
+	return idea.HeldForReview == false && userID != 0 && (idea.PublisherID == userID || isCollaboratorOfIdea(idea, userID))
+}
+
+// publicIdeasFilter : Mongo filter of the ideas everyone can see
+func publicIdeasFilter() bson.M {
+	return bson.M{"held_for_review": bson.M{"$ne": true}, "visibility": bson.M{"$nin": notPublicVisibilities}}
+}
+
+// ideasVisibleToFilter : Mongo filter of public ideas along with the drafts and private ideas userID publishes or collaborates on
+func ideasVisibleToFilter(userID int64) bson.M {
+	// Ideas of deleted users are left with publisher id 0, anonymous visitors must not match them
+	if userID == 0 {
+		return publicIdeasFilter()
+	}
+	return bson.M{"held_for_review": bson.M{"$ne": true}, "$or": bson.A{
+		bson.M{"visibility": bson.M{"$nin": notPublicVisibilities}},
+		bson.M{"publisher_id": userID},
+		bson.M{"collaborators.user_id": userID},
+	}}
+}
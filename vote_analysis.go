@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	moderationReporterVoteAnalysis = "vote_analysis"
+	// Gazes older than this are not analysed even when the job has not run for longer
+	voteAnalysisMaxWindowInSeconds = 7 * 24 * 60 * 60
+)
+
+// VoteAnalysisConfig : Thresholds of the scheduled job looking for manipulated gazes
+type VoteAnalysisConfig struct {
+	Interval             time.Duration
+	NewAccountAgeSeconds int64
+	NewAccountBurstGazes int64
+	SameIPClusterUsers   int64
+	// Window of the first run, later runs start where the last one stopped
+	AnalysisWindowInSeconds int64
+}
+
+// VoteAnalysisCheckpointStructure : Time up to which gazes were analysed, in job_checkpoints
+type VoteAnalysisCheckpointStructure struct {
+	Job           string `bson:"_id"`
+	AnalysedUntil int64  `bson:"analysed_until"`
+}
+
+// GazeBurstStructure : Structure of gazes on an idea grouped during analysis
+type GazeBurstStructure struct {
+	IdeaID  primitive.ObjectID `bson:"_id"`
+	UserIDs []int64            `bson:"userIDs"`
+	Total   int64              `bson:"total"`
+}
+
+// GazeIPClusterStructure : Structure of users gazing an idea from the same address
+type GazeIPClusterStructure struct {
+	Group struct {
+		IdeaID primitive.ObjectID `bson:"ideaID"`
+		IPHash string             `bson:"ip_hash"`
+	} `bson:"_id"`
+	UserIDs []int64 `bson:"userIDs"`
+}
+
+func loadVoteAnalysisConfig(configLoader *ConfigLoader, quarantineConfig QuarantineConfig) VoteAnalysisConfig {
+	var voteAnalysisConfig VoteAnalysisConfig
+
+	voteAnalysisConfig.Interval = time.Duration(configLoader.Int("VOTE_ANALYSIS_INTERVAL_MINUTES", 60)) * time.Minute
+	voteAnalysisConfig.NewAccountAgeSeconds = quarantineConfig.AccountAgeSeconds
+	voteAnalysisConfig.NewAccountBurstGazes = configLoader.Int("VOTE_ANALYSIS_NEW_ACCOUNT_BURST", 5)
+	if voteAnalysisConfig.NewAccountBurstGazes <= 0 {
+		configLoader.Invalid("VOTE_ANALYSIS_NEW_ACCOUNT_BURST", "should be more than 0")
+	}
+	// A single user is no cluster, with less than 2 every gaze would be reported
+	voteAnalysisConfig.SameIPClusterUsers = configLoader.Int("VOTE_ANALYSIS_IP_CLUSTER", 3)
+	if voteAnalysisConfig.SameIPClusterUsers < 2 {
+		configLoader.Invalid("VOTE_ANALYSIS_IP_CLUSTER", "should be at least 2")
+	}
+	voteAnalysisConfig.AnalysisWindowInSeconds = int64(voteAnalysisConfig.Interval.Seconds())
+
+	return voteAnalysisConfig
+}
+
+// hashClientIP : Addresses are only kept as hashes, enough to find clusters without storing them.
+// The hash is kept on the gaze rather than read from the analytics events, as request_events hold
+// neither the user nor the address, can be switched off and drop their oldest events once full
+func hashClientIP(clientIP string) string {
+	hashedIP := sha256.Sum256([]byte(clientIP))
+	return hex.EncodeToString(hashedIP[:])
+}
+
+func runVoteAnalysisJob(databaseClient *mongo.Client, voteAnalysisConfig VoteAnalysisConfig) {
+	runScheduledJob(databaseClient, "vote_analysis", voteAnalysisConfig.Interval, func() error {
+		return analyzeRecentGazes(databaseClient, voteAnalysisConfig)
+	})
+}
+
+// voteAnalysisWindowStart : Runs which were skipped or failed leave their gazes to the next run
+func voteAnalysisWindowStart(databaseContext context.Context, checkpointsCollection *mongo.Collection,
+	voteAnalysisConfig VoteAnalysisConfig, currentTime int64) (int64, error) {
+	var checkpoint VoteAnalysisCheckpointStructure
+	errInFinding := checkpointsCollection.FindOne(databaseContext, bson.M{"_id": moderationReporterVoteAnalysis}).Decode(&checkpoint)
+	if errInFinding == mongo.ErrNoDocuments {
+		return currentTime - voteAnalysisConfig.AnalysisWindowInSeconds, nil
+	}
+	if errInFinding != nil {
+		return 0, errInFinding
+	}
+
+	if checkpoint.AnalysedUntil < currentTime-voteAnalysisMaxWindowInSeconds {
+		return currentTime - voteAnalysisMaxWindowInSeconds, nil
+	}
+	return checkpoint.AnalysedUntil, nil
+}
+
+func analyzeRecentGazes(databaseClient *mongo.Client, voteAnalysisConfig VoteAnalysisConfig) error {
+	likesCollection := databaseClient.Database("sardene-db").Collection("likes")
+	checkpointsCollection := databaseClient.Database("sardene-db").Collection("job_checkpoints")
+	databaseContext, cancelDBContext := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancelDBContext()
+
+	currentTime := time.Now().Unix()
+	windowStart, errInCheckpoint := voteAnalysisWindowStart(databaseContext, checkpointsCollection, voteAnalysisConfig, currentTime)
+	if errInCheckpoint != nil {
+		return errInCheckpoint
+	}
+	newAccountsSince := currentTime - voteAnalysisConfig.NewAccountAgeSeconds
+	gazesInWindow := bson.M{"$gte": windowStart, "$lt": currentTime}
+
+	// Bursts of gazes from accounts that were just created
+	newAccountBurstPipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": gazesInWindow}}},
+		{{Key: "$lookup", Value: bson.M{"from": "users", "localField": "userID", "foreignField": "userID", "as": "user"}}},
+		{{Key: "$unwind", Value: "$user"}},
+		{{Key: "$match", Value: bson.M{"user.created_at": bson.M{"$gte": newAccountsSince}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$ideaID", "userIDs": bson.M{"$addToSet": "$userID"}, "total": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"total": bson.M{"$gte": voteAnalysisConfig.NewAccountBurstGazes}}}},
+	}
+
+	burstsCursor, errInAggregating := likesCollection.Aggregate(databaseContext, newAccountBurstPipeline, options.Aggregate())
+	if errInAggregating != nil {
+		return errInAggregating
+	}
+	defer burstsCursor.Close(databaseContext)
+
+	for burstsCursor.Next(databaseContext) {
+		var gazeBurst GazeBurstStructure
+		errInDecoding := burstsCursor.Decode(&gazeBurst)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+
+		evidence := bson.M{
+			"window_start":     windowStart,
+			"user_ids":         gazeBurst.UserIDs,
+			"new_account_gaze": gazeBurst.Total,
+		}
+		errInReporting := fileVoteManipulationReport(databaseContext, databaseClient, gazeBurst.IdeaID, "gaze_burst_from_new_accounts", evidence)
+		if errInReporting != nil {
+			return errInReporting
+		}
+	}
+
+	// Several users gazing the same idea from one address
+	sameIPClusterPipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": gazesInWindow, "ip_hash": bson.M{"$exists": true}}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"ideaID": "$ideaID", "ip_hash": "$ip_hash"}, "userIDs": bson.M{"$addToSet": "$userID"}}}},
+		{{Key: "$match", Value: bson.M{"userIDs." + strconv.FormatInt(voteAnalysisConfig.SameIPClusterUsers-1, 10): bson.M{"$exists": true}}}},
+	}
+
+	clustersCursor, errInAggregatingClusters := likesCollection.Aggregate(databaseContext, sameIPClusterPipeline, options.Aggregate())
+	if errInAggregatingClusters != nil {
+		return errInAggregatingClusters
+	}
+	defer clustersCursor.Close(databaseContext)
+
+	for clustersCursor.Next(databaseContext) {
+		var ipCluster GazeIPClusterStructure
+		errInDecoding := clustersCursor.Decode(&ipCluster)
+		if errInDecoding != nil {
+			return errInDecoding
+		}
+
+		evidence := bson.M{
+			"window_start": windowStart,
+			"ip_hash":      ipCluster.Group.IPHash,
+			"user_ids":     ipCluster.UserIDs,
+		}
+		errInReporting := fileVoteManipulationReport(databaseContext, databaseClient, ipCluster.Group.IdeaID, "gazes_from_same_ip", evidence)
+		if errInReporting != nil {
+			return errInReporting
+		}
+	}
+
+	_, errInSaving := checkpointsCollection.UpdateOne(databaseContext, bson.M{"_id": moderationReporterVoteAnalysis},
+		bson.M{"$set": bson.M{"analysed_until": currentTime}}, options.Update().SetUpsert(true))
+	return errInSaving
+}
+
+// fileVoteManipulationReport : Skips ideas that already have an open report for the same reason
+func fileVoteManipulationReport(databaseContext context.Context, databaseClient *mongo.Client, ideaID primitive.ObjectID, reason string, evidence bson.M) error {
+	moderationCollection := databaseClient.Database("sardene-db").Collection("moderation_queue")
+
+	openReportFilter := bson.M{
+		"idea_id":  ideaID,
+		"reporter": moderationReporterVoteAnalysis,
+		"reasons":  reason,
+		"status":   moderationStatusOpen,
+	}
+	openReports, errInCounting := moderationCollection.CountDocuments(databaseContext, openReportFilter, options.Count())
+	if errInCounting != nil {
+		return errInCounting
+	}
+	if openReports != 0 {
+		return nil
+	}
+
+	log.Println("Vote analysis flagged idea", ideaID.Hex(), "for", reason)
+	return fileModerationReport(databaseContext, databaseClient, ideaID, []string{reason}, moderationReporterVoteAnalysis, evidence)
+}